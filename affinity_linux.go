@@ -0,0 +1,62 @@
+// goforever - processes management
+// Copyright (c) 2013 Garrett Woodworth (https://github.com/gwoo).
+
+package process
+
+import (
+	"io/ioutil"
+	"strconv"
+	"syscall"
+	"unsafe"
+)
+
+//Pin every thread of pid to cpus with sched_setaffinity. Threads started
+//later inherit the mask from the one creating them.
+func setAffinity(pid int, cpus []int) error {
+	max := 0
+	for _, cpu := range cpus {
+		if cpu > max {
+			max = cpu
+		}
+	}
+	mask := make([]uint64, max/64+1)
+	for _, cpu := range cpus {
+		mask[cpu/64] |= 1 << uint(cpu%64)
+	}
+	tids := []int{pid}
+	if tasks, err := ioutil.ReadDir("/proc/" + strconv.Itoa(pid) + "/task"); err == nil {
+		tids = tids[:0]
+		for _, task := range tasks {
+			if tid, err := strconv.Atoi(task.Name()); err == nil {
+				tids = append(tids, tid)
+			}
+		}
+	}
+	for _, tid := range tids {
+		_, _, errno := syscall.RawSyscall(syscall.SYS_SCHED_SETAFFINITY, uintptr(tid),
+			uintptr(len(mask)*8), uintptr(unsafe.Pointer(&mask[0])))
+		if errno != 0 && errno != syscall.ESRCH {
+			return errno
+		}
+	}
+	return nil
+}
+
+//CPUs pid's main thread may run on.
+func getAffinity(pid int) ([]int, error) {
+	mask := make([]uint64, 16)
+	_, _, errno := syscall.RawSyscall(syscall.SYS_SCHED_GETAFFINITY, uintptr(pid),
+		uintptr(len(mask)*8), uintptr(unsafe.Pointer(&mask[0])))
+	if errno != 0 {
+		return nil, errno
+	}
+	var cpus []int
+	for i, word := range mask {
+		for bit := 0; bit < 64; bit++ {
+			if word&(1<<uint(bit)) != 0 {
+				cpus = append(cpus, i*64+bit)
+			}
+		}
+	}
+	return cpus, nil
+}
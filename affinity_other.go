@@ -0,0 +1,20 @@
+// goforever - processes management
+// Copyright (c) 2013 Garrett Woodworth (https://github.com/gwoo).
+
+//go:build !linux
+
+package process
+
+import (
+	"errors"
+)
+
+var errAffinity = errors.New("CPU affinity is only supported on Linux.")
+
+func setAffinity(pid int, cpus []int) error {
+	return errAffinity
+}
+
+func getAffinity(pid int) ([]int, error) {
+	return nil, errAffinity
+}
@@ -0,0 +1,28 @@
+// goforever - processes management
+// Copyright (c) 2013 Garrett Woodworth (https://github.com/gwoo).
+
+package process
+
+import (
+	"runtime"
+	"testing"
+)
+
+func TestCPUAffinity(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("CPU affinity is only supported on Linux.")
+	}
+	p := &Process{Command: "/bin/sleep", Args: []string{"10"}, CPUAffinity: []int{0}}
+	if err := p.start("sleep"); err != nil {
+		t.Fatal(err)
+	}
+	defer p.Stop()
+	if cpus, err := getAffinity(p.Snapshot().Pid); err != nil || len(cpus) != 1 || cpus[0] != 0 {
+		t.Errorf("Expected [0]. Result %#v %#v\n", cpus, err)
+	}
+	p.Stop()
+	p.CPUAffinity = []int{-1}
+	if err := p.start("sleep"); err == nil {
+		t.Error("Expected a negative CPU to fail.")
+	}
+}
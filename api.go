@@ -0,0 +1,511 @@
+// goforever - processes management
+// Copyright (c) 2013 Garrett Woodworth (https://github.com/gwoo).
+
+package process
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//Roles of API tokens. Readers may use the GET routes, operators every route.
+const (
+	RoleRead     = "read"
+	RoleOperator = "operator"
+)
+
+//Bearer tokens accepted by the API, mapped to their role.
+type Auth struct {
+	Tokens map[string]string `json:"tokens"`
+}
+
+//Role of the request's bearer token, empty when it has none or a wrong one.
+func (a *Auth) role(r *http.Request) string {
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if token == "" {
+		return ""
+	}
+	role := ""
+	for t, rl := range a.Tokens {
+		if subtle.ConstantTimeCompare([]byte(t), []byte(token)) == 1 {
+			role = rl
+		}
+	}
+	return role
+}
+
+//Wrap h to require role: 401 without a valid token, 403 for a token with
+//too little access. A nil a allows everything.
+func (a *Auth) require(role string, h http.HandlerFunc) http.HandlerFunc {
+	if a == nil {
+		return h
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch got := a.role(r); {
+		case got == "":
+			w.Header().Set("WWW-Authenticate", `Bearer realm="process"`)
+			apiError(w, http.StatusUnauthorized, "Missing or invalid token.")
+		case got != RoleOperator && got != role:
+			apiError(w, http.StatusForbidden, "Token does not allow this.")
+		default:
+			h(w, r)
+		}
+	}
+}
+
+//HTTP control API. /healthz is always open and webhooks check their own
+//signature; with auth the other routes need a token of the listed role.
+//
+//	GET  /ui/                           web dashboard
+//	GET  /healthz                       supervisor health
+//	GET  /metrics                       Prometheus metrics         read
+//	GET  /processes                     snapshots                  read
+//	GET  /processes/{name}              snapshot                   read
+//	GET  /processes/{name}/logs         ring sink tail, see Tail   read
+//	GET  /processes/{name}/revisions    Revisions                  read
+//	GET  /processes/{name}/probes       ProbeStats                 read
+//	GET  /processes/{name}/stats        Stats, see Process         read
+//	GET  /processes/{name}/exec         ExecEnv, see Process       operator
+//	POST /processes/{name}/{action}     start, stop or restart     operator
+//	POST /processes/{name}/{action}     pause, resume, freeze, thaw operator
+//	POST /processes/{name}/apply        Apply the body definition  operator
+//	POST /processes/{name}/rollback     Rollback to ?revision=     operator
+//	POST /batch                         Ops in order, see Batch    operator
+//	POST /cluster/nodes/{node}/{action} drain or undrain           operator
+//	POST /hooks/{name}                  Webhook payload, signed
+//	GET  /operations/{id}               operation state            read
+//	GET  /events                        Events, see EventFilter    read
+//	GET  /graph                         Graph, ?format=dot for DOT read
+//	GET  /drift                         Drift of running binaries  read
+//	GET  /cluster                       NodeStatus of all nodes    read
+//	GET  /cluster/node                  NodeStatus of this node    read
+//	GET  /cluster/processes/{name}      Placements, see Locate     read
+//	GET  /debug/...                     pprof and expvar, see Debug operator
+//
+//Process and operation routes take ?namespace= for namespaced processes,
+//logs ?stream= (default stdout), ?lines= (default 100) and ?follow=1 to
+//keep streaming new lines as plain text. Start, stop and restart beyond a
+//process's ActionLimit get a 429 with Retry-After, as do batches with a
+//step beyond it; webhook rules beyond it are skipped. With an
+//Idempotency-Key header they are done once: a retry gets the earlier
+//operation back.
+func (m *Manager) API(auth *Auth) http.Handler {
+	health := m.HealthHandler()
+	ui := dashboardHandler()
+	read := auth.require(RoleRead, m.apiRead)
+	execEnv := auth.require(RoleOperator, m.apiExecEnv)
+	operate := auth.require(RoleOperator, m.apiOperate)
+	debug := auth.require(RoleOperator, debugHandler().ServeHTTP)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/healthz":
+			health.ServeHTTP(w, r)
+		case r.URL.Path == "/" || r.URL.Path == "/ui":
+			http.Redirect(w, r, "/ui/", http.StatusFound)
+		case strings.HasPrefix(r.URL.Path, "/ui/") && r.Method == http.MethodGet:
+			ui.ServeHTTP(w, r)
+		case strings.HasPrefix(r.URL.Path, "/hooks/") && r.Method == http.MethodPost:
+			m.apiHook(w, r, strings.TrimPrefix(r.URL.Path, "/hooks/"))
+		case strings.HasPrefix(r.URL.Path, "/debug/") && m.Debug:
+			debug(w, r)
+		case r.Method == http.MethodGet && strings.HasSuffix(r.URL.Path, "/exec"):
+			execEnv(w, r)
+		case r.Method == http.MethodGet:
+			read(w, r)
+		case r.Method == http.MethodPost:
+			operate(w, r)
+		default:
+			apiError(w, http.StatusMethodNotAllowed, "Method not allowed.")
+		}
+	})
+}
+
+func (m *Manager) apiRead(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if parts[0] == "metrics" && len(parts) == 1 {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		m.WriteMetrics(w)
+		return
+	}
+	switch {
+	case parts[0] == "drift" && len(parts) == 1:
+		apiJSON(w, http.StatusOK, m.Drift())
+		return
+	case parts[0] == "cluster" && len(parts) == 1:
+		apiJSON(w, http.StatusOK, m.Nodes())
+		return
+	case parts[0] == "cluster" && len(parts) == 2 && parts[1] == "node":
+		apiJSON(w, http.StatusOK, m.localNode())
+		return
+	case parts[0] == "cluster" && len(parts) == 3 && parts[1] == "processes":
+		apiJSON(w, http.StatusOK, m.Locate(parts[2]))
+		return
+	case parts[0] == "graph" && len(parts) == 1:
+		if r.URL.Query().Get("format") == "dot" {
+			w.Header().Set("Content-Type", "text/vnd.graphviz")
+			m.Graph().WriteDOT(w)
+			return
+		}
+		apiJSON(w, http.StatusOK, m.Graph())
+		return
+	case parts[0] == "events" && len(parts) == 1:
+		m.apiEvents(w, r)
+		return
+	}
+	n, ok := m.apiSpace(w, r)
+	if !ok {
+		return
+	}
+	switch {
+	case parts[0] == "processes" && len(parts) == 1:
+		apiJSON(w, http.StatusOK, n.Snapshot())
+	case parts[0] == "processes" && len(parts) == 2:
+		p, err := n.Lookup(parts[1])
+		if err != nil {
+			apiError(w, http.StatusNotFound, err.Error())
+			return
+		}
+		apiJSON(w, http.StatusOK, p.Snapshot())
+	case parts[0] == "processes" && len(parts) == 3 && parts[2] == "logs":
+		p, err := n.Lookup(parts[1])
+		if err != nil {
+			apiError(w, http.StatusNotFound, err.Error())
+			return
+		}
+		stream := r.URL.Query().Get("stream")
+		if stream == "" {
+			stream = "stdout"
+		}
+		lines, err := strconv.Atoi(r.URL.Query().Get("lines"))
+		if err != nil {
+			lines = 100
+		}
+		if r.URL.Query().Get("follow") != "" {
+			follow(w, r, p, stream, lines)
+			return
+		}
+		apiJSON(w, http.StatusOK, p.Tail(stream, lines))
+	case parts[0] == "processes" && len(parts) == 3 && parts[2] == "revisions":
+		revs, err := n.Revisions(parts[1])
+		if err != nil {
+			apiError(w, http.StatusNotFound, err.Error())
+			return
+		}
+		apiJSON(w, http.StatusOK, revs)
+	case parts[0] == "processes" && len(parts) == 3 && parts[2] == "probes":
+		p, err := n.Lookup(parts[1])
+		if err != nil {
+			apiError(w, http.StatusNotFound, err.Error())
+			return
+		}
+		apiJSON(w, http.StatusOK, p.ProbeStats())
+	case parts[0] == "processes" && len(parts) == 3 && parts[2] == "stats":
+		p, err := n.Lookup(parts[1])
+		if err != nil {
+			apiError(w, http.StatusNotFound, err.Error())
+			return
+		}
+		stats, err := p.Stats()
+		if err != nil {
+			apiError(w, http.StatusConflict, err.Error())
+			return
+		}
+		apiJSON(w, http.StatusOK, stats)
+	case parts[0] == "operations" && len(parts) == 2:
+		op := n.Operation(parts[1])
+		if op == nil {
+			apiError(w, http.StatusNotFound, "Unknown operation.")
+			return
+		}
+		apiJSON(w, http.StatusOK, op)
+	default:
+		apiError(w, http.StatusNotFound, "Not found.")
+	}
+}
+
+//The exec environment holds the processes' secrets, so it is only given
+//to operators.
+func (m *Manager) apiExecEnv(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if parts[0] != "processes" || len(parts) != 3 {
+		apiError(w, http.StatusNotFound, "Not found.")
+		return
+	}
+	n, ok := m.apiSpace(w, r)
+	if !ok {
+		return
+	}
+	p, err := n.Lookup(parts[1])
+	if err != nil {
+		apiError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	e, err := p.ExecEnv()
+	if err != nil {
+		apiError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	apiJSON(w, http.StatusOK, e)
+}
+
+func (m *Manager) apiOperate(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if parts[0] == "cluster" && len(parts) == 4 && parts[1] == "nodes" {
+		m.apiDrain(w, r, parts[2], parts[3])
+		return
+	}
+	if parts[0] == "batch" && len(parts) == 1 {
+		m.apiBatch(w, r)
+		return
+	}
+	if parts[0] != "processes" || len(parts) != 3 {
+		apiError(w, http.StatusNotFound, "Not found.")
+		return
+	}
+	n, ok := m.apiSpace(w, r)
+	if !ok {
+		return
+	}
+	name := parts[1]
+	if !n.Exists(name) {
+		apiError(w, http.StatusNotFound, ErrNotFound.Error())
+		return
+	}
+	if parts[2] == "apply" || parts[2] == "rollback" {
+		n.apiRevise(w, r, name, parts[2])
+		return
+	}
+	if action := apiActions(n)[parts[2]]; action != nil {
+		if err := action(name); err != nil {
+			apiError(w, http.StatusConflict, err.Error())
+			return
+		}
+		apiJSON(w, http.StatusOK, n.Get(name).Snapshot())
+		return
+	}
+	key := r.Header.Get("Idempotency-Key")
+	prior, err := n.keyedOperation(key, parts[2], name)
+	if err != nil {
+		apiError(w, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+	if prior != nil {
+		apiJSON(w, http.StatusAccepted, prior)
+		return
+	}
+	if parts[2] != "start" && parts[2] != "stop" && parts[2] != "restart" {
+		apiError(w, http.StatusNotFound, "Unknown action.")
+		return
+	}
+	if wait, err := n.Get(name).allowAction(parts[2]); err != nil {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+		apiError(w, http.StatusTooManyRequests, err.Error())
+		return
+	}
+	op, err := n.operate(parts[2], name, key)
+	if err == ErrKeyReused {
+		apiError(w, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+	if err != nil {
+		apiError(w, http.StatusConflict, err.Error())
+		return
+	}
+	apiJSON(w, http.StatusAccepted, op)
+}
+
+//Apply the definition in the body, or roll back to the revision parameter,
+//and answer the new revision number once the process restarted. Counted
+//against the ActionLimit like the other restarts.
+func (m *Manager) apiRevise(w http.ResponseWriter, r *http.Request, name, action string) {
+	def := &Process{}
+	var number int
+	if action == "apply" {
+		if err := json.NewDecoder(r.Body).Decode(def); err != nil {
+			apiError(w, http.StatusBadRequest, "Bad definition: "+err.Error())
+			return
+		}
+	} else {
+		var err error
+		if number, err = strconv.Atoi(r.URL.Query().Get("revision")); err != nil {
+			apiError(w, http.StatusBadRequest, "Bad revision: "+err.Error())
+			return
+		}
+	}
+	if wait, err := m.Get(name).allowAction(action); err != nil {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+		apiError(w, http.StatusTooManyRequests, err.Error())
+		return
+	}
+	var rev int
+	var err error
+	if action == "apply" {
+		rev, err = m.Apply(name, def)
+	} else {
+		rev, err = m.Rollback(name, number)
+	}
+	if err != nil && rev == 0 {
+		apiError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	result := struct {
+		Revision int    `json:"revision"`
+		Error    string `json:"error,omitempty"`
+	}{Revision: rev}
+	code := http.StatusOK
+	if err != nil {
+		result.Error, code = err.Error(), http.StatusConflict
+	}
+	apiJSON(w, code, result)
+}
+
+//Actions answered with the process snapshot once done, unlike operations.
+func apiActions(m *Manager) map[string]func(string) error {
+	return map[string]func(string) error{
+		"pause":  m.Pause,
+		"resume": m.Unpause,
+		"freeze": m.Freeze,
+		"thaw":   m.Thaw,
+	}
+}
+
+//Run the batch of Ops in the body, a JSON list, and answer once it is done:
+//400 if it fails the checks, 409 with the operations run if a step failed.
+func (m *Manager) apiBatch(w http.ResponseWriter, r *http.Request) {
+	n, ok := m.apiSpace(w, r)
+	if !ok {
+		return
+	}
+	var ops []Op
+	if err := json.NewDecoder(r.Body).Decode(&ops); err != nil {
+		apiError(w, http.StatusBadRequest, "Bad batch: "+err.Error())
+		return
+	}
+	if err := n.checkOps(ops); err != nil {
+		apiError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if wait, err := n.allowOps(ops); err != nil {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+		apiError(w, http.StatusTooManyRequests, err.Error())
+		return
+	}
+	done, err := n.Batch(ops)
+	result := struct {
+		Operations []*Operation `json:"operations"`
+		Error      string       `json:"error,omitempty"`
+	}{Operations: done}
+	code := http.StatusOK
+	if err != nil {
+		result.Error, code = err.Error(), http.StatusConflict
+	}
+	apiJSON(w, code, result)
+}
+
+//Answer the events selected by the process, type, namespace, since and
+//until (RFC 3339) and limit parameters.
+func (m *Manager) apiEvents(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	f := EventFilter{Process: q.Get("process"), Type: q.Get("type"), Namespace: q.Get("namespace")}
+	for param, t := range map[string]*time.Time{"since": &f.Since, "until": &f.Until} {
+		if v := q.Get(param); v != "" {
+			var err error
+			if *t, err = time.Parse(time.RFC3339, v); err != nil {
+				apiError(w, http.StatusBadRequest, "Bad "+param+": "+err.Error())
+				return
+			}
+		}
+	}
+	if v := q.Get("limit"); v != "" {
+		var err error
+		if f.Limit, err = strconv.Atoi(v); err != nil {
+			apiError(w, http.StatusBadRequest, "Bad limit: "+err.Error())
+			return
+		}
+	}
+	events, err := m.Events(f)
+	if err != nil {
+		apiError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	apiJSON(w, http.StatusOK, events)
+}
+
+//Stream the tail and then new lines of stream as plain text until the
+//client goes away.
+func follow(w http.ResponseWriter, r *http.Request, p *Process, stream string, lines int) {
+	ch, stop := p.Follow(stream)
+	defer stop()
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	for _, line := range p.Tail(stream, lines) {
+		fmt.Fprintln(w, line)
+	}
+	flusher, _ := w.(http.Flusher)
+	for {
+		if flusher != nil {
+			flusher.Flush()
+		}
+		select {
+		case line, ok := <-ch:
+			if !ok {
+				return
+			}
+			fmt.Fprintln(w, line)
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+func (m *Manager) apiDrain(w http.ResponseWriter, r *http.Request, node, action string) {
+	var err error
+	switch action {
+	case "drain":
+		err = m.Drain(r.Context(), node)
+	case "undrain":
+		err = m.Undrain(r.Context(), node)
+	default:
+		apiError(w, http.StatusNotFound, "Unknown action.")
+		return
+	}
+	switch err {
+	case nil:
+		apiJSON(w, http.StatusOK, map[string]string{"node": node, "action": action})
+	case ErrNoCluster, ErrUnknownNode:
+		apiError(w, http.StatusNotFound, err.Error())
+	default:
+		apiError(w, http.StatusBadGateway, err.Error())
+	}
+}
+
+//Manager of the request's ?namespace=, m without one.
+func (m *Manager) apiSpace(w http.ResponseWriter, r *http.Request) (*Manager, bool) {
+	ns := r.URL.Query().Get("namespace")
+	if ns == "" {
+		return m, true
+	}
+	m.mu.Lock()
+	n := m.spaces[ns]
+	m.mu.Unlock()
+	if n == nil {
+		apiError(w, http.StatusNotFound, "Unknown namespace.")
+		return nil, false
+	}
+	return n, true
+}
+
+func apiJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
+
+func apiError(w http.ResponseWriter, code int, msg string) {
+	apiJSON(w, code, map[string]string{"error": msg})
+}
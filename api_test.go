@@ -0,0 +1,71 @@
+// goforever - processes management
+// Copyright (c) 2013 Garrett Woodworth (https://github.com/gwoo).
+
+package process
+
+import (
+	"bufio"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAPIAuth(t *testing.T) {
+	m := NewManager()
+	m.Runner = NewFakeRunner()
+	m.Add("web", &Process{Command: "/usr/bin/web", Ping: "1h"})
+	h := m.API(&Auth{Tokens: map[string]string{"r": RoleRead, "o": RoleOperator}})
+	for _, c := range []struct {
+		method, path, token string
+		code                int
+	}{
+		{"GET", "/healthz", "", http.StatusOK},
+		{"GET", "/processes", "", http.StatusUnauthorized},
+		{"GET", "/processes", "wrong", http.StatusUnauthorized},
+		{"GET", "/processes", "r", http.StatusOK},
+		{"GET", "/processes/web", "o", http.StatusOK},
+		{"GET", "/processes/nope", "r", http.StatusNotFound},
+		{"GET", "/processes?namespace=nope", "r", http.StatusNotFound},
+		{"GET", "/processes/web/exec", "r", http.StatusForbidden},
+		{"GET", "/processes/web/exec", "o", http.StatusOK},
+		{"POST", "/processes/web/start", "r", http.StatusForbidden},
+		{"POST", "/processes/web/start", "o", http.StatusAccepted},
+		{"POST", "/processes/web/jump", "o", http.StatusNotFound},
+		{"GET", "/metrics", "r", http.StatusOK},
+	} {
+		req := httptest.NewRequest(c.method, c.path, nil)
+		if c.token != "" {
+			req.Header.Set("Authorization", "Bearer "+c.token)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if rec.Code != c.code {
+			t.Errorf("%s %s with %q: expected %d. Result %d %s\n", c.method, c.path, c.token, c.code, rec.Code, rec.Body)
+		}
+	}
+}
+
+func TestAPIFollow(t *testing.T) {
+	m := NewManager()
+	p := &Process{Command: "/usr/bin/web"}
+	m.Add("web", p)
+	r := p.ring("stdout", 10)
+	r.Write([]byte("old\n"))
+	srv := httptest.NewServer(m.API(nil))
+	defer srv.Close()
+	resp, err := http.Get(srv.URL + "/processes/web/logs?follow=1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	s := bufio.NewScanner(resp.Body)
+	s.Scan()
+	if s.Text() != "old" {
+		t.Errorf("Expected %#v. Result %#v\n", "old", s.Text())
+	}
+	r.Write([]byte("new\n"))
+	s.Scan()
+	if s.Text() != "new" {
+		t.Errorf("Expected %#v. Result %#v\n", "new", s.Text())
+	}
+}
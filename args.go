@@ -0,0 +1,163 @@
+// goforever - processes management
+// Copyright (c) 2013 Garrett Woodworth (https://github.com/gwoo).
+
+package process
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+//Values referenced by configured flags.
+type Vars map[string]interface{}
+
+//Builder for command line arguments.
+type ArgsBuilder struct {
+	args []string
+}
+
+//Start a command line with the given arguments.
+func NewArgs(args ...string) *ArgsBuilder {
+	return &ArgsBuilder{args: append([]string(nil), args...)}
+}
+
+//Append plain arguments.
+func (b *ArgsBuilder) Add(args ...string) *ArgsBuilder {
+	b.args = append(b.args, args...)
+	return b
+}
+
+//Append name=value, or just name when value is empty.
+func (b *ArgsBuilder) Flag(name, value string) *ArgsBuilder {
+	if value == "" {
+		return b.Add(name)
+	}
+	return b.Add(name + "=" + value)
+}
+
+//Append the flag only when cond is true.
+func (b *ArgsBuilder) FlagIf(cond bool, name, value string) *ArgsBuilder {
+	if cond {
+		b.Flag(name, value)
+	}
+	return b
+}
+
+//Append the flag once per value.
+func (b *ArgsBuilder) Each(name string, values []string) *ArgsBuilder {
+	for _, v := range values {
+		b.Flag(name, v)
+	}
+	return b
+}
+
+//The built arguments.
+func (b *ArgsBuilder) Build() []string {
+	return append([]string(nil), b.args...)
+}
+
+//Configured flag, expanded from the process Vars when the command line is
+//built:
+//
+//	{"name": "--port", "each": "ports"}          one flag per element of ports
+//	{"name": "--verbose", "if": "debug"}         only when debug is truthy
+//	{"name": "--config", "value": "${dir}/app.conf"}
+//
+//"if" may be negated with a leading "!". Separate puts the value in its own
+//argument instead of joining it with "=".
+type Flag struct {
+	Name     string `json:"name"`
+	Value    string `json:"value,omitempty"`
+	Each     string `json:"each,omitempty"`
+	If       string `json:"if,omitempty"`
+	Separate bool   `json:"separate,omitempty"`
+}
+
+var varRef = regexp.MustCompile(`\$\{([A-Za-z0-9_.-]+)\}`)
+
+//Expand flags against vars and append them to b.
+func (b *ArgsBuilder) Flags(flags []Flag, vars Vars) error {
+	for _, f := range flags {
+		if f.If != "" {
+			name, negate := strings.TrimPrefix(f.If, "!"), strings.HasPrefix(f.If, "!")
+			if truthy(vars[name]) == negate {
+				continue
+			}
+		}
+		var values []string
+		switch {
+		case f.Each != "":
+			v, ok := vars[f.Each]
+			if !ok {
+				return fmt.Errorf("Flag %s: unknown var %q.", f.Name, f.Each)
+			}
+			values = listValues(v)
+		default:
+			v, err := expandVars(f.Value, vars)
+			if err != nil {
+				return fmt.Errorf("Flag %s: %s", f.Name, err)
+			}
+			values = []string{v}
+		}
+		for _, v := range values {
+			if f.Separate && v != "" {
+				b.Add(f.Name, v)
+				continue
+			}
+			b.Flag(f.Name, v)
+		}
+	}
+	return nil
+}
+
+//Replace ${name} references with vars values.
+func expandVars(s string, vars Vars) (string, error) {
+	var err error
+	r := varRef.ReplaceAllStringFunc(s, func(ref string) string {
+		name := varRef.FindStringSubmatch(ref)[1]
+		v, ok := vars[name]
+		if !ok {
+			err = fmt.Errorf("Unknown var %q.", name)
+			return ""
+		}
+		return fmt.Sprint(v)
+	})
+	return r, err
+}
+
+func listValues(v interface{}) []string {
+	switch l := v.(type) {
+	case []string:
+		return l
+	case []interface{}:
+		values := make([]string, len(l))
+		for i, e := range l {
+			values[i] = fmt.Sprint(e)
+		}
+		return values
+	case nil:
+		return nil
+	}
+	return []string{fmt.Sprint(v)}
+}
+
+func truthy(v interface{}) bool {
+	switch t := v.(type) {
+	case nil:
+		return false
+	case bool:
+		return t
+	case string:
+		return t != "" && t != "0" && t != "false"
+	case float64:
+		return t != 0
+	case int:
+		return t != 0
+	case []interface{}:
+		return len(t) > 0
+	case []string:
+		return len(t) > 0
+	}
+	return true
+}
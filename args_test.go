@@ -0,0 +1,51 @@
+// goforever - processes management
+// Copyright (c) 2013 Garrett Woodworth (https://github.com/gwoo).
+
+package process
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestArgsBuilder(t *testing.T) {
+	r := NewArgs("web").
+		Flag("--config", "app.conf").
+		FlagIf(false, "--debug", "").
+		FlagIf(true, "--verbose", "").
+		Each("--port", []string{"80", "443"}).
+		Build()
+	ex := []string{"web", "--config=app.conf", "--verbose", "--port=80", "--port=443"}
+	if !reflect.DeepEqual(ex, r) {
+		t.Errorf("Expected %#v. Result %#v\n", ex, r)
+	}
+}
+
+func TestArgsFlags(t *testing.T) {
+	var p Process
+	data := `{
+		"vars": {"ports": [8080, 8081], "debug": false, "dir": "/etc/web"},
+		"flags": [
+			{"name": "--port", "each": "ports"},
+			{"name": "--debug", "if": "debug"},
+			{"name": "--quiet", "if": "!debug"},
+			{"name": "-c", "value": "${dir}/web.conf", "separate": true}
+		]
+	}`
+	if err := json.Unmarshal([]byte(data), &p); err != nil {
+		t.Fatal(err)
+	}
+	b := NewArgs()
+	if err := b.Flags(p.Flags, p.Vars); err != nil {
+		t.Fatalf("Error: %s.", err)
+	}
+	ex := []string{"--port=8080", "--port=8081", "--quiet", "-c", "/etc/web/web.conf"}
+	if r := b.Build(); !reflect.DeepEqual(ex, r) {
+		t.Errorf("Expected %#v. Result %#v\n", ex, r)
+	}
+	err := NewArgs().Flags([]Flag{{Name: "--x", Value: "${missing}"}}, p.Vars)
+	if err == nil {
+		t.Error("Expected error for unknown var.")
+	}
+}
@@ -0,0 +1,266 @@
+// goforever - processes management
+// Copyright (c) 2013 Garrett Woodworth (https://github.com/gwoo).
+
+package process
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+//Release fetched before start: a binary, or a .tar.gz/.tgz unpacked, into
+//Dir/<version>, with the symlink Dir/current pointing at it. Command can
+//then name e.g. Dir/current/bin/web. A version already in Dir is not
+//fetched again.
+type Artifact struct {
+	//http(s) URL, or s3://bucket/key for a public object. Private objects
+	//take a presigned https URL.
+	URL string `json:"url"`
+	//Hex checksum the download must match.
+	SHA256 string `json:"sha256,omitempty"`
+	//Directory name of the release, defaults to the first 12 characters
+	//of SHA256. One of them is required.
+	Version string `json:"version,omitempty"`
+	Dir     string `json:"dir"`
+	//Limit on the download, default 5m.
+	Timeout string `json:"timeout,omitempty"`
+}
+
+var ErrChecksum = errors.New("Checksum mismatch.")
+
+func (a *Artifact) version() string {
+	if a.Version != "" {
+		return a.Version
+	}
+	if len(a.SHA256) >= 12 {
+		return strings.ToLower(a.SHA256[:12])
+	}
+	return ""
+}
+
+//URL fetched, s3:// mapped to the bucket's https endpoint.
+func (a *Artifact) url() string {
+	if rest := strings.TrimPrefix(a.URL, "s3://"); rest != a.URL {
+		bucket, key := rest, ""
+		if i := strings.Index(rest, "/"); i >= 0 {
+			bucket, key = rest[:i], rest[i+1:]
+		}
+		return "https://" + bucket + ".s3.amazonaws.com/" + key
+	}
+	return a.URL
+}
+
+func (a *Artifact) validate() error {
+	if a.URL == "" || a.Dir == "" {
+		return errors.New("Artifact needs a url and a dir.")
+	}
+	v := a.version()
+	if v == "" {
+		return errors.New("Artifact needs a version or a sha256.")
+	}
+	if v != filepath.Base(v) || v == "." || v == ".." || v == "current" {
+		return fmt.Errorf("Bad artifact version %q.", v)
+	}
+	return nil
+}
+
+//Fetch the Artifact unless its version is already in Dir and point the
+//current symlink at it.
+func (p *Process) fetchArtifact() error {
+	a := p.Artifact
+	if a == nil {
+		return nil
+	}
+	if err := a.validate(); err != nil {
+		return err
+	}
+	dest := filepath.Join(a.Dir, a.version())
+	if _, err := os.Stat(dest); os.IsNotExist(err) {
+		start := time.Now()
+		if err := a.fetch(dest); err != nil {
+			return err
+		}
+		p.log(LevelInfo, "artifact fetched", Fields{"version": a.version(), "duration": time.Since(start)})
+	} else if err != nil {
+		return err
+	}
+	return a.link()
+}
+
+//Download, verify and unpack the release into dest, which only appears
+//once complete.
+func (a *Artifact) fetch(dest string) error {
+	if err := os.MkdirAll(a.Dir, 0755); err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(a.Dir, ".fetch-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+	client := &http.Client{Timeout: durationOr(a.Timeout, 5*time.Minute)}
+	resp, err := client.Get(a.url())
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s: %s", a.URL, resp.Status)
+	}
+	sum := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(tmp, sum), resp.Body); err != nil {
+		return err
+	}
+	if a.SHA256 != "" && !strings.EqualFold(hex.EncodeToString(sum.Sum(nil)), a.SHA256) {
+		return ErrChecksum
+	}
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	unpacked := filepath.Join(a.Dir, "."+filepath.Base(dest)+".tmp")
+	os.RemoveAll(unpacked)
+	if err := os.Mkdir(unpacked, 0755); err != nil {
+		return err
+	}
+	name := path.Base(strings.SplitN(a.URL, "?", 2)[0])
+	if strings.HasSuffix(name, ".tar.gz") || strings.HasSuffix(name, ".tgz") {
+		err = untar(tmp, unpacked)
+	} else {
+		err = copyFile(tmp, filepath.Join(unpacked, name), 0755)
+	}
+	if err != nil {
+		os.RemoveAll(unpacked)
+		return err
+	}
+	return os.Rename(unpacked, dest)
+}
+
+//Point Dir/current at the version, replacing the link atomically.
+func (a *Artifact) link() error {
+	current := filepath.Join(a.Dir, "current")
+	if target, err := os.Readlink(current); err == nil && target == a.version() {
+		return nil
+	}
+	tmp := current + ".tmp"
+	os.Remove(tmp)
+	if err := os.Symlink(a.version(), tmp); err != nil {
+		return err
+	}
+	return os.Rename(tmp, current)
+}
+
+//Unpack a gzipped tar into the empty dir, refusing entries and links that
+//lead outside it, either by .. or through links unpacked before.
+func untar(r io.Reader, dir string) error {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return err
+	}
+	defer gz.Close()
+	root, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return err
+	}
+	links := map[string]bool{}
+	tr := tar.NewReader(gz)
+	for {
+		h, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		name, ok := archivePath(links, h.Name)
+		if !ok {
+			return fmt.Errorf("Archive entry %q is outside of it.", h.Name)
+		}
+		if name == "" {
+			//The archive root, e.g. "./".
+			continue
+		}
+		target := filepath.Join(root, filepath.FromSlash(name))
+		switch h.Typeflag {
+		case tar.TypeDir:
+			err = os.MkdirAll(target, 0755)
+		case tar.TypeReg:
+			if err = os.MkdirAll(filepath.Dir(target), 0755); err == nil {
+				if err = within(root, filepath.Dir(target)); err == nil {
+					err = copyFile(tr, target, os.FileMode(h.Mode).Perm())
+				}
+			}
+		case tar.TypeSymlink:
+			if _, ok := archivePath(links, path.Dir(name)+"/"+h.Linkname); !ok || path.IsAbs(h.Linkname) {
+				return fmt.Errorf("Archive link %q points outside of it.", h.Name)
+			}
+			if err = os.Symlink(h.Linkname, target); err == nil {
+				links[name] = true
+			}
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+//Clean slash-separated path of an archive entry, false if it leaves the
+//archive by .. or goes through one of its links.
+func archivePath(links map[string]bool, name string) (string, bool) {
+	var parts []string
+	comps := strings.Split(name, "/")
+	for i, c := range comps {
+		switch c {
+		case "", ".":
+			continue
+		case "..":
+			if len(parts) == 0 {
+				return "", false
+			}
+			parts = parts[:len(parts)-1]
+		default:
+			parts = append(parts, c)
+		}
+		if links[strings.Join(parts, "/")] && i < len(comps)-1 {
+			return "", false
+		}
+	}
+	return strings.Join(parts, "/"), true
+}
+
+//Error unless dir, symlinks resolved, is root or below it.
+func within(root, dir string) error {
+	resolved, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return err
+	}
+	rel, err := filepath.Rel(root, resolved)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(os.PathSeparator)) {
+		return fmt.Errorf("%s is outside of %s.", dir, root)
+	}
+	return nil
+}
+
+func copyFile(r io.Reader, name string, mode os.FileMode) error {
+	f, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_EXCL, mode)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,135 @@
+// goforever - processes management
+// Copyright (c) 2013 Garrett Woodworth (https://github.com/gwoo).
+
+package process
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func tarball(t *testing.T, files map[string]string) []byte {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for name, data := range files {
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0755, Size: int64(len(data)), Typeflag: tar.TypeReg}); err != nil {
+			t.Fatal(err)
+		}
+		tw.Write([]byte(data))
+	}
+	tw.Close()
+	gz.Close()
+	return buf.Bytes()
+}
+
+func sha(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func TestArtifact(t *testing.T) {
+	release := tarball(t, map[string]string{"bin/web": "#!/bin/sh\n"})
+	var fetches int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&fetches, 1)
+		w.Write(release)
+	}))
+	defer srv.Close()
+	dir := t.TempDir()
+	r := NewFakeRunner()
+	p := &Process{Name: "web", Command: filepath.Join(dir, "current", "bin", "web"), Runner: r, Ping: "1h",
+		Artifact: &Artifact{URL: srv.URL + "/web.tar.gz", SHA256: sha(release), Dir: dir}}
+	if _, err := RunProcess("web", p); err != nil {
+		t.Fatalf("Expected no error. Result %#v\n", err)
+	}
+	p.Stop()
+	if data, err := ioutil.ReadFile(p.Command); err != nil || string(data) != "#!/bin/sh\n" {
+		t.Errorf("Expected the binary in current. Result %#v %v\n", string(data), err)
+	}
+	if target, _ := os.Readlink(filepath.Join(dir, "current")); target != sha(release)[:12] {
+		t.Errorf("Expected %#v. Result %#v\n", sha(release)[:12], target)
+	}
+	RunProcess("web", p)
+	p.Stop()
+	if n := atomic.LoadInt32(&fetches); n != 1 {
+		t.Errorf("Expected 1 fetch. Result %d\n", n)
+	}
+	p.Artifact = &Artifact{URL: srv.URL + "/web-2", SHA256: strings.Repeat("0", 64), Dir: dir, Version: "2"}
+	if err := p.fetchArtifact(); err != ErrChecksum {
+		t.Errorf("Expected %#v. Result %#v\n", ErrChecksum, err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "2")); !os.IsNotExist(err) {
+		t.Errorf("Expected no version 2. Result %v\n", err)
+	}
+	p.Artifact.SHA256 = ""
+	if err := p.fetchArtifact(); err != nil {
+		t.Fatalf("Expected no error. Result %#v\n", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "current", "web-2")); err != nil {
+		t.Errorf("Expected the plain binary in current. Result %v\n", err)
+	}
+}
+
+func TestArtifactChecks(t *testing.T) {
+	for _, a := range []*Artifact{{URL: "https://x/y", Dir: "/tmp"}, {URL: "https://x/y", Dir: "/tmp", Version: "../etc"}, {Dir: "/tmp", Version: "1"}} {
+		if err := a.validate(); err == nil {
+			t.Errorf("Expected an error for %#v\n", a)
+		}
+	}
+	if u := (&Artifact{URL: "s3://releases/web/1.tgz"}).url(); u != "https://releases.s3.amazonaws.com/web/1.tgz" {
+		t.Errorf("Expected the bucket endpoint. Result %#v\n", u)
+	}
+	for _, entries := range [][]tar.Header{
+		{{Name: "../evil", Typeflag: tar.TypeReg}},
+		{{Name: "l2", Linkname: ".", Typeflag: tar.TypeSymlink}, {Name: "l1", Linkname: "l2/..", Typeflag: tar.TypeSymlink}, {Name: "l1/evil", Typeflag: tar.TypeReg}},
+		{{Name: "l", Linkname: ".", Typeflag: tar.TypeSymlink}, {Name: "l/evil", Typeflag: tar.TypeReg}},
+		{{Name: "up", Linkname: "../..", Typeflag: tar.TypeSymlink}},
+	} {
+		parent := t.TempDir()
+		dir := filepath.Join(parent, "release")
+		os.Mkdir(dir, 0755)
+		if err := untar(bytes.NewReader(archive(t, entries)), dir); err == nil {
+			t.Errorf("Expected %#v refused.\n", entries)
+		}
+		if _, err := os.Stat(filepath.Join(parent, "evil")); err == nil {
+			t.Errorf("Expected nothing written outside by %#v.\n", entries)
+		}
+	}
+	dir := t.TempDir()
+	ok := []tar.Header{{Name: "./", Typeflag: tar.TypeDir}, {Name: "..foo", Typeflag: tar.TypeReg}, {Name: "bin/", Typeflag: tar.TypeDir},
+		{Name: "bin/web", Typeflag: tar.TypeReg}, {Name: "web", Linkname: "bin/../bin/web", Typeflag: tar.TypeSymlink}}
+	if err := untar(bytes.NewReader(archive(t, ok)), dir); err != nil {
+		t.Errorf("Expected no error. Result %#v\n", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "web")); err != nil {
+		t.Errorf("Expected the link to the binary. Result %v\n", err)
+	}
+}
+
+//Gzipped tar of the entries, regular files empty.
+func archive(t *testing.T, entries []tar.Header) []byte {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for i := range entries {
+		entries[i].Mode = 0755
+		if err := tw.WriteHeader(&entries[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tw.Close()
+	gz.Close()
+	return buf.Bytes()
+}
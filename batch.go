@@ -0,0 +1,96 @@
+// goforever - processes management
+// Copyright (c) 2013 Garrett Woodworth (https://github.com/gwoo).
+
+package process
+
+import (
+	"fmt"
+)
+
+//One step of a Batch: the start, stop or restart of a process.
+type Op struct {
+	Action  string `json:"action"`
+	Process string `json:"process"`
+}
+
+//Check every step of a batch: a known action on a known process, whose
+//configuration is valid when it is to be started.
+func (m *Manager) checkOps(ops []Op) error {
+	for i, o := range ops {
+		p, err := m.Lookup(o.Process)
+		if err != nil {
+			return fmt.Errorf("Op %d: %s: %s", i, o.Process, err)
+		}
+		switch o.Action {
+		case "stop":
+		case "start", "restart":
+			if err := p.validate(); err != nil {
+				return fmt.Errorf("Op %d: %s", i, err)
+			}
+		default:
+			return fmt.Errorf("Op %d: unknown action %q.", i, o.Action)
+		}
+	}
+	return nil
+}
+
+//Run ops in order, each after the previous one has finished. Nothing is
+//applied unless all of them pass the checks first. When a step fails the
+//steps before it are undone in reverse order where they can be: started
+//processes are stopped and stopped ones started again; restarts stay.
+//Returns the operations run, rollbacks included.
+func (m *Manager) Batch(ops []Op) ([]*Operation, error) {
+	if err := m.checkOps(ops); err != nil {
+		return nil, err
+	}
+	var done []*Operation
+	for i, o := range ops {
+		op, err := m.apply(o)
+		if op != nil {
+			done = append(done, op)
+			err = op.Wait()
+		}
+		if err != nil {
+			done = append(done, m.rollback(ops[:i])...)
+			return done, fmt.Errorf("%s %s: %s", o.Action, o.Process, err)
+		}
+	}
+	return done, nil
+}
+
+func (m *Manager) apply(o Op) (*Operation, error) {
+	switch o.Action {
+	case "start":
+		return m.Start(o.Process)
+	case "stop":
+		return m.Stop(o.Process)
+	case "restart":
+		return m.Restart(o.Process)
+	}
+	return nil, fmt.Errorf("Unknown action %q.", o.Action)
+}
+
+//Undo the applied steps, last first.
+func (m *Manager) rollback(applied []Op) []*Operation {
+	var ops []*Operation
+	for i := len(applied) - 1; i >= 0; i-- {
+		undo := Op{Process: applied[i].Process}
+		switch applied[i].Action {
+		case "start":
+			undo.Action = "stop"
+		case "stop":
+			undo.Action = "start"
+		default:
+			continue
+		}
+		op, err := m.apply(undo)
+		if err == nil {
+			ops = append(ops, op)
+			err = op.Wait()
+		}
+		if err != nil {
+			m.log(LevelError, "rollback failed", Fields{"process": undo.Process, "action": undo.Action, "error": err})
+		}
+	}
+	return ops
+}
@@ -0,0 +1,54 @@
+// goforever - processes management
+// Copyright (c) 2013 Garrett Woodworth (https://github.com/gwoo).
+
+package process
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestBatch(t *testing.T) {
+	m := NewManager()
+	m.Runner = NewFakeRunner()
+	m.Add("web", &Process{Command: "/usr/bin/web", Ping: "1h"})
+	m.Add("db", &Process{Command: "/usr/bin/db", Ping: "1h"})
+	m.Add("bad", &Process{Command: "/usr/bin/bad", Ping: "1h", Hooks: &Hooks{PreStart: []string{"/bin/false"}}})
+	ops, err := m.Batch([]Op{{"start", "db"}, {"start", "web"}})
+	if err != nil || len(ops) != 2 || m.Get("web").CurrentPid() == 0 || m.Get("db").CurrentPid() == 0 {
+		t.Fatalf("Expected db and web started. Result %v %d\n", err, len(ops))
+	}
+	for _, bad := range [][]Op{{{"stop", "web"}, {"start", "nope"}}, {{"stop", "web"}, {"jump", "db"}}} {
+		if ops, err := m.Batch(bad); err == nil || ops != nil || m.Get("web").CurrentPid() == 0 {
+			t.Errorf("Expected %#v to be refused before stopping web. Result %v\n", bad, err)
+		}
+	}
+	ops, err = m.Batch([]Op{{"stop", "web"}, {"restart", "db"}, {"start", "bad"}})
+	if err == nil || !strings.HasPrefix(err.Error(), "start bad:") {
+		t.Errorf("Expected the bad start to fail. Result %v\n", err)
+	}
+	if len(ops) != 4 || ops[3].Type != "start" || ops[3].Process != "web" || m.Get("web").CurrentPid() == 0 {
+		t.Errorf("Expected web started again. Result %#v\n", ops)
+	}
+}
+
+func TestAPIBatch(t *testing.T) {
+	m := NewManager()
+	m.Runner = NewFakeRunner()
+	m.Add("web", &Process{Command: "/usr/bin/web", Ping: "1h"})
+	h := m.API(nil)
+	for body, code := range map[string]int{
+		`[{"action": "start", "process": "web"}]`:  http.StatusOK,
+		`[{"action": "start", "process": "nope"}]`: http.StatusBadRequest,
+		`{"action": "start"}`:                      http.StatusBadRequest,
+	} {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest("POST", "/batch", strings.NewReader(body)))
+		if rec.Code != code {
+			t.Errorf("%s: expected %d. Result %d %s\n", body, code, rec.Code, rec.Body)
+		}
+	}
+	m.Get("web").Stop()
+}
@@ -0,0 +1,92 @@
+// goforever - processes management
+// Copyright (c) 2013 Garrett Woodworth (https://github.com/gwoo).
+
+package process
+
+import (
+	"os"
+	"time"
+)
+
+//Command binary a run was started from, the file last seen at its path and
+//a change to it not yet quiet for long enough.
+type binState struct {
+	info    BinaryInfo
+	mod     time.Time
+	size    int64
+	sum     string
+	pending os.FileInfo
+	since   time.Time
+}
+
+//Note the binary of the just started process. Called with p.cycle held.
+func (p *Process) recordBinary() {
+	var state *binState
+	if bin, err := p.binary(); err == nil && bin != "" {
+		if info, err := p.readBinary(bin); err == nil {
+			state = &binState{info: info, mod: info.ModTime, size: info.Size, sum: info.SHA256}
+		}
+	}
+	p.mu.Lock()
+	p.bin = state
+	p.mu.Unlock()
+}
+
+//Whether the binary of the running process has a new content that has
+//stayed unchanged for the WatchBinary quiet period. A new file with the
+//same content is taken as the running binary.
+func (p *Process) binaryChanged() bool {
+	p.mu.Lock()
+	state := p.bin
+	running := p.Pid > 0 && p.frozen == ""
+	p.mu.Unlock()
+	if state == nil || !running {
+		return false
+	}
+	fi, err := os.Stat(state.info.Path)
+	if err != nil {
+		//Removed or being replaced.
+		return false
+	}
+	now := p.clock().Now()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if state.pending == nil && fi.ModTime().Equal(state.mod) && fi.Size() == state.size {
+		return false
+	}
+	if state.pending == nil || !fi.ModTime().Equal(state.pending.ModTime()) || fi.Size() != state.pending.Size() {
+		state.pending, state.since = fi, now
+		return false
+	}
+	if now.Sub(state.since) < durationOr(p.WatchBinary, 0) {
+		return false
+	}
+	state.pending = nil
+	state.mod, state.size = fi.ModTime(), fi.Size()
+	sum, err := fileSHA256(state.info.Path)
+	if err != nil || sum == state.sum {
+		return false
+	}
+	state.sum = sum
+	return sum != state.info.SHA256
+}
+
+//Poll the Command binary of processes with WatchBinary every interval,
+//restarting the running ones whose binary changed, once the new one has
+//been left alone for the quiet period, one process or instance at a time,
+//see restartChanged. Suits deploying by copying over the binary. Stops
+//when done is closed.
+func (m *Manager) WatchBinaries(interval time.Duration, done <-chan struct{}) {
+	go func() {
+		for {
+			select {
+			case <-done:
+				return
+			case <-m.clock().After(interval):
+			}
+			m.restartChanged(func(p *Process) bool {
+				return p.WatchBinary != "" && p.binaryChanged()
+			}, "binary changed")
+		}
+	}()
+}
@@ -0,0 +1,103 @@
+// goforever - processes management
+// Copyright (c) 2013 Garrett Woodworth (https://github.com/gwoo).
+
+package process
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestBinaryChanged(t *testing.T) {
+	bin := filepath.Join(t.TempDir(), "web")
+	ioutil.WriteFile(bin, []byte("v1"), 0755)
+	clock := NewFakeClock(time.Now())
+	p := &Process{Name: "web", Command: bin, Runner: NewFakeRunner(), Clock: clock, Ping: "1h", WatchBinary: "5s"}
+	RunProcess("web", p)
+	defer p.Stop()
+	running := *p.Binary()
+	if p.binaryChanged() {
+		t.Errorf("Expected no change.\n")
+	}
+	replace := func(data string, mod time.Time) {
+		ioutil.WriteFile(bin, []byte(data), 0755)
+		os.Chtimes(bin, mod, mod)
+	}
+	base := time.Now().Add(time.Minute)
+	replace("v2", base)
+	if p.binaryChanged() {
+		t.Errorf("Expected the change to wait for the quiet period.\n")
+	}
+	clock.Advance(3 * time.Second)
+	replace("v2 still copying", base.Add(time.Second))
+	if p.binaryChanged() {
+		t.Errorf("Expected the quiet period restarted.\n")
+	}
+	clock.Advance(3 * time.Second)
+	if p.binaryChanged() {
+		t.Errorf("Expected the quiet period restarted by the second write.\n")
+	}
+	clock.Advance(3 * time.Second)
+	if !p.binaryChanged() {
+		t.Errorf("Expected the change after the quiet period.\n")
+	}
+	if b := p.Binary(); b == nil || *b != running {
+		t.Errorf("Expected %#v. Result %#v\n", running, b)
+	}
+	replace("v2 still copying", base.Add(2*time.Second))
+	p.binaryChanged()
+	clock.Advance(6 * time.Second)
+	if p.binaryChanged() {
+		t.Errorf("Expected a touch with the same content ignored.\n")
+	}
+}
+
+func TestWatchBinaries(t *testing.T) {
+	bin := filepath.Join(t.TempDir(), "web")
+	ioutil.WriteFile(bin, []byte("v1"), 0755)
+	r := NewFakeRunner()
+	m := NewManager()
+	m.Add("web", &Process{Command: bin, Runner: r, Ping: "1h", WatchBinary: "1ms"})
+	RunProcess("web", m.Get("web"))
+	defer m.Get("web").Stop()
+	done := make(chan struct{})
+	defer close(done)
+	m.WatchBinaries(5*time.Millisecond, done)
+	ioutil.WriteFile(bin, []byte("v2"), 0755)
+	mod := time.Now().Add(time.Minute)
+	os.Chtimes(bin, mod, mod)
+	waitFor(t, func() bool { return len(r.Processes()) == 2 && len(r.Running()) == 1 })
+}
+
+func TestWatchBinariesInstances(t *testing.T) {
+	bin := filepath.Join(t.TempDir(), "web")
+	ioutil.WriteFile(bin, []byte("v1"), 0755)
+	r := NewFakeRunner()
+	r.OnStart = func(f *FakeProcess) { f.IgnoreSignals = true }
+	m := NewManager()
+	for i := 0; i < 2; i++ {
+		n, _ := m.AddInstance("web", &Process{Command: bin, Runner: r, Ping: "1h", WatchBinary: "1ms"})
+		RunProcess(n, m.Get(n))
+		defer m.Get(n).Stop()
+	}
+	first, second := r.Processes()[0], r.Processes()[1]
+	r.OnStart = nil
+	done := make(chan struct{})
+	defer close(done)
+	m.WatchBinaries(5*time.Millisecond, done)
+	ioutil.WriteFile(bin, []byte("v2"), 0755)
+	mod := time.Now().Add(time.Minute)
+	os.Chtimes(bin, mod, mod)
+	waitFor(t, func() bool { return len(first.Signals()) > 0 })
+	time.Sleep(20 * time.Millisecond)
+	if len(second.Signals()) > 0 {
+		t.Errorf("Expected one instance restarted at a time. Result %#v\n", second.Signals())
+	}
+	first.Exit(0)
+	waitFor(t, func() bool { return len(second.Signals()) > 0 })
+	second.Exit(0)
+	waitFor(t, func() bool { return len(r.Processes()) == 4 && len(r.Running()) == 2 })
+}
@@ -0,0 +1,100 @@
+// goforever - processes management
+// Copyright (c) 2013 Garrett Woodworth (https://github.com/gwoo).
+
+package process
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+//Blue/green restart settings. Each color gets its own Env and Vars on top of
+//the process ones, so the two copies can listen on different ports while
+//they run side by side. Health probes may reference the color Vars, e.g.
+//{"tcp": "127.0.0.1:${port}"}.
+type BlueGreen struct {
+	Env  map[string][]string `json:"env,omitempty"`
+	Vars map[string]Vars     `json:"vars,omitempty"`
+}
+
+const (
+	Blue  = "blue"
+	Green = "green"
+)
+
+//Check that the colors differ, otherwise both copies would fight over the
+//same ports.
+func (bg *BlueGreen) validate() error {
+	if reflect.DeepEqual(bg.Env[Blue], bg.Env[Green]) && reflect.DeepEqual(bg.Vars[Blue], bg.Vars[Green]) {
+		return errors.New("Blue and green have the same env and vars.")
+	}
+	return nil
+}
+
+//Copy of p configured for color. Pidfile, Logfile and Errfile expand
+//{color}; a Pidfile without it gets a ".color" suffix.
+func (p *Process) colored(color string) *Process {
+	c := p.clone()
+	c.Name = p.Name
+	c.manager = p.manager
+	c.group = p.group
+	c.color = color
+	c.Env = mergeEnv(c.Env, p.BlueGreen.Env[color])
+	vars := Vars{}
+	for k, v := range p.Vars {
+		vars[k] = v
+	}
+	for k, v := range p.BlueGreen.Vars[color] {
+		vars[k] = v
+	}
+	c.Vars = vars
+	expand := func(s string) string {
+		return strings.Replace(s, "{color}", color, -1)
+	}
+	c.Logfile = expand(c.Logfile)
+	c.Errfile = expand(c.Errfile)
+	pidfile := expand(string(c.Pidfile))
+	if pidfile == string(c.Pidfile) && pidfile != "" {
+		pidfile += "." + color
+	}
+	c.Pidfile = Pidfile(pidfile)
+	return c
+}
+
+//Start a copy of the process in the other color next to the running one,
+//wait for it to become healthy and then stop the old copy. If the new copy
+//does not become healthy it is stopped and the old one keeps running.
+func (m *Manager) BlueGreenRestart(name string) error {
+	old, err := m.Lookup(name)
+	if err != nil {
+		return err
+	}
+	if old.BlueGreen == nil {
+		return fmt.Errorf("%s has no blue_green settings.", name)
+	}
+	if err := old.BlueGreen.validate(); err != nil {
+		return err
+	}
+	color := Green
+	if old.color == Green {
+		color = Blue
+	}
+	next := old.colored(color)
+	if _, err := RunProcess(name, next); err != nil {
+		return err
+	}
+	ctx, cancel := next.startContext()
+	defer cancel()
+	if err := next.waitHealthy(ctx); err != nil {
+		next.Stop()
+		return err
+	}
+	m.mu.Lock()
+	m.procs[name] = next
+	m.mu.Unlock()
+	old.Stop()
+	next.emit(EventRestart, "blue/green switch to "+color)
+	return nil
+}
@@ -0,0 +1,59 @@
+// goforever - processes management
+// Copyright (c) 2013 Garrett Woodworth (https://github.com/gwoo).
+
+package process
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestBlueGreenRestart(t *testing.T) {
+	dir, err := ioutil.TempDir("", "process")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	port := ln.Addr().String()[strings.LastIndex(ln.Addr().String(), ":")+1:]
+	m := NewManager()
+	old := &Process{
+		Command: "/bin/sleep",
+		Args:    []string{"10"},
+		Pidfile: Pidfile(filepath.Join(dir, "web.pid")),
+		Health:  []Probe{{TCP: "127.0.0.1:${port}"}},
+		BlueGreen: &BlueGreen{Vars: map[string]Vars{
+			Blue:  {"port": "1"},
+			Green: {"port": port},
+		}},
+	}
+	m.Add("web", old)
+	RunProcess("web", old)
+	if err := m.BlueGreenRestart("web"); err != nil {
+		t.Fatalf("Error: %s.", err)
+	}
+	next := m.Get("web")
+	defer next.Stop()
+	s := next.Snapshot()
+	if next == old || s.Color != Green || s.Pid == 0 || s.Pidfile != filepath.Join(dir, "web.pid.green") {
+		t.Errorf("Unexpected green process %#v\n", s)
+	}
+	if old.Snapshot().Status != "stopped" {
+		t.Errorf("Expected old process stopped. Result %#v\n", old.Snapshot())
+	}
+	next.HealthTimeout = "10ms"
+	if err := m.BlueGreenRestart("web"); err == nil {
+		t.Error("Expected blue to fail its health check.")
+	}
+	if m.Get("web") != next {
+		t.Error("Expected green to keep running after a failed switch.")
+	}
+}
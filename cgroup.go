@@ -0,0 +1,68 @@
+// goforever - processes management
+// Copyright (c) 2013 Garrett Woodworth (https://github.com/gwoo).
+
+package process
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+//Mount point of the cgroup v2 hierarchy.
+var cgroupRoot = "/sys/fs/cgroup"
+
+//Directory of the process's Cgroup, "" without one.
+func (p *Process) cgroupDir() string {
+	if p.Cgroup == "" || filepath.IsAbs(p.Cgroup) {
+		return p.Cgroup
+	}
+	return filepath.Join(cgroupRoot, p.Cgroup)
+}
+
+//oom_kill count in the memory.events of the cgroup in dir, false if it
+//cannot be read.
+func oomKills(dir string) (int, bool) {
+	f, err := os.Open(filepath.Join(dir, "memory.events"))
+	if err != nil {
+		return 0, false
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 2 && fields[0] == "oom_kill" {
+			n, err := strconv.Atoi(fields[1])
+			return n, err == nil
+		}
+	}
+	return 0, false
+}
+
+//Move the running process into its Cgroup and note the cgroup's OOM kills
+//so far, see oomInCgroup.
+func (p *Process) enterCgroup(pid int) error {
+	dir := p.cgroupDir()
+	if err := joinCgroup(dir, pid); err != nil {
+		return err
+	}
+	base, _ := oomKills(dir)
+	p.mu.Lock()
+	p.oomBase = base
+	p.mu.Unlock()
+	return nil
+}
+
+//Whether the cgroup counted an OOM kill since the process entered it.
+func (p *Process) oomInCgroup() bool {
+	dir := p.cgroupDir()
+	if dir == "" {
+		return false
+	}
+	n, ok := oomKills(dir)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return ok && n > p.oomBase
+}
@@ -0,0 +1,71 @@
+// goforever - processes management
+// Copyright (c) 2013 Garrett Woodworth (https://github.com/gwoo).
+
+package process
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestCgroupOOM(t *testing.T) {
+	defer func(root string) { cgroupRoot = root }(cgroupRoot)
+	cgroupRoot = t.TempDir()
+	dir := filepath.Join(cgroupRoot, "goforever", "web")
+	events := func(kills int) {
+		data := "low 0\nhigh 0\nmax 2\noom 1\noom_kill " + strconv.Itoa(kills) + "\n"
+		if err := ioutil.WriteFile(filepath.Join(dir, "memory.events"), []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	clock := NewFakeClock(time.Now())
+	r := NewFakeRunner()
+	p := &Process{Command: "web", Runner: r, Ping: "1h", Respawn: 2, Cgroup: "goforever/web", Delay: "1s", OOMDelay: "1m", Clock: clock}
+	RunProcess("web", p)
+	procs, err := ioutil.ReadFile(filepath.Join(dir, "cgroup.procs"))
+	if ex := strconv.Itoa(FakePid); err != nil || string(procs) != ex {
+		t.Fatalf("Expected %#v. Result %#v %v\n", ex, string(procs), err)
+	}
+	events(1)
+	r.Running()[0].Signal(syscall.SIGKILL)
+	waitFor(t, func() bool { return p.Snapshot().LastExit != nil })
+	if exit := p.Snapshot().LastExit; !exit.OOM || exit.Reason != "killed by OOM" {
+		t.Errorf("Expected an OOM kill. Result %#v\n", exit)
+	}
+	//Respawned after OOMDelay, not Delay.
+	clock.BlockUntil(2)
+	clock.Advance(time.Second)
+	time.Sleep(20 * time.Millisecond)
+	if len(r.Running()) != 0 {
+		t.Errorf("Expected no respawn after Delay.\n")
+	}
+	clock.Advance(time.Minute)
+	waitFor(t, func() bool { return len(r.Running()) == 1 })
+	p.Stop()
+}
+
+func TestFreezeCgroup(t *testing.T) {
+	dir := t.TempDir()
+	r := NewFakeRunner()
+	p := &Process{Name: "web", Command: "web", Runner: r, Ping: "1h", Cgroup: dir}
+	RunProcess("web", p)
+	defer p.Stop()
+	freeze := filepath.Join(dir, "cgroup.freeze")
+	if err := p.Freeze(); err != nil {
+		t.Fatalf("Expected no error. Result %#v\n", err)
+	}
+	if data, _ := ioutil.ReadFile(freeze); string(data) != "1" {
+		t.Errorf("Expected %#v. Result %#v\n", "1", string(data))
+	}
+	p.Thaw()
+	if data, _ := ioutil.ReadFile(freeze); string(data) != "0" {
+		t.Errorf("Expected %#v. Result %#v\n", "0", string(data))
+	}
+	if signals := r.Running()[0].Signals(); len(signals) != 0 {
+		t.Errorf("Expected no signals. Result %#v\n", signals)
+	}
+}
@@ -0,0 +1,14 @@
+// goforever - processes management
+// Copyright (c) 2013 Garrett Woodworth (https://github.com/gwoo).
+
+//go:build !linux
+
+package process
+
+import (
+	"errors"
+)
+
+func joinCgroup(dir string, pid int) error {
+	return errors.New("Cgroups are only supported on Linux.")
+}
@@ -0,0 +1,135 @@
+// goforever - processes management
+// Copyright (c) 2013 Garrett Woodworth (https://github.com/gwoo).
+
+package process
+
+import (
+	"errors"
+	"math/rand"
+	"os"
+	"sync"
+	"time"
+)
+
+var ErrChaosStart = errors.New("Chaos: start failure.")
+
+//Failures injected by a ChaosRunner.
+type ChaosPolicy struct {
+	//Probability (0-1) that a start fails with ErrChaosStart.
+	StartFailure float64
+	//Upper bound of a random delay before each start.
+	StartDelay time.Duration
+	//Signal each process at a random time within KillAfter of its start.
+	//Zero disables kills.
+	KillAfter time.Duration
+	//Probability (0-1) that a started process is picked for a kill.
+	//Defaults to 1 when KillAfter is set.
+	KillProbability float64
+	//Signal sent, os.Kill when nil.
+	Signal os.Signal
+	//Seed for reproducible runs. Zero uses the current time.
+	Seed int64
+}
+
+//Runner wrapping another one and injecting failures according to a policy,
+//to see how restart policies and dependent services behave under failure.
+type ChaosRunner struct {
+	Runner Runner
+	Policy ChaosPolicy
+	//Clock for delays and kills. Nil uses RealClock.
+	Clock Clock
+	mu    sync.Mutex
+	rand  *rand.Rand
+	kills int
+}
+
+//Wrap r with the policy.
+func NewChaosRunner(r Runner, policy ChaosPolicy) *ChaosRunner {
+	seed := policy.Seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	return &ChaosRunner{Runner: r, Policy: policy, rand: rand.New(rand.NewSource(seed))}
+}
+
+//Number of processes killed so far.
+func (c *ChaosRunner) Kills() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.kills
+}
+
+func (c *ChaosRunner) Start(cmd *Cmd) (Handle, error) {
+	clock := c.Clock
+	if clock == nil {
+		clock = RealClock
+	}
+	if d := c.duration(c.Policy.StartDelay); d > 0 {
+		clock.Sleep(d)
+	}
+	if c.chance(c.Policy.StartFailure) {
+		return nil, ErrChaosStart
+	}
+	h, err := c.Runner.Start(cmd)
+	if err != nil || c.Policy.KillAfter <= 0 {
+		return h, err
+	}
+	probability := c.Policy.KillProbability
+	if probability == 0 {
+		probability = 1
+	}
+	if !c.chance(probability) {
+		return h, nil
+	}
+	ch := &chaosHandle{Handle: h, done: make(chan struct{})}
+	after := clock.After(c.duration(c.Policy.KillAfter) + 1)
+	go func() {
+		select {
+		case <-ch.done:
+			return
+		case <-after:
+		}
+		sig := c.Policy.Signal
+		if sig == nil {
+			sig = os.Kill
+		}
+		if h.Signal(sig) == nil {
+			c.mu.Lock()
+			c.kills++
+			c.mu.Unlock()
+		}
+	}()
+	return ch, nil
+}
+
+//Random duration in [0, max).
+func (c *ChaosRunner) duration(max time.Duration) time.Duration {
+	if max <= 0 {
+		return 0
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return time.Duration(c.rand.Int63n(int64(max)))
+}
+
+func (c *ChaosRunner) chance(p float64) bool {
+	if p <= 0 {
+		return false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.rand.Float64() < p
+}
+
+//Handle that stops the pending kill once the process has exited.
+type chaosHandle struct {
+	Handle
+	once sync.Once
+	done chan struct{}
+}
+
+func (h *chaosHandle) Wait() (*ExitStatus, error) {
+	s, err := h.Handle.Wait()
+	h.once.Do(func() { close(h.done) })
+	return s, err
+}
@@ -0,0 +1,32 @@
+// goforever - processes management
+// Copyright (c) 2013 Garrett Woodworth (https://github.com/gwoo).
+
+package process
+
+import (
+	"testing"
+	"time"
+)
+
+func TestChaosRunnerKill(t *testing.T) {
+	clock := NewFakeClock(time.Unix(0, 0))
+	fake := NewFakeRunner()
+	c := NewChaosRunner(fake, ChaosPolicy{KillAfter: time.Minute, Seed: 1})
+	c.Clock = clock
+	p := &Process{Command: "/usr/bin/web", Runner: c, Clock: clock, Respawn: 1, Ping: "1h"}
+	RunProcess("web", p)
+	clock.BlockUntil(2)
+	clock.Advance(time.Minute)
+	waitFor(t, func() bool { return len(fake.Processes()) == 2 })
+	if c.Kills() != 1 || p.Snapshot().Respawns != 1 {
+		t.Errorf("Expected one kill and respawn. Result %d %#v\n", c.Kills(), p.Snapshot())
+	}
+	p.Stop()
+}
+
+func TestChaosRunnerStartFailure(t *testing.T) {
+	c := NewChaosRunner(NewFakeRunner(), ChaosPolicy{StartFailure: 1})
+	if _, err := c.Start(&Cmd{Path: "/usr/bin/web"}); err != ErrChaosStart {
+		t.Errorf("Expected %#v. Result %#v\n", ErrChaosStart, err)
+	}
+}
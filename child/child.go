@@ -0,0 +1,80 @@
+// goforever - processes management
+// Copyright (c) 2013 Garrett Woodworth (https://github.com/gwoo).
+
+//Package child is the side of the supervisor's protocols spoken by
+//supervised Go programs, without importing the supervisor itself.
+package child
+
+import (
+	"errors"
+	"net"
+	"net/rpc"
+	"net/rpc/jsonrpc"
+	"os"
+	"strconv"
+)
+
+//Variable naming the control channel's descriptor, see
+//process.ControlEnv.
+const ControlEnv = "PROCESS_CONTROL_FD"
+
+var (
+	ErrNoControl   = errors.New("No control channel.")
+	ErrUnsupported = errors.New("Command not supported.")
+)
+
+//Handlers of the commands sent over the control channel. A nil handler
+//refuses its command, which makes the supervisor fall back to signals.
+type Control struct {
+	Reload func() error
+	Stats  func() (map[string]interface{}, error)
+	Stop   func() error
+}
+
+//Serve c on the control channel until the supervisor closes it.
+//ErrNoControl if the process was started without one.
+func Serve(c *Control) error {
+	fd, err := strconv.Atoi(os.Getenv(ControlEnv))
+	if err != nil {
+		return ErrNoControl
+	}
+	f := os.NewFile(uintptr(fd), "control")
+	conn, err := net.FileConn(f)
+	f.Close()
+	if err != nil {
+		return err
+	}
+	s := rpc.NewServer()
+	if err := s.RegisterName("Control", &server{c}); err != nil {
+		return err
+	}
+	s.ServeCodec(jsonrpc.NewServerCodec(conn))
+	return nil
+}
+
+type server struct {
+	c *Control
+}
+
+func (s *server) Reload(args *struct{}, reply *struct{}) error {
+	if s.c.Reload == nil {
+		return ErrUnsupported
+	}
+	return s.c.Reload()
+}
+
+func (s *server) Stats(args *struct{}, reply *map[string]interface{}) error {
+	if s.c.Stats == nil {
+		return ErrUnsupported
+	}
+	stats, err := s.c.Stats()
+	*reply = stats
+	return err
+}
+
+func (s *server) Stop(args *struct{}, reply *struct{}) error {
+	if s.c.Stop == nil {
+		return ErrUnsupported
+	}
+	return s.c.Stop()
+}
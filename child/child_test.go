@@ -0,0 +1,57 @@
+// goforever - processes management
+// Copyright (c) 2013 Garrett Woodworth (https://github.com/gwoo).
+
+//go:build unix
+
+package child
+
+import (
+	"net"
+	"net/rpc/jsonrpc"
+	"os"
+	"strconv"
+	"syscall"
+	"testing"
+)
+
+func TestServe(t *testing.T) {
+	os.Unsetenv(ControlEnv)
+	if err := Serve(&Control{}); err != ErrNoControl {
+		t.Errorf("Expected %#v. Result %#v\n", ErrNoControl, err)
+	}
+	fds, err := syscall.Socketpair(syscall.AF_UNIX, syscall.SOCK_STREAM, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.Setenv(ControlEnv, strconv.Itoa(fds[0]))
+	defer os.Unsetenv(ControlEnv)
+	reloads := 0
+	done := make(chan error)
+	go func() {
+		done <- Serve(&Control{
+			Reload: func() error { reloads++; return nil },
+			Stats:  func() (map[string]interface{}, error) { return map[string]interface{}{"conns": 3}, nil },
+		})
+	}()
+	f := os.NewFile(uintptr(fds[1]), "supervisor")
+	conn, err := net.FileConn(f)
+	f.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := jsonrpc.NewClient(conn)
+	if err := c.Call("Control.Reload", struct{}{}, &struct{}{}); err != nil || reloads != 1 {
+		t.Errorf("Expected a reload. Result %d %v\n", reloads, err)
+	}
+	stats := map[string]interface{}{}
+	if err := c.Call("Control.Stats", struct{}{}, &stats); err != nil || stats["conns"] != 3.0 {
+		t.Errorf("Expected %#v. Result %#v %v\n", 3.0, stats["conns"], err)
+	}
+	if err := c.Call("Control.Stop", struct{}{}, &struct{}{}); err == nil || err.Error() != ErrUnsupported.Error() {
+		t.Errorf("Expected %#v. Result %v\n", ErrUnsupported.Error(), err)
+	}
+	c.Close()
+	if err := <-done; err != nil {
+		t.Errorf("Expected %#v. Result %#v\n", nil, err)
+	}
+}
@@ -0,0 +1,102 @@
+// goforever - processes management
+// Copyright (c) 2013 Garrett Woodworth (https://github.com/gwoo).
+
+package child
+
+import (
+	"net"
+	"os"
+	"os/signal"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+)
+
+//Tell the supervisor the program is ready to serve, see process.Notify.
+//Like the other notifications it does nothing when the program is not
+//supervised with NOTIFY_SOCKET, so it can be called unconditionally.
+func Ready() error {
+	return Notify("READY=1")
+}
+
+//Tell the supervisor the program is alive, at least every
+//WatchdogInterval, see process.Watchdog.
+func Heartbeat() error {
+	return Notify("WATCHDOG=1")
+}
+
+//Tell the supervisor the program is shutting down.
+func Stopping() error {
+	return Notify("STOPPING=1")
+}
+
+//Show text as the process's notice in the supervisor's status.
+func Status(text string) error {
+	return Notify("STATUS=" + text)
+}
+
+//Send newline separated assignments to NOTIFY_SOCKET, as sd_notify.
+func Notify(state string) error {
+	path := os.Getenv("NOTIFY_SOCKET")
+	if path == "" {
+		return nil
+	}
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	_, err = conn.Write([]byte(state))
+	return err
+}
+
+//Longest wait between Heartbeats before the supervisor restarts the
+//program, 0 without a watchdog.
+func WatchdogInterval() time.Duration {
+	usec, err := strconv.ParseInt(os.Getenv("WATCHDOG_USEC"), 10, 64)
+	if err != nil || usec <= 0 {
+		return 0
+	}
+	return time.Duration(usec) * time.Microsecond
+}
+
+//Send Heartbeats at half the WatchdogInterval until stop is closed. Returns
+//at once without a watchdog.
+func Heartbeats(stop <-chan struct{}) {
+	interval := WatchdogInterval() / 2
+	if interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			Heartbeat()
+		case <-stop:
+			return
+		}
+	}
+}
+
+var (
+	shutdownOnce sync.Once
+	shutdown     = make(chan struct{})
+)
+
+//Closed when the supervisor stops the program with TERM, or it is
+//interrupted. The program should then finish its work and exit before the
+//supervisor's stop timeout.
+func Shutdown() <-chan struct{} {
+	shutdownOnce.Do(func() {
+		signals := make(chan os.Signal, 1)
+		signal.Notify(signals, syscall.SIGTERM, os.Interrupt)
+		go func() {
+			<-signals
+			Stopping()
+			close(shutdown)
+		}()
+	})
+	return shutdown
+}
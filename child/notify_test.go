@@ -0,0 +1,62 @@
+// goforever - processes management
+// Copyright (c) 2013 Garrett Woodworth (https://github.com/gwoo).
+
+//go:build unix
+
+package child
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestNotify(t *testing.T) {
+	os.Unsetenv("NOTIFY_SOCKET")
+	if err := Ready(); err != nil {
+		t.Errorf("Expected %#v unsupervised. Result %#v\n", nil, err)
+	}
+	path := filepath.Join(t.TempDir(), "notify")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	os.Setenv("NOTIFY_SOCKET", path)
+	defer os.Unsetenv("NOTIFY_SOCKET")
+	Ready()
+	Heartbeat()
+	Status("warming up")
+	buf := make([]byte, 128)
+	for _, want := range []string{"READY=1", "WATCHDOG=1", "STATUS=warming up"} {
+		n, err := conn.Read(buf)
+		if err != nil || string(buf[:n]) != want {
+			t.Errorf("Expected %#v. Result %#v %v\n", want, string(buf[:n]), err)
+		}
+	}
+}
+
+func TestWatchdogInterval(t *testing.T) {
+	os.Setenv("WATCHDOG_USEC", "1500000")
+	defer os.Unsetenv("WATCHDOG_USEC")
+	if d := WatchdogInterval(); d != 1500*time.Millisecond {
+		t.Errorf("Expected %#v. Result %#v\n", 1500*time.Millisecond, d)
+	}
+	os.Setenv("WATCHDOG_USEC", "x")
+	if d := WatchdogInterval(); d != 0 {
+		t.Errorf("Expected %#v. Result %#v\n", 0, d)
+	}
+}
+
+func TestShutdown(t *testing.T) {
+	ch := Shutdown()
+	syscall.Kill(os.Getpid(), syscall.SIGTERM)
+	select {
+	case <-ch:
+	case <-time.After(5 * time.Second):
+		t.Errorf("Expected a shutdown notification.\n")
+	}
+}
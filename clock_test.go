@@ -0,0 +1,77 @@
+// goforever - processes management
+// Copyright (c) 2013 Garrett Woodworth (https://github.com/gwoo).
+
+package process
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFakeClock(t *testing.T) {
+	c := NewFakeClock(time.Unix(0, 0))
+	after := c.After(time.Second)
+	tick := c.NewTicker(400 * time.Millisecond)
+	defer tick.Stop()
+	c.Advance(500 * time.Millisecond)
+	select {
+	case <-after:
+		t.Error("Expected timer not to fire before its deadline.")
+	case <-tick.C():
+	}
+	c.Advance(500 * time.Millisecond)
+	if r := <-after; !r.Equal(time.Unix(1, 0)) {
+		t.Errorf("Expected %s. Result %s\n", time.Unix(1, 0), r)
+	}
+	if r := c.Now(); !r.Equal(time.Unix(1, 0)) {
+		t.Errorf("Expected %s. Result %s\n", time.Unix(1, 0), r)
+	}
+}
+
+func TestStartBackoffClock(t *testing.T) {
+	c := NewFakeClock(time.Unix(0, 0))
+	p := &Process{
+		Command:      "/nonexistent/command",
+		Pidfile:      "missing.pid",
+		StartRetries: 2,
+		StartBackoff: "1s",
+		Clock:        c,
+	}
+	done := make(chan struct{})
+	go func() {
+		RunProcess("missing", p)
+		close(done)
+	}()
+	for _, d := range []time.Duration{time.Second, 2 * time.Second} {
+		c.BlockUntil(1)
+		c.Advance(d - time.Millisecond)
+		if c.Waiters() != 1 {
+			t.Fatalf("Expected backoff of %s.\n", d)
+		}
+		c.Advance(time.Millisecond)
+	}
+	<-done
+	if s := p.Snapshot(); s.Status != "fatal" {
+		t.Errorf("Expected fatal. Result %#v\n", s)
+	}
+}
+
+func TestPingClock(t *testing.T) {
+	c := NewFakeClock(time.Unix(0, 0))
+	p := &Process{Ping: "30s", Clock: c}
+	refreshed := make(chan bool)
+	p.ping(func(d time.Duration, p *Process) {
+		refreshed <- d == 30*time.Second
+	})
+	c.BlockUntil(1)
+	for i := 0; i < 3; i++ {
+		c.Advance(30 * time.Second)
+		if !<-refreshed {
+			t.Error("Expected the process Ping interval.")
+		}
+	}
+	p.Release("stopped")
+	for c.Waiters() != 0 {
+		time.Sleep(time.Millisecond)
+	}
+}
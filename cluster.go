@@ -0,0 +1,250 @@
+// goforever - processes management
+// Copyright (c) 2013 Garrett Woodworth (https://github.com/gwoo).
+
+package process
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+//Static peers whose process status the manager polls, so that any node's
+//API can tell where a process runs and whether it is healthy.
+type Cluster struct {
+	//Name of this node, default the hostname.
+	Node string `json:"node,omitempty"`
+	//Control API URLs of the other supervisors.
+	Peers []string `json:"peers"`
+	//Token for the peers' APIs, and PEM CAs for https peers.
+	Token string `json:"token,omitempty"`
+	CA    string `json:"ca,omitempty"`
+	//Poll interval, default 5s. Peers not answering for three intervals
+	//are reported as stale.
+	Interval string `json:"interval,omitempty"`
+	//Lock electing the node that runs each Singleton process, checked
+	//every interval. Locker replaces the built-in locks.
+	Lock   *Lock  `json:"lock,omitempty"`
+	Locker Locker `json:"-"`
+}
+
+//Status of a cluster node as last seen.
+type NodeStatus struct {
+	Node      string        `json:"node"`
+	Addr      string        `json:"addr,omitempty"`
+	Local     bool          `json:"local,omitempty"`
+	Seen      time.Time     `json:"seen,omitempty"`
+	Stale     bool          `json:"stale,omitempty"`
+	Draining  bool          `json:"draining,omitempty"`
+	Error     string        `json:"error,omitempty"`
+	Processes []ProcessInfo `json:"processes"`
+}
+
+//Where a process runs, see Manager.Locate.
+type Placement struct {
+	Node   string `json:"node"`
+	Pid    int    `json:"pid,omitempty"`
+	Status string `json:"status,omitempty"`
+	Health string `json:"health,omitempty"`
+	Ready  bool   `json:"ready"`
+	//The node was not heard from lately, the placement may be outdated.
+	Stale bool `json:"stale,omitempty"`
+}
+
+type clusterState struct {
+	c        *Cluster
+	node     string
+	interval time.Duration
+	client   *http.Client
+	mu       sync.Mutex
+	peers    map[string]*NodeStatus
+	leases   *leases
+	draining bool
+}
+
+//Start polling the peers of c every interval until done is closed. With a
+//Lock, call it before Run: singletons are then started by the node
+//winning their lock rather than by Run.
+func (m *Manager) JoinCluster(c *Cluster, done <-chan struct{}) error {
+	if len(c.Peers) == 0 {
+		return errors.New("A cluster needs peers.")
+	}
+	s := &clusterState{
+		c:        c,
+		node:     c.Node,
+		interval: durationOr(c.Interval, 5*time.Second),
+		client:   &http.Client{Timeout: 5 * time.Second},
+		peers:    map[string]*NodeStatus{},
+	}
+	if s.node == "" {
+		s.node, _ = os.Hostname()
+	}
+	if c.CA != "" {
+		data, err := ioutil.ReadFile(c.CA)
+		if err != nil {
+			return err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(data) {
+			return errors.New("No certificates in CA file.")
+		}
+		s.client.Transport = &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool}}
+	}
+	for _, addr := range c.Peers {
+		s.peers[addr] = &NodeStatus{Addr: addr}
+	}
+	if c.Lock != nil || c.Locker != nil {
+		l := &leases{locker: c.Locker, lock: c.Lock, held: map[string]bool{}, renewed: map[string]time.Time{}}
+		if l.lock == nil {
+			l.lock = &Lock{}
+		}
+		l.ttl = durationOr(l.lock.TTL, 3*s.interval)
+		if l.locker == nil {
+			locker, err := l.lock.locker()
+			if err != nil {
+				return err
+			}
+			l.locker = locker
+		}
+		s.leases = l
+	}
+	m.mu.Lock()
+	if m.cluster != nil {
+		m.mu.Unlock()
+		return errors.New("Already in a cluster.")
+	}
+	m.cluster = s
+	m.mu.Unlock()
+	go func() {
+		for {
+			s.poll()
+			if s.leases != nil {
+				m.elect(s)
+			}
+			select {
+			case <-done:
+				return
+			case <-m.clock().After(s.interval):
+			}
+		}
+	}()
+	return nil
+}
+
+//Fetch every peer's local status at once.
+func (s *clusterState) poll() {
+	var wg sync.WaitGroup
+	for _, addr := range s.c.Peers {
+		wg.Add(1)
+		go func(addr string) {
+			defer wg.Done()
+			n, err := s.fetch(addr)
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			old := s.peers[addr]
+			if err != nil {
+				old.Error = err.Error()
+				return
+			}
+			n.Addr = addr
+			n.Seen = time.Now()
+			s.peers[addr] = n
+		}(addr)
+	}
+	wg.Wait()
+}
+
+func (s *clusterState) fetch(addr string) (*NodeStatus, error) {
+	req, err := http.NewRequest("GET", strings.TrimRight(addr, "/")+"/cluster/node", nil)
+	if err != nil {
+		return nil, err
+	}
+	if s.c.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+s.c.Token)
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s: %s", addr, resp.Status)
+	}
+	n := &NodeStatus{}
+	if err := json.NewDecoder(resp.Body).Decode(n); err != nil {
+		return nil, err
+	}
+	return n, nil
+}
+
+//This node's status.
+func (m *Manager) localNode() *NodeStatus {
+	n := &NodeStatus{Local: true, Seen: time.Now(), Processes: m.Snapshot()}
+	m.mu.Lock()
+	s := m.cluster
+	m.mu.Unlock()
+	if s != nil {
+		n.Node = s.node
+		n.Draining = s.isDraining()
+	}
+	return n
+}
+
+//This node followed by its peers, sorted by name. Without JoinCluster
+//only this node.
+func (m *Manager) Nodes() []NodeStatus {
+	nodes := []NodeStatus{*m.localNode()}
+	m.mu.Lock()
+	s := m.cluster
+	m.mu.Unlock()
+	if s == nil {
+		return nodes
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var peers []NodeStatus
+	for _, n := range s.peers {
+		p := *n
+		p.Processes = append([]ProcessInfo{}, n.Processes...)
+		p.Stale = p.Seen.IsZero() || time.Since(p.Seen) > 3*s.interval
+		peers = append(peers, p)
+	}
+	sort.Slice(peers, func(i, j int) bool {
+		if peers[i].Node != peers[j].Node {
+			return peers[i].Node < peers[j].Node
+		}
+		return peers[i].Addr < peers[j].Addr
+	})
+	return append(nodes, peers...)
+}
+
+//The nodes running a process (or instance) named name, or having it
+//configured.
+func (m *Manager) Locate(name string) []Placement {
+	placements := []Placement{}
+	for _, n := range m.Nodes() {
+		for _, info := range n.Processes {
+			if info.Name != name && (info.Namespace == "" || info.Namespace+"/"+info.Name != name) {
+				continue
+			}
+			placements = append(placements, Placement{
+				Node:   n.Node,
+				Pid:    info.Pid,
+				Status: info.Status,
+				Health: info.Health,
+				Ready:  info.Ready,
+				Stale:  n.Stale,
+			})
+		}
+	}
+	return placements
+}
@@ -0,0 +1,61 @@
+// goforever - processes management
+// Copyright (c) 2013 Garrett Woodworth (https://github.com/gwoo).
+
+package process
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCluster(t *testing.T) {
+	auth := &Auth{Tokens: map[string]string{"peer": RoleRead}}
+	a, b := NewManager(), NewManager()
+	a.Add("web", &Process{Command: "/usr/bin/web", Pid: 10, Status: "running"})
+	b.Add("web", &Process{Command: "/usr/bin/web"})
+	b.Add("db", &Process{Command: "/usr/bin/db", Pid: 20, Status: "running"})
+	sa := httptest.NewServer(a.API(auth))
+	defer sa.Close()
+	sb := httptest.NewServer(b.API(auth))
+	defer sb.Close()
+	done := make(chan struct{})
+	defer close(done)
+	if err := a.JoinCluster(&Cluster{Node: "a", Peers: []string{sb.URL}, Token: "peer"}, done); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.JoinCluster(&Cluster{Node: "b", Peers: []string{sa.URL}, Token: "peer"}, done); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, func() bool {
+		return len(a.Locate("db")) == 1
+	})
+	db := a.Locate("db")[0]
+	if db.Node != "b" || db.Pid != 20 || !db.Ready || db.Stale {
+		t.Errorf("Unexpected placement %#v\n", db)
+	}
+	waitFor(t, func() bool {
+		return len(b.Locate("web")) == 2
+	})
+	if web := b.Locate("web"); web[0].Node != "b" || web[1].Node != "a" || web[1].Pid != 10 {
+		t.Errorf("Unexpected placements %#v\n", web)
+	}
+	if err := a.JoinCluster(&Cluster{Peers: []string{sb.URL}}, done); err == nil {
+		t.Errorf("Expected an error joining twice.\n")
+	}
+}
+
+func TestClusterUnreachable(t *testing.T) {
+	m := NewManager()
+	done := make(chan struct{})
+	defer close(done)
+	srv := httptest.NewServer(NewManager().API(&Auth{Tokens: map[string]string{"x": RoleRead}}))
+	defer srv.Close()
+	m.JoinCluster(&Cluster{Node: "a", Peers: []string{srv.URL}}, done)
+	waitFor(t, func() bool {
+		nodes := m.Nodes()
+		return len(nodes) == 2 && nodes[1].Error != ""
+	})
+	if n := m.Nodes()[1]; !n.Stale {
+		t.Errorf("Expected a peer never seen to be stale. Result %#v\n", n)
+	}
+}
@@ -0,0 +1,178 @@
+// goforever - processes management
+// Copyright (c) 2013 Garrett Woodworth (https://github.com/gwoo).
+
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+const defaultAddr = "http://127.0.0.1:2224"
+
+//Client of a running supervisor's control API.
+type client struct {
+	//Context name, empty when given by -addr.
+	name  string
+	addr  string
+	token string
+	http  *http.Client
+}
+
+//Flags selecting the supervisors to talk to.
+type target struct {
+	fs      *flag.FlagSet
+	addr    string
+	token   string
+	ca      string
+	context string
+}
+
+//Register the flags selecting the supervisor: -addr, or -context naming
+//saved contexts (see contextCmd), defaulting to the current context.
+func clientFlags(fs *flag.FlagSet) *target {
+	t := &target{fs: fs}
+	fs.StringVar(&t.addr, "addr", defaultAddr, "URL of the supervisor's control API")
+	fs.StringVar(&t.token, "token", os.Getenv("PROCESS_TOKEN"), "API token, default $PROCESS_TOKEN")
+	fs.StringVar(&t.ca, "ca", "", "PEM CAs to verify an https -addr with")
+	fs.StringVar(&t.context, "context", "", "saved contexts to use, comma separated or all")
+	return t
+}
+
+//Clients of the selected supervisors, once the flags are parsed.
+func (t *target) clients() ([]*client, error) {
+	set := map[string]bool{}
+	t.fs.Visit(func(f *flag.Flag) {
+		set[f.Name] = true
+	})
+	if set["addr"] && set["context"] {
+		return nil, errors.New("Use either -addr or -context.")
+	}
+	if !set["addr"] {
+		cs, err := loadContexts()
+		if err != nil {
+			return nil, err
+		}
+		names, err := cs.pick(t.context)
+		if err != nil {
+			return nil, err
+		}
+		var clients []*client
+		for _, name := range names {
+			ctx := cs.Contexts[name]
+			token := ctx.Token
+			if set["token"] || token == "" {
+				token = t.token
+			}
+			ca := ctx.CA
+			if set["ca"] {
+				ca = t.ca
+			}
+			c, err := newClient(ctx.Addr, token, ca)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %s", name, err)
+			}
+			c.name = name
+			clients = append(clients, c)
+		}
+		if len(clients) > 0 {
+			return clients, nil
+		}
+	}
+	c, err := newClient(t.addr, t.token, t.ca)
+	if err != nil {
+		return nil, err
+	}
+	return []*client{c}, nil
+}
+
+//The single selected supervisor.
+func (t *target) client() (*client, error) {
+	clients, err := t.clients()
+	if err != nil {
+		return nil, err
+	}
+	if len(clients) > 1 {
+		return nil, errors.New("Select a single context.")
+	}
+	return clients[0], nil
+}
+
+func newClient(addr, token, ca string) (*client, error) {
+	c := &client{addr: strings.TrimRight(addr, "/"), token: token, http: &http.Client{}}
+	if ca != "" {
+		data, err := ioutil.ReadFile(ca)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(data) {
+			return nil, errors.New("No certificates in CA file.")
+		}
+		c.http.Transport = &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool}}
+	}
+	return c, nil
+}
+
+//Path of a process route. Names may be given as namespace/name.
+func processPath(name, rest string, query url.Values) string {
+	if query == nil {
+		query = url.Values{}
+	}
+	if i := strings.Index(name, "/"); i >= 0 {
+		query.Set("namespace", name[:i])
+		name = name[i+1:]
+	}
+	path := "/processes/" + url.PathEscape(name) + rest
+	if len(query) > 0 {
+		path += "?" + query.Encode()
+	}
+	return path
+}
+
+//Send a request, turning API errors into Go errors. The caller closes the
+//body.
+func (c *client) do(method, path string) (*http.Response, error) {
+	req, err := http.NewRequest(method, c.addr+path, nil)
+	if err != nil {
+		return nil, err
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 300 {
+		defer resp.Body.Close()
+		var body struct {
+			Error string `json:"error"`
+		}
+		json.NewDecoder(resp.Body).Decode(&body)
+		if body.Error == "" {
+			body.Error = resp.Status
+		}
+		return nil, fmt.Errorf("%s %s: %s", method, path, body.Error)
+	}
+	return resp, nil
+}
+
+//Decode the JSON answer to a request into v.
+func (c *client) json(method, path string, v interface{}) error {
+	resp, err := c.do(method, path)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return json.NewDecoder(resp.Body).Decode(v)
+}
@@ -0,0 +1,73 @@
+// goforever - processes management
+// Copyright (c) 2013 Garrett Woodworth (https://github.com/gwoo).
+
+package main
+
+import (
+	"fmt"
+)
+
+//Completes subcommands, and process names for logs, status and exec by
+//asking the running supervisor (with $PROCESS_TOKEN).
+const bashCompletion = `_process() {
+	local cur=${COMP_WORDS[COMP_CWORD]}
+	if [ "$COMP_CWORD" -eq 1 ]; then
+		COMPREPLY=($(compgen -W "run logs status exec completion context where drain freeze drift" -- "$cur"))
+		return
+	fi
+	case ${COMP_WORDS[1]} in
+	logs|status|exec|where|freeze)
+		case $cur in
+		-*) ;;
+		*) COMPREPLY=($(compgen -W "$(process status -o name 2>/dev/null)" -- "$cur")) ;;
+		esac
+		;;
+	completion)
+		COMPREPLY=($(compgen -W "bash zsh" -- "$cur"))
+		;;
+	context)
+		COMPREPLY=($(compgen -W "list use set delete" -- "$cur"))
+		;;
+	esac
+}
+complete -o default -F _process process
+`
+
+const zshCompletion = `#compdef process
+
+_process() {
+	if (( CURRENT == 2 )); then
+		compadd run logs status exec completion context where drain freeze drift
+		return
+	fi
+	case $words[2] in
+	logs|status|exec|where|freeze)
+		compadd -- ${(f)"$(process status -o name 2>/dev/null)"}
+		;;
+	completion)
+		compadd bash zsh
+		;;
+	context)
+		compadd list use set delete
+		;;
+	esac
+}
+
+compdef _process process
+`
+
+//Print the completion script of a shell.
+func completion(args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: process completion bash|zsh")
+	}
+	switch args[0] {
+	case "bash":
+		fmt.Print(bashCompletion)
+	case "zsh":
+		fmt.Print(zshCompletion)
+	default:
+		return fmt.Errorf("Unknown shell %q.", args[0])
+	}
+	return nil
+}
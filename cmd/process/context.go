@@ -0,0 +1,163 @@
+// goforever - processes management
+// Copyright (c) 2013 Garrett Woodworth (https://github.com/gwoo).
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+//Supervisors the CLI knows by name, like kubectl contexts.
+type contexts struct {
+	Current  string               `json:"current,omitempty"`
+	Contexts map[string]*endpoint `json:"contexts"`
+}
+
+//Address and credentials of a context.
+type endpoint struct {
+	Addr  string `json:"addr"`
+	Token string `json:"token,omitempty"`
+	CA    string `json:"ca,omitempty"`
+}
+
+//$PROCESS_CONTEXTS, default ~/.config/process/contexts.json.
+func contextsPath() string {
+	if path := os.Getenv("PROCESS_CONTEXTS"); path != "" {
+		return path
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		dir = "."
+	}
+	return filepath.Join(dir, "process", "contexts.json")
+}
+
+//Read the contexts, none when the file does not exist.
+func loadContexts() (*contexts, error) {
+	cs := &contexts{Contexts: map[string]*endpoint{}}
+	data, err := ioutil.ReadFile(contextsPath())
+	if os.IsNotExist(err) {
+		return cs, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, cs); err != nil {
+		return nil, fmt.Errorf("%s: %s", contextsPath(), err)
+	}
+	if cs.Contexts == nil {
+		cs.Contexts = map[string]*endpoint{}
+	}
+	return cs, nil
+}
+
+func (cs *contexts) save() error {
+	path := contextsPath()
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	data, _ := json.MarshalIndent(cs, "", "  ")
+	return ioutil.WriteFile(path, append(data, '\n'), 0600)
+}
+
+func (cs *contexts) names() []string {
+	var names []string
+	for name := range cs.Contexts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+//Contexts selected by a -context value: comma separated names, "all", or
+//the current context when empty.
+func (cs *contexts) pick(sel string) ([]string, error) {
+	switch sel {
+	case "":
+		if cs.Current == "" {
+			return nil, nil
+		}
+		sel = cs.Current
+	case "all":
+		return cs.names(), nil
+	}
+	names := strings.Split(sel, ",")
+	for _, name := range names {
+		if cs.Contexts[name] == nil {
+			return nil, fmt.Errorf("Unknown context %q.", name)
+		}
+	}
+	return names, nil
+}
+
+//Manage contexts:
+//
+//	process context                       list them
+//	process context use name              make name the current one
+//	process context set name -addr url [-token t] [-ca file]
+//	process context delete name
+func contextCmd(args []string) error {
+	cs, err := loadContexts()
+	if err != nil {
+		return err
+	}
+	if len(args) == 0 || args[0] == "list" {
+		tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "CURRENT\tNAME\tADDR")
+		for _, name := range cs.names() {
+			current := ""
+			if name == cs.Current {
+				current = "*"
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%s\n", current, name, cs.Contexts[name].Addr)
+		}
+		return tw.Flush()
+	}
+	if len(args) < 2 {
+		return fmt.Errorf("usage: process context [list|use name|set name -addr url|delete name]")
+	}
+	name := args[1]
+	switch args[0] {
+	case "use":
+		if cs.Contexts[name] == nil {
+			return fmt.Errorf("Unknown context %q.", name)
+		}
+		cs.Current = name
+	case "set":
+		fs := flag.NewFlagSet("context set", flag.ExitOnError)
+		c := cs.Contexts[name]
+		if c == nil {
+			c = &endpoint{}
+		}
+		fs.StringVar(&c.Addr, "addr", c.Addr, "URL of the supervisor's control API")
+		fs.StringVar(&c.Token, "token", c.Token, "API token")
+		fs.StringVar(&c.CA, "ca", c.CA, "PEM CAs to verify an https -addr with")
+		fs.Parse(args[2:])
+		if c.Addr == "" {
+			return fmt.Errorf("A context needs an -addr.")
+		}
+		cs.Contexts[name] = c
+		if cs.Current == "" {
+			cs.Current = name
+		}
+	case "delete":
+		if cs.Contexts[name] == nil {
+			return fmt.Errorf("Unknown context %q.", name)
+		}
+		delete(cs.Contexts, name)
+		if cs.Current == name {
+			cs.Current = ""
+		}
+	default:
+		return fmt.Errorf("Unknown context command %q.", args[0])
+	}
+	return cs.save()
+}
@@ -0,0 +1,35 @@
+// goforever - processes management
+// Copyright (c) 2013 Garrett Woodworth (https://github.com/gwoo).
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net/url"
+)
+
+//Drain a cluster node, or undrain it with -undo.
+func drain(args []string) error {
+	fs := flag.NewFlagSet("drain", flag.ExitOnError)
+	target := clientFlags(fs)
+	undo := fs.Bool("undo", false, "let the node take singletons again")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		return fmt.Errorf("usage: process drain [-undo] node")
+	}
+	c, err := target.client()
+	if err != nil {
+		return err
+	}
+	action := "drain"
+	if *undo {
+		action = "undrain"
+	}
+	resp, err := c.do("POST", "/cluster/nodes/"+url.PathEscape(fs.Arg(0))+"/"+action)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
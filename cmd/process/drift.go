@@ -0,0 +1,59 @@
+// goforever - processes management
+// Copyright (c) 2013 Garrett Woodworth (https://github.com/gwoo).
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/jrossi/process"
+)
+
+//Print the binary each process runs against the one on disk, -outdated
+//only those differing.
+func drift(args []string) error {
+	fs := flag.NewFlagSet("drift", flag.ExitOnError)
+	target := clientFlags(fs)
+	outdated := fs.Bool("outdated", false, "only processes running an outdated binary")
+	fs.Parse(args)
+	c, err := target.client()
+	if err != nil {
+		return err
+	}
+	var drifts []process.Drift
+	if err := c.json("GET", "/drift", &drifts); err != nil {
+		return err
+	}
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "NAME\tRUNNING\tDISK\tOUTDATED")
+	for _, d := range drifts {
+		if *outdated && !d.Outdated {
+			continue
+		}
+		name := d.Process
+		if d.Namespace != "" {
+			name = d.Namespace + "/" + name
+		}
+		disk := d.Error
+		if d.Disk != nil {
+			disk = binary(*d.Disk)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%t\n", name, binary(d.Running), orDash(disk), d.Outdated)
+	}
+	return tw.Flush()
+}
+
+//Version and short hash of a binary, e.g. "v1.2.0 (3f2a9c1d)".
+func binary(b process.BinaryInfo) string {
+	sum := b.SHA256
+	if len(sum) > 8 {
+		sum = sum[:8]
+	}
+	if b.Version == "" {
+		return sum
+	}
+	return b.Version + " (" + sum + ")"
+}
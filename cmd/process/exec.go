@@ -0,0 +1,46 @@
+// goforever - processes management
+// Copyright (c) 2013 Garrett Woodworth (https://github.com/gwoo).
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+
+	"github.com/jrossi/process"
+)
+
+//Run a command in a managed process's environment, directory and user,
+//exiting with its exit code.
+func execIn(args []string) error {
+	fs := flag.NewFlagSet("exec", flag.ExitOnError)
+	target := clientFlags(fs)
+	fs.Parse(args)
+	rest := fs.Args()
+	if len(rest) > 1 && rest[1] == "--" {
+		rest = append(rest[:1], rest[2:]...)
+	}
+	if len(rest) < 2 {
+		return fmt.Errorf("usage: process exec name -- command [args...]")
+	}
+	c, err := target.client()
+	if err != nil {
+		return err
+	}
+	e := &process.ExecEnv{}
+	if err := c.json("GET", processPath(rest[0], "/exec", nil), e); err != nil {
+		return err
+	}
+	cmd := exec.Command(rest[1], rest[2:]...)
+	cmd.Env = e.Env
+	cmd.Dir = e.Dir
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	asUser(cmd, e.Uid, e.Gid)
+	err = cmd.Run()
+	if x, ok := err.(*exec.ExitError); ok {
+		os.Exit(x.ExitCode())
+	}
+	return err
+}
@@ -0,0 +1,13 @@
+// goforever - processes management
+// Copyright (c) 2013 Garrett Woodworth (https://github.com/gwoo).
+
+//go:build !unix
+
+package main
+
+import (
+	"os/exec"
+)
+
+//Commands run as the caller.
+func asUser(cmd *exec.Cmd, uid, gid int) {}
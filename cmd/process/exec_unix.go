@@ -0,0 +1,23 @@
+// goforever - processes management
+// Copyright (c) 2013 Garrett Woodworth (https://github.com/gwoo).
+
+//go:build unix
+
+package main
+
+import (
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+//Switch to the ids when running as root and they differ; otherwise the
+//command runs as the caller.
+func asUser(cmd *exec.Cmd, uid, gid int) {
+	if os.Getuid() != 0 || uid < 0 || (uid == 0 && gid == 0) {
+		return
+	}
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		Credential: &syscall.Credential{Uid: uint32(uid), Gid: uint32(gid)},
+	}
+}
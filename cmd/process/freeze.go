@@ -0,0 +1,34 @@
+// goforever - processes management
+// Copyright (c) 2013 Garrett Woodworth (https://github.com/gwoo).
+
+package main
+
+import (
+	"flag"
+	"fmt"
+)
+
+//Freeze a process, or thaw it with -undo.
+func freeze(args []string) error {
+	fs := flag.NewFlagSet("freeze", flag.ExitOnError)
+	target := clientFlags(fs)
+	undo := fs.Bool("undo", false, "let the process run again")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		return fmt.Errorf("usage: process freeze [-undo] name")
+	}
+	c, err := target.client()
+	if err != nil {
+		return err
+	}
+	action := "freeze"
+	if *undo {
+		action = "thaw"
+	}
+	resp, err := c.do("POST", processPath(fs.Arg(0), "/"+action, nil))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
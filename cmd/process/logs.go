@@ -0,0 +1,111 @@
+// goforever - processes management
+// Copyright (c) 2013 Garrett Woodworth (https://github.com/gwoo).
+
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strconv"
+	"sync"
+)
+
+//ANSI colors given to processes in turn.
+var colors = []string{"36", "33", "32", "35", "34", "91", "92", "93", "94", "95", "96"}
+
+//Print the ring sink output of processes, prefixed with their name,
+//interleaving both streams of all of them with -f.
+func logs(args []string) error {
+	fs := flag.NewFlagSet("logs", flag.ExitOnError)
+	target := clientFlags(fs)
+	f := fs.Bool("f", false, "follow the output")
+	n := fs.Int("n", 100, "lines of each stream to show first")
+	noColor := fs.Bool("no-color", !isTerminal(os.Stdout), "do not color the prefixes")
+	fs.Parse(args)
+	names := fs.Args()
+	if len(names) == 0 {
+		return fmt.Errorf("usage: process logs [-f] [-n lines] name...")
+	}
+	c, err := target.client()
+	if err != nil {
+		return err
+	}
+	width := 0
+	for _, name := range names {
+		if len(name) > width {
+			width = len(name)
+		}
+	}
+	out := &prefixWriter{w: os.Stdout}
+	var wg sync.WaitGroup
+	errs := make(chan error, 2*len(names))
+	for i, name := range names {
+		prefix := fmt.Sprintf("%-*s | ", width, name)
+		if !*noColor {
+			prefix = "\x1b[" + colors[i%len(colors)] + "m" + prefix + "\x1b[0m"
+		}
+		for _, stream := range []string{"stdout", "stderr"} {
+			q := url.Values{"stream": {stream}, "lines": {strconv.Itoa(*n)}}
+			if *f {
+				q.Set("follow", "1")
+				wg.Add(1)
+				go func(path, prefix string) {
+					defer wg.Done()
+					errs <- out.copy(c, path, prefix)
+				}(processPath(name, "/logs", q), prefix)
+				continue
+			}
+			var lines []string
+			if err := c.json("GET", processPath(name, "/logs", q), &lines); err != nil {
+				return err
+			}
+			for _, line := range lines {
+				out.line(prefix, line)
+			}
+		}
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//Serializes lines of concurrent streams.
+type prefixWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (p *prefixWriter) line(prefix, line string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	fmt.Fprintln(p.w, prefix+line)
+}
+
+//Print the lines of a followed stream until the supervisor closes it.
+func (p *prefixWriter) copy(c *client, path, prefix string) error {
+	resp, err := c.do("GET", path)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	s := bufio.NewScanner(resp.Body)
+	s.Buffer(make([]byte, 64*1024), 1024*1024)
+	for s.Scan() {
+		p.line(prefix, s.Text())
+	}
+	return s.Err()
+}
+
+func isTerminal(f *os.File) bool {
+	fi, err := f.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,183 @@
+// goforever - processes management
+// Copyright (c) 2013 Garrett Woodworth (https://github.com/gwoo).
+
+//Command process runs a supervisor from a JSON config and talks to a
+//running one over its control API.
+//
+//	process run -config processes.json [-listen 127.0.0.1:2224] [-auth tokens.json]
+//	    [-tls-cert cert.pem -tls-key key.pem [-tls-client-ca ca.pem]]
+//
+//	process logs [-f] [-n 100] web worker
+//	process status [-o table|wide|json|name] [web]
+//	process exec web -- env
+//	process completion bash|zsh
+//	process context set prod -addr https://prod:2224 -token t
+//	process status -context all
+//	process where web
+//	process drain node-2
+//
+//With -listen the control API and a dashboard (/ui/) are served.
+//SIGTERM and SIGINT shut the supervisor down, SIGUSR2 re-executes it in
+//place (after replacing the binary) without stopping the processes.
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/jrossi/process"
+)
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+	}
+	var err error
+	switch os.Args[1] {
+	case "run":
+		err = run(os.Args[2:])
+	case "logs":
+		err = logs(os.Args[2:])
+	case "status":
+		err = status(os.Args[2:])
+	case "exec":
+		err = execIn(os.Args[2:])
+	case "completion":
+		err = completion(os.Args[2:])
+	case "context":
+		err = contextCmd(os.Args[2:])
+	case "where":
+		err = where(os.Args[2:])
+	case "drain":
+		err = drain(os.Args[2:])
+	case "freeze":
+		err = freeze(os.Args[2:])
+	case "drift":
+		err = drift(os.Args[2:])
+	default:
+		usage()
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, `usage:
+  process run -config file [-listen addr] [-auth file] [-tls-cert file -tls-key file [-tls-client-ca file]]
+  process logs [-addr url] [-token token] [-f] [-n lines] name...
+  process status [-addr url] [-token token] [-o table|wide|json|name] [name...]
+  process exec [-addr url] [-token token] name -- command [args...]
+  process completion bash|zsh
+  process context [list|use name|set name -addr url [-token token] [-ca file]|delete name]
+  process where [-addr url] [-token token] name
+  process drain [-addr url] [-token token] [-undo] node
+  process freeze [-addr url] [-token token] [-undo] name
+  process drift [-addr url] [-token token] [-outdated]
+
+Commands talking to a supervisor take -addr or -context (comma separated
+names or all, default the current context). status shows every selected
+supervisor, the other commands need a single one.`)
+	os.Exit(2)
+}
+
+//Run the supervisor until SIGTERM or SIGINT, then shut it down.
+func run(args []string) error {
+	fs := flag.NewFlagSet("run", flag.ExitOnError)
+	config := fs.String("config", "processes.json", "config file")
+	listen := fs.String("listen", "", "address of the control API")
+	authFile := fs.String("auth", "", "JSON file of API tokens, {\"tokens\": {\"token\": \"read|operator\"}}")
+	cert := fs.String("tls-cert", "", "PEM certificate serving the API over TLS")
+	key := fs.String("tls-key", "", "PEM key of -tls-cert")
+	clientCA := fs.String("tls-client-ca", "", "PEM CAs required of client certificates")
+	cors := fs.String("cors", "", "comma separated origins allowed to call the API from a browser")
+	timeout := fs.Duration("shutdown-timeout", 30*time.Second, "time allowed for stopping processes")
+	detach := fs.Bool("detach", false, "leave processes running on exit, to be adopted by the next run")
+	debug := fs.Bool("debug", false, "serve the supervisor's pprof profiles and expvar under /debug/ in the API")
+	fs.Parse(args)
+	c, err := process.LoadConfig(*config)
+	if err != nil {
+		return err
+	}
+	m, err := c.Manager()
+	if err != nil {
+		return err
+	}
+	m.Detach = *detach
+	m.Debug = *debug
+	if c.Cluster != nil {
+		if err := m.JoinCluster(c.Cluster, nil); err != nil {
+			return err
+		}
+	}
+	if c.StatsD != nil {
+		if err := m.EmitStatsD(c.StatsD, nil); err != nil {
+			return err
+		}
+	}
+	if err := m.Restore(); err != nil {
+		return err
+	}
+	if err := m.Resume(); err != nil {
+		return err
+	}
+	var listeners []net.Listener
+	if *listen != "" {
+		var auth *process.Auth
+		if *authFile != "" {
+			if auth, err = loadAuth(*authFile); err != nil {
+				return err
+			}
+		}
+		l, err := process.Listen("tcp", *listen)
+		if err != nil {
+			return err
+		}
+		listeners = append(listeners, l)
+		var served net.Listener = l
+		if *cert != "" {
+			c, err := (&process.TLS{Cert: *cert, Key: *key, ClientCA: *clientCA}).Config()
+			if err != nil {
+				return err
+			}
+			served = tls.NewListener(l, c)
+		}
+		h := m.API(auth)
+		if *cors != "" {
+			h = process.CORS(strings.Split(*cors, ","), h)
+		}
+		go http.Serve(served, h)
+	}
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGTERM, os.Interrupt)
+	m.Run()
+	upgradeOn(m, listeners)
+	<-signals
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+	return m.Shutdown(ctx)
+}
+
+func loadAuth(path string) (*process.Auth, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	auth := &process.Auth{}
+	if err := json.Unmarshal(data, auth); err != nil {
+		return nil, err
+	}
+	return auth, nil
+}
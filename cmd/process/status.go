@@ -0,0 +1,221 @@
+// goforever - processes management
+// Copyright (c) 2013 Garrett Woodworth (https://github.com/gwoo).
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/jrossi/process"
+)
+
+//A process of one of the supervisors.
+type row struct {
+	//Context it was fetched from, when several are shown.
+	Context string `json:"context,omitempty"`
+	process.ProcessInfo
+}
+
+//Print the processes of the selected supervisors, all or those named.
+//Supervisors that cannot be reached are reported without hiding the
+//others.
+func status(args []string) error {
+	fs := flag.NewFlagSet("status", flag.ExitOnError)
+	target := clientFlags(fs)
+	output := fs.String("o", "table", "output format: table, wide, json or name")
+	fs.Parse(args)
+	switch *output {
+	case "table", "wide", "json", "name":
+	default:
+		return fmt.Errorf("Unknown output format %q.", *output)
+	}
+	clients, err := target.clients()
+	if err != nil {
+		return err
+	}
+	rows, err := fetch(clients)
+	if names := fs.Args(); len(names) > 0 {
+		var perr error
+		if rows, perr = pick(rows, names); err == nil {
+			err = perr
+		}
+	}
+	switch *output {
+	case "name":
+		for _, r := range rows {
+			fmt.Println(fullName(r.ProcessInfo))
+		}
+	case "json":
+		e := json.NewEncoder(os.Stdout)
+		e.SetIndent("", "  ")
+		e.Encode(rows)
+	default:
+		table(os.Stdout, rows, *output == "wide", len(clients) > 1)
+	}
+	return err
+}
+
+//Processes of all clients, in their order.
+func fetch(clients []*client) ([]row, error) {
+	results := make([][]process.ProcessInfo, len(clients))
+	errs := make([]error, len(clients))
+	var wg sync.WaitGroup
+	for i, c := range clients {
+		wg.Add(1)
+		go func(i int, c *client) {
+			defer wg.Done()
+			errs[i] = c.json("GET", "/processes", &results[i])
+		}(i, c)
+	}
+	wg.Wait()
+	rows := []row{}
+	var err error
+	for i, c := range clients {
+		if errs[i] != nil {
+			if c.name != "" {
+				errs[i] = fmt.Errorf("%s: %s", c.name, errs[i])
+			}
+			fmt.Fprintln(os.Stderr, errs[i])
+			err = errors.New("Some supervisors could not be reached.")
+			continue
+		}
+		name := ""
+		if len(clients) > 1 {
+			name = c.name
+		}
+		for _, info := range results[i] {
+			rows = append(rows, row{name, info})
+		}
+	}
+	if len(clients) == 1 && err != nil {
+		err = errs[0]
+	}
+	return rows, err
+}
+
+//Processes by name, as namespace/name for namespaced ones, in the order
+//given.
+func pick(rows []row, names []string) ([]row, error) {
+	picked := []row{}
+	for _, name := range names {
+		found := false
+		for _, r := range rows {
+			if fullName(r.ProcessInfo) == name {
+				picked = append(picked, r)
+				found = true
+			}
+		}
+		if !found {
+			return picked, fmt.Errorf("Unknown process %q.", name)
+		}
+	}
+	return picked, nil
+}
+
+func fullName(info process.ProcessInfo) string {
+	if info.Namespace != "" {
+		return info.Namespace + "/" + info.Name
+	}
+	return info.Name
+}
+
+func table(w io.Writer, rows []row, wide, contexts bool) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	header := "NAME\tSTATUS\tPID\tUPTIME\tRESTARTS\tCPU\tMEM"
+	if contexts {
+		header = "CONTEXT\t" + header
+	}
+	if wide {
+		header += "\tHEALTH\tREADY\tEXIT\tREASON\tPING\tCOMMAND"
+	}
+	fmt.Fprintln(tw, header)
+	for _, r := range rows {
+		info := r.ProcessInfo
+		line := fmt.Sprintf("%s\t%s\t%s\t%s\t%d\t%s\t%s", fullName(info), info.Status,
+			orDash(info.Pid), duration(info.Uptime), info.Respawns, cpu(info), bytes(info.Memory))
+		if contexts {
+			line = r.Context + "\t" + line
+		}
+		if wide {
+			exit := "-"
+			if info.LastExit != nil {
+				exit = strconv.Itoa(info.LastExit.Code)
+				if info.LastExit.Reason != "" {
+					exit = info.LastExit.Reason
+				}
+			}
+			health := info.Health
+			if health == "" {
+				health = "-"
+			}
+			line += fmt.Sprintf("\t%s\t%t\t%s\t%s\t%s\t%s", health, info.Ready, exit, orDash(info.Reason), orDash(info.Ping), info.Command)
+		}
+		fmt.Fprintln(tw, line)
+	}
+	return tw.Flush()
+}
+
+func orDash(v interface{}) string {
+	switch v := v.(type) {
+	case int:
+		if v > 0 {
+			return strconv.Itoa(v)
+		}
+	case string:
+		if v != "" {
+			return v
+		}
+	}
+	return "-"
+}
+
+//Largest two units of d, like 3d4h or 5m12s.
+func duration(d time.Duration) string {
+	if d <= 0 {
+		return "-"
+	}
+	s := int64(d / time.Second)
+	switch {
+	case s < 60:
+		return fmt.Sprintf("%ds", s)
+	case s < 3600:
+		return fmt.Sprintf("%dm%ds", s/60, s%60)
+	case s < 86400:
+		return fmt.Sprintf("%dh%dm", s/3600, s%3600/60)
+	}
+	return fmt.Sprintf("%dd%dh", s/86400, s%86400/3600)
+}
+
+//Average CPU use since the start, as ps reports it.
+func cpu(info process.ProcessInfo) string {
+	if info.Uptime <= 0 || info.CPUTime <= 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%.1f%%", 100*info.CPUTime.Seconds()/info.Uptime.Seconds())
+}
+
+func bytes(n uint64) string {
+	if n == 0 {
+		return "-"
+	}
+	units := []string{"B", "K", "M", "G", "T"}
+	v := float64(n)
+	i := 0
+	for v >= 1024 && i < len(units)-1 {
+		v /= 1024
+		i++
+	}
+	if i == 0 {
+		return fmt.Sprintf("%d%s", n, units[0])
+	}
+	return fmt.Sprintf("%.1f%s", v, units[i])
+}
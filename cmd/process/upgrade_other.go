@@ -0,0 +1,14 @@
+// goforever - processes management
+// Copyright (c) 2013 Garrett Woodworth (https://github.com/gwoo).
+
+//go:build !unix
+
+package main
+
+import (
+	"net"
+
+	"github.com/jrossi/process"
+)
+
+func upgradeOn(m *process.Manager, listeners []net.Listener) {}
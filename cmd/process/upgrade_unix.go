@@ -0,0 +1,32 @@
+// goforever - processes management
+// Copyright (c) 2013 Garrett Woodworth (https://github.com/gwoo).
+
+//go:build unix
+
+package main
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/jrossi/process"
+)
+
+//Re-exec the supervisor binary on SIGUSR2, handing over children and
+//listeners.
+func upgradeOn(m *process.Manager, listeners []net.Listener) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGUSR2)
+	go func() {
+		for range signals {
+			path, err := os.Executable()
+			if err == nil {
+				err = m.Upgrade(path, listeners...)
+			}
+			fmt.Fprintln(os.Stderr, "upgrade failed:", err)
+		}
+	}()
+}
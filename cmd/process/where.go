@@ -0,0 +1,42 @@
+// goforever - processes management
+// Copyright (c) 2013 Garrett Woodworth (https://github.com/gwoo).
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net/url"
+	"os"
+	"text/tabwriter"
+
+	"github.com/jrossi/process"
+)
+
+//Print the cluster nodes running a process.
+func where(args []string) error {
+	fs := flag.NewFlagSet("where", flag.ExitOnError)
+	target := clientFlags(fs)
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		return fmt.Errorf("usage: process where name")
+	}
+	c, err := target.client()
+	if err != nil {
+		return err
+	}
+	var placements []process.Placement
+	if err := c.json("GET", "/cluster/processes/"+url.PathEscape(fs.Arg(0)), &placements); err != nil {
+		return err
+	}
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "NODE\tSTATUS\tPID\tHEALTH\tREADY")
+	for _, p := range placements {
+		node := p.Node
+		if p.Stale {
+			node += " (stale)"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%t\n", node, orDash(p.Status), orDash(p.Pid), orDash(p.Health), p.Ready)
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,127 @@
+// goforever - processes management
+// Copyright (c) 2013 Garrett Woodworth (https://github.com/gwoo).
+
+package process
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+//Shell running Process.Shell scripts.
+var shellPath = "/bin/sh"
+
+//Command line of a process, built explicitly as either an argv executed
+//without a shell or a shell script. Arguments of an argv reach the process
+//as they are, so values taken from users cannot inject shell syntax; in a
+//script they are quoted.
+type CommandLine struct {
+	path   string
+	args   []string
+	argv0  string
+	script string
+}
+
+//Execute path with args, without a shell. A path without a slash is looked
+//up in PATH by Validate.
+func Exec(path string, args ...string) *CommandLine {
+	return &CommandLine{path: path, args: append([]string(nil), args...)}
+}
+
+//Run script with /bin/sh -c. The script itself is run as written, so it
+//must not contain untrusted input; pass that with Args.
+func Shell(script string) *CommandLine {
+	return &CommandLine{script: script}
+}
+
+//Append arguments: to the argv, or quoted to the script.
+func (c *CommandLine) Args(args ...string) *CommandLine {
+	if c.script == "" {
+		c.args = append(c.args, args...)
+		return c
+	}
+	for _, a := range args {
+		c.script += " " + shellQuote(a)
+	}
+	return c
+}
+
+//Set argv[0], which otherwise is the binary's base name.
+func (c *CommandLine) Argv0(name string) *CommandLine {
+	c.argv0 = name
+	return c
+}
+
+//Check that the binary exists and is executable, resolving a bare name in
+//PATH. A path that is not a file but looks like a shell command line is
+//reported as one.
+func (c *CommandLine) Validate() error {
+	if c.script != "" {
+		return nil
+	}
+	if c.path == "" {
+		return errors.New("Command is empty.")
+	}
+	resolved, err := exec.LookPath(c.path)
+	if err != nil {
+		if strings.ContainsAny(c.path, " \t|&;<>()$`\"'*?") {
+			return fmt.Errorf("%q is not a binary but looks like a shell command, use Shell.", c.path)
+		}
+		return err
+	}
+	if fi, err := os.Stat(resolved); err != nil || !fi.Mode().IsRegular() {
+		return fmt.Errorf("%s is not a regular file.", resolved)
+	}
+	c.path = resolved
+	return nil
+}
+
+//Validate and set the Command, Args, Argv0 and Shell of p.
+func (c *CommandLine) Apply(p *Process) error {
+	if err := c.Validate(); err != nil {
+		return err
+	}
+	p.Command, p.Args, p.Argv0, p.Shell = c.path, append([]string(nil), c.args...), c.argv0, c.script
+	return nil
+}
+
+//Command line for NewProcess, replacing its command argument.
+func WithCommand(c *CommandLine) Option {
+	return c.Apply
+}
+
+//Executable and start of the argv: Argv0 (default the binary's base name,
+//as a shell would set it) and Args for Command, or the shell with Argv0
+//(default the process name) and Args as the script's $0 and parameters.
+func (p *Process) commandLine() (string, *ArgsBuilder, error) {
+	if p.Shell != "" {
+		if p.Command != "" {
+			return "", nil, errors.New("Command and Shell are exclusive.")
+		}
+		name := p.Argv0
+		if name == "" {
+			name = p.Name
+		}
+		return shellPath, NewArgs("sh", "-c", p.Shell, name).Add(p.Args...), nil
+	}
+	name := p.Argv0
+	if name == "" {
+		name = filepath.Base(p.Command)
+	}
+	return p.Command, NewArgs(name).Add(p.Args...), nil
+}
+
+var shellSafe = regexp.MustCompile(`^[A-Za-z0-9_@%+=:,./-]+$`)
+
+//Quote s as one shell word.
+func shellQuote(s string) string {
+	if shellSafe.MatchString(s) {
+		return s
+	}
+	return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+}
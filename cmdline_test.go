@@ -0,0 +1,90 @@
+// goforever - processes management
+// Copyright (c) 2013 Garrett Woodworth (https://github.com/gwoo).
+
+package process
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCommandLine(t *testing.T) {
+	c := Exec("sh", "-c", "true")
+	if err := c.Validate(); err != nil || !filepath.IsAbs(c.path) {
+		t.Errorf("Expected sh resolved. Result %#v %#v\n", c.path, err)
+	}
+	if err := Exec("ls -la | grep x").Validate(); err == nil || !strings.Contains(err.Error(), "use Shell") {
+		t.Errorf("Expected a shell string rejected. Result %#v\n", err)
+	}
+	if err := Exec(os.TempDir()).Validate(); err == nil {
+		t.Error("Expected a directory rejected.")
+	}
+	if q := Shell("echo").Args("plain", "it's; rm -rf /").script; q != `echo plain 'it'\''s; rm -rf /'` {
+		t.Errorf("Unexpected quoting %s\n", q)
+	}
+
+	runner := NewFakeRunner()
+	p, err := NewProcess("web", "", WithCommand(Exec("sh", "-v").Argv0("web-worker")), WithRunner(runner))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.start(""); err != nil {
+		t.Fatal(err)
+	}
+	p.Stop()
+	if args := runner.Processes()[0].Cmd.Args; args[0] != "web-worker" || args[1] != "-v" {
+		t.Errorf("Expected argv0 set. Result %#v\n", args)
+	}
+	if _, err := NewProcess("web", "/bin/true", WithCommand(Shell("true")), func(p *Process) error {
+		p.Command = "/bin/true"
+		return nil
+	}); err == nil {
+		t.Error("Expected Command and Shell to be exclusive.")
+	}
+}
+
+func TestShell(t *testing.T) {
+	dir, err := ioutil.TempDir("", "process")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	out := filepath.Join(dir, "out")
+	p, err := NewProcess("script", "", WithCommand(Shell(`printf '%s|' "$0" "$@" >`).Args(out, "; touch pwned")),
+		WithArgs("positional"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.start(""); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, func() bool { data, _ := ioutil.ReadFile(out); return len(data) > 0 })
+	p.Stop()
+	if data, _ := ioutil.ReadFile(out); string(data) != "script|positional|; touch pwned|" {
+		t.Errorf("Unexpected output %#v\n", string(data))
+	}
+}
+
+func TestArgv0(t *testing.T) {
+	runner := NewFakeRunner()
+	p := &Process{Command: "/usr/bin/gunicorn", Args: []string{"app:wsgi"}, Runner: runner}
+	if err := p.start("web"); err != nil {
+		t.Fatal(err)
+	}
+	p.Stop()
+	p.Argv0 = "sh"
+	p.Command = "/bin/busybox"
+	if err := p.start("web"); err != nil {
+		t.Fatal(err)
+	}
+	p.Stop()
+	procs := runner.Processes()
+	for i, expected := range []string{"gunicorn app:wsgi", "sh app:wsgi"} {
+		if args := strings.Join(procs[i].Cmd.Args, " "); args != expected {
+			t.Errorf("Expected %#v. Result %#v\n", expected, args)
+		}
+	}
+}
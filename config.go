@@ -0,0 +1,161 @@
+// goforever - processes management
+// Copyright (c) 2013 Garrett Woodworth (https://github.com/gwoo).
+
+package process
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"strings"
+)
+
+//Supervisor configuration, loaded from JSON.
+type Config struct {
+	Defaults  Template            `json:"defaults"`
+	Processes map[string]*Process `json:"processes"`
+	//Peers polled once the manager joins, see Manager.JoinCluster.
+	Cluster *Cluster `json:"cluster,omitempty"`
+	//Agent the metrics are pushed to, see Manager.EmitStatsD.
+	StatsD *StatsD `json:"statsd,omitempty"`
+	//Directory of the pidfiles of processes without one, see
+	//Manager.RunDir. Processes still get Defaults.Pidfile first.
+	RunDir string `json:"run_dir,omitempty"`
+	//See Manager.EventLog.
+	EventLog string `json:"event_log,omitempty"`
+	//Directory of a FileStore kept by the manager, see Manager.Store.
+	Store string `json:"store,omitempty"`
+	//See Manager.Webhooks.
+	Webhooks map[string]*Webhook `json:"webhooks,omitempty"`
+}
+
+//Fields inherited by processes that leave them empty. Logfile, Errfile and
+//Pidfile are patterns where {name} is replaced with the process name, e.g.
+//"/var/log/app/{name}.log". Env entries are merged by key with the process
+//entries winning. A process sets respawn -1 to never respawn whatever the
+//template's Respawn.
+type Template struct {
+	Env      []string `json:"env,omitempty"`
+	Logfile  string   `json:"logfile,omitempty"`
+	Errfile  string   `json:"errfile,omitempty"`
+	Pidfile  string   `json:"pidfile,omitempty"`
+	Path     string   `json:"path,omitempty"`
+	Respawn  int      `json:"respawn,omitempty"`
+	Delay    string   `json:"delay,omitempty"`
+	Ping     string   `json:"ping,omitempty"`
+	LogLevel string   `json:"log_level,omitempty"`
+}
+
+//Load a config file.
+func LoadConfig(path string) (*Config, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	c := &Config{}
+	if err := json.Unmarshal(data, c); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+//Create a manager with every configured process, defaults applied. A
+//process with Instances > 1 is added as name-1, name-2, ...
+func (c *Config) Manager() (*Manager, error) {
+	m := NewManager()
+	for name, p := range c.Processes {
+		if p.Instances > 1 {
+			for i := 0; i < p.Instances; i++ {
+				inst := p.clone()
+				n, err := m.AddInstance(name, inst)
+				if err != nil {
+					return nil, err
+				}
+				c.Defaults.Apply(n, inst)
+			}
+			continue
+		}
+		c.Defaults.Apply(name, p)
+		if err := m.Add(name, p); err != nil {
+			return nil, err
+		}
+	}
+	//Set after adding so that Defaults.Pidfile comes first.
+	m.mu.Lock()
+	m.RunDir = c.RunDir
+	m.EventLog = c.EventLog
+	m.Webhooks = c.Webhooks
+	for name, p := range m.procs {
+		m.defaultPidfile(name, p)
+	}
+	m.mu.Unlock()
+	if _, err := m.order(); err != nil {
+		return nil, err
+	}
+	if c.Store != "" {
+		s, err := NewFileStore(c.Store)
+		if err != nil {
+			return nil, err
+		}
+		m.Store = s
+	}
+	return m, nil
+}
+
+//Fill the empty fields of p from the template. {name} is also expanded
+//in the process's own Logfile, Errfile and Pidfile.
+func (t *Template) Apply(name string, p *Process) {
+	expand := func(pattern string) string {
+		return strings.Replace(pattern, "{name}", name, -1)
+	}
+	p.Env = mergeEnv(t.Env, p.Env)
+	if p.Logfile == "" {
+		p.Logfile = t.Logfile
+	}
+	if p.Errfile == "" {
+		p.Errfile = t.Errfile
+	}
+	if p.Pidfile == "" {
+		p.Pidfile = Pidfile(t.Pidfile)
+	}
+	p.Logfile = expand(p.Logfile)
+	p.Errfile = expand(p.Errfile)
+	p.Pidfile = Pidfile(expand(string(p.Pidfile)))
+	if p.Path == "" {
+		p.Path = t.Path
+	}
+	if p.Respawn < 0 {
+		p.Respawn = 0
+	} else if p.Respawn == 0 {
+		p.Respawn = t.Respawn
+	}
+	if p.Delay == "" {
+		p.Delay = t.Delay
+	}
+	if p.Ping == "" {
+		p.Ping = t.Ping
+	}
+	if p.LogLevel == "" {
+		p.LogLevel = t.LogLevel
+	}
+}
+
+//Merge KEY=VALUE lists, later entries replacing earlier ones with the same key.
+func mergeEnv(lists ...[]string) []string {
+	var env []string
+	index := map[string]int{}
+	for _, list := range lists {
+		for _, kv := range list {
+			key := kv
+			if i := strings.Index(kv, "="); i >= 0 {
+				key = kv[:i]
+			}
+			if i, ok := index[key]; ok {
+				env[i] = kv
+				continue
+			}
+			index[key] = len(env)
+			env = append(env, kv)
+		}
+	}
+	return env
+}
@@ -0,0 +1,75 @@
+// goforever - processes management
+// Copyright (c) 2013 Garrett Woodworth (https://github.com/gwoo).
+
+package process
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestLoadConfigDefaults(t *testing.T) {
+	dir, err := ioutil.TempDir("", "process")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "config.json")
+	data := `{
+		"defaults": {"env": ["MODE=prod", "DEBUG=0"], "logfile": "logs/{name}.log", "respawn": 3},
+		"processes": {
+			"web": {"command": "/bin/web", "env": ["DEBUG=1"]},
+			"worker": {"command": "/bin/worker", "logfile": "worker.log", "respawn": 1},
+			"once": {"command": "/bin/once", "respawn": -1}
+		}
+	}`
+	if err := ioutil.WriteFile(path, []byte(data), 0660); err != nil {
+		t.Fatal(err)
+	}
+	c, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("Error: %s.", err)
+	}
+	m, err := c.Manager()
+	if err != nil {
+		t.Fatalf("Error: %s.", err)
+	}
+	web := m.Get("web")
+	if ex := []string{"MODE=prod", "DEBUG=1"}; !reflect.DeepEqual(ex, web.Env) {
+		t.Errorf("Expected %#v. Result %#v\n", ex, web.Env)
+	}
+	if web.Logfile != "logs/web.log" || web.Respawn != 3 || web.Name != "web" {
+		t.Errorf("Defaults not applied: %s\n", web)
+	}
+	worker := m.Get("worker")
+	if worker.Logfile != "worker.log" || worker.Respawn != 1 {
+		t.Errorf("Defaults overrode process fields: %s\n", worker)
+	}
+	if once := m.Get("once"); once.Respawn != 0 {
+		t.Errorf("Expected no respawns. Result %d\n", once.Respawn)
+	}
+}
+
+func TestConfigRunDir(t *testing.T) {
+	c := &Config{
+		RunDir:    "/run/goforever",
+		Defaults:  Template{},
+		Processes: map[string]*Process{"web": {Command: "/usr/bin/web", Instances: 2}, "db": {Command: "/usr/bin/db"}},
+	}
+	m, err := c.Manager()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p := m.Get("web-2").Pidfile; p != "/run/goforever/web-2.pid" {
+		t.Errorf("Expected %#v. Result %#v\n", "/run/goforever/web-2.pid", p)
+	}
+	c.Defaults.Pidfile = "/var/run/{name}.pid"
+	c.Processes = map[string]*Process{"web": {Command: "/usr/bin/web", Instances: 2}}
+	m, _ = c.Manager()
+	if p := m.Get("web-1").Pidfile; p != "/var/run/web-1.pid" {
+		t.Errorf("Expected the template pidfile first. Result %#v\n", p)
+	}
+}
@@ -0,0 +1,101 @@
+// goforever - processes management
+// Copyright (c) 2013 Garrett Woodworth (https://github.com/gwoo).
+
+package process
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+)
+
+//Run the process as a container of Image, through the CLI of a container
+//runtime kept in the foreground: docker (default), podman, or nerdctl for
+//containerd. The CLI forwards signals and the container's output and exit
+//status, so restarts, health probes and logs work as for host processes.
+//Command and Args, when set, replace the image's command. Env entries that
+//differ from the supervisor's environment are passed with -e.
+type Container struct {
+	Runtime string `json:"runtime,omitempty"`
+	Image   string `json:"image"`
+	//Container name, process-<name> by default. A leftover container of
+	//that name is removed before each start.
+	Name string `json:"name,omitempty"`
+	//Further options of the runtime's run command, e.g. "-p", "8080:80".
+	Options []string `json:"options,omitempty"`
+}
+
+//Runner starting the CLI of a Container.
+type containerRunner struct {
+	c    *Container
+	name string
+}
+
+func (c *Container) runner(process string) *containerRunner {
+	name := c.Name
+	if name == "" {
+		name = "process-" + process
+	}
+	return &containerRunner{c: c, name: name}
+}
+
+func (r *containerRunner) runtime() string {
+	if r.c.Runtime == "" {
+		return "docker"
+	}
+	return r.c.Runtime
+}
+
+//Arguments of the run command of cmd.
+func (r *containerRunner) args(cmd *Cmd) []string {
+	args := []string{r.runtime(), "run", "--rm", "--name", r.name}
+	for _, kv := range ownEnv(cmd.Env) {
+		args = append(args, "-e", kv)
+	}
+	args = append(args, r.c.Options...)
+	args = append(args, r.c.Image)
+	if cmd.Path != "" {
+		args = append(args, cmd.Path)
+	}
+	if len(cmd.Args) > 1 {
+		args = append(args, cmd.Args[1:]...)
+	}
+	return args
+}
+
+func (r *containerRunner) Start(cmd *Cmd) (Handle, error) {
+	if r.c.Image == "" {
+		return nil, errors.New("A container needs an Image.")
+	}
+	for _, f := range cmd.Files[3:] {
+		if f != nil {
+			return nil, errors.New("Containers cannot inherit Sockets.")
+		}
+	}
+	path, err := exec.LookPath(r.runtime())
+	if err != nil {
+		return nil, fmt.Errorf("container runtime: %s", err)
+	}
+	exec.Command(path, "rm", "-f", r.name).Run()
+	h, err := ExecRunner.Start(&Cmd{Path: path, Args: r.args(cmd), Env: cmd.Env, Dir: cmd.Dir, Files: cmd.Files[:3]})
+	if err != nil {
+		return nil, err
+	}
+	return &containerHandle{Handle: h, path: path, name: r.name}, nil
+}
+
+//Handle of the runtime CLI. Killing the CLI alone would leave the container
+//running, so KILL goes to the container first.
+type containerHandle struct {
+	Handle
+	path string
+	name string
+}
+
+func (h *containerHandle) Signal(sig os.Signal) error {
+	if sig == os.Kill {
+		exec.Command(h.path, "kill", h.name).Run()
+	}
+	return h.Handle.Signal(sig)
+}
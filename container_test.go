@@ -0,0 +1,50 @@
+// goforever - processes management
+// Copyright (c) 2013 Garrett Woodworth (https://github.com/gwoo).
+
+package process
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestContainer(t *testing.T) {
+	dir := t.TempDir()
+	log, runtime := filepath.Join(dir, "log"), filepath.Join(dir, "docker")
+	script := "#!/bin/sh\necho \"$@\" >> " + log + "\n[ \"$1\" = run ] && exec sleep 10\nexit 0\n"
+	ioutil.WriteFile(runtime, []byte(script), 0755)
+	p := &Process{
+		Command:   "nginx",
+		Args:      []string{"-g", "daemon off;"},
+		Env:       []string{"FOO=bar"},
+		Ping:      "1h",
+		Container: &Container{Runtime: runtime, Image: "nginx:1", Options: []string{"-p", "8080:80"}},
+	}
+	if _, err := RunProcess("web", p); err != nil {
+		t.Fatal(err)
+	}
+	lines := func() []string {
+		data, _ := ioutil.ReadFile(log)
+		return strings.Split(strings.TrimSpace(string(data)), "\n")
+	}
+	waitFor(t, func() bool { return len(lines()) == 2 })
+	ex := []string{"rm -f process-web", "run --rm --name process-web -e FOO=bar -p 8080:80 nginx:1 nginx -g daemon off;"}
+	if r := lines(); r[0] != ex[0] || r[1] != ex[1] {
+		t.Errorf("Expected %#v. Result %#v\n", ex, r)
+	}
+	p.mu.Lock()
+	x := p.x
+	p.mu.Unlock()
+	x.Signal(os.Kill)
+	waitFor(t, func() bool { return p.CurrentPid() == 0 })
+	if r := lines(); len(r) != 3 || r[2] != "kill process-web" {
+		t.Errorf("Expected the container killed. Result %#v\n", r)
+	}
+	p = &Process{Container: &Container{Runtime: runtime}}
+	if _, err := RunProcess("noimage", p); err == nil {
+		t.Error("Expected a container without an image to fail.")
+	}
+}
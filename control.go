@@ -0,0 +1,105 @@
+// goforever - processes management
+// Copyright (c) 2013 Garrett Woodworth (https://github.com/gwoo).
+
+package process
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/rpc"
+	"net/rpc/jsonrpc"
+	"os"
+	"time"
+)
+
+//Variable naming the child's descriptor of its control channel, a unix
+//stream socket to the supervisor. The child serves JSON-RPC
+//(net/rpc/jsonrpc) on it with the methods Control.Reload, Control.Stats
+//and Control.Stop; github.com/jrossi/process/child does this for Go
+//programs.
+const ControlEnv = "PROCESS_CONTROL_FD"
+
+var ErrNoControl = errors.New("Process has no control channel.")
+
+//Open a control channel for the next start, the child's end first.
+func (p *Process) openControl() (*os.File, *os.File, error) {
+	if !p.Control {
+		return nil, nil, nil
+	}
+	child, parent, err := socketPair()
+	if err != nil {
+		return nil, nil, fmt.Errorf("control: %s", err)
+	}
+	return child, parent, nil
+}
+
+//Keep the supervisor's end of the control channel of a started child.
+func (p *Process) keepControl(parent *os.File) {
+	if parent == nil {
+		return
+	}
+	conn, err := net.FileConn(parent)
+	parent.Close()
+	if err != nil {
+		p.log(LevelError, "control channel failed", Fields{"error": err})
+		return
+	}
+	p.mu.Lock()
+	p.control = jsonrpc.NewClient(conn)
+	p.mu.Unlock()
+}
+
+//Close the control channel. Called with p.mu held.
+func (p *Process) closeControl() {
+	if p.control != nil {
+		p.control.Close()
+		p.control = nil
+	}
+}
+
+//Call a method of the child's control server, waiting up to timeout for
+//the reply.
+func (p *Process) call(method string, reply interface{}, timeout time.Duration) error {
+	p.mu.Lock()
+	c := p.control
+	p.mu.Unlock()
+	if c == nil {
+		return ErrNoControl
+	}
+	call := c.Go("Control."+method, struct{}{}, reply, make(chan *rpc.Call, 1))
+	select {
+	case <-call.Done:
+		return call.Error
+	case <-p.clock().After(timeout):
+		return fmt.Errorf("No reply to Control.%s within %s.", method, timeout)
+	}
+}
+
+//Statistics the process reports over its control channel.
+func (p *Process) Stats() (map[string]interface{}, error) {
+	stats := map[string]interface{}{}
+	if err := p.call("Stats", &stats, 5*time.Second); err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
+
+//Ask the process to stop over its control channel. False if it has none or
+//refused, in which case it should be signalled instead.
+func (p *Process) controlStop() bool {
+	if !p.hasControl() {
+		return false
+	}
+	if err := p.call("Stop", &struct{}{}, 5*time.Second); err != nil {
+		p.log(LevelWarn, "control stop failed", Fields{"error": err})
+		return false
+	}
+	return true
+}
+
+func (p *Process) hasControl() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.control != nil
+}
@@ -0,0 +1,15 @@
+// goforever - processes management
+// Copyright (c) 2013 Garrett Woodworth (https://github.com/gwoo).
+
+//go:build !unix
+
+package process
+
+import (
+	"errors"
+	"os"
+)
+
+func socketPair() (*os.File, *os.File, error) {
+	return nil, nil, errors.New("Control channels need a unix system.")
+}
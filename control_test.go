@@ -0,0 +1,88 @@
+// goforever - processes management
+// Copyright (c) 2013 Garrett Woodworth (https://github.com/gwoo).
+
+//go:build unix
+
+package process
+
+import (
+	"net"
+	"net/rpc"
+	"net/rpc/jsonrpc"
+	"sync"
+	"testing"
+)
+
+type fakeControl struct {
+	mu      sync.Mutex
+	reloads int
+	stops   int
+	proc    *FakeProcess
+}
+
+func (c *fakeControl) Reload(args *struct{}, reply *struct{}) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.reloads++
+	return nil
+}
+
+func (c *fakeControl) Stats(args *struct{}, reply *map[string]interface{}) error {
+	*reply = map[string]interface{}{"requests": 7}
+	return nil
+}
+
+func (c *fakeControl) Stop(args *struct{}, reply *struct{}) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.stops++
+	c.proc.Exit(0)
+	return nil
+}
+
+func TestControl(t *testing.T) {
+	c := &fakeControl{}
+	r := NewFakeRunner()
+	r.OnStart = func(proc *FakeProcess) {
+		c.proc = proc
+		found := false
+		for _, kv := range proc.Cmd.Env {
+			found = found || kv == ControlEnv+"=3"
+		}
+		if !found || len(proc.Cmd.Files) != 4 {
+			t.Errorf("Expected the control channel as descriptor 3.\n")
+			return
+		}
+		conn, err := net.FileConn(proc.Cmd.Files[3])
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		s := rpc.NewServer()
+		s.RegisterName("Control", c)
+		go s.ServeCodec(jsonrpc.NewServerCodec(conn))
+	}
+	p := &Process{Command: "web", Runner: r, Ping: "1h", Control: true}
+	if err := p.start("web"); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Reload(); err != nil {
+		t.Errorf("Expected %#v. Result %#v\n", nil, err)
+	}
+	stats, err := p.Stats()
+	if err != nil || stats["requests"] != 7.0 {
+		t.Errorf("Expected %#v. Result %#v %v\n", 7.0, stats["requests"], err)
+	}
+	p.Stop()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.reloads != 1 || c.stops != 1 {
+		t.Errorf("Expected a reload and a stop. Result %d %d\n", c.reloads, c.stops)
+	}
+	if sigs := c.proc.Signals(); len(sigs) != 0 {
+		t.Errorf("Expected no signals. Result %v\n", sigs)
+	}
+	if _, err := p.Stats(); err != ErrNoControl {
+		t.Errorf("Expected %#v. Result %#v\n", ErrNoControl, err)
+	}
+}
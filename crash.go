@@ -0,0 +1,203 @@
+// goforever - processes management
+// Copyright (c) 2013 Garrett Woodworth (https://github.com/gwoo).
+
+package process
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+//Collection of core dumps, stack dumps and crash reports into a crash
+//directory.
+type Crash struct {
+	//Directory the dumps are moved to, named <process>-<pid>-<time>.core
+	//or .stack, and crash reports are written to.
+	Dir string `json:"dir"`
+	//Where the kernel writes core files, with %p for the pid, relative to
+	//the working directory. Defaults to /proc/sys/kernel/core_pattern, or
+	//to /cores/core.%P on macOS. Cores piped to a program are not collected.
+	CorePattern string `json:"core_pattern,omitempty"`
+	//Signal asking a hung process for a stack dump before it is killed on
+	//stop timeout: QUIT for Go and Java, ABRT for a core dump.
+	DumpSignal string `json:"dump_signal,omitempty"`
+	//Wait after DumpSignal before killing. Defaults to 2s.
+	DumpWait string `json:"dump_wait,omitempty"`
+	//Dumps and crash reports kept per process, the oldest deleted first.
+	//Defaults to 5.
+	MaxFiles int `json:"max_files,omitempty"`
+	//Output lines in the CrashReport written when the process reaches its
+	//respawn limit. Defaults to 100.
+	ReportLines int `json:"report_lines,omitempty"`
+}
+
+//Signals accepted in DumpSignal.
+var dumpSignals = map[string]os.Signal{"QUIT": syscall.SIGQUIT, "ABRT": syscall.SIGABRT}
+
+//Glob matching the core files of pid.
+func (c *Crash) coreGlob(pid int) string {
+	pattern := c.CorePattern
+	if pattern == "" {
+		pattern = "core"
+		if runtime.GOOS == "darwin" {
+			pattern = "/cores/core.%P"
+		} else if data, err := ioutil.ReadFile("/proc/sys/kernel/core_pattern"); err == nil {
+			pattern = strings.TrimSpace(string(data))
+			if uses, _ := ioutil.ReadFile("/proc/sys/kernel/core_uses_pid"); strings.TrimSpace(string(uses)) == "1" && !strings.Contains(pattern, "%p") {
+				pattern += ".%p"
+			}
+		}
+	}
+	if strings.HasPrefix(pattern, "|") {
+		return ""
+	}
+	var glob strings.Builder
+	for i := 0; i < len(pattern); i++ {
+		if pattern[i] != '%' || i == len(pattern)-1 {
+			glob.WriteByte(pattern[i])
+			continue
+		}
+		i++
+		switch pattern[i] {
+		case 'p', 'P':
+			glob.WriteString(strconv.Itoa(pid))
+		case '%':
+			glob.WriteByte('%')
+		default:
+			glob.WriteByte('*')
+		}
+	}
+	if wd, err := os.Getwd(); err == nil && !filepath.IsAbs(glob.String()) {
+		return filepath.Join(wd, glob.String())
+	}
+	return glob.String()
+}
+
+//Name for a new dump of pid in the crash directory.
+func (p *Process) dumpPath(pid int, ext string) string {
+	now := p.clock().Now().UTC().Format("20060102-150405")
+	return filepath.Join(p.Crash.Dir, fmt.Sprintf("%s-%d-%s.%s", p.Name, pid, now, ext))
+}
+
+//Move the core file pid left into the crash directory. The new path, empty
+//if there was none.
+func (p *Process) collectCore(pid int) string {
+	glob := p.Crash.coreGlob(pid)
+	if glob == "" {
+		p.log(LevelWarn, "core dumps are piped, not collected", nil)
+		return ""
+	}
+	matches, _ := filepath.Glob(glob)
+	if len(matches) == 0 {
+		return ""
+	}
+	if err := os.MkdirAll(p.Crash.Dir, 0750); err != nil {
+		p.log(LevelError, "crash directory failed", Fields{"error": err})
+		return ""
+	}
+	dest := p.dumpPath(pid, "core")
+	if err := moveFile(matches[0], dest); err != nil {
+		p.log(LevelError, "core collection failed", Fields{"file": matches[0], "error": err})
+		return ""
+	}
+	p.log(LevelInfo, "core collected", Fields{"file": dest})
+	p.retainDumps()
+	return dest
+}
+
+//Send DumpSignal to a process that did not stop in time and save the
+//stderr lines it prints meanwhile, when they go to a ring sink.
+func (p *Process) dumpStack(x Handle, exited chan struct{}) {
+	sig, ok := dumpSignals[strings.TrimPrefix(strings.ToUpper(p.Crash.DumpSignal), "SIG")]
+	if !ok {
+		p.log(LevelWarn, "unknown dump signal", Fields{"signal": p.Crash.DumpSignal})
+		return
+	}
+	lines, stop := p.Follow("stderr")
+	defer stop()
+	if err := x.Signal(sig); err != nil {
+		p.log(LevelDebug, "dump signal failed", Fields{"error": err})
+		return
+	}
+	var dump []string
+	wait := p.clock().After(durationOr(p.Crash.DumpWait, 2*time.Second))
+	for done := false; !done; {
+		select {
+		case line := <-lines:
+			dump = append(dump, line)
+		case <-exited:
+			//Collect the lines still in the pipe.
+			exited = nil
+			wait = p.clock().After(100 * time.Millisecond)
+		case <-wait:
+			done = true
+		}
+	}
+	if len(dump) == 0 {
+		return
+	}
+	if err := os.MkdirAll(p.Crash.Dir, 0750); err != nil {
+		p.log(LevelError, "crash directory failed", Fields{"error": err})
+		return
+	}
+	dest := p.dumpPath(x.Pid(), "stack")
+	if err := ioutil.WriteFile(dest, []byte(strings.Join(dump, "\n")+"\n"), 0640); err != nil {
+		p.log(LevelError, "stack dump failed", Fields{"error": err})
+		return
+	}
+	p.log(LevelInfo, "stack dumped", Fields{"file": dest})
+	p.retainDumps()
+}
+
+//Delete the oldest dumps of the process beyond MaxFiles.
+func (p *Process) retainDumps() {
+	max := p.Crash.MaxFiles
+	if max <= 0 {
+		max = 5
+	}
+	matches, _ := filepath.Glob(filepath.Join(p.Crash.Dir, p.Name+"-*"))
+	//Names sort by time within a pid, not across pids.
+	sort.Slice(matches, func(i, j int) bool {
+		fi, erri := os.Stat(matches[i])
+		fj, errj := os.Stat(matches[j])
+		return erri == nil && errj == nil && fi.ModTime().Before(fj.ModTime())
+	})
+	for len(matches) > max {
+		os.Remove(matches[0])
+		matches = matches[1:]
+	}
+}
+
+//Rename, or copy and remove across devices.
+func moveFile(src, dest string) error {
+	if os.Rename(src, dest) == nil {
+		return nil
+	}
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dest, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0640)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		os.Remove(dest)
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	return os.Remove(src)
+}
@@ -0,0 +1,9 @@
+// goforever - processes management
+// Copyright (c) 2013 Garrett Woodworth (https://github.com/gwoo).
+
+//go:build !unix
+
+package process
+
+//Core dumps are a unix feature.
+func raiseCoreLimit() {}
@@ -0,0 +1,87 @@
+// goforever - processes management
+// Copyright (c) 2013 Garrett Woodworth (https://github.com/gwoo).
+
+package process
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestCoreGlob(t *testing.T) {
+	wd, _ := os.Getwd()
+	for pattern, expected := range map[string]string{
+		"core.%p":               filepath.Join(wd, "core.42"),
+		"/var/crash/%e.%p.%t":   "/var/crash/*.42.*",
+		"/cores/core.%P":        "/cores/core.42",
+		"/tmp/100%%-%p":         "/tmp/100%-42",
+		"|/usr/lib/coredump %p": "",
+	} {
+		if glob := (&Crash{CorePattern: pattern}).coreGlob(42); glob != expected {
+			t.Errorf("%s: expected %#v. Result %#v\n", pattern, expected, glob)
+		}
+	}
+}
+
+func TestCollectCore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "process")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	runner := NewFakeRunner()
+	crashes := filepath.Join(dir, "crashes")
+	p := &Process{Command: "web", Runner: runner, Crash: &Crash{Dir: crashes, CorePattern: filepath.Join(dir, "core.%p"), MaxFiles: 2}}
+	m := NewManager()
+	m.Add("web", p)
+	for i := 0; i < 3; i++ {
+		if err := p.start("web"); err != nil {
+			t.Fatal(err)
+		}
+		pid := p.Snapshot().Pid
+		ioutil.WriteFile(filepath.Join(dir, "core."+strconv.Itoa(pid)), []byte("core"), 0644)
+		go p.Watch()
+		runner.Processes()[i].Signal(os.Kill)
+		waitFor(t, func() bool { return p.Snapshot().Status == "fatal" })
+		exit := p.Snapshot().LastExit
+		if exit == nil || !strings.HasPrefix(exit.Core, filepath.Join(crashes, "web-"+strconv.Itoa(pid)+"-")) {
+			t.Errorf("Expected the core of %d collected. Result %#v\n", pid, exit)
+		}
+		m.ClearFatal("web")
+	}
+	//Each fatal exit also wrote a crash report.
+	if matches, _ := filepath.Glob(filepath.Join(crashes, "*")); len(matches) != 2 {
+		t.Errorf("Expected 2 dumps kept. Result %#v\n", matches)
+	}
+}
+
+func TestDumpStack(t *testing.T) {
+	dir, err := ioutil.TempDir("", "process")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	p := &Process{
+		Command:  "/bin/sh",
+		Args:     []string{"-c", "trap 'echo goroutine 1 >&2; exit 2' QUIT; trap '' TERM; echo ready; while :; do sleep 0.1; done"},
+		Output:   &Output{Sinks: []Sink{{Type: "ring"}}},
+		Timeouts: &Timeouts{Stop: "100ms"},
+		Crash:    &Crash{Dir: dir, DumpSignal: "QUIT"},
+	}
+	if err := p.start("hung"); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, func() bool { return len(p.Tail("stdout", 0)) > 0 })
+	p.Stop()
+	matches, _ := filepath.Glob(filepath.Join(dir, "hung-*.stack"))
+	if len(matches) != 1 {
+		t.Fatalf("Expected a stack dump. Result %#v\n", matches)
+	}
+	if data, _ := ioutil.ReadFile(matches[0]); string(data) != "goroutine 1\n" {
+		t.Errorf("Expected the dump saved. Result %#v\n", string(data))
+	}
+}
@@ -0,0 +1,25 @@
+// goforever - processes management
+// Copyright (c) 2013 Garrett Woodworth (https://github.com/gwoo).
+
+//go:build unix
+
+package process
+
+import (
+	"sync"
+	"syscall"
+)
+
+var coreLimit sync.Once
+
+//Raise the supervisor's core size limit to the hard limit, once, so that
+//the children it starts inherit it and can dump core.
+func raiseCoreLimit() {
+	coreLimit.Do(func() {
+		var l syscall.Rlimit
+		if syscall.Getrlimit(syscall.RLIMIT_CORE, &l) == nil && l.Cur < l.Max {
+			l.Cur = l.Max
+			syscall.Setrlimit(syscall.RLIMIT_CORE, &l)
+		}
+	})
+}
@@ -0,0 +1,127 @@
+// Process table, refreshed every two seconds, with the log tail of the
+// selected process and the latest events.
+(function () {
+  var token = document.getElementById("token");
+  var selected = null;
+  token.value = localStorage.getItem("process.token") || "";
+  token.onchange = function () {
+    localStorage.setItem("process.token", token.value);
+    refresh();
+  };
+
+  function api(method, path) {
+    var headers = {};
+    if (token.value) {
+      headers.Authorization = "Bearer " + token.value;
+    }
+    return fetch(path, {method: method, headers: headers}).then(function (r) {
+      return r.json().then(function (body) {
+        if (!r.ok) {
+          throw new Error(body.error || r.statusText);
+        }
+        return body;
+      });
+    });
+  }
+
+  function duration(ns) {
+    var s = Math.floor((ns || 0) / 1e9);
+    if (s < 60) return s + "s";
+    if (s < 3600) return Math.floor(s / 60) + "m";
+    if (s < 86400) return Math.floor(s / 3600) + "h";
+    return Math.floor(s / 86400) + "d";
+  }
+
+  function processPath(p, action) {
+    return "processes/" + encodeURIComponent(p.name) + "/" + action + query(p);
+  }
+
+  function query(p) {
+    return p.namespace ? "?namespace=" + encodeURIComponent(p.namespace) : "";
+  }
+
+  function cell(row, text, cls) {
+    var td = row.insertCell();
+    td.textContent = text;
+    if (cls) td.className = cls;
+    return td;
+  }
+
+  function button(td, p, action) {
+    var b = document.createElement("button");
+    b.textContent = action;
+    b.onclick = function (e) {
+      e.stopPropagation();
+      api("POST", processPath(p, action)).then(refresh, alert);
+    };
+    td.appendChild(b);
+  }
+
+  function render(procs) {
+    var body = document.getElementById("processes");
+    body.innerHTML = "";
+    procs.forEach(function (p) {
+      var name = (p.namespace ? p.namespace + "/" : "") + p.name;
+      var row = body.insertRow();
+      if (selected && selected.name === p.name && selected.namespace === p.namespace) {
+        row.className = "selected";
+      }
+      row.onclick = function () {
+        selected = p;
+        refresh();
+      };
+      cell(row, name);
+      cell(row, p.status || "", p.status);
+      cell(row, p.pid || "");
+      cell(row, p.pid ? duration(p.uptime) : "");
+      cell(row, p.respawns);
+      cell(row, p.health || "");
+      var actions = cell(row, "");
+      ["start", "stop", "restart"].forEach(function (a) { button(actions, p, a); });
+    });
+  }
+
+  function logs() {
+    var section = document.getElementById("logs");
+    if (!selected) {
+      section.hidden = true;
+      return Promise.resolve();
+    }
+    section.hidden = false;
+    document.getElementById("logs-name").textContent = selected.name;
+    return api("GET", processPath(selected, "logs")).then(function (lines) {
+      document.getElementById("logs-lines").textContent = (lines || []).join("\n");
+    });
+  }
+
+  function activity() {
+    return api("GET", "events?limit=20").then(function (events) {
+      var list = document.getElementById("events");
+      list.innerHTML = "";
+      events.reverse().forEach(function (e) {
+        var li = document.createElement("li");
+        var name = (e.namespace ? e.namespace + "/" : "") + e.process;
+        li.textContent = new Date(e.time).toLocaleTimeString() + " " + name + " " + e.type +
+          (e.reason ? ": " + e.reason : "");
+        li.className = e.type;
+        list.appendChild(li);
+      });
+    });
+  }
+
+  function refresh() {
+    fetch("healthz").then(function (r) { return r.json(); }).then(function (h) {
+      var el = document.getElementById("health");
+      el.textContent = h.status;
+      el.className = h.status;
+    });
+    api("GET", "processes").then(render).then(logs).then(activity).catch(function (e) {
+      var body = document.getElementById("processes");
+      body.innerHTML = "";
+      cell(body.insertRow(), e.message).colSpan = 7;
+    });
+  }
+
+  refresh();
+  setInterval(refresh, 2000);
+})();
@@ -0,0 +1,63 @@
+// goforever - processes management
+// Copyright (c) 2013 Garrett Woodworth (https://github.com/gwoo).
+
+package process
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestDashboard(t *testing.T) {
+	h := NewManager().API(&Auth{Tokens: map[string]string{"r": RoleRead}})
+	for path, want := range map[string]string{
+		"/ui/":       "<title>process</title>",
+		"/ui/app.js": "Bearer",
+	} {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest("GET", path, nil))
+		if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), want) {
+			t.Errorf("%s: expected 200 with %q. Result %d %q\n", path, want, rec.Code, rec.Body)
+		}
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+	if rec.Code != http.StatusFound || rec.Header().Get("Location") != "/ui/" {
+		t.Errorf("Expected %#v. Result %d %#v\n", "/ui/", rec.Code, rec.Header().Get("Location"))
+	}
+}
+
+func TestAPILogs(t *testing.T) {
+	m := NewManager()
+	p := &Process{Command: "/usr/bin/web"}
+	m.Add("web", p)
+	r := p.ring("stdout", 10)
+	r.Write([]byte("one\n"))
+	r.Write([]byte("two\n"))
+	rec := httptest.NewRecorder()
+	m.API(nil).ServeHTTP(rec, httptest.NewRequest("GET", "/processes/web/logs?lines=1", nil))
+	if result := strings.TrimSpace(rec.Body.String()); result != `["two"]` {
+		t.Errorf("Expected %#v. Result %#v\n", `["two"]`, result)
+	}
+}
+
+func TestCORS(t *testing.T) {
+	h := CORS([]string{"https://ops.example.com/"}, NewManager().API(nil))
+	req := httptest.NewRequest("OPTIONS", "/processes", nil)
+	req.Header.Set("Origin", "https://ops.example.com")
+	req.Header.Set("Access-Control-Request-Method", "POST")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusNoContent || rec.Header().Get("Access-Control-Allow-Origin") != "https://ops.example.com" {
+		t.Errorf("Expected a preflight answer. Result %d %#v\n", rec.Code, rec.Header())
+	}
+	req = httptest.NewRequest("GET", "/processes", nil)
+	req.Header.Set("Origin", "https://evil.example.com")
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if result := rec.Header().Get("Access-Control-Allow-Origin"); result != "" {
+		t.Errorf("Expected %#v. Result %#v\n", "", result)
+	}
+}
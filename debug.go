@@ -0,0 +1,41 @@
+// goforever - processes management
+// Copyright (c) 2013 Garrett Woodworth (https://github.com/gwoo).
+
+package process
+
+import (
+	"expvar"
+	"net/http"
+	"net/http/pprof"
+	"strings"
+)
+
+//Profiles and variables of the supervisor itself, served by the API when
+//Manager.Debug is set:
+//
+//	/debug/pprof/         net/http/pprof index and profiles
+//	/debug/vars           expvar
+//
+//Importing net/http/pprof and expvar also registers them on
+//http.DefaultServeMux, which the supervisor does not serve.
+func debugHandler() http.Handler {
+	vars := expvar.Handler()
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch name := strings.TrimPrefix(r.URL.Path, "/debug/pprof/"); {
+		case r.URL.Path == "/debug/vars":
+			vars.ServeHTTP(w, r)
+		case name == r.URL.Path:
+			http.NotFound(w, r)
+		case name == "cmdline":
+			pprof.Cmdline(w, r)
+		case name == "profile":
+			pprof.Profile(w, r)
+		case name == "symbol":
+			pprof.Symbol(w, r)
+		case name == "trace":
+			pprof.Trace(w, r)
+		default:
+			pprof.Index(w, r)
+		}
+	})
+}
@@ -0,0 +1,44 @@
+// goforever - processes management
+// Copyright (c) 2013 Garrett Woodworth (https://github.com/gwoo).
+
+package process
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestDebug(t *testing.T) {
+	m := NewManager()
+	h := m.API(&Auth{Tokens: map[string]string{"r": RoleRead, "o": RoleOperator}})
+	get := func(path, token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", path, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+	if rec := get("/debug/vars", "o"); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 without Debug. Result %d\n", rec.Code)
+	}
+	m.Debug = true
+	for _, c := range []struct {
+		path, token string
+		code        int
+		body        string
+	}{
+		{"/debug/vars", "r", http.StatusForbidden, ""},
+		{"/debug/vars", "o", http.StatusOK, "memstats"},
+		{"/debug/pprof/", "o", http.StatusOK, "goroutine"},
+		{"/debug/pprof/goroutine?debug=1", "o", http.StatusOK, "debugHandler"},
+		{"/debug/pprof/cmdline", "o", http.StatusOK, ""},
+		{"/debug/nope", "o", http.StatusNotFound, ""},
+	} {
+		rec := get(c.path, c.token)
+		if rec.Code != c.code || !strings.Contains(rec.Body.String(), c.body) {
+			t.Errorf("%s with %q: expected %d with %q. Result %d %.200s\n", c.path, c.token, c.code, c.body, rec.Code, rec.Body)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+// goforever - processes management
+// Copyright (c) 2013 Garrett Woodworth (https://github.com/gwoo).
+
+package process
+
+import (
+	"os"
+	"syscall"
+)
+
+//Let go of the child without stopping it, keeping its pidfile so that the
+//next supervisor adopts it. A child with piped Output loses its reader and
+//may die of SIGPIPE, so it is stopped instead.
+func (p *Process) detach() {
+	if p.Output != nil {
+		p.log(LevelWarn, "piped output, stopping instead of detaching", nil)
+		p.stop(nil)
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.Pid = 0
+	p.Status = "detached"
+}
+
+//Adopt the process in the pidfile, or found by Match, if it is running, as
+//left by a detached shutdown or started outside the supervisor. True if
+//adopted or already running after Resume.
+func (p *Process) adopt() bool {
+	p.mu.Lock()
+	resumed := p.x != nil && p.Pid > 0
+	p.mu.Unlock()
+	if resumed {
+		return true
+	}
+	if p.Pidfile == "" && p.Match == nil {
+		return false
+	}
+	pid, err := p.locate()
+	if err != nil {
+		p.log(LevelWarn, "cannot locate process", Fields{"error": err})
+		return false
+	}
+	if pid <= 0 || !alive(pid) {
+		return false
+	}
+	if _, _, err := p.Find(); err != nil {
+		return false
+	}
+	p.log(LevelInfo, "adopted", nil)
+	return true
+}
+
+//Whether pid exists and has not exited.
+func alive(pid int) bool {
+	process, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	return process.Signal(syscall.Signal(0)) == nil && !procZombie(pid)
+}
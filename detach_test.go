@@ -0,0 +1,51 @@
+// goforever - processes management
+// Copyright (c) 2013 Garrett Woodworth (https://github.com/gwoo).
+
+package process
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDetachAndAdopt(t *testing.T) {
+	dir, err := ioutil.TempDir("", "process")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	pidfile := Pidfile(filepath.Join(dir, "sleep.pid"))
+	m := NewManager()
+	m.Detach = true
+	m.Add("sleep", &Process{Command: "/bin/sleep", Args: []string{"10"}, Pidfile: pidfile, Ping: "1h"})
+	m.Run()
+	pid := pidfile.read()
+	if pid <= 0 {
+		t.Fatal("Expected a pidfile.")
+	}
+	defer func() {
+		if p, err := os.FindProcess(pid); err == nil {
+			p.Kill()
+		}
+	}()
+	if err := m.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if !alive(pid) || pidfile.read() != pid {
+		t.Fatal("Expected the child to keep running with its pidfile.")
+	}
+
+	next := NewManager()
+	p := &Process{Command: "/bin/sleep", Args: []string{"10"}, Pidfile: pidfile, Ping: "1h"}
+	next.Add("sleep", p)
+	next.Run()
+	if s := p.Snapshot(); s.Pid != pid || s.Status != "running" {
+		t.Errorf("Expected pid %d adopted. Result %#v\n", pid, s)
+	}
+	if err := next.Shutdown(context.Background()); err != nil || p.Snapshot().Status != "stopped" {
+		t.Errorf("Expected the adopted child stopped. Result %#v\n", err)
+	}
+}
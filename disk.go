@@ -0,0 +1,119 @@
+// goforever - processes management
+// Copyright (c) 2013 Garrett Woodworth (https://github.com/gwoo).
+
+package process
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+//What to do when a directory exceeds its DiskLimit. Every action logs a
+//warning and emits EventDisk.
+const (
+	DiskWarn = "warn"
+	//Rotate the piped log files and delete their archives, oldest first,
+	//until the directory is back under the limit.
+	DiskRotate = "rotate"
+	//Stop the process and mark it fatal.
+	DiskStop = "stop"
+)
+
+//Size limit on a directory, see Process.Disk.
+type DiskLimit struct {
+	Path string `json:"path"`
+	//Bytes of the files under Path.
+	Max int64 `json:"max"`
+	//warn (default), rotate or stop.
+	Action string `json:"action,omitempty"`
+}
+
+//Total size of the regular files under path.
+func dirSize(path string) (int64, error) {
+	var size int64
+	err := filepath.Walk(path, func(_ string, fi os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if fi.Mode().IsRegular() {
+			size += fi.Size()
+		}
+		return nil
+	})
+	return size, err
+}
+
+//Check the Disk limits once, acting on those exceeded.
+func (p *Process) checkDisk() {
+	for _, l := range p.Disk {
+		size, err := dirSize(l.Path)
+		if err != nil {
+			p.log(LevelWarn, "disk check failed", Fields{"path": l.Path, "error": err})
+			continue
+		}
+		if size <= l.Max {
+			continue
+		}
+		reason := fmt.Sprintf("%s holds %d bytes, over %d", l.Path, size, l.Max)
+		p.log(LevelWarn, "disk limit exceeded", Fields{"path": l.Path, "size": size, "max": l.Max, "action": l.Action})
+		p.emit(EventDisk, reason)
+		switch l.Action {
+		case DiskRotate:
+			p.shrinkLogs(size - l.Max)
+		case DiskStop:
+			p.stop(nil)
+			p.fatal(reason)
+		}
+	}
+}
+
+//Rotate the piped log files, then delete archives oldest first until
+//excess bytes are freed.
+func (p *Process) shrinkLogs(excess int64) {
+	p.mu.Lock()
+	logs := append([]*logFile(nil), p.logs...)
+	p.mu.Unlock()
+	for _, f := range logs {
+		f.mu.Lock()
+		if f.f != nil && f.size > 0 {
+			if err := f.rotate(); err != nil {
+				p.log(LevelError, "rotate failed", Fields{"file": f.path, "error": err})
+			}
+		}
+		f.mu.Unlock()
+	}
+	for _, a := range p.Archives() {
+		if excess <= 0 {
+			break
+		}
+		if err := os.Remove(a.path); err != nil {
+			p.log(LevelError, "remove archive failed", Fields{"file": a.path, "error": err})
+			continue
+		}
+		excess -= a.Size
+	}
+}
+
+//Check the Disk limits of every process each interval until done is
+//closed.
+func (m *Manager) WatchDisk(interval time.Duration, done <-chan struct{}) {
+	go func() {
+		for {
+			select {
+			case <-done:
+				return
+			case <-m.clock().After(interval):
+			}
+			for _, name := range m.Keys() {
+				if p := m.Get(name); p != nil && len(p.Disk) > 0 {
+					p.checkDisk()
+				}
+			}
+		}
+	}()
+}
@@ -0,0 +1,69 @@
+// goforever - processes management
+// Copyright (c) 2013 Garrett Woodworth (https://github.com/gwoo).
+
+package process
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestDiskLimits(t *testing.T) {
+	dir, err := ioutil.TempDir("", "process")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	logfile := filepath.Join(dir, "web.log")
+	day := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i, name := range []string{
+		logfile + "." + day.Format(archiveLayout),
+		logfile + "." + day.Add(time.Hour).Format(archiveLayout),
+		logfile,
+	} {
+		if err := ioutil.WriteFile(name, bytes.Repeat([]byte("x"), 100*(i+1)), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if size, err := dirSize(dir); err != nil || size != 600 {
+		t.Errorf("Expected 600. Result %#v %#v\n", size, err)
+	}
+	if size, err := dirSize(filepath.Join(dir, "missing")); err != nil || size != 0 {
+		t.Errorf("Expected 0 for a missing directory. Result %#v %#v\n", size, err)
+	}
+
+	m := NewManager()
+	var events []Event
+	m.OnEvent(func(e Event) { events = append(events, e) })
+	runner := NewFakeRunner()
+	p := &Process{Command: "web", Runner: runner, Logfile: logfile, Disk: []DiskLimit{{Path: dir, Max: 1000}}}
+	m.Add("web", p)
+	p.checkDisk()
+	if len(events) != 0 {
+		t.Errorf("Expected no events under the limit. Result %#v\n", events)
+	}
+	p.Disk[0].Max = 500
+	p.checkDisk()
+	if len(events) != 1 || events[0].Type != EventDisk {
+		t.Errorf("Expected a disk event. Result %#v\n", events)
+	}
+
+	p.Disk[0].Action = DiskRotate
+	p.checkDisk()
+	if archives := p.Archives(); len(archives) != 1 || archives[0].Size != 200 {
+		t.Errorf("Expected the oldest archive deleted. Result %#v\n", archives)
+	}
+
+	if err := p.start("web"); err != nil {
+		t.Fatal(err)
+	}
+	p.Disk[0] = DiskLimit{Path: dir, Max: 100, Action: DiskStop}
+	p.checkDisk()
+	if s := p.Snapshot(); s.Status != "fatal" || len(runner.Running()) != 0 {
+		t.Errorf("Expected the process stopped and fatal. Result %#v\n", s)
+	}
+}
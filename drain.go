@@ -0,0 +1,114 @@
+// goforever - processes management
+// Copyright (c) 2013 Garrett Woodworth (https://github.com/gwoo).
+
+package process
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+//Returned for cluster operations on a manager that has not joined one.
+var ErrNoCluster = errors.New("Not in a cluster.")
+
+//Returned for a node that is neither this one nor a known peer.
+var ErrUnknownNode = errors.New("Unknown node.")
+
+//Drain node for maintenance: it stops taking singleton locks, stops the
+//singletons it runs and releases their locks so that peers take over.
+//Other processes keep running. A peer is asked to drain over its API.
+func (m *Manager) Drain(ctx context.Context, node string) error {
+	return m.setDraining(ctx, node, true)
+}
+
+//Let a drained node take singleton locks again.
+func (m *Manager) Undrain(ctx context.Context, node string) error {
+	return m.setDraining(ctx, node, false)
+}
+
+func (m *Manager) setDraining(ctx context.Context, node string, drain bool) error {
+	m.mu.Lock()
+	s := m.cluster
+	m.mu.Unlock()
+	if s == nil {
+		return ErrNoCluster
+	}
+	if node != "" && node != s.node {
+		return s.forward(ctx, node, drain)
+	}
+	s.mu.Lock()
+	s.draining = drain
+	s.mu.Unlock()
+	if !drain || s.leases == nil {
+		return nil
+	}
+	l := s.leases
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	var errs []error
+	for name, held := range l.held {
+		if !held {
+			continue
+		}
+		if op, err := m.Stop(name); err == nil {
+			select {
+			case <-op.Done():
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+		if err := l.locker.Unlock(l.lock.key(name), s.node); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %s", name, err))
+		}
+		l.held[name] = false
+		m.Get(name).log(LevelInfo, "singleton released", Fields{"node": s.node})
+	}
+	return errors.Join(errs...)
+}
+
+//Whether this node is draining.
+func (s *clusterState) isDraining() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.draining
+}
+
+//Ask the peer named node to drain or undrain itself.
+func (s *clusterState) forward(ctx context.Context, node string, drain bool) error {
+	addr := ""
+	s.mu.Lock()
+	for _, n := range s.peers {
+		if n.Node == node {
+			addr = n.Addr
+		}
+	}
+	s.mu.Unlock()
+	if addr == "" {
+		return ErrUnknownNode
+	}
+	action := "undrain"
+	if drain {
+		action = "drain"
+	}
+	u := strings.TrimRight(addr, "/") + "/cluster/nodes/" + url.PathEscape(node) + "/" + action
+	req, err := http.NewRequestWithContext(ctx, "POST", u, nil)
+	if err != nil {
+		return err
+	}
+	if s.c.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+s.c.Token)
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s: %s", node, resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+// goforever - processes management
+// Copyright (c) 2013 Garrett Woodworth (https://github.com/gwoo).
+
+package process
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestDrain(t *testing.T) {
+	lock := &Lock{Type: "file", Path: t.TempDir(), TTL: "1m"}
+	auth := &Auth{Tokens: map[string]string{"peer": RoleOperator}}
+	a, b := NewManager(), NewManager()
+	for _, m := range []*Manager{a, b} {
+		m.Runner = NewFakeRunner()
+		m.Add("cron", &Process{Command: "/usr/bin/cron", Ping: "1h", Singleton: true})
+	}
+	sa := httptest.NewServer(a.API(auth))
+	defer sa.Close()
+	sb := httptest.NewServer(b.API(auth))
+	defer sb.Close()
+	done := make(chan struct{})
+	defer close(done)
+	a.JoinCluster(&Cluster{Node: "a", Peers: []string{sb.URL}, Token: "peer", Lock: lock, Interval: "10ms"}, done)
+	waitFor(t, func() bool {
+		return a.Get("cron").Snapshot().Pid > 0
+	})
+	b.JoinCluster(&Cluster{Node: "b", Peers: []string{sa.URL}, Token: "peer", Lock: lock, Interval: "10ms"}, done)
+	waitFor(t, func() bool {
+		nodes := b.Nodes()
+		return len(nodes) == 2 && nodes[1].Node == "a"
+	})
+	if err := b.Drain(context.Background(), "a"); err != nil {
+		t.Fatal(err)
+	}
+	if pid := a.Get("cron").Snapshot().Pid; pid != 0 {
+		t.Errorf("Expected the drained node to stop cron. Result pid %d\n", pid)
+	}
+	waitFor(t, func() bool {
+		return b.Get("cron").Snapshot().Pid > 0
+	})
+	if n := a.Nodes()[0]; !n.Draining {
+		t.Errorf("Expected a to report draining. Result %#v\n", n)
+	}
+	if err := b.Drain(context.Background(), "c"); err != ErrUnknownNode {
+		t.Errorf("Expected %#v. Result %#v\n", ErrUnknownNode, err)
+	}
+	if err := b.Undrain(context.Background(), "a"); err != nil || a.Nodes()[0].Draining {
+		t.Errorf("Expected a to be undrained. Result %v\n", err)
+	}
+	if err := NewManager().Drain(context.Background(), ""); err != ErrNoCluster {
+		t.Errorf("Expected %#v. Result %#v\n", ErrNoCluster, err)
+	}
+}
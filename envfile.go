@@ -0,0 +1,96 @@
+// goforever - processes management
+// Copyright (c) 2013 Garrett Woodworth (https://github.com/gwoo).
+
+package process
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"time"
+)
+
+//Read a KEY=VALUE env file. Blank lines, # comments and a leading "export "
+//are ignored, and values may be wrapped in single or double quotes.
+func ReadEnvFile(path string) ([]string, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var env []string
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+		i := strings.Index(line, "=")
+		if i < 1 {
+			return nil, fmt.Errorf("%s:%d: expected KEY=VALUE.", path, n)
+		}
+		key, value := strings.TrimSpace(line[:i]), strings.TrimSpace(line[i+1:])
+		if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+			value = value[1 : len(value)-1]
+		}
+		env = append(env, key+"="+value)
+	}
+	return env, scanner.Err()
+}
+
+//Environment for the child: EnvFile entries overridden by Env.
+func (p *Process) environ() ([]string, error) {
+	if p.EnvFile == "" {
+		return p.Env, nil
+	}
+	env, err := ReadEnvFile(p.EnvFile)
+	if err != nil {
+		return nil, err
+	}
+	return mergeEnv(env, p.Env), nil
+}
+
+//Hash of the EnvFile and Secrets contents. Missing files hash as empty so
+//that their appearance counts as a change.
+func (p *Process) sourcesHash() string {
+	h := sha256.New()
+	for _, path := range append([]string{p.EnvFile}, p.Secrets...) {
+		if path == "" {
+			continue
+		}
+		data, _ := ioutil.ReadFile(path)
+		fmt.Fprintf(h, "%s\x00%d\x00", path, len(data))
+		h.Write(data)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+//Poll the EnvFile and Secrets of processes with RestartOnChange every
+//interval, restarting the running ones whose sources changed since they
+//were started. Restarts happen one process at a time, see restartChanged,
+//and are recorded as restart events with the cause. Stops when done is
+//closed.
+func (m *Manager) WatchSources(interval time.Duration, done <-chan struct{}) {
+	go func() {
+		for {
+			select {
+			case <-done:
+				return
+			case <-m.clock().After(interval):
+			}
+			m.restartChanged(func(p *Process) bool {
+				if !p.RestartOnChange {
+					return false
+				}
+				p.mu.Lock()
+				hash, running := p.srcHash, p.Pid > 0
+				p.mu.Unlock()
+				return running && hash != p.sourcesHash()
+			}, "env or secret changed")
+		}
+	}()
+}
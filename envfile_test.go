@@ -0,0 +1,77 @@
+// goforever - processes management
+// Copyright (c) 2013 Garrett Woodworth (https://github.com/gwoo).
+
+package process
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestEnvFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "process")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "web.env")
+	data := "# comment\n\nexport DB=postgres\nTOKEN=\"a b\"\nMODE=prod\n"
+	if err := ioutil.WriteFile(path, []byte(data), 0660); err != nil {
+		t.Fatal(err)
+	}
+	p := &Process{EnvFile: path, Env: []string{"MODE=dev"}}
+	env, err := p.environ()
+	if err != nil {
+		t.Fatalf("Error: %s.", err)
+	}
+	ex := []string{"DB=postgres", "TOKEN=a b", "MODE=dev"}
+	if !reflect.DeepEqual(ex, env) {
+		t.Errorf("Expected %#v. Result %#v\n", ex, env)
+	}
+
+	before := p.sourcesHash()
+	if before != p.sourcesHash() {
+		t.Error("Expected stable hash.")
+	}
+	ioutil.WriteFile(path, []byte("DB=mysql\n"), 0660)
+	if before == p.sourcesHash() {
+		t.Error("Expected hash to change with the env file.")
+	}
+}
+
+func TestWatchSources(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "web.env")
+	ioutil.WriteFile(path, []byte("DB=postgres\n"), 0660)
+	r := NewFakeRunner()
+	r.OnStart = func(f *FakeProcess) { f.IgnoreSignals = true }
+	m := NewManager()
+	for _, name := range []string{"a", "b"} {
+		m.Add(name, &Process{Command: name, Runner: r, Ping: "1h", EnvFile: path, RestartOnChange: true})
+		RunProcess(name, m.Get(name))
+	}
+	a, b := r.Processes()[0], r.Processes()[1]
+	r.OnStart = nil
+	done := make(chan struct{})
+	defer close(done)
+	m.WatchSources(5*time.Millisecond, done)
+	ioutil.WriteFile(path, []byte("DB=mysql\n"), 0660)
+	waitFor(t, func() bool { return len(a.Signals()) > 0 })
+	time.Sleep(20 * time.Millisecond)
+	if len(b.Signals()) > 0 {
+		t.Errorf("Expected b left alone until a restarted. Result %#v\n", b.Signals())
+	}
+	a.Exit(0)
+	waitFor(t, func() bool { return len(b.Signals()) > 0 })
+	if ex := []os.Signal{syscall.SIGTERM}; !reflect.DeepEqual(ex, b.Signals()) {
+		t.Errorf("Expected %#v. Result %#v\n", ex, b.Signals())
+	}
+	b.Exit(0)
+	waitFor(t, func() bool { return len(r.Processes()) == 4 && len(r.Running()) == 2 })
+	m.Get("a").Stop()
+	m.Get("b").Stop()
+}
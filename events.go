@@ -0,0 +1,63 @@
+// goforever - processes management
+// Copyright (c) 2013 Garrett Woodworth (https://github.com/gwoo).
+
+package process
+
+import (
+	"time"
+)
+
+//Event types.
+const (
+	EventStart   = "start"
+	EventStop    = "stop"
+	EventRestart = "restart"
+	EventExit    = "exit"
+	EventFatal   = "fatal"
+	EventDisk    = "disk"
+	EventApply   = "apply"
+)
+
+//Something that happened to a process, with the cause.
+type Event struct {
+	Time    time.Time `json:"time"`
+	Process string    `json:"process"`
+	Type    string    `json:"type"`
+	Reason  string    `json:"reason,omitempty"`
+	//Namespace of the process, see Manager.Namespace.
+	Namespace string `json:"namespace,omitempty"`
+}
+
+//Register f to be called with every event. Handlers run synchronously and
+//must not block.
+func (m *Manager) OnEvent(f func(Event)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.handlers = append(m.handlers, f)
+}
+
+func (m *Manager) emit(e Event) {
+	if e.Time.IsZero() {
+		e.Time = m.clock().Now()
+	}
+	m.mu.Lock()
+	handlers := append([]func(Event){}, m.handlers...)
+	m.mu.Unlock()
+	for _, f := range handlers {
+		f(e)
+	}
+	if m.parent != nil {
+		e.Namespace = m.ns
+		m.parent.emit(e)
+		return
+	}
+	m.recordEvent(e)
+}
+
+//Emit an event for the process through its manager, if any.
+func (p *Process) emit(typ, reason string) {
+	if p.manager == nil {
+		return
+	}
+	p.manager.emit(Event{Process: p.Name, Type: typ, Reason: reason})
+}
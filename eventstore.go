@@ -0,0 +1,139 @@
+// goforever - processes management
+// Copyright (c) 2013 Garrett Woodworth (https://github.com/gwoo).
+
+package process
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"time"
+)
+
+//Events kept in memory for Manager.Events.
+var maxEvents = 1000
+
+//Selects events. Empty fields match every event.
+type EventFilter struct {
+	Process   string    `json:"process,omitempty"`
+	Type      string    `json:"type,omitempty"`
+	Namespace string    `json:"namespace,omitempty"`
+	Since     time.Time `json:"since,omitempty"`
+	Until     time.Time `json:"until,omitempty"`
+	//Latest matching events returned, all by default.
+	Limit int `json:"limit,omitempty"`
+}
+
+func (f EventFilter) match(e Event) bool {
+	return (f.Process == "" || e.Process == f.Process) &&
+		(f.Type == "" || e.Type == f.Type) &&
+		(f.Namespace == "" || e.Namespace == f.Namespace) &&
+		(f.Since.IsZero() || !e.Time.Before(f.Since)) &&
+		(f.Until.IsZero() || e.Time.Before(f.Until))
+}
+
+//Keep an event of this manager or its namespaces, appending it to the
+//EventLog. Called on the top manager.
+func (m *Manager) recordEvent(e Event) {
+	m.mu.Lock()
+	if m.events = append(m.events, e); len(m.events) > maxEvents {
+		m.events = m.events[len(m.events)-maxEvents:]
+		m.dropped = true
+	}
+	path, store := m.EventLog, m.Store
+	m.mu.Unlock()
+	if path != "" {
+		if err := appendEvent(path, e); err != nil {
+			m.log(LevelError, "event log failed", Fields{"error": err, "path": path})
+		}
+	}
+	if store == nil {
+		return
+	}
+	if err := store.AddEvent(e); err != nil {
+		m.log(LevelError, "storing event failed", Fields{"error": err})
+	}
+	switch e.Type {
+	case EventStart, EventStop, EventExit, EventFatal:
+		//Not from the emitting goroutine, which may be in a transition.
+		m.saves.Add(1)
+		go func() {
+			defer m.saves.Done()
+			m.saveState()
+		}()
+	}
+}
+
+func appendEvent(path string, e Event) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+//Events matching f, oldest first. The last events are kept in memory; with
+//an EventLog older ones are read back from it. With a Store, including
+//those of previous runs, it is queried instead. A namespace only sees its
+//own events.
+func (m *Manager) Events(f EventFilter) ([]Event, error) {
+	if m.parent != nil {
+		f.Namespace = m.ns
+		return m.parent.Events(f)
+	}
+	m.mu.Lock()
+	kept := append([]Event(nil), m.events...)
+	path, dropped, store := m.EventLog, m.dropped, m.Store
+	m.mu.Unlock()
+	if store != nil {
+		return store.Events(f)
+	}
+	events := kept
+	if path != "" && dropped && (f.Since.IsZero() || len(kept) == 0 || f.Since.Before(kept[0].Time)) {
+		var err error
+		if events, err = readEvents(path); err != nil {
+			return nil, err
+		}
+	}
+	return filterEvents(events, f), nil
+}
+
+//Events matching f, at most its Limit latest.
+func filterEvents(events []Event, f EventFilter) []Event {
+	matched := []Event{}
+	for _, e := range events {
+		if f.match(e) {
+			matched = append(matched, e)
+		}
+	}
+	if f.Limit > 0 && len(matched) > f.Limit {
+		matched = matched[len(matched)-f.Limit:]
+	}
+	return matched
+}
+
+//Every event in an EventLog.
+func readEvents(path string) ([]Event, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	var events []Event
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var e Event
+		if json.Unmarshal(scanner.Bytes(), &e) == nil {
+			events = append(events, e)
+		}
+	}
+	return events, scanner.Err()
+}
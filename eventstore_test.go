@@ -0,0 +1,76 @@
+// goforever - processes management
+// Copyright (c) 2013 Garrett Woodworth (https://github.com/gwoo).
+
+package process
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestEvents(t *testing.T) {
+	clock := NewFakeClock(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+	m := NewManager()
+	m.Clock = clock
+	start := clock.Now()
+	m.emit(Event{Process: "web", Type: EventStart})
+	clock.Advance(time.Minute)
+	m.emit(Event{Process: "db", Type: EventStart})
+	m.emit(Event{Process: "web", Type: EventExit, Reason: "exited with code 1"})
+	n, _ := m.Namespace("team-a")
+	n.emit(Event{Process: "web", Type: EventStart})
+	for _, c := range []struct {
+		f  EventFilter
+		ex int
+	}{
+		{EventFilter{}, 4},
+		{EventFilter{Process: "web"}, 3},
+		{EventFilter{Type: EventStart}, 3},
+		{EventFilter{Since: start.Add(time.Second)}, 3},
+		{EventFilter{Until: start.Add(time.Second)}, 1},
+		{EventFilter{Namespace: "team-a"}, 1},
+		{EventFilter{Limit: 2}, 2},
+	} {
+		events, err := m.Events(c.f)
+		if err != nil || len(events) != c.ex {
+			t.Errorf("%#v: expected %d events. Result %#v %v\n", c.f, c.ex, events, err)
+		}
+	}
+	if events, _ := n.Events(EventFilter{}); len(events) != 1 || events[0].Namespace != "team-a" {
+		t.Errorf("Expected the namespace's event. Result %#v\n", events)
+	}
+	if events, _ := m.Events(EventFilter{Limit: 1}); events[0].Namespace != "team-a" {
+		t.Errorf("Expected the latest event. Result %#v\n", events)
+	}
+	w := httptest.NewRecorder()
+	m.API(nil).ServeHTTP(w, httptest.NewRequest("GET", "/events?process=web&type=exit", nil))
+	var events []Event
+	if err := json.Unmarshal(w.Body.Bytes(), &events); err != nil || len(events) != 1 || events[0].Reason != "exited with code 1" {
+		t.Errorf("Expected the exit event. Result %s\n", w.Body.String())
+	}
+	w = httptest.NewRecorder()
+	m.API(nil).ServeHTTP(w, httptest.NewRequest("GET", "/events?since=yesterday", nil))
+	if w.Code != 400 {
+		t.Errorf("Expected %#v. Result %#v\n", 400, w.Code)
+	}
+}
+
+func TestEventLog(t *testing.T) {
+	defer func(max int) { maxEvents = max }(maxEvents)
+	maxEvents = 2
+	m := NewManager()
+	m.EventLog = filepath.Join(t.TempDir(), "events.log")
+	for _, name := range []string{"a", "b", "c"} {
+		m.emit(Event{Process: name, Type: EventStart})
+	}
+	events, err := m.Events(EventFilter{})
+	if err != nil || len(events) != 3 || events[0].Process != "a" {
+		t.Errorf("Expected the events read back from the log. Result %#v %v\n", events, err)
+	}
+	if events, _ := m.Events(EventFilter{Since: time.Now().Add(time.Hour)}); len(events) != 0 {
+		t.Errorf("Expected no events. Result %#v\n", events)
+	}
+}
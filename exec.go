@@ -0,0 +1,36 @@
+// goforever - processes management
+// Copyright (c) 2013 Garrett Woodworth (https://github.com/gwoo).
+
+package process
+
+import (
+	"os"
+)
+
+//Environment a process's command runs in, for running other commands the
+//same way, e.g. to debug it.
+type ExecEnv struct {
+	Dir string   `json:"dir"`
+	Env []string `json:"env"`
+	//Ids of the supervisor, which the children run as. -1 on Windows.
+	Uid int `json:"uid"`
+	Gid int `json:"gid"`
+}
+
+//Environment the process's next start would get.
+func (p *Process) ExecEnv() (*ExecEnv, error) {
+	env, err := p.environ()
+	if err != nil {
+		return nil, err
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		return nil, err
+	}
+	return &ExecEnv{
+		Dir: wd,
+		Env: append(os.Environ(), env...),
+		Uid: os.Getuid(),
+		Gid: os.Getgid(),
+	}, nil
+}
@@ -0,0 +1,30 @@
+// goforever - processes management
+// Copyright (c) 2013 Garrett Woodworth (https://github.com/gwoo).
+
+package process
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestExecEnv(t *testing.T) {
+	envfile := filepath.Join(t.TempDir(), "env")
+	ioutil.WriteFile(envfile, []byte("A=file\nB=file\n"), 0600)
+	p := &Process{EnvFile: envfile, Env: []string{"B=env"}}
+	e, err := p.ExecEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	wd, _ := os.Getwd()
+	n := len(e.Env)
+	if e.Dir != wd || e.Uid != os.Getuid() || n < 2 || e.Env[n-2] != "A=file" || e.Env[n-1] != "B=env" {
+		t.Errorf("Unexpected exec env %#v\n", e)
+	}
+	p.EnvFile = filepath.Join(t.TempDir(), "missing")
+	if _, err := p.ExecEnv(); err == nil {
+		t.Errorf("Expected an error for a missing envfile.\n")
+	}
+}
@@ -0,0 +1,73 @@
+// goforever - processes management
+// Copyright (c) 2013 Garrett Woodworth (https://github.com/gwoo).
+
+package process
+
+import (
+	"fmt"
+	"syscall"
+)
+
+//Signals named in exit reasons, with what they usually mean.
+var signalReasons = map[syscall.Signal][2]string{
+	syscall.SIGHUP:  {"SIGHUP", "hung up"},
+	syscall.SIGINT:  {"SIGINT", "interrupted"},
+	syscall.SIGQUIT: {"SIGQUIT", "quit"},
+	syscall.SIGILL:  {"SIGILL", "illegal instruction"},
+	syscall.SIGTRAP: {"SIGTRAP", "trace trap"},
+	syscall.SIGABRT: {"SIGABRT", "aborted"},
+	syscall.SIGBUS:  {"SIGBUS", "bus error"},
+	syscall.SIGFPE:  {"SIGFPE", "floating point exception"},
+	syscall.SIGKILL: {"SIGKILL", "killed"},
+	syscall.SIGSEGV: {"SIGSEGV", "segmentation fault"},
+	syscall.SIGPIPE: {"SIGPIPE", "broken pipe"},
+	syscall.SIGALRM: {"SIGALRM", "alarm clock"},
+	syscall.SIGTERM: {"SIGTERM", "terminated"},
+}
+
+//Readable cause of the exit, e.g. "segmentation fault (SIGSEGV)". Codes
+//above 128, which shells use for children killed by signal code-128, are
+//described as that signal.
+func (s *ExitStatus) Reason() string {
+	if s.Signal != 0 {
+		return signalReason(s.Signal)
+	}
+	switch {
+	case s.Code == 0:
+		return "exited successfully"
+	case s.Code == 126:
+		return "exit 126 (command not executable)"
+	case s.Code == 127:
+		return "exit 127 (command not found)"
+	case s.Code > 128 && s.Code < 160:
+		return fmt.Sprintf("exit %d (%s)", s.Code, signalReason(syscall.Signal(s.Code-128)))
+	}
+	return fmt.Sprintf("exited with code %d", s.Code)
+}
+
+//Signal killing the process, directly or as code 128+N, 0 if none.
+func (s *ExitStatus) killSignal() syscall.Signal {
+	if s.Signal != 0 {
+		return s.Signal
+	}
+	if s.Code > 128 && s.Code < 160 {
+		return syscall.Signal(s.Code - 128)
+	}
+	return 0
+}
+
+func signalReason(sig syscall.Signal) string {
+	if r, ok := signalReasons[sig]; ok {
+		return r[1] + " (" + r[0] + ")"
+	}
+	return fmt.Sprintf("signal %d", int(sig))
+}
+
+//Reason for the exit of pid and whether the OOM killer caused it, as
+//counted by the process's Cgroup or recorded in the kernel log.
+func (p *Process) exitReason(s *ExitStatus, pid int) (string, bool) {
+	if s.killSignal() == syscall.SIGKILL && (p.oomInCgroup() || pid > 0 && oomKilled(pid)) {
+		return "killed by OOM", true
+	}
+	return s.Reason(), false
+}
@@ -0,0 +1,57 @@
+// goforever - processes management
+// Copyright (c) 2013 Garrett Woodworth (https://github.com/gwoo).
+
+package process
+
+import (
+	"sync"
+	"syscall"
+	"testing"
+)
+
+func TestExitReason(t *testing.T) {
+	for s, want := range map[ExitStatus]string{
+		{Code: 0}:                              "exited successfully",
+		{Code: 3}:                              "exited with code 3",
+		{Code: 127}:                            "exit 127 (command not found)",
+		{Code: 137}:                            "exit 137 (killed (SIGKILL))",
+		{Code: -1, Signal: syscall.SIGSEGV}:    "segmentation fault (SIGSEGV)",
+		{Code: -1, Signal: syscall.Signal(40)}: "signal 40",
+	} {
+		if r := s.Reason(); r != want {
+			t.Errorf("Expected %#v. Result %#v\n", want, r)
+		}
+	}
+}
+
+func TestExitEvent(t *testing.T) {
+	m := NewManager()
+	var mu sync.Mutex
+	var reasons []string
+	m.OnEvent(func(e Event) {
+		mu.Lock()
+		defer mu.Unlock()
+		if e.Type == EventExit {
+			reasons = append(reasons, e.Reason)
+		}
+	})
+	r := NewFakeRunner()
+	p := &Process{Command: "web", Runner: r, Ping: "1h", Respawn: 1}
+	m.Add("web", p)
+	RunProcess("web", p)
+	r.Running()[0].Signal(syscall.SIGSEGV)
+	waitFor(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(reasons) > 0
+	})
+	p.Stop()
+	if exit := p.Snapshot().LastExit; exit.Reason != "segmentation fault (SIGSEGV)" {
+		t.Errorf("Expected %#v. Result %#v\n", "segmentation fault (SIGSEGV)", exit.Reason)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(reasons) != 1 || reasons[0] != "segmentation fault (SIGSEGV)" {
+		t.Errorf("Expected one exit event. Result %#v\n", reasons)
+	}
+}
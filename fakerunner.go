@@ -0,0 +1,148 @@
+// goforever - processes management
+// Copyright (c) 2013 Garrett Woodworth (https://github.com/gwoo).
+
+package process
+
+import (
+	"errors"
+	"os"
+	"sync"
+	"syscall"
+)
+
+//In-memory Runner that executes nothing, for testing supervision configs.
+//Fake processes run until they are signalled or told to Exit.
+type FakeRunner struct {
+	//Returned by Start when set, e.g. to simulate a missing binary.
+	StartErr error
+	//Called with every started process.
+	OnStart func(*FakeProcess)
+	mu      sync.Mutex
+	pid     int
+	procs   []*FakeProcess
+}
+
+//First fake pid. It is beyond the pid limit of Linux and the BSDs, so the
+//OS calls made with fake pids never reach a real process.
+const FakePid = 5000000
+
+//Create a fake runner handing out pids from FakePid.
+func NewFakeRunner() *FakeRunner {
+	return &FakeRunner{pid: FakePid - 1}
+}
+
+func (r *FakeRunner) Start(cmd *Cmd) (Handle, error) {
+	r.mu.Lock()
+	if r.StartErr != nil {
+		r.mu.Unlock()
+		return nil, r.StartErr
+	}
+	r.pid++
+	f := &FakeProcess{Cmd: cmd, pid: r.pid, exit: make(chan *ExitStatus, 1), done: make(chan struct{})}
+	r.procs = append(r.procs, f)
+	onStart := r.OnStart
+	r.mu.Unlock()
+	if onStart != nil {
+		onStart(f)
+	}
+	return f, nil
+}
+
+//Every process started so far.
+func (r *FakeRunner) Processes() []*FakeProcess {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]*FakeProcess(nil), r.procs...)
+}
+
+//Processes that have not exited.
+func (r *FakeRunner) Running() []*FakeProcess {
+	var running []*FakeProcess
+	for _, f := range r.Processes() {
+		if !f.Exited() {
+			running = append(running, f)
+		}
+	}
+	return running
+}
+
+//Process started by a FakeRunner.
+type FakeProcess struct {
+	Cmd *Cmd
+	//Ignore TERM and other catchable signals. KILL always ends the process.
+	IgnoreSignals bool
+	mu            sync.Mutex
+	pid           int
+	signals       []os.Signal
+	exit          chan *ExitStatus
+	done          chan struct{}
+	exited        bool
+}
+
+func (f *FakeProcess) Pid() int {
+	return f.pid
+}
+
+func (f *FakeProcess) Wait() (*ExitStatus, error) {
+	s := <-f.exit
+	close(f.done)
+	return s, nil
+}
+
+func (f *FakeProcess) Signal(sig os.Signal) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.exited {
+		return os.ErrProcessDone
+	}
+	if sig == syscall.Signal(0) {
+		return nil
+	}
+	f.signals = append(f.signals, sig)
+	if sig == stopSignal || sig == contSignal {
+		return nil
+	}
+	if s, ok := sig.(syscall.Signal); ok && (sig == os.Kill || !f.IgnoreSignals) {
+		f.end(&ExitStatus{Code: -1, Signal: s})
+	}
+	return nil
+}
+
+func (f *FakeProcess) Release() error {
+	return nil
+}
+
+//Make the process exit with code.
+func (f *FakeProcess) Exit(code int) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.exited {
+		return errors.New("Fake process already exited.")
+	}
+	f.end(&ExitStatus{Code: code})
+	return nil
+}
+
+func (f *FakeProcess) end(s *ExitStatus) {
+	f.exited = true
+	f.exit <- s
+}
+
+//Whether the process has exited.
+func (f *FakeProcess) Exited() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.exited
+}
+
+//Closed once the exit has been collected by Wait.
+func (f *FakeProcess) Done() <-chan struct{} {
+	return f.done
+}
+
+//Signals received so far.
+func (f *FakeProcess) Signals() []os.Signal {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]os.Signal(nil), f.signals...)
+}
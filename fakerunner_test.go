@@ -0,0 +1,71 @@
+// goforever - processes management
+// Copyright (c) 2013 Garrett Woodworth (https://github.com/gwoo).
+
+package process
+
+import (
+	"errors"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestFakeRunnerRespawn(t *testing.T) {
+	r := NewFakeRunner()
+	m := NewManager()
+	m.Runner = r
+	p := &Process{Command: "/usr/bin/web", Args: []string{"-v"}, Respawn: 1}
+	m.Add("web", p)
+	RunProcess("web", p)
+	first := r.Running()[0]
+	if first.Cmd.Args[1] != "-v" || p.Snapshot().Pid != first.Pid() {
+		t.Fatalf("Unexpected fake process %#v\n", first.Cmd)
+	}
+	if alive(first.Pid()) {
+		t.Errorf("Expected fake pid %d to name no real process.\n", first.Pid())
+	}
+
+	first.Exit(1)
+	waitFor(t, func() bool { return len(r.Running()) == 1 && r.Running()[0] != first })
+	if s := p.Snapshot(); s.Respawns != 1 || s.LastExit == nil || s.LastExit.Code != 1 {
+		t.Errorf("Expected one respawn after exit 1. Result %#v\n", s)
+	}
+
+	second := r.Running()[0]
+	second.Exit(1)
+	waitFor(t, func() bool { return p.Snapshot().Status == "fatal" })
+
+	r.StartErr = errors.New("permission denied")
+	if err := m.Retry("web"); err == nil {
+		t.Error("Expected the start error.")
+	}
+}
+
+func TestFakeRunnerStop(t *testing.T) {
+	r := NewFakeRunner()
+	r.OnStart = func(f *FakeProcess) { f.IgnoreSignals = true }
+	p := &Process{Command: "/usr/bin/web", Runner: r, Timeouts: &Timeouts{Stop: "10ms"}}
+	RunProcess("web", p)
+	p.Stop()
+	f := r.Processes()[0]
+	ex := []os.Signal{syscall.SIGTERM, os.Kill}
+	if s := f.Signals(); len(s) != 2 || s[0] != ex[0] || s[1] != ex[1] {
+		t.Errorf("Expected %v. Result %v\n", ex, s)
+	}
+	if !f.Exited() || p.Snapshot().Status != "stopped" {
+		t.Errorf("Expected stopped. Result %#v\n", p.Snapshot())
+	}
+}
+
+//Poll cond for up to a second.
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	for i := 0; i < 100; i++ {
+		if cond() {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("Timed out waiting for condition.")
+}
@@ -0,0 +1,53 @@
+// goforever - processes management
+// Copyright (c) 2013 Garrett Woodworth (https://github.com/gwoo).
+
+package process
+
+import (
+	"errors"
+	"fmt"
+)
+
+var ErrNotFatal = errors.New("Process is not fatal.")
+
+//Names of the processes in the fatal state.
+func (m *Manager) Fatal() []string {
+	var names []string
+	for _, name := range m.Keys() {
+		if p := m.Get(name); p != nil && p.Snapshot().Status == "fatal" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+//Reset a fatal process to stopped, clearing its reason and respawn count.
+func (m *Manager) ClearFatal(name string) error {
+	p, err := m.Lookup(name)
+	if err != nil {
+		return err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.Status != "fatal" {
+		return ErrNotFatal
+	}
+	p.Status = "stopped"
+	p.reason = ""
+	p.respawns = 0
+	return nil
+}
+
+//Clear a fatal process and start it again. Returns an error if it goes
+//straight back to fatal.
+func (m *Manager) Retry(name string) error {
+	if err := m.ClearFatal(name); err != nil {
+		return err
+	}
+	p, err := RunProcess(name, m.Get(name))
+	if err != nil {
+		return fmt.Errorf("%s: %s", name, err)
+	}
+	p.emit(EventStart, "retry")
+	return nil
+}
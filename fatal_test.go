@@ -0,0 +1,41 @@
+// goforever - processes management
+// Copyright (c) 2013 Garrett Woodworth (https://github.com/gwoo).
+
+package process
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRetryFatal(t *testing.T) {
+	dir, err := ioutil.TempDir("", "process")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	m := NewManager()
+	p := &Process{Command: filepath.Join(dir, "missing"), Pidfile: Pidfile(filepath.Join(dir, "p.pid"))}
+	m.Add("p", p)
+	RunProcess("p", p)
+	if names := m.Fatal(); len(names) != 1 || names[0] != "p" {
+		t.Fatalf("Expected p fatal. Result %#v\n", names)
+	}
+	if err := m.Retry("p"); err == nil {
+		t.Error("Expected retry of a missing command to fail.")
+	}
+	p.Command = "/bin/sleep"
+	p.Args = []string{"10"}
+	if err := m.Retry("p"); err != nil {
+		t.Fatalf("Error: %s.", err)
+	}
+	defer p.Stop()
+	if s := p.Snapshot(); s.Pid == 0 || s.Reason != "" {
+		t.Errorf("Expected p running after retry. Result %#v\n", s)
+	}
+	if err := m.ClearFatal("p"); err != ErrNotFatal {
+		t.Errorf("Expected %#v. Result %#v\n", ErrNotFatal, err)
+	}
+}
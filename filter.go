@@ -0,0 +1,82 @@
+// goforever - processes management
+// Copyright (c) 2013 Garrett Woodworth (https://github.com/gwoo).
+
+package process
+
+import (
+	"regexp"
+)
+
+//Drops or reroutes piped output lines. A line matches when it matches the
+//regular expression, or when its level is below Below; lines without a
+//recognized level (JSON or logfmt level=) never match Below. The first
+//matching filter wins.
+type Filter struct {
+	Match string `json:"match,omitempty"`
+	Below string `json:"below,omitempty"`
+	//Name of the sink receiving the matching lines. Empty drops them.
+	Route string `json:"route,omitempty"`
+	//Streams filtered, default both.
+	Streams []string `json:"streams,omitempty"`
+}
+
+var logfmtLevel = regexp.MustCompile(`(?:^|\s)(?:level|lvl)="?(\w+)`)
+
+type filter struct {
+	re    *regexp.Regexp
+	below Level
+	level bool
+	route string
+}
+
+//Compile the filters of one stream, logging and skipping invalid ones.
+func (p *Process) filters(stream string) []filter {
+	var list []filter
+	for _, f := range p.Output.Filters {
+		if !(&Sink{Streams: f.Streams}).wants(stream) {
+			continue
+		}
+		c := filter{route: f.Route}
+		if f.Match != "" {
+			re, err := regexp.Compile(f.Match)
+			if err != nil {
+				p.log(LevelError, "invalid filter", Fields{"match": f.Match, "error": err})
+				continue
+			}
+			c.re = re
+		}
+		if f.Below != "" {
+			l, err := ParseLevel(f.Below)
+			if err != nil {
+				p.log(LevelError, "invalid filter", Fields{"below": f.Below, "error": err})
+				continue
+			}
+			c.below, c.level = l, true
+		}
+		list = append(list, c)
+	}
+	return list
+}
+
+func (f *filter) matches(line []byte) bool {
+	if f.re != nil && f.re.Match(line) {
+		return true
+	}
+	if f.level {
+		if l, ok := lineLevel(line); ok && l < f.below {
+			return true
+		}
+	}
+	return false
+}
+
+//Level of a JSON or logfmt line.
+func lineLevel(line []byte) (Level, bool) {
+	if l, _, _, ok := parseJSONLine(line); ok {
+		return l, true
+	}
+	if m := logfmtLevel.FindSubmatch(line); m != nil {
+		return childLevel(string(m[1])), true
+	}
+	return LevelInfo, false
+}
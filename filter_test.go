@@ -0,0 +1,47 @@
+// goforever - processes management
+// Copyright (c) 2013 Garrett Woodworth (https://github.com/gwoo).
+
+package process
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestFilters(t *testing.T) {
+	p := &Process{Name: "web", Output: &Output{Filters: []Filter{
+		{Match: "^healthcheck"},
+		{Below: "info", Route: "debug"},
+	}}}
+	var all, debug bytes.Buffer
+	m := &multiSink{filters: p.filters("stdout"), sinks: []*sinkWriter{
+		{name: "stdout/file", w: nopCloser{&all}, errors: new(uint64), p: p},
+		{name: "stdout/debug", route: "debug", routed: true, w: nopCloser{&debug}, errors: new(uint64), p: p},
+	}}
+	for _, line := range []string{
+		"healthcheck ok\n",
+		"level=debug msg=x\n",
+		`{"level":"trace","msg":"y"}` + "\n",
+		"level=info msg=z\n",
+		"plain\n",
+	} {
+		m.Write([]byte(line))
+	}
+	if ex := "level=info msg=z\nplain\n"; all.String() != ex {
+		t.Errorf("Expected %#v. Result %#v\n", ex, all.String())
+	}
+	if ex := "level=debug msg=x\n{\"level\":\"trace\",\"msg\":\"y\"}\n"; debug.String() != ex {
+		t.Errorf("Expected %#v. Result %#v\n", ex, debug.String())
+	}
+}
+
+func TestInvalidFilter(t *testing.T) {
+	p := &Process{Name: "web", Logger: &recordLogger{}, Output: &Output{Filters: []Filter{
+		{Match: "("},
+		{Below: "loud"},
+		{Match: "ok", Streams: []string{"stderr"}},
+	}}}
+	if r := p.filters("stdout"); len(r) != 0 {
+		t.Errorf("Expected no filters. Result %#v\n", r)
+	}
+}
@@ -0,0 +1,118 @@
+// goforever - processes management
+// Copyright (c) 2013 Garrett Woodworth (https://github.com/gwoo).
+
+package process
+
+import (
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+)
+
+var ErrNoJobControl = errors.New("Freezing needs a Cgroup or a unix system.")
+
+//Suspensions of a running process, see Process.Freeze.
+const (
+	EventFreeze = "freeze"
+	EventThaw   = "thaw"
+)
+
+//Suspend the running process without killing it, until Thaw: through the
+//cgroup freezer with a Cgroup, SIGSTOP otherwise. Its status is frozen and
+//it is not health checked meanwhile.
+func (p *Process) Freeze() error {
+	p.cycle.Lock()
+	defer p.cycle.Unlock()
+	p.mu.Lock()
+	x, frozen, status := p.x, p.frozen, p.Status
+	p.mu.Unlock()
+	if x == nil {
+		return ErrNotRunning
+	}
+	if frozen != "" {
+		return nil
+	}
+	if err := p.suspend(x, true); err != nil {
+		return err
+	}
+	p.mu.Lock()
+	p.frozen = status
+	p.Status = "frozen"
+	p.mu.Unlock()
+	p.log(LevelInfo, "frozen", nil)
+	p.emit(EventFreeze, "requested")
+	return nil
+}
+
+//Let a frozen process run again.
+func (p *Process) Thaw() error {
+	p.cycle.Lock()
+	defer p.cycle.Unlock()
+	return p.thaw()
+}
+
+//Thaw with p.cycle held, see Thaw.
+func (p *Process) thaw() error {
+	p.mu.Lock()
+	x, frozen := p.x, p.frozen
+	p.mu.Unlock()
+	if x == nil || frozen == "" {
+		return nil
+	}
+	if err := p.suspend(x, false); err != nil {
+		return err
+	}
+	p.mu.Lock()
+	p.frozen = ""
+	if p.Status == "frozen" {
+		p.Status = frozen
+	}
+	p.mu.Unlock()
+	p.log(LevelInfo, "thawed", nil)
+	p.emit(EventThaw, "requested")
+	return nil
+}
+
+//Whether the process is frozen, see Freeze.
+func (p *Process) Frozen() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.frozen != ""
+}
+
+//Freeze or thaw the cgroup of the process, or signal it without one.
+func (p *Process) suspend(x Handle, freeze bool) error {
+	if dir := p.cgroupDir(); dir != "" {
+		state := "0"
+		if freeze {
+			state = "1"
+		}
+		return ioutil.WriteFile(filepath.Join(dir, "cgroup.freeze"), []byte(state), 0644)
+	}
+	sig := contSignal
+	if freeze {
+		sig = stopSignal
+	}
+	if sig == nil {
+		return ErrNoJobControl
+	}
+	return x.Signal(sig)
+}
+
+//Freeze the process, see Process.Freeze.
+func (m *Manager) Freeze(name string) error {
+	p, err := m.Lookup(name)
+	if err != nil {
+		return err
+	}
+	return p.Freeze()
+}
+
+//Thaw the process, see Process.Thaw.
+func (m *Manager) Thaw(name string) error {
+	p, err := m.Lookup(name)
+	if err != nil {
+		return err
+	}
+	return p.Thaw()
+}
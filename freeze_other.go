@@ -0,0 +1,16 @@
+// goforever - processes management
+// Copyright (c) 2013 Garrett Woodworth (https://github.com/gwoo).
+
+//go:build !unix
+
+package process
+
+import (
+	"os"
+)
+
+//No job control signals, only the cgroup freezer suspends processes.
+var (
+	stopSignal os.Signal
+	contSignal os.Signal
+)
@@ -0,0 +1,59 @@
+// goforever - processes management
+// Copyright (c) 2013 Garrett Woodworth (https://github.com/gwoo).
+
+//go:build unix
+
+package process
+
+import (
+	"net/http/httptest"
+	"syscall"
+	"testing"
+)
+
+func TestFreeze(t *testing.T) {
+	r := NewFakeRunner()
+	m := NewManager()
+	m.Add("web", &Process{Command: "web", Runner: r, Ping: "1h"})
+	p := m.Get("web")
+	if err := p.Freeze(); err != ErrNotRunning {
+		t.Errorf("Expected %#v. Result %#v\n", ErrNotRunning, err)
+	}
+	RunProcess("web", p)
+	if err := m.Freeze("web"); err != nil || !p.Frozen() || p.CurrentStatus() != "frozen" {
+		t.Fatalf("Expected web frozen. Result %#v %#v\n", err, p.CurrentStatus())
+	}
+	proc := r.Running()[0]
+	if err := m.Thaw("web"); err != nil || p.Frozen() || p.CurrentStatus() != "started" {
+		t.Errorf("Expected web thawed. Result %#v %#v\n", err, p.CurrentStatus())
+	}
+	p.Freeze()
+	p.Stop()
+	signals := proc.Signals()
+	expected := []syscall.Signal{syscall.SIGSTOP, syscall.SIGCONT, syscall.SIGSTOP, syscall.SIGCONT, syscall.SIGTERM}
+	if len(signals) != len(expected) {
+		t.Fatalf("Expected %#v. Result %#v\n", expected, signals)
+	}
+	for i, sig := range expected {
+		if signals[i] != sig {
+			t.Errorf("Expected %#v. Result %#v\n", expected, signals)
+		}
+	}
+	if p.Frozen() {
+		t.Errorf("Expected the stopped process thawed.\n")
+	}
+}
+
+func TestFreezeAPI(t *testing.T) {
+	m := NewManager()
+	m.Add("web", &Process{Command: "web", Runner: NewFakeRunner(), Ping: "1h"})
+	RunProcess("web", m.Get("web"))
+	defer m.Get("web").Stop()
+	for _, c := range []struct{ action, status string }{{"freeze", "frozen"}, {"thaw", "started"}} {
+		w := httptest.NewRecorder()
+		m.API(nil).ServeHTTP(w, httptest.NewRequest("POST", "/processes/web/"+c.action, nil))
+		if w.Code != 200 || m.Get("web").CurrentStatus() != c.status {
+			t.Errorf("Expected %#v. Result %d %s\n", c.status, w.Code, w.Body.String())
+		}
+	}
+}
@@ -0,0 +1,17 @@
+// goforever - processes management
+// Copyright (c) 2013 Garrett Woodworth (https://github.com/gwoo).
+
+//go:build unix
+
+package process
+
+import (
+	"os"
+	"syscall"
+)
+
+//Signals suspending and resuming a process without a cgroup.
+var (
+	stopSignal os.Signal = syscall.SIGSTOP
+	contSignal os.Signal = syscall.SIGCONT
+)
@@ -0,0 +1,128 @@
+// goforever - processes management
+// Copyright (c) 2013 Garrett Woodworth (https://github.com/gwoo).
+
+package process
+
+import (
+	"fmt"
+	"io"
+	"sort"
+)
+
+//Supervision tree and dependencies of a manager, see Manager.Graph.
+type Graph struct {
+	Nodes []GraphNode `json:"nodes"`
+	Edges []GraphEdge `json:"edges"`
+}
+
+//A process of a Graph. The ID is its name, after "namespace/" for
+//namespaced processes and after the parent's ID and "/" for children.
+type GraphNode struct {
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	Namespace string `json:"namespace,omitempty"`
+	Status    string `json:"status,omitempty"`
+	Health    string `json:"health,omitempty"`
+}
+
+//Kinds of graph edges.
+const (
+	EdgeChild     = "child"
+	EdgeDependsOn = "depends_on"
+)
+
+//From a parent to its child, or from a process to one in its DependsOn.
+//Dependencies that do not exist still get an edge.
+type GraphEdge struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+	Kind string `json:"kind"`
+}
+
+//Graph of the processes, their children and DependsOn edges with their
+//current status, namespaces included.
+func (m *Manager) Graph() *Graph {
+	g := &Graph{Nodes: []GraphNode{}, Edges: []GraphEdge{}}
+	m.graph(g)
+	for _, n := range m.spaceList() {
+		n.graph(g)
+	}
+	return g
+}
+
+func (m *Manager) graph(g *Graph) {
+	prefix := ""
+	if m.ns != "" {
+		prefix = m.ns + "/"
+	}
+	for _, name := range m.Keys() {
+		p := m.Get(name)
+		if p == nil {
+			continue
+		}
+		g.add(prefix+name, m.ns, p)
+		for _, dep := range p.DependsOn {
+			g.Edges = append(g.Edges, GraphEdge{From: prefix + name, To: prefix + dep, Kind: EdgeDependsOn})
+		}
+	}
+}
+
+//Add p and its children under id.
+func (g *Graph) add(id, ns string, p *Process) {
+	info := p.Snapshot()
+	g.Nodes = append(g.Nodes, GraphNode{ID: id, Name: info.Name, Namespace: ns, Status: info.Status, Health: info.Health})
+	names := p.children.Keys()
+	sort.Strings(names)
+	for _, name := range names {
+		child := id + "/" + name
+		g.Edges = append(g.Edges, GraphEdge{From: id, To: child, Kind: EdgeChild})
+		g.add(child, ns, p.children.Get(name))
+	}
+}
+
+//Write the graph in Graphviz DOT. Nodes are labelled with their name and
+//status and grouped by namespace; dependency edges are dashed.
+func (g *Graph) WriteDOT(w io.Writer) error {
+	if _, err := fmt.Fprintln(w, "digraph processes {"); err != nil {
+		return err
+	}
+	var spaces []string
+	byNS := map[string][]GraphNode{}
+	for _, n := range g.Nodes {
+		if _, ok := byNS[n.Namespace]; !ok {
+			spaces = append(spaces, n.Namespace)
+		}
+		byNS[n.Namespace] = append(byNS[n.Namespace], n)
+	}
+	for _, ns := range spaces {
+		indent := "\t"
+		if ns != "" {
+			fmt.Fprintf(w, "\tsubgraph %q {\n\t\tlabel=%q;\n", "cluster_"+ns, ns)
+			indent = "\t\t"
+		}
+		for _, n := range byNS[ns] {
+			label := n.Name
+			if n.Status != "" {
+				label += "\n" + n.Status
+			}
+			if n.Health != "" {
+				label += " (" + n.Health + ")"
+			}
+			fmt.Fprintf(w, "%s%q [label=%q];\n", indent, n.ID, label)
+		}
+		if ns != "" {
+			fmt.Fprintln(w, "\t}")
+		}
+	}
+	for _, e := range g.Edges {
+		style := ""
+		if e.Kind == EdgeDependsOn {
+			style = " [style=dashed]"
+		}
+		if _, err := fmt.Fprintf(w, "\t%q -> %q%s;\n", e.From, e.To, style); err != nil {
+			return err
+		}
+	}
+	_, err := fmt.Fprintln(w, "}")
+	return err
+}
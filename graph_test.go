@@ -0,0 +1,41 @@
+// goforever - processes management
+// Copyright (c) 2013 Garrett Woodworth (https://github.com/gwoo).
+
+package process
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestGraph(t *testing.T) {
+	m := NewManager()
+	web := &Process{Command: "/usr/bin/web", DependsOn: []string{"db"}}
+	web.Add("worker", &Process{Command: "/usr/bin/worker"})
+	m.Add("web", web)
+	m.Add("db", &Process{Command: "/usr/bin/db"})
+	n, _ := m.Namespace("team")
+	n.Add("cron", &Process{Command: "/usr/bin/cron"})
+	g := m.Graph()
+	var ids []string
+	for _, node := range g.Nodes {
+		ids = append(ids, node.ID)
+	}
+	if ex := "db web web/worker team/cron"; strings.Join(ids, " ") != ex {
+		t.Errorf("Expected %#v. Result %#v\n", ex, ids)
+	}
+	ex := []GraphEdge{{"web", "db", EdgeDependsOn}, {"web", "web/worker", EdgeChild}}
+	if len(g.Edges) != 2 || g.Edges[0] != ex[1] || g.Edges[1] != ex[0] {
+		t.Errorf("Expected %#v. Result %#v\n", ex, g.Edges)
+	}
+	var b bytes.Buffer
+	if err := g.WriteDOT(&b); err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{`"web" -> "db" [style=dashed];`, `subgraph "cluster_team" {`, `"team/cron" [label="cron"];`} {
+		if !strings.Contains(b.String(), line) {
+			t.Errorf("Expected %q in\n%s", line, b.String())
+		}
+	}
+}
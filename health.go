@@ -0,0 +1,188 @@
+// goforever - processes management
+// Copyright (c) 2013 Garrett Woodworth (https://github.com/gwoo).
+
+package process
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"time"
+)
+
+var ErrNotRunning = errors.New("Process is not running.")
+
+//Health check. Exactly one of TCP (host:port accepting connections), HTTP
+//(URL answering 2xx), Exec (command exiting 0), File (path existing) or DNS
+//(host name resolving) should be set.
+type Probe struct {
+	TCP     string   `json:"tcp,omitempty"`
+	HTTP    string   `json:"http,omitempty"`
+	Exec    []string `json:"exec,omitempty"`
+	File    string   `json:"file,omitempty"`
+	DNS     string   `json:"dns,omitempty"`
+	Timeout string   `json:"timeout,omitempty"`
+}
+
+//Run the probe once. Timeout defaults to 5s.
+func (pr *Probe) Check() error {
+	ctx, cancel := context.WithTimeout(context.Background(), durationOr(pr.Timeout, 5*time.Second))
+	defer cancel()
+	switch {
+	case pr.TCP != "":
+		var d net.Dialer
+		conn, err := d.DialContext(ctx, "tcp", pr.TCP)
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+	case pr.HTTP != "":
+		req, err := http.NewRequestWithContext(ctx, "GET", pr.HTTP, nil)
+		if err != nil {
+			return err
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		if resp.StatusCode < 200 || resp.StatusCode > 299 {
+			return fmt.Errorf("%s returned %s.", pr.HTTP, resp.Status)
+		}
+		return nil
+	case len(pr.Exec) > 0:
+		return exec.CommandContext(ctx, pr.Exec[0], pr.Exec[1:]...).Run()
+	case pr.File != "":
+		_, err := os.Stat(pr.File)
+		return err
+	case pr.DNS != "":
+		_, err := net.DefaultResolver.LookupHost(ctx, pr.DNS)
+		return err
+	}
+	return errors.New("Probe has no check.")
+}
+
+//Check that the process is running and passes all Health probes.
+func (p *Process) Healthy() error {
+	p.mu.Lock()
+	running := p.Pid > 0
+	p.mu.Unlock()
+	if !running {
+		return ErrNotRunning
+	}
+	return p.checkProbes(p.Health)
+}
+
+//Run probes with ${var} references expanded, stopping at the first failure.
+func (p *Process) checkProbes(probes []Probe) error {
+	for i := range probes {
+		pr, err := probes[i].expand(p.Vars)
+		if err != nil {
+			return err
+		}
+		if err := pr.Check(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//Copy of the probe with ${var} references replaced.
+func (pr *Probe) expand(vars Vars) (*Probe, error) {
+	c := &Probe{Timeout: pr.Timeout}
+	var err error
+	if c.TCP, err = expandVars(pr.TCP, vars); err != nil {
+		return nil, err
+	}
+	if c.HTTP, err = expandVars(pr.HTTP, vars); err != nil {
+		return nil, err
+	}
+	if c.File, err = expandVars(pr.File, vars); err != nil {
+		return nil, err
+	}
+	if c.DNS, err = expandVars(pr.DNS, vars); err != nil {
+		return nil, err
+	}
+	for _, arg := range pr.Exec {
+		arg, err = expandVars(arg, vars)
+		if err != nil {
+			return nil, err
+		}
+		c.Exec = append(c.Exec, arg)
+	}
+	return c, nil
+}
+
+//Poll Healthy until it passes or ctx is done.
+func (p *Process) waitHealthy(ctx context.Context) error {
+	tick := p.clock().NewTicker(250 * time.Millisecond)
+	defer tick.Stop()
+	for {
+		err := p.Healthy()
+		if err == nil {
+			return nil
+		}
+		select {
+		case <-tick.C():
+		case <-ctx.Done():
+			return fmt.Errorf("%s not healthy: %s", p.Name, err)
+		}
+	}
+}
+
+//String naming what the probe checks.
+func (pr *Probe) String() string {
+	switch {
+	case pr.TCP != "":
+		return "tcp " + pr.TCP
+	case pr.HTTP != "":
+		return "http " + pr.HTTP
+	case len(pr.Exec) > 0:
+		return "exec " + pr.Exec[0]
+	case pr.File != "":
+		return "file " + pr.File
+	case pr.DNS != "":
+		return "dns " + pr.DNS
+	}
+	return "empty probe"
+}
+
+//Poll the WaitFor probes until they all pass or WaitTimeout (default 60s)
+//expires.
+func (p *Process) waitFor() error {
+	if len(p.WaitFor) == 0 {
+		return nil
+	}
+	timeout := p.clock().After(durationOr(p.WaitTimeout, time.Minute))
+	tick := p.clock().NewTicker(time.Second)
+	defer tick.Stop()
+	for i := 0; i < len(p.WaitFor); {
+		pr, err := p.WaitFor[i].expand(p.Vars)
+		if err != nil {
+			return err
+		}
+		if err = pr.Check(); err == nil {
+			i++
+			continue
+		}
+		p.log(LevelDebug, "waiting", Fields{"for": pr.String(), "error": err})
+		select {
+		case <-tick.C():
+		case <-timeout:
+			return fmt.Errorf("waiting for %s: %s", pr, err)
+		}
+	}
+	return nil
+}
+
+//Parse d, falling back to def when empty or invalid.
+func durationOr(d string, def time.Duration) time.Duration {
+	if t, err := time.ParseDuration(d); err == nil && d != "" {
+		return t
+	}
+	return def
+}
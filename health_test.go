@@ -0,0 +1,44 @@
+// goforever - processes management
+// Copyright (c) 2013 Garrett Woodworth (https://github.com/gwoo).
+
+package process
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWaitFor(t *testing.T) {
+	dir, err := ioutil.TempDir("", "process")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	clock := NewFakeClock(time.Now())
+	p := &Process{
+		Name:    "web",
+		Clock:   clock,
+		Vars:    Vars{"dir": dir},
+		WaitFor: []Probe{{File: "${dir}/ready"}},
+	}
+	done := make(chan error)
+	go func() { done <- p.waitFor() }()
+	clock.BlockUntil(2)
+	ioutil.WriteFile(filepath.Join(dir, "ready"), nil, 0600)
+	clock.Advance(time.Second)
+	if err := <-done; err != nil {
+		t.Errorf("Expected %#v. Result %#v\n", nil, err)
+	}
+
+	p.WaitFor = []Probe{{File: "${dir}/never"}}
+	go func() { done <- p.waitFor() }()
+	clock.BlockUntil(2)
+	clock.Advance(time.Minute)
+	if err := <-done; err == nil || !strings.Contains(err.Error(), "waiting for file") {
+		t.Errorf("Expected a wait timeout. Result %#v\n", err)
+	}
+}
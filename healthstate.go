@@ -0,0 +1,159 @@
+// goforever - processes management
+// Copyright (c) 2013 Garrett Woodworth (https://github.com/gwoo).
+
+package process
+
+import (
+	"time"
+)
+
+//Health states, distinct from the run Status.
+const (
+	HealthUnknown   = "unknown"
+	HealthHealthy   = "healthy"
+	HealthDegraded  = "degraded"
+	HealthUnhealthy = "unhealthy"
+)
+
+//Health state change, with the new state as reason.
+const EventHealth = "health"
+
+//How the probe success rate over the last Window checks maps to a state.
+type HealthPolicy struct {
+	//Checks kept. Defaults to 10.
+	Window int `json:"window,omitempty"`
+	//Lowest success rate counted healthy, default 1, and degraded, default
+	//0.5. Below that the process is unhealthy.
+	Healthy  float64 `json:"healthy,omitempty"`
+	Degraded float64 `json:"degraded,omitempty"`
+}
+
+//Results of the checks since the process was (re)started.
+type healthWindow struct {
+	pid     int
+	results []bool
+	state   string
+}
+
+func (p *Process) healthPolicy() HealthPolicy {
+	hp := HealthPolicy{}
+	if p.HealthPolicy != nil {
+		hp = *p.HealthPolicy
+	}
+	if hp.Window <= 0 {
+		hp.Window = 10
+	}
+	if hp.Healthy <= 0 {
+		hp.Healthy = 1
+	}
+	if hp.Degraded <= 0 {
+		hp.Degraded = 0.5
+	}
+	return hp
+}
+
+//Run the Health probes once and update the health state, emitting an
+//event when it changes. Returns the state.
+func (p *Process) checkHealth() string {
+	if !p.cycle.TryLock() {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		if p.health == nil {
+			return HealthUnknown
+		}
+		return p.health.state
+	}
+	begin := p.clock().Now()
+	err := p.Healthy()
+	latency := p.clock().Now().Sub(begin)
+	p.cycle.Unlock()
+	hp := p.healthPolicy()
+	p.mu.Lock()
+	if err != ErrNotRunning {
+		p.recordProbe(ProbeSample{Time: begin, Latency: latency, OK: err == nil})
+	}
+	old := HealthUnknown
+	if p.health != nil {
+		old = p.health.state
+	}
+	if p.health == nil || p.health.pid != p.Pid {
+		p.health = &healthWindow{pid: p.Pid}
+	}
+	h := p.health
+	if err == ErrNotRunning {
+		h.results, h.state = nil, HealthUnknown
+	} else {
+		h.results = append(h.results, err == nil)
+		if len(h.results) > hp.Window {
+			h.results = h.results[len(h.results)-hp.Window:]
+		}
+		h.state = hp.state(h.results)
+	}
+	state := h.state
+	p.mu.Unlock()
+	if state != old {
+		fields := Fields{"health": state}
+		if err != nil {
+			fields["error"] = err
+		}
+		p.log(LevelInfo, "health changed", fields)
+		p.emit(EventHealth, state)
+		p.updateRegistration(state)
+	}
+	return state
+}
+
+func (hp HealthPolicy) state(results []bool) string {
+	ok := 0
+	for _, r := range results {
+		if r {
+			ok++
+		}
+	}
+	rate := float64(ok) / float64(len(results))
+	switch {
+	case rate >= hp.Healthy:
+		return HealthHealthy
+	case rate >= hp.Degraded:
+		return HealthDegraded
+	}
+	return HealthUnhealthy
+}
+
+//Health state from the checks since the last start, see WatchHealth.
+func (p *Process) HealthState() string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.health == nil || p.health.pid != p.Pid {
+		return HealthUnknown
+	}
+	return p.health.state
+}
+
+//Check the processes with Health probes or a registration every interval,
+//keeping their health state, and restart those failing their Liveness
+//probes. Without Health probes a running process is healthy. Stops when
+//done is closed.
+func (m *Manager) WatchHealth(interval time.Duration, done <-chan struct{}) {
+	go func() {
+		for {
+			select {
+			case <-done:
+				return
+			case <-m.clock().After(interval):
+			}
+			for _, name := range m.Keys() {
+				p := m.Get(name)
+				if p == nil || p.Paused() || p.Frozen() {
+					continue
+				}
+				if p.checkLiveness() {
+					continue
+				}
+				if len(p.Health) > 0 || p.Register != nil {
+					p.checkHealth()
+				}
+			}
+		}
+	}()
+}
@@ -0,0 +1,87 @@
+// goforever - processes management
+// Copyright (c) 2013 Garrett Woodworth (https://github.com/gwoo).
+
+package process
+
+import (
+	"net"
+	"testing"
+)
+
+func TestHealthPolicyState(t *testing.T) {
+	hp := (&Process{HealthPolicy: &HealthPolicy{Window: 4}}).healthPolicy()
+	for ex, results := range map[string][]bool{
+		HealthHealthy:   {true, true, true},
+		HealthDegraded:  {true, false, true, true},
+		HealthUnhealthy: {false, false, true},
+	} {
+		if r := hp.state(results); r != ex {
+			t.Errorf("%v: expected %#v. Result %#v\n", results, ex, r)
+		}
+	}
+}
+
+func TestCheckHealth(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := NewManager()
+	var events []string
+	m.OnEvent(func(e Event) {
+		if e.Type == EventHealth {
+			events = append(events, e.Reason)
+		}
+	})
+	p := &Process{Pid: 1, Health: []Probe{{TCP: l.Addr().String()}}, HealthPolicy: &HealthPolicy{Window: 2}}
+	m.Add("web", p)
+	if r := p.checkHealth(); r != HealthHealthy {
+		t.Errorf("Expected %#v. Result %#v\n", HealthHealthy, r)
+	}
+	l.Close()
+	p.checkHealth()
+	p.checkHealth()
+	p.Pid = 0
+	p.checkHealth()
+	ex := []string{HealthHealthy, HealthDegraded, HealthUnhealthy, HealthUnknown}
+	if len(events) != len(ex) {
+		t.Fatalf("Expected %#v. Result %#v\n", ex, events)
+	}
+	for i := range ex {
+		if events[i] != ex[i] {
+			t.Errorf("Expected %#v. Result %#v\n", ex, events)
+		}
+	}
+}
+
+func TestCheckHealthInTransition(t *testing.T) {
+	r := NewFakeRunner()
+	p := &Process{
+		Command:  "web",
+		Runner:   r,
+		Ping:     "1h",
+		Hooks:    &Hooks{PreStart: []string{"sleep", "0.2"}},
+		Health:   []Probe{{File: "/nonexistent/ready"}},
+		Liveness: &Liveness{Probes: []Probe{{File: "/nonexistent/alive"}}, Failures: 1},
+	}
+	started := make(chan struct{})
+	go func() {
+		RunProcess("web", p)
+		close(started)
+	}()
+	waitFor(t, func() bool {
+		if !p.cycle.TryLock() {
+			return true
+		}
+		p.cycle.Unlock()
+		return false
+	})
+	if s := p.checkHealth(); s != HealthUnknown || p.checkLiveness() {
+		t.Errorf("Expected the probes to skip a starting process. Result %s\n", s)
+	}
+	p.Stop()
+	<-started
+	if s := p.CurrentStatus(); s != "stopped" || len(r.Running()) != 0 {
+		t.Errorf("Expected the stop to follow the start. Result %s\n", s)
+	}
+}
@@ -0,0 +1,135 @@
+// goforever - processes management
+// Copyright (c) 2013 Garrett Woodworth (https://github.com/gwoo).
+
+package process
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+//Limits for each lifecycle phase, as durations like "10s".
+type Timeouts struct {
+	//Start hooks plus waiting for Health. Defaults to HealthTimeout or 30s.
+	Start string `json:"start,omitempty"`
+	//Time between TERM and KILL. Defaults to 10s.
+	Stop string `json:"stop,omitempty"`
+	//Reload hook or signal. Defaults to 30s.
+	Reload string `json:"reload,omitempty"`
+	//Each hook command. Defaults to 30s.
+	HookExec string `json:"hook_exec,omitempty"`
+}
+
+//Commands run around lifecycle transitions, argv style. A failing PreStart
+//aborts the start; other hook failures are logged. Reload replaces the
+//default SIGHUP sent by Process.Reload. Verify is given the binary as its
+//last argument before start, e.g. to check a signature, and a failure
+//refuses the start.
+type Hooks struct {
+	PreStart  []string `json:"pre_start,omitempty"`
+	PostStart []string `json:"post_start,omitempty"`
+	PreStop   []string `json:"pre_stop,omitempty"`
+	PostStop  []string `json:"post_stop,omitempty"`
+	Reload    []string `json:"reload,omitempty"`
+	Verify    []string `json:"verify,omitempty"`
+}
+
+func (p *Process) timeouts() Timeouts {
+	if p.Timeouts == nil {
+		return Timeouts{}
+	}
+	return *p.Timeouts
+}
+
+func (p *Process) hooks() Hooks {
+	if p.Hooks == nil {
+		return Hooks{}
+	}
+	return *p.Hooks
+}
+
+//Context bounded by the Start timeout.
+func (p *Process) startContext() (context.Context, context.CancelFunc) {
+	d := durationOr(p.timeouts().Start, durationOr(p.HealthTimeout, 30*time.Second))
+	return context.WithTimeout(context.Background(), d)
+}
+
+//Run a hook command with the process environment plus PROCESS_NAME and
+//PROCESS_PID, bounded by ctx and the HookExec timeout.
+func (p *Process) runHook(ctx context.Context, name string, argv []string) error {
+	if len(argv) == 0 {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, durationOr(p.timeouts().HookExec, 30*time.Second))
+	defer cancel()
+	env, err := p.environ()
+	if err != nil {
+		return err
+	}
+	p.mu.Lock()
+	pid := p.Pid
+	p.mu.Unlock()
+	cmd := exec.CommandContext(ctx, argv[0], argv[1:]...)
+	cmd.Env = append(os.Environ(), env...)
+	cmd.Env = append(cmd.Env, "PROCESS_NAME="+p.Name, "PROCESS_PID="+strconv.Itoa(pid))
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		p.log(LevelError, "hook failed", Fields{"hook": name, "error": err, "output": string(out)})
+		return fmt.Errorf("%s hook: %s", name, err)
+	}
+	p.log(LevelDebug, "hook", Fields{"hook": name})
+	return nil
+}
+
+//Wait for x to exit. Processes we did not start (no exited channel) are
+//polled with signal 0.
+func waitExit(ctx context.Context, x Handle, exited <-chan struct{}) error {
+	if exited != nil {
+		select {
+		case <-exited:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	tick := time.NewTicker(100 * time.Millisecond)
+	defer tick.Stop()
+	for {
+		if err := x.Signal(syscall.Signal(0)); err != nil {
+			return nil
+		}
+		select {
+		case <-tick.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+//Reload the process by running the Reload hook, or when there is none
+//calling Control.Reload over its control channel or sending SIGHUP, bounded
+//by the Reload timeout.
+func (p *Process) Reload() error {
+	p.cycle.Lock()
+	defer p.cycle.Unlock()
+	ctx, cancel := context.WithTimeout(context.Background(), durationOr(p.timeouts().Reload, 30*time.Second))
+	defer cancel()
+	if h := p.hooks().Reload; len(h) > 0 {
+		return p.runHook(ctx, "reload", h)
+	}
+	if p.hasControl() {
+		return p.call("Reload", &struct{}{}, durationOr(p.timeouts().Reload, 30*time.Second))
+	}
+	p.mu.Lock()
+	x := p.x
+	p.mu.Unlock()
+	if x == nil {
+		return ErrNotRunning
+	}
+	return x.Signal(syscall.SIGHUP)
+}
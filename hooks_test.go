@@ -0,0 +1,75 @@
+// goforever - processes management
+// Copyright (c) 2013 Garrett Woodworth (https://github.com/gwoo).
+
+package process
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestHooksAndStopTimeout(t *testing.T) {
+	dir, err := ioutil.TempDir("", "process")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	out := filepath.Join(dir, "hook.out")
+	p := &Process{
+		Command:  "/bin/sh",
+		Args:     []string{"-c", "trap '' TERM; while true; do sleep 1; done"},
+		Pidfile:  Pidfile(filepath.Join(dir, "sh.pid")),
+		Timeouts: &Timeouts{Stop: "200ms"},
+		Hooks: &Hooks{
+			PreStart: []string{"/bin/sh", "-c", "echo $PROCESS_NAME > " + out},
+			PostStop: []string{"/bin/sh", "-c", "echo stopped >> " + out},
+		},
+	}
+	if r := p.Start("trap"); r == "" {
+		t.Fatal("Expected start.")
+	}
+	time.Sleep(100 * time.Millisecond)
+	begin := time.Now()
+	p.Stop()
+	if d := time.Since(begin); d > 2*time.Second {
+		t.Errorf("Expected KILL after the stop timeout. Took %s\n", d)
+	}
+	data, _ := ioutil.ReadFile(out)
+	if ex := "trap\nstopped\n"; string(data) != ex {
+		t.Errorf("Expected %#v. Result %#v\n", ex, string(data))
+	}
+
+	p.Hooks.PreStart = []string{"/bin/false"}
+	if r := p.Start("trap"); r != "" {
+		t.Error("Expected a failing pre_start hook to abort the start.")
+	}
+}
+
+func TestHooksOncePerRun(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "hook.out")
+	r := NewFakeRunner()
+	p := &Process{
+		Name:    "web",
+		Command: "web",
+		Runner:  r,
+		Ping:    "1h",
+		Respawn: 1,
+		Hooks:   &Hooks{PreStop: []string{"/bin/sh", "-c", "echo pre_stop >> " + out}},
+	}
+	RunProcess("web", p)
+	r.Processes()[0].Exit(1)
+	waitFor(t, func() bool { return len(r.Processes()) == 2 })
+	r.Processes()[1].Exit(1)
+	waitFor(t, func() bool { return p.CurrentStatus() == "fatal" })
+	p.Stop()
+	if _, err := os.Stat(out); !os.IsNotExist(err) {
+		data, _ := ioutil.ReadFile(out)
+		t.Errorf("Expected no pre_stop hook for exited children. Result %#v\n", string(data))
+	}
+	if s := p.CurrentStatus(); s != "fatal" {
+		t.Errorf("Expected %#v. Result %#v\n", "fatal", s)
+	}
+}
@@ -0,0 +1,44 @@
+// goforever - processes management
+// Copyright (c) 2013 Garrett Woodworth (https://github.com/gwoo).
+
+package process
+
+import (
+	"errors"
+)
+
+var ErrKeyReused = errors.New("Idempotency key already used for another operation.")
+
+//Operation requested earlier with the idempotency key, nil if there is none
+//among the kept operations. ErrKeyReused if it is not a typ of name. Every
+//key of requests coalesced into one operation leads to it.
+func (m *Manager) keyedOperation(key, typ, name string) (*Operation, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.keyedLocked(key, typ, name)
+}
+
+//See keyedOperation. Called with m.mu held.
+func (m *Manager) keyedLocked(key, typ, name string) (*Operation, error) {
+	op := m.keys[key]
+	if key == "" || op == nil {
+		return nil, nil
+	}
+	if op.Type != typ || op.Process != name {
+		return nil, ErrKeyReused
+	}
+	return op, nil
+}
+
+//Drop the keys of operations no longer kept. Called with m.mu held.
+func (m *Manager) forgetKeys(ops []*Operation) {
+	dropped := map[*Operation]bool{}
+	for _, op := range ops {
+		dropped[op] = true
+	}
+	for key, op := range m.keys {
+		if dropped[op] {
+			delete(m.keys, key)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+// goforever - processes management
+// Copyright (c) 2013 Garrett Woodworth (https://github.com/gwoo).
+
+package process
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func postKeyed(m *Manager, path, key string) (int, map[string]string) {
+	req := httptest.NewRequest("POST", path, nil)
+	req.Header.Set("Idempotency-Key", key)
+	w := httptest.NewRecorder()
+	m.API(nil).ServeHTTP(w, req)
+	var body map[string]string
+	json.Unmarshal(w.Body.Bytes(), &body)
+	return w.Code, body
+}
+
+func TestIdempotencyKey(t *testing.T) {
+	r := NewFakeRunner()
+	m := NewManager()
+	m.Add("web", &Process{Command: "web", Runner: r, Ping: "1h"})
+	m.Add("db", &Process{Command: "db", Runner: r, Ping: "1h"})
+	RunProcess("web", m.Get("web"))
+	defer m.Get("web").Stop()
+	code, first := postKeyed(m, "/processes/web/restart", "deploy-42")
+	if code != 202 {
+		t.Fatalf("Expected 202. Result %d %#v\n", code, first)
+	}
+	m.Operation(first["id"]).Wait()
+	code, retry := postKeyed(m, "/processes/web/restart", "deploy-42")
+	if code != 202 || retry["id"] != first["id"] {
+		t.Errorf("Expected operation %s again. Result %d %#v\n", first["id"], code, retry)
+	}
+	if starts := len(r.Processes()); starts != 2 {
+		t.Errorf("Expected 2 starts. Result %d\n", starts)
+	}
+	if code, _ := postKeyed(m, "/processes/db/restart", "deploy-42"); code != 422 {
+		t.Errorf("Expected 422 for another process. Result %d\n", code)
+	}
+	if code, other := postKeyed(m, "/processes/web/restart", "deploy-43"); code != 202 || other["id"] == first["id"] {
+		t.Errorf("Expected a new operation. Result %d %#v\n", code, other)
+	}
+}
+
+func TestIdempotencyKeysCoalesced(t *testing.T) {
+	clock := NewFakeClock(time.Now())
+	r := NewFakeRunner()
+	m := NewManager()
+	m.Add("web", &Process{Command: "web", Runner: r, Clock: clock, Ping: "1h", RestartDebounce: "10s"})
+	RunProcess("web", m.Get("web"))
+	defer m.Get("web").Stop()
+	_, a := postKeyed(m, "/processes/web/restart", "a")
+	_, b := postKeyed(m, "/processes/web/restart", "b")
+	if a["id"] == "" || b["id"] != a["id"] {
+		t.Fatalf("Expected one coalesced restart. Result %#v %#v\n", a, b)
+	}
+	clock.BlockUntil(2)
+	clock.Advance(10 * time.Second)
+	m.Operation(a["id"]).Wait()
+	for _, key := range []string{"a", "b"} {
+		if _, retry := postKeyed(m, "/processes/web/restart", key); retry["id"] != a["id"] {
+			t.Errorf("Expected operation %s for key %s. Result %#v\n", a["id"], key, retry)
+		}
+	}
+	if starts := len(r.Processes()); starts != 2 {
+		t.Errorf("Expected 2 starts. Result %d\n", starts)
+	}
+}
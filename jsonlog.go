@@ -0,0 +1,88 @@
+// goforever - processes management
+// Copyright (c) 2013 Garrett Woodworth (https://github.com/gwoo).
+
+package process
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+)
+
+//Keys holding the level and message in child JSON lines.
+var (
+	levelKeys   = []string{"level", "lvl", "severity"}
+	messageKeys = []string{"msg", "message"}
+)
+
+//Parse a JSON object line written by a child. Level and message are taken
+//from their usual keys, everything else is returned as fields.
+func parseJSONLine(line []byte) (Level, string, Fields, bool) {
+	line = bytes.TrimSpace(line)
+	if len(line) == 0 || line[0] != '{' {
+		return LevelInfo, "", nil, false
+	}
+	fields := Fields{}
+	if err := json.Unmarshal(line, &fields); err != nil {
+		return LevelInfo, "", nil, false
+	}
+	level := LevelInfo
+	for _, k := range levelKeys {
+		if s, ok := fields[k].(string); ok {
+			level = childLevel(s)
+			delete(fields, k)
+			break
+		}
+	}
+	var msg string
+	for _, k := range messageKeys {
+		if s, ok := fields[k].(string); ok {
+			msg = s
+			delete(fields, k)
+			break
+		}
+	}
+	return level, msg, fields, true
+}
+
+//Map the level names used by common logging libraries.
+func childLevel(s string) Level {
+	switch strings.ToLower(s) {
+	case "trace":
+		return LevelDebug
+	case "warning":
+		return LevelWarn
+	case "err", "fatal", "critical", "panic":
+		return LevelError
+	}
+	l, _ := ParseLevel(s)
+	return l
+}
+
+//Sink passing child output to the process's structured logger. With
+//Output.JSON, JSON lines keep their level, message and fields; other lines
+//are logged as text at info, or warn for stderr.
+type loggerSink struct {
+	p      *Process
+	stream string
+}
+
+func (s *loggerSink) Write(line []byte) (int, error) {
+	if s.p.Output.JSON {
+		if level, msg, fields, ok := parseJSONLine(line); ok {
+			fields["stream"] = s.stream
+			s.p.log(level, msg, fields)
+			return len(line), nil
+		}
+	}
+	level := LevelInfo
+	if s.stream == "stderr" {
+		level = LevelWarn
+	}
+	s.p.log(level, strings.TrimRight(string(line), "\r\n"), Fields{"stream": s.stream})
+	return len(line), nil
+}
+
+func (s *loggerSink) Close() error {
+	return nil
+}
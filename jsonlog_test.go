@@ -0,0 +1,61 @@
+// goforever - processes management
+// Copyright (c) 2013 Garrett Woodworth (https://github.com/gwoo).
+
+package process
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestParseJSONLine(t *testing.T) {
+	level, msg, fields, ok := parseJSONLine([]byte(`{"level":"warning","msg":"slow","ms":12}` + "\n"))
+	if !ok || level != LevelWarn || msg != "slow" || fields["ms"] != float64(12) || len(fields) != 1 {
+		t.Errorf("Expected warn \"slow\" ms=12. Result %v %#v %#v\n", level, msg, fields)
+	}
+	if _, _, _, ok := parseJSONLine([]byte("plain text\n")); ok {
+		t.Error("Expected plain text not to parse.")
+	}
+}
+
+func TestLoggerSink(t *testing.T) {
+	l := &recordLogger{}
+	p := &Process{Name: "web", Logger: l, Output: &Output{JSON: true}}
+	s := &loggerSink{p: p, stream: "stdout"}
+	s.Write([]byte(`{"level":"error","message":"boom","code":3}` + "\n"))
+	s.Write([]byte("hello\n"))
+	entries := l.entries()
+	if len(entries) != 2 {
+		t.Fatalf("Expected 2 entries. Result %#v\n", entries)
+	}
+	if e := entries[0]; e.level != LevelError || e.msg != "boom" || e.fields["code"] != float64(3) || e.fields["stream"] != "stdout" {
+		t.Errorf("Expected error \"boom\" code=3. Result %#v\n", e)
+	}
+	if e := entries[1]; e.level != LevelInfo || e.msg != "hello" {
+		t.Errorf("Expected info \"hello\". Result %#v\n", e)
+	}
+}
+
+type entry struct {
+	level  Level
+	msg    string
+	fields Fields
+}
+
+//Logger keeping every entry, for tests.
+type recordLogger struct {
+	mu   sync.Mutex
+	list []entry
+}
+
+func (l *recordLogger) Log(level Level, msg string, fields Fields) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.list = append(l.list, entry{level, msg, fields})
+}
+
+func (l *recordLogger) entries() []entry {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]entry(nil), l.list...)
+}
@@ -0,0 +1,88 @@
+// goforever - processes management
+// Copyright (c) 2013 Garrett Woodworth (https://github.com/gwoo).
+
+package process
+
+import (
+	"fmt"
+)
+
+//Probes whose failure restarts the process. Health probes are readiness
+//checks: failing them only marks the process not ready, which removes its
+//registration and holds up rolling restarts.
+type Liveness struct {
+	Probes []Probe `json:"probes"`
+	//Consecutive failures before a restart. Defaults to 3.
+	Failures int `json:"failures,omitempty"`
+	//Grace period after a start before probing.
+	Delay string `json:"delay,omitempty"`
+}
+
+//Consecutive liveness failures of one run. Once restarting the run is not
+//checked again.
+type liveState struct {
+	pid        int
+	failures   int
+	restarting bool
+}
+
+//Run the Liveness probes once, restarting the process when they failed
+//Failures times in a row. True if it was restarted.
+func (p *Process) checkLiveness() bool {
+	l := p.Liveness
+	p.mu.Lock()
+	pid, started := p.Pid, p.started
+	p.mu.Unlock()
+	if l == nil || pid <= 0 || p.clock().Now().Sub(started) < durationOr(l.Delay, 0) {
+		return false
+	}
+	p.mu.Lock()
+	restarting := p.liveSt != nil && p.liveSt.pid == pid && p.liveSt.restarting
+	p.mu.Unlock()
+	if restarting || !p.cycle.TryLock() {
+		return false
+	}
+	err := p.checkProbes(l.Probes)
+	p.cycle.Unlock()
+	p.mu.Lock()
+	if p.liveSt == nil || p.liveSt.pid != pid {
+		p.liveSt = &liveState{pid: pid}
+	}
+	if err == nil {
+		p.liveSt.failures = 0
+	} else {
+		p.liveSt.failures++
+	}
+	failures := p.liveSt.failures
+	limit := l.Failures
+	if limit <= 0 {
+		limit = 3
+	}
+	if err == nil || failures < limit {
+		p.mu.Unlock()
+		return false
+	}
+	p.liveSt.failures = 0
+	p.liveSt.restarting = true
+	p.mu.Unlock()
+	reason := fmt.Sprintf("liveness failed %d times: %s", failures, err)
+	p.log(LevelWarn, "not live, restarting", Fields{"error": err, "failures": failures})
+	p.emit(EventRestart, reason)
+	p.Restart()
+	return true
+}
+
+//Whether the process passes its readiness (Health) checks: its health
+//state is healthy or degraded. Without probes a running process is ready.
+func (p *Process) Ready() bool {
+	if len(p.Health) == 0 {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		return p.Pid > 0
+	}
+	switch p.HealthState() {
+	case HealthHealthy, HealthDegraded:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,49 @@
+// goforever - processes management
+// Copyright (c) 2013 Garrett Woodworth (https://github.com/gwoo).
+
+package process
+
+import (
+	"testing"
+)
+
+func TestLivenessRestart(t *testing.T) {
+	r := NewFakeRunner()
+	m := NewManager()
+	m.Runner = r
+	var reasons []string
+	m.OnEvent(func(e Event) {
+		if e.Type == EventRestart {
+			reasons = append(reasons, e.Reason)
+		}
+	})
+	p := &Process{
+		Command:  "/usr/bin/web",
+		Ping:     "1h",
+		Liveness: &Liveness{Probes: []Probe{{File: "/nonexistent/alive"}}, Failures: 2},
+		Health:   []Probe{{File: "/nonexistent/ready"}},
+	}
+	m.Add("web", p)
+	RunProcess("web", p)
+	first := r.Running()[0]
+	if p.checkLiveness() {
+		t.Error("Expected no restart after one failure.")
+	}
+	if !p.checkLiveness() {
+		t.Fatal("Expected a restart after two failures.")
+	}
+	if p.checkLiveness() {
+		t.Error("Expected no second restart of the same run.")
+	}
+	waitFor(t, func() bool { return len(r.Running()) == 1 && r.Running()[0] != first })
+	if !first.Exited() || len(reasons) != 1 {
+		t.Errorf("Expected one restart. Result %#v\n", reasons)
+	}
+	if p.checkLiveness() {
+		t.Error("Expected the failures to reset with the new process.")
+	}
+	p.checkHealth()
+	if p.Ready() || p.Snapshot().Ready {
+		t.Error("Expected failing readiness to mark the process not ready.")
+	}
+}
@@ -0,0 +1,325 @@
+// goforever - processes management
+// Copyright (c) 2013 Garrett Woodworth (https://github.com/gwoo).
+
+package process
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+//Lease based lock electing the node that runs a singleton process. A lease
+//not renewed within its ttl expires, letting another node take over.
+type Locker interface {
+	//Acquire or renew the lock on key for node, reporting whether node
+	//holds it.
+	Lock(key, node string, ttl time.Duration) (bool, error)
+	//Release the lock if node holds it.
+	Unlock(key, node string) error
+}
+
+//Built-in lock of a cluster's singletons.
+type Lock struct {
+	//file, etcd or consul.
+	Type string `json:"type"`
+	//Directory of the lock files, shared by the nodes (e.g. over NFS).
+	//File locks rely on the nodes' clocks being in sync.
+	Path string `json:"path,omitempty"`
+	//etcd or Consul URL, defaults as for Registration.
+	URL string `json:"url,omitempty"`
+	//Key prefix, default process/singleton/.
+	Prefix string `json:"prefix,omitempty"`
+	//Lease time, default three cluster intervals.
+	TTL string `json:"ttl,omitempty"`
+}
+
+func (l *Lock) locker() (Locker, error) {
+	switch l.Type {
+	case "file":
+		if l.Path == "" {
+			return nil, errors.New("A file lock needs a path.")
+		}
+		return &fileLocker{dir: l.Path}, nil
+	case "etcd":
+		return &etcdLocker{url: (&Registration{Type: "etcd", URL: l.URL}).url(), leases: map[string]string{}}, nil
+	case "consul":
+		return &consulLocker{url: (&Registration{Type: "consul", URL: l.URL}).url(), sessions: map[string]string{}}, nil
+	}
+	return nil, fmt.Errorf("Unknown lock %q.", l.Type)
+}
+
+func (l *Lock) key(name string) string {
+	prefix := l.Prefix
+	if prefix == "" {
+		prefix = "process/singleton/"
+	}
+	return strings.TrimRight(prefix, "/") + "/" + name
+}
+
+//Lock files holding the owner and the lease's expiry. Each renewal or
+//takeover moves the lease to the next generation, which only the node
+//creating its claim file may do.
+type fileLocker struct {
+	dir string
+}
+
+type fileLease struct {
+	Node    string    `json:"node"`
+	Expires time.Time `json:"expires"`
+	Gen     int       `json:"gen,omitempty"`
+}
+
+func (f *fileLocker) path(key string) string {
+	return filepath.Join(f.dir, strings.Replace(key, "/", "_", -1)+".lock")
+}
+
+func (f *fileLocker) read(path string) (*fileLease, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	l := &fileLease{}
+	if err := json.Unmarshal(data, l); err != nil {
+		return nil, fmt.Errorf("%s: %s", path, err)
+	}
+	return l, nil
+}
+
+func (f *fileLocker) Lock(key, node string, ttl time.Duration) (bool, error) {
+	path := f.path(key)
+	data, _ := json.Marshal(&fileLease{Node: node, Expires: time.Now().Add(ttl)})
+	l, err := f.read(path)
+	if os.IsNotExist(err) {
+		//Only one node can create the file.
+		w, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+		if os.IsExist(err) {
+			return false, nil
+		}
+		if err != nil {
+			return false, err
+		}
+		_, err = w.Write(data)
+		if cerr := w.Close(); err == nil {
+			err = cerr
+		}
+		return err == nil, err
+	}
+	if err != nil {
+		return false, err
+	}
+	if l.Node != node && time.Now().Before(l.Expires) {
+		return false, nil
+	}
+	//Renew, or take over the expired lease. Of the nodes racing for it
+	//only one creates the claim of the next generation.
+	gen, ok, err := f.claim(path, node, l.Gen+1, ttl)
+	if !ok || err != nil {
+		return false, err
+	}
+	data, _ = json.Marshal(&fileLease{Node: node, Expires: time.Now().Add(ttl), Gen: gen})
+	tmp := fmt.Sprintf("%s.%s.tmp", path, url.PathEscape(node))
+	if err := ioutil.WriteFile(tmp, data, 0644); err != nil {
+		return false, err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return false, err
+	}
+	//Keep the previous claim too, for a node that read the previous lease.
+	f.prune(path, gen-1)
+	return true, nil
+}
+
+func (f *fileLocker) claimPath(path string, gen int) string {
+	return fmt.Sprintf("%s.%d", path, gen)
+}
+
+//Create the claim of generation gen, or of a later one when a node left
+//its claim behind for longer than ttl without moving the lease to it.
+func (f *fileLocker) claim(path, node string, gen int, ttl time.Duration) (int, bool, error) {
+	for ; ; gen++ {
+		name := f.claimPath(path, gen)
+		w, err := os.OpenFile(name, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+		if err == nil {
+			_, err = w.Write([]byte(node))
+			if cerr := w.Close(); err == nil {
+				err = cerr
+			}
+			return gen, err == nil, err
+		}
+		if !os.IsExist(err) {
+			return 0, false, err
+		}
+		fi, err := os.Stat(name)
+		if err != nil || time.Since(fi.ModTime()) < ttl {
+			return 0, false, nil
+		}
+		if l, err := f.read(path); err != nil || l.Gen >= gen {
+			//Moved on since it was read.
+			return 0, false, nil
+		}
+	}
+}
+
+//Remove the claims of the generations before gen.
+func (f *fileLocker) prune(path string, gen int) {
+	names, _ := filepath.Glob(path + ".*")
+	for _, name := range names {
+		n, err := strconv.Atoi(strings.TrimPrefix(name, path+"."))
+		if err == nil && n < gen {
+			os.Remove(name)
+		}
+	}
+}
+
+func (f *fileLocker) Unlock(key, node string) error {
+	path := f.path(key)
+	l, err := f.read(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil || l.Node != node {
+		return err
+	}
+	if err := os.Remove(path); err != nil {
+		return err
+	}
+	f.prune(path, l.Gen+2)
+	return nil
+}
+
+//Keys attached to etcd leases, created only when missing.
+type etcdLocker struct {
+	url    string
+	mu     sync.Mutex
+	leases map[string]string
+}
+
+func b64(s string) string {
+	return base64.StdEncoding.EncodeToString([]byte(s))
+}
+
+func (e *etcdLocker) Lock(key, node string, ttl time.Duration) (bool, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if id := e.leases[key]; id != "" {
+		var alive struct {
+			Result struct {
+				TTL string `json:"TTL"`
+			} `json:"result"`
+		}
+		if err := registryJSON("POST", e.url+"/v3/lease/keepalive", map[string]string{"ID": id}, &alive); err != nil {
+			return false, err
+		}
+		if alive.Result.TTL == "" || alive.Result.TTL == "0" {
+			delete(e.leases, key)
+		}
+	}
+	if e.leases[key] == "" {
+		var grant struct {
+			ID string `json:"ID"`
+		}
+		secs := strconv.Itoa(int((ttl + time.Second - 1) / time.Second))
+		if err := registryJSON("POST", e.url+"/v3/lease/grant", map[string]string{"TTL": secs}, &grant); err != nil {
+			return false, err
+		}
+		e.leases[key] = grant.ID
+	}
+	var txn struct {
+		Succeeded bool `json:"succeeded"`
+		Responses []struct {
+			Range struct {
+				Kvs []struct {
+					Value string `json:"value"`
+				} `json:"kvs"`
+			} `json:"response_range"`
+		} `json:"responses"`
+	}
+	err := registryJSON("POST", e.url+"/v3/kv/txn", map[string]interface{}{
+		"compare": []map[string]string{{"target": "CREATE", "key": b64(key), "create_revision": "0"}},
+		"success": []map[string]interface{}{{"request_put": map[string]string{"key": b64(key), "value": b64(node), "lease": e.leases[key]}}},
+		"failure": []map[string]interface{}{{"request_range": map[string]string{"key": b64(key)}}},
+	}, &txn)
+	if err != nil || txn.Succeeded {
+		return txn.Succeeded, err
+	}
+	for _, r := range txn.Responses {
+		for _, kv := range r.Range.Kvs {
+			value, _ := base64.StdEncoding.DecodeString(kv.Value)
+			return string(value) == node, nil
+		}
+	}
+	return false, nil
+}
+
+//Revoking the lease deletes the key if it is attached to it.
+func (e *etcdLocker) Unlock(key, node string) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	id := e.leases[key]
+	if id == "" {
+		return nil
+	}
+	delete(e.leases, key)
+	return registryCall("POST", e.url+"/v3/lease/revoke", map[string]string{"ID": id})
+}
+
+//Consul KV locks acquired with sessions deleting the key on expiry.
+type consulLocker struct {
+	url      string
+	mu       sync.Mutex
+	sessions map[string]string
+}
+
+func (c *consulLocker) Lock(key, node string, ttl time.Duration) (bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if id := c.sessions[key]; id != "" {
+		var renewed []json.RawMessage
+		if err := registryJSON("PUT", c.url+"/v1/session/renew/"+id, nil, &renewed); err != nil || len(renewed) == 0 {
+			delete(c.sessions, key)
+		}
+	}
+	if c.sessions[key] == "" {
+		var session struct {
+			ID string `json:"ID"`
+		}
+		err := registryJSON("PUT", c.url+"/v1/session/create", map[string]string{
+			"Name":     key,
+			"TTL":      ttl.String(),
+			"Behavior": "delete",
+		}, &session)
+		if err != nil {
+			return false, err
+		}
+		c.sessions[key] = session.ID
+	}
+	var acquired bool
+	u := c.url + "/v1/kv/" + key + "?acquire=" + url.QueryEscape(c.sessions[key])
+	if err := registryJSON("PUT", u, node, &acquired); err != nil {
+		return false, err
+	}
+	return acquired, nil
+}
+
+func (c *consulLocker) Unlock(key, node string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	id := c.sessions[key]
+	if id == "" {
+		return nil
+	}
+	delete(c.sessions, key)
+	registryCall("PUT", c.url+"/v1/kv/"+key+"?release="+url.QueryEscape(id), node)
+	return registryCall("PUT", c.url+"/v1/session/destroy/"+id, nil)
+}
@@ -0,0 +1,222 @@
+// goforever - processes management
+// Copyright (c) 2013 Garrett Woodworth (https://github.com/gwoo).
+
+package process
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+//Check that a and b exclude each other on key and that unlocking hands
+//the lock over.
+func testLocker(t *testing.T, a, b Locker) {
+	if held, err := a.Lock("singleton/web", "a", time.Minute); !held || err != nil {
+		t.Fatalf("Expected a to acquire the lock. Result %v %v\n", held, err)
+	}
+	if held, err := b.Lock("singleton/web", "b", time.Minute); held || err != nil {
+		t.Errorf("Expected b not to get a held lock. Result %v %v\n", held, err)
+	}
+	if held, err := a.Lock("singleton/web", "a", time.Minute); !held || err != nil {
+		t.Errorf("Expected a to renew the lock. Result %v %v\n", held, err)
+	}
+	if err := b.Unlock("singleton/web", "b"); err != nil {
+		t.Errorf("Expected %#v. Result %#v\n", nil, err)
+	}
+	if err := a.Unlock("singleton/web", "a"); err != nil {
+		t.Errorf("Expected %#v. Result %#v\n", nil, err)
+	}
+	if held, err := b.Lock("singleton/web", "b", time.Minute); !held || err != nil {
+		t.Errorf("Expected b to acquire the released lock. Result %v %v\n", held, err)
+	}
+}
+
+func TestFileLocker(t *testing.T) {
+	dir := t.TempDir()
+	testLocker(t, &fileLocker{dir: dir}, &fileLocker{dir: dir})
+	f := &fileLocker{dir: dir}
+	f.Lock("db", "a", time.Millisecond)
+	time.Sleep(5 * time.Millisecond)
+	if held, err := f.Lock("db", "b", time.Minute); !held || err != nil {
+		t.Errorf("Expected b to take over an expired lease. Result %v %v\n", held, err)
+	}
+	if held, err := f.Lock("db", "b", time.Minute); !held || err != nil {
+		t.Errorf("Expected b to renew. Result %v %v\n", held, err)
+	}
+	f.Unlock("db", "b")
+	if names, _ := filepath.Glob(filepath.Join(dir, "db.lock*")); len(names) != 0 {
+		t.Errorf("Expected the lock files removed. Result %#v\n", names)
+	}
+}
+
+func TestFileLockerTakeover(t *testing.T) {
+	dir := t.TempDir()
+	f := &fileLocker{dir: dir}
+	for round := 0; round < 300; round++ {
+		f.Lock("db", "old", time.Millisecond)
+		time.Sleep(2 * time.Millisecond)
+		var wg sync.WaitGroup
+		var mu sync.Mutex
+		var held []string
+		start := make(chan struct{})
+		for i := 0; i < 16; i++ {
+			wg.Add(1)
+			go func(node string) {
+				defer wg.Done()
+				<-start
+				if ok, _ := (&fileLocker{dir: dir}).Lock("db", node, time.Minute); ok {
+					mu.Lock()
+					held = append(held, node)
+					mu.Unlock()
+				}
+			}(fmt.Sprint("node", i))
+		}
+		close(start)
+		wg.Wait()
+		if len(held) != 1 {
+			t.Fatalf("Expected one node to take over. Result %#v\n", held)
+		}
+		f.Unlock("db", held[0])
+	}
+}
+
+//Enough of etcd's lease and txn API for the locker.
+type etcdServer struct {
+	mu     sync.Mutex
+	seq    int
+	leases map[string]bool
+	kvs    map[string][2]string
+}
+
+func (s *etcdServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var body map[string]interface{}
+	data, _ := ioutil.ReadAll(r.Body)
+	json.Unmarshal(data, &body)
+	switch r.URL.Path {
+	case "/v3/lease/grant":
+		s.seq++
+		id := fmt.Sprint(s.seq)
+		s.leases[id] = true
+		fmt.Fprintf(w, `{"ID":%q,"TTL":%q}`, id, body["TTL"])
+	case "/v3/lease/keepalive":
+		ttl := "0"
+		if s.leases[body["ID"].(string)] {
+			ttl = "60"
+		}
+		fmt.Fprintf(w, `{"result":{"TTL":%q}}`, ttl)
+	case "/v3/lease/revoke":
+		id := body["ID"].(string)
+		delete(s.leases, id)
+		for k, kv := range s.kvs {
+			if kv[1] == id {
+				delete(s.kvs, k)
+			}
+		}
+		fmt.Fprint(w, `{}`)
+	case "/v3/kv/txn":
+		key := body["compare"].([]interface{})[0].(map[string]interface{})["key"].(string)
+		if kv, ok := s.kvs[key]; ok {
+			fmt.Fprintf(w, `{"succeeded":false,"responses":[{"response_range":{"kvs":[{"value":%q}]}}]}`, kv[0])
+			return
+		}
+		put := body["success"].([]interface{})[0].(map[string]interface{})["request_put"].(map[string]interface{})
+		s.kvs[key] = [2]string{put["value"].(string), put["lease"].(string)}
+		fmt.Fprint(w, `{"succeeded":true}`)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func TestEtcdLocker(t *testing.T) {
+	s := &etcdServer{leases: map[string]bool{}, kvs: map[string][2]string{}}
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+	l := &Lock{Type: "etcd", URL: ts.URL}
+	a, _ := l.locker()
+	b, _ := l.locker()
+	testLocker(t, a, b)
+	if kv := s.kvs[base64.StdEncoding.EncodeToString([]byte("singleton/web"))]; kv[0] != base64.StdEncoding.EncodeToString([]byte("b")) {
+		t.Errorf("Expected the key to hold b. Result %#v\n", kv)
+	}
+}
+
+//Enough of Consul's session and KV API for the locker.
+type consulServer struct {
+	mu       sync.Mutex
+	seq      int
+	sessions map[string]bool
+	holders  map[string]string
+}
+
+func (s *consulServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch path := r.URL.Path; {
+	case path == "/v1/session/create":
+		s.seq++
+		id := fmt.Sprint(s.seq)
+		s.sessions[id] = true
+		fmt.Fprintf(w, `{"ID":%q}`, id)
+	case strings.HasPrefix(path, "/v1/session/renew/"):
+		if !s.sessions[strings.TrimPrefix(path, "/v1/session/renew/")] {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, `[{}]`)
+	case strings.HasPrefix(path, "/v1/session/destroy/"):
+		id := strings.TrimPrefix(path, "/v1/session/destroy/")
+		delete(s.sessions, id)
+		for k, holder := range s.holders {
+			if holder == id {
+				delete(s.holders, k)
+			}
+		}
+		fmt.Fprint(w, `true`)
+	case strings.HasPrefix(path, "/v1/kv/"):
+		key := strings.TrimPrefix(path, "/v1/kv/")
+		if id := r.URL.Query().Get("release"); id != "" {
+			if s.holders[key] == id {
+				delete(s.holders, key)
+			}
+			fmt.Fprint(w, `true`)
+			return
+		}
+		id := r.URL.Query().Get("acquire")
+		if holder, ok := s.holders[key]; ok && holder != id {
+			fmt.Fprint(w, `false`)
+			return
+		}
+		s.holders[key] = id
+		fmt.Fprint(w, `true`)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func TestConsulLocker(t *testing.T) {
+	ts := httptest.NewServer(&consulServer{sessions: map[string]bool{}, holders: map[string]string{}})
+	defer ts.Close()
+	l := &Lock{Type: "consul", URL: ts.URL}
+	a, _ := l.locker()
+	b, _ := l.locker()
+	testLocker(t, a, b)
+}
+
+func TestLockErrors(t *testing.T) {
+	for _, l := range []*Lock{{Type: "file"}, {Type: "zookeeper"}} {
+		if _, err := l.locker(); err == nil {
+			t.Errorf("%s: expected an error.\n", l.Type)
+		}
+	}
+}
@@ -0,0 +1,296 @@
+// goforever - processes management
+// Copyright (c) 2013 Garrett Woodworth (https://github.com/gwoo).
+
+package process
+
+import (
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"sync"
+)
+
+//Permissions of log files and of the directories created for them. Modes
+//are octal strings; owner and group are names or numeric ids.
+type LogFiles struct {
+	//Defaults to 0750.
+	DirMode string `json:"dir_mode,omitempty"`
+	//Defaults to 0660.
+	FileMode string `json:"file_mode,omitempty"`
+	Owner    string `json:"owner,omitempty"`
+	Group    string `json:"group,omitempty"`
+}
+
+func modeOr(s string, def os.FileMode) os.FileMode {
+	if m, err := strconv.ParseUint(s, 8, 32); err == nil {
+		return os.FileMode(m)
+	}
+	return def
+}
+
+//Uid and gid to chown to, -1 for unchanged.
+func (c *LogFiles) ids() (int, int, error) {
+	uid, gid := -1, -1
+	if c.Owner != "" {
+		id := c.Owner
+		if u, err := user.Lookup(c.Owner); err == nil {
+			id = u.Uid
+		}
+		n, err := strconv.Atoi(id)
+		if err != nil {
+			return -1, -1, user.UnknownUserError(c.Owner)
+		}
+		uid = n
+	}
+	if c.Group != "" {
+		id := c.Group
+		if g, err := user.LookupGroup(c.Group); err == nil {
+			id = g.Gid
+		}
+		n, err := strconv.Atoi(id)
+		if err != nil {
+			return -1, -1, user.UnknownGroupError(c.Group)
+		}
+		gid = n
+	}
+	return uid, gid, nil
+}
+
+//Function giving a path the configured owner and group.
+func (c *LogFiles) chowner() (func(string) error, error) {
+	uid, gid, err := c.ids()
+	if err != nil {
+		return nil, err
+	}
+	return func(path string) error {
+		if uid == -1 && gid == -1 {
+			return nil
+		}
+		return os.Chown(path, uid, gid)
+	}, nil
+}
+
+//Open a log file for appending, creating missing parent directories.
+//Created directories and files get the configured modes and owner; a nil
+//c uses the defaults.
+func openLog(path string, c *LogFiles) (*os.File, error) {
+	if c == nil {
+		c = &LogFiles{}
+	}
+	chown, err := c.chowner()
+	if err != nil {
+		return nil, err
+	}
+	if err := mkdirs(filepath.Dir(path), modeOr(c.DirMode, 0750), chown); err != nil {
+		return nil, err
+	}
+	_, err = os.Stat(path)
+	created := os.IsNotExist(err)
+	mode := modeOr(c.FileMode, 0660)
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR|os.O_APPEND, mode)
+	if err != nil {
+		return nil, err
+	}
+	if created {
+		file.Chmod(mode)
+		if err := chown(path); err != nil {
+			file.Close()
+			return nil, err
+		}
+	}
+	return file, nil
+}
+
+//Create dir and its missing parents with mode, regardless of the umask.
+func mkdirs(dir string, mode os.FileMode, chown func(string) error) error {
+	if _, err := os.Stat(dir); err == nil {
+		return nil
+	}
+	if parent := filepath.Dir(dir); parent != dir {
+		if err := mkdirs(parent, mode, chown); err != nil {
+			return err
+		}
+	}
+	if err := os.Mkdir(dir, mode); err != nil {
+		if os.IsExist(err) {
+			return nil
+		}
+		return err
+	}
+	if err := os.Chmod(dir, mode); err != nil {
+		return err
+	}
+	return chown(dir)
+}
+
+//Open a log of the process, falling back to the supervisor's stderr when
+//it cannot be opened. Nil for an empty path.
+func (p *Process) openLog(path string) *os.File {
+	if path == "" {
+		return nil
+	}
+	file, err := openLog(path, p.LogFiles)
+	if err != nil {
+		p.log(LevelError, "log open failed, using stderr", Fields{"file": path, "error": err})
+		return os.Stderr
+	}
+	return file
+}
+
+//File sink of piped output, rotated by Output.Rotate and reopened by
+//Manager.ReopenLogs.
+//Piped output file, shared by the streams written to the same path so that
+//they rotate it together.
+type logFile struct {
+	p    *Process
+	path string
+	r    *Rotate
+	mu   sync.Mutex
+	f    *os.File
+	size int64
+	//Streams holding the file, guarded by p.mu.
+	refs int
+}
+
+func (p *Process) openLogFile(path string) (*logFile, error) {
+	f := &logFile{p: p, path: path, refs: 1}
+	if p.Output != nil {
+		f.r = p.Output.Rotate
+	}
+	if err := f.r.check(); err != nil {
+		return nil, err
+	}
+	if l := p.sharedLog(path); l != nil {
+		return l, nil
+	}
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+	p.mu.Lock()
+	for _, l := range p.logs {
+		if l.path == path {
+			//Opened by the other stream meanwhile.
+			l.refs++
+			p.mu.Unlock()
+			f.f.Close()
+			return l, nil
+		}
+	}
+	p.logs = append(p.logs, f)
+	p.mu.Unlock()
+	return f, nil
+}
+
+//The log file already open at path, with one more reference, nil if none.
+func (p *Process) sharedLog(path string) *logFile {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, l := range p.logs {
+		if l.path == path {
+			l.refs++
+			return l
+		}
+	}
+	return nil
+}
+
+func (f *logFile) open() error {
+	file, err := openLog(f.path, f.p.LogFiles)
+	if err != nil {
+		return err
+	}
+	fi, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	f.f, f.size = file, fi.Size()
+	return nil
+}
+
+func (f *logFile) Write(b []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.f == nil {
+		if err := f.open(); err != nil {
+			return 0, err
+		}
+	}
+	if f.r != nil && f.r.MaxSize > 0 && f.size > 0 && f.size+int64(len(b)) > f.r.MaxSize {
+		if err := f.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := f.f.Write(b)
+	f.size += int64(n)
+	return n, err
+}
+
+//Drop a reference, closing the file with the last one.
+func (f *logFile) Close() error {
+	f.p.mu.Lock()
+	if f.refs--; f.refs > 0 {
+		f.p.mu.Unlock()
+		return nil
+	}
+	for i, l := range f.p.logs {
+		if l == f {
+			f.p.logs = append(f.p.logs[:i], f.p.logs[i+1:]...)
+			break
+		}
+	}
+	f.p.mu.Unlock()
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.f == nil {
+		return nil
+	}
+	err := f.f.Close()
+	f.f = nil
+	return err
+}
+
+//Close the file and open the path again, picking up a file moved away by
+//an external rotation.
+func (f *logFile) reopen() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.f != nil {
+		f.f.Close()
+		f.f = nil
+	}
+	return f.open()
+}
+
+//Reopen the piped output files of the process.
+func (p *Process) ReopenLogs() error {
+	p.mu.Lock()
+	logs := append([]*logFile(nil), p.logs...)
+	p.mu.Unlock()
+	var first error
+	for _, f := range logs {
+		if err := f.reopen(); err != nil {
+			p.log(LevelError, "reopen failed", Fields{"file": f.path, "error": err})
+			if first == nil {
+				first = err
+			}
+		}
+	}
+	return first
+}
+
+//Close and reopen the log files of every process, e.g. on SIGUSR1 after
+//logrotate moved them. Only piped output (see Output) can be reopened;
+//processes without it hold their own files. Returns the first error.
+func (m *Manager) ReopenLogs() error {
+	var first error
+	for _, name := range m.Keys() {
+		if p := m.Get(name); p != nil {
+			if err := p.ReopenLogs(); err != nil && first == nil {
+				first = err
+			}
+		}
+	}
+	return first
+}
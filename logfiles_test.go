@@ -0,0 +1,93 @@
+// goforever - processes management
+// Copyright (c) 2013 Garrett Woodworth (https://github.com/gwoo).
+
+package process
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestNewLogCreatesDirs(t *testing.T) {
+	dir, err := ioutil.TempDir("", "process")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "a", "b", "out.log")
+	f, err := openLog(path, &LogFiles{DirMode: "0700", FileMode: "0600"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	for p, ex := range map[string]os.FileMode{
+		filepath.Join(dir, "a"):      0700 | os.ModeDir,
+		filepath.Join(dir, "a", "b"): 0700 | os.ModeDir,
+		path:                         0600,
+	} {
+		fi, err := os.Stat(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if fi.Mode() != ex {
+			t.Errorf("%s: expected %v. Result %v\n", p, ex, fi.Mode())
+		}
+	}
+	if f, err := NewLog(""); f != nil || err != nil {
+		t.Errorf("Expected nil for an empty path. Result %#v %#v\n", f, err)
+	}
+}
+
+func TestOpenLogFallback(t *testing.T) {
+	dir, err := ioutil.TempDir("", "process")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	p := &Process{Name: "web", Logger: &recordLogger{}}
+	if f := p.openLog(dir); f != os.Stderr {
+		t.Errorf("Expected stderr. Result %#v\n", f)
+	}
+	if len(p.Logger.(*recordLogger).entries()) != 1 {
+		t.Error("Expected the failure to be logged.")
+	}
+	p.LogFiles = &LogFiles{Owner: "no-such-user-here"}
+	if _, err := openLog(filepath.Join(dir, "out.log"), p.LogFiles); err == nil {
+		t.Error("Expected unknown user error.")
+	}
+}
+
+func TestReopenLogs(t *testing.T) {
+	dir, err := ioutil.TempDir("", "process")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	m := NewManager()
+	p := &Process{Output: &Output{}}
+	m.Add("web", p)
+	path := filepath.Join(dir, "out.log")
+	f, err := p.openLogFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Write([]byte("a\n"))
+	if err := os.Rename(path, path+".1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.ReopenLogs(); err != nil {
+		t.Fatal(err)
+	}
+	f.Write([]byte("b\n"))
+	f.Close()
+	for p, ex := range map[string]string{path + ".1": "a\n", path: "b\n"} {
+		if r, _ := ioutil.ReadFile(p); string(r) != ex {
+			t.Errorf("%s: expected %#v. Result %#v\n", p, ex, string(r))
+		}
+	}
+	if len(p.logs) != 0 {
+		t.Errorf("Expected closed files to be forgotten. Result %#v\n", p.logs)
+	}
+}
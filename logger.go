@@ -0,0 +1,139 @@
+// goforever - processes management
+// Copyright (c) 2013 Garrett Woodworth (https://github.com/gwoo).
+
+package process
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+//Structured fields attached to a supervisor log line.
+type Fields map[string]interface{}
+
+//Severity of a supervisor message.
+type Level int
+
+const (
+	LevelDebug Level = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+var levelNames = []string{"debug", "info", "warn", "error"}
+
+func (l Level) String() string {
+	if l < LevelDebug || l > LevelError {
+		return fmt.Sprintf("level(%d)", int(l))
+	}
+	return levelNames[l]
+}
+
+//Parse a level name. "quiet" is an alias for warn and "verbose" for debug.
+func ParseLevel(s string) (Level, error) {
+	switch strings.ToLower(s) {
+	case "quiet":
+		return LevelWarn, nil
+	case "verbose":
+		return LevelDebug, nil
+	}
+	for i, name := range levelNames {
+		if strings.EqualFold(s, name) {
+			return Level(i), nil
+		}
+	}
+	return LevelInfo, fmt.Errorf("Unknown log level %q.", s)
+}
+
+//Logger receives supervisor messages.
+type Logger interface {
+	Log(level Level, msg string, fields Fields)
+}
+
+//Logger used by processes without their own.
+var DefaultLogger Logger = NewTextLogger(os.Stderr)
+
+//Create a logger writing logfmt lines (time=... msg=... key=value).
+func NewTextLogger(w io.Writer) Logger {
+	return &textLogger{w: w}
+}
+
+type textLogger struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (l *textLogger) Log(level Level, msg string, fields Fields) {
+	var b strings.Builder
+	b.WriteString("time=")
+	b.WriteString(time.Now().Format(time.RFC3339))
+	b.WriteString(" level=")
+	b.WriteString(level.String())
+	b.WriteString(" msg=")
+	b.WriteString(logfmtValue(msg))
+	for _, k := range fields.keys() {
+		b.WriteString(" ")
+		b.WriteString(k)
+		b.WriteString("=")
+		b.WriteString(logfmtValue(fmt.Sprint(fields[k])))
+	}
+	b.WriteString("\n")
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	io.WriteString(l.w, b.String())
+}
+
+//Create a logger writing one JSON object per line.
+func NewJSONLogger(w io.Writer) Logger {
+	return &jsonLogger{w: w}
+}
+
+type jsonLogger struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (l *jsonLogger) Log(level Level, msg string, fields Fields) {
+	line := make(map[string]interface{}, len(fields)+2)
+	for k, v := range fields {
+		if err, ok := v.(error); ok {
+			v = err.Error()
+		}
+		line[k] = v
+	}
+	line["time"] = time.Now().Format(time.RFC3339)
+	line["level"] = level.String()
+	line["msg"] = msg
+	js, err := json.Marshal(line)
+	if err != nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.w.Write(append(js, '\n'))
+}
+
+//Sorted keys for stable output.
+func (f Fields) keys() []string {
+	keys := make([]string, 0, len(f))
+	for k := range f {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func logfmtValue(s string) string {
+	if s == "" || strings.ContainsAny(s, " =\"\t\n") {
+		return strconv.Quote(s)
+	}
+	return s
+}
@@ -0,0 +1,174 @@
+// goforever - processes management
+// Copyright (c) 2013 Garrett Woodworth (https://github.com/gwoo).
+
+package process
+
+import (
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+//Manager supervises a set of named processes.
+type Manager struct {
+	//Logger for processes without their own. Nil uses DefaultLogger.
+	Logger Logger
+	//Minimum level logged for processes without their own LogLevel.
+	LogLevel Level
+	//Clock for processes without their own. Nil uses RealClock.
+	Clock Clock
+	//Leave the processes running on Shutdown, keeping their pidfiles, so
+	//that an upgraded supervisor adopts them in Run.
+	Detach bool
+	//Directory of the pidfiles of processes added without one, named
+	//after the process, e.g. /run/goforever/web.pid.
+	RunDir string
+	//Serve the supervisor's pprof profiles and expvar variables in the
+	//API, to operators.
+	Debug bool
+	//Ping interval of processes without their own. Defaults to DefaultPing.
+	Ping string
+	//Runner for processes without their own. Nil uses ExecRunner.
+	Runner Runner
+	//File every event is appended to as a JSON line, keeping the history
+	//beyond what Events holds in memory.
+	EventLog string
+	//Keeps the events, definitions and exit history across restarts, see
+	//Restore.
+	Store Store
+	//Webhooks served at POST /hooks/{name}, see Webhook.
+	Webhooks map[string]*Webhook
+	mu       sync.Mutex
+	procs    children
+	handlers []func(Event)
+	ops      []*Operation
+	opSeq    int
+	started  time.Time
+	errs     []ErrorEntry
+	closing  bool
+	parent   *Manager
+	ns       string
+	spaces   map[string]*Manager
+	cluster  *clusterState
+	events   []Event
+	dropped  bool
+	saving   sync.Mutex
+	saves    sync.WaitGroup
+	revs     map[string][]Revision
+	keys     map[string]*Operation
+}
+
+//Create an empty manager logging at info level.
+func NewManager() *Manager {
+	return &Manager{
+		LogLevel: LevelInfo,
+		procs:    children{},
+		started:  time.Now(),
+	}
+}
+
+//Add a process under a unique, valid name.
+func (m *Manager) Add(name string, p *Process) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.procs.Add(name, p); err != nil {
+		return err
+	}
+	p.manager = m
+	m.defaultPidfile(name, p)
+	return nil
+}
+
+//Add a process as the next free instance of name (name-1, name-2, ...).
+func (m *Manager) AddInstance(name string, p *Process) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	n, err := m.procs.AddInstance(name, p)
+	if err != nil {
+		return "", err
+	}
+	p.manager = m
+	p.group = name
+	m.defaultPidfile(n, p)
+	return n, nil
+}
+
+//Give p a pidfile in RunDir if it has none. Called with m.mu held.
+func (m *Manager) defaultPidfile(name string, p *Process) {
+	if p.Pidfile == "" && m.RunDir != "" {
+		p.Pidfile = Pidfile(filepath.Join(m.RunDir, name+".pid"))
+	}
+}
+
+//Instances added with AddInstance under name, sorted by name.
+func (m *Manager) Instances(name string) []*Process {
+	var procs []*Process
+	for _, n := range m.Keys() {
+		if p := m.Get(n); p != nil && p.group == name {
+			procs = append(procs, p)
+		}
+	}
+	return procs
+}
+
+//Get a process by name, nil if there is none. See Lookup.
+func (m *Manager) Get(name string) *Process {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.procs.Get(name)
+}
+
+//Get a process by name, ErrNotFound if there is none.
+func (m *Manager) Lookup(name string) (*Process, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.procs.Lookup(name)
+}
+
+//Whether there is a process named name.
+func (m *Manager) Exists(name string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.procs.Exists(name)
+}
+
+//Sorted process names.
+func (m *Manager) Keys() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	keys := m.procs.Keys()
+	sort.Strings(keys)
+	return keys
+}
+
+//Run all processes. Processes start after those in their DependsOn have
+//started; without a valid order they all start at once. Processes still
+//running from a detached shutdown are adopted instead. Singleton processes
+//are left to the election when the cluster has a lock.
+func (m *Manager) Run() {
+	layers, err := m.order()
+	if err != nil {
+		m.log(LevelError, "bad dependencies", Fields{"error": err})
+		layers = [][]string{m.Keys()}
+	}
+	for _, layer := range layers {
+		var started sync.WaitGroup
+		for _, name := range layer {
+			p := m.Get(name)
+			if p.Singleton && m.electing() {
+				continue
+			}
+			if p.adopt() {
+				go p.Watch()
+				continue
+			}
+			started.Add(1)
+			go func(name string) {
+				defer started.Done()
+				RunProcess(name, p)
+			}(name)
+		}
+		started.Wait()
+	}
+}
@@ -0,0 +1,112 @@
+// goforever - processes management
+// Copyright (c) 2013 Garrett Woodworth (https://github.com/gwoo).
+
+package process
+
+import (
+	"errors"
+	"os"
+	"regexp"
+	"sort"
+)
+
+//How to recognize the running process among all processes, for daemons
+//started outside the supervisor that write no pidfile. A process must match
+//every field given.
+type Match struct {
+	//Regular expression matched against the command line, its arguments
+	//separated by spaces.
+	Command string `json:"command,omitempty"`
+	//Path of the executable.
+	Exe string `json:"exe,omitempty"`
+	//Port the process listens on, e.g. "8080" or "udp:53".
+	Port string `json:"port,omitempty"`
+}
+
+//A process seen by a scan.
+type procEntry struct {
+	pid     int
+	ppid    int
+	exe     string
+	cmdline string
+}
+
+//Pids of the processes matching m, main processes first: those whose parent
+//does not match too, then by pid.
+func (m *Match) find() ([]int, error) {
+	if m.Command == "" && m.Exe == "" && m.Port == "" {
+		return nil, errors.New("Match needs a command, exe or port.")
+	}
+	var listening map[int]bool
+	if m.Port != "" {
+		network, port, err := parsePort(m.Port)
+		if err != nil {
+			return nil, err
+		}
+		pids, err := portPids(network, port)
+		if err != nil || len(pids) == 0 {
+			return nil, err
+		}
+		listening = map[int]bool{}
+		for _, pid := range pids {
+			listening[pid] = true
+		}
+	}
+	var re *regexp.Regexp
+	if m.Command != "" {
+		var err error
+		if re, err = regexp.Compile(m.Command); err != nil {
+			return nil, err
+		}
+	}
+	procs, err := scanProcs()
+	if err != nil {
+		return nil, err
+	}
+	matched := map[int]bool{}
+	var pids []int
+	for _, e := range procs {
+		if (listening != nil && !listening[e.pid]) || (m.Exe != "" && e.exe != m.Exe) || (re != nil && !re.MatchString(e.cmdline)) {
+			continue
+		}
+		matched[e.pid] = true
+		pids = append(pids, e.pid)
+	}
+	parents := map[int]int{}
+	for _, e := range procs {
+		parents[e.pid] = e.ppid
+	}
+	sort.Slice(pids, func(i, j int) bool {
+		ci, cj := matched[parents[pids[i]]], matched[parents[pids[j]]]
+		if ci != cj {
+			return cj
+		}
+		return pids[i] < pids[j]
+	})
+	return pids, nil
+}
+
+//Pid of the running process: the pidfile's if it is alive, otherwise the
+//main process found by Match. 0 if there is none.
+func (p *Process) locate() (int, error) {
+	pid := 0
+	if p.Pidfile != "" {
+		var err error
+		if pid, err = p.Pidfile.Pid(); err != nil {
+			return 0, err
+		}
+	}
+	if p.Match == nil || (pid > 0 && alive(pid)) {
+		return pid, nil
+	}
+	pids, err := p.Match.find()
+	if err != nil {
+		return 0, err
+	}
+	for _, pid := range pids {
+		if pid != os.Getpid() {
+			return pid, nil
+		}
+	}
+	return 0, nil
+}
@@ -0,0 +1,67 @@
+// goforever - processes management
+// Copyright (c) 2013 Garrett Woodworth (https://github.com/gwoo).
+
+package process
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+func TestMatch(t *testing.T) {
+	//A legacy daemon started outside the supervisor, without a pidfile.
+	cmd := exec.Command("/bin/sleep", "31.4159")
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer cmd.Process.Kill()
+	go cmd.Wait()
+	pid := cmd.Process.Pid
+
+	pids, err := (&Match{Command: `^/bin/sleep 31\.4159$`}).find()
+	if err != nil || len(pids) != 1 || pids[0] != pid {
+		t.Errorf("Expected [%d]. Result %#v %#v\n", pid, pids, err)
+	}
+	if exe, ok := procExe(pid); ok {
+		pids, _ = (&Match{Command: `31\.4159`, Exe: exe}).find()
+		if len(pids) != 1 || pids[0] != pid {
+			t.Errorf("Expected [%d] by exe. Result %#v\n", pid, pids)
+		}
+		pids, _ = (&Match{Command: `31\.4159`, Exe: "/nonexistent"}).find()
+		if len(pids) != 0 {
+			t.Errorf("Expected no match. Result %#v\n", pids)
+		}
+	}
+	if _, err := (&Match{}).find(); err == nil {
+		t.Error("Expected an empty match to fail.")
+	}
+	if _, err := (&Match{Command: "("}).find(); err == nil {
+		t.Error("Expected a bad expression to fail.")
+	}
+
+	dir, err := ioutil.TempDir("", "process")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	pidfile := Pidfile(filepath.Join(dir, "sleep.pid"))
+	m := NewManager()
+	p := &Process{Command: "/bin/sleep", Args: []string{"31.4159"}, Pidfile: pidfile, Ping: "1h",
+		Match: &Match{Command: `^/bin/sleep 31\.4159$`}}
+	m.Add("sleep", p)
+	m.Run()
+	if s := p.Snapshot(); s.Pid != pid || s.Status != "running" {
+		t.Errorf("Expected pid %d adopted. Result %#v\n", pid, s)
+	}
+	if pidfile.read() != pid {
+		t.Errorf("Expected pid %d recorded. Result %#v\n", pid, pidfile.read())
+	}
+	if err := m.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, func() bool { return !alive(pid) })
+}
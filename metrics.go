@@ -0,0 +1,121 @@
+// goforever - processes management
+// Copyright (c) 2013 Garrett Woodworth (https://github.com/gwoo).
+
+package process
+
+import (
+	"fmt"
+	"io"
+	"sort"
+)
+
+//One value of a metric with its extra labels, e.g. `stream="stdout"`.
+type sample struct {
+	labels string
+	value  float64
+}
+
+//Exported metric.
+type metric struct {
+	name, typ, help string
+	samples         func(ProcessInfo) []sample
+}
+
+var metrics = []metric{
+	{"process_up", "gauge", "Whether the process is running.", func(s ProcessInfo) []sample {
+		if s.Pid > 0 {
+			return []sample{{"", 1}}
+		}
+		return []sample{{"", 0}}
+	}},
+	{"process_respawns", "gauge", "Respawns since the last refresh.", func(s ProcessInfo) []sample {
+		return []sample{{"", float64(s.Respawns)}}
+	}},
+	{"process_log_dropped_total", "counter", "Lines of child output dropped by the overflow policy.", func(s ProcessInfo) []sample {
+		return labeled("stream", s.LogDropped)
+	}},
+	{"process_log_sink_errors_total", "counter", "Failed writes of child output to a sink.", func(s ProcessInfo) []sample {
+		return labeled("sink", s.LogSinkErrors)
+	}},
+	{"process_health", "gauge", "Health state of processes with probes, 1 for the current one.", func(s ProcessInfo) []sample {
+		if s.Health == "" {
+			return nil
+		}
+		values := map[string]uint64{}
+		for _, state := range []string{HealthUnknown, HealthHealthy, HealthDegraded, HealthUnhealthy} {
+			values[state] = 0
+		}
+		values[s.Health] = 1
+		return labeled("state", values)
+	}},
+	{"process_rate_limited_total", "counter", "API action requests refused by the action limit.", func(s ProcessInfo) []sample {
+		return labeled("action", s.RateLimited)
+	}},
+	{"process_probe_success_ratio", "gauge", "Share of the last health checks that passed.", func(s ProcessInfo) []sample {
+		if s.Probes == nil {
+			return nil
+		}
+		return []sample{{"", s.Probes.SuccessRate}}
+	}},
+	{"process_probe_latency_seconds", "gauge", "Latency quantiles of the last health checks.", func(s ProcessInfo) []sample {
+		if s.Probes == nil {
+			return nil
+		}
+		return []sample{
+			{`quantile="0.5"`, s.Probes.LatencyP50.Seconds()},
+			{`quantile="0.9"`, s.Probes.LatencyP90.Seconds()},
+			{`quantile="0.99"`, s.Probes.LatencyP99.Seconds()},
+		}
+	}},
+	{"process_probe_checks_total", "counter", "Health checks run.", func(s ProcessInfo) []sample {
+		if s.Probes == nil {
+			return nil
+		}
+		return []sample{{"", float64(s.Probes.Checks)}}
+	}},
+	{"process_probe_failures_total", "counter", "Health checks failed.", func(s ProcessInfo) []sample {
+		if s.Probes == nil {
+			return nil
+		}
+		return []sample{{"", float64(s.Probes.Failures)}}
+	}},
+}
+
+//Samples labeled by the map keys, sorted.
+func labeled(label string, values map[string]uint64) []sample {
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	samples := make([]sample, len(keys))
+	for i, k := range keys {
+		samples[i] = sample{fmt.Sprintf("%s=%q", label, k), float64(values[k])}
+	}
+	return samples
+}
+
+//Write process metrics in the Prometheus text format.
+func (m *Manager) WriteMetrics(w io.Writer) error {
+	infos := m.Snapshot()
+	for _, metric := range metrics {
+		if _, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", metric.name, metric.help, metric.name, metric.typ); err != nil {
+			return err
+		}
+		for _, s := range infos {
+			for _, sm := range metric.samples(s) {
+				labels := fmt.Sprintf("process=%q", s.Name)
+				if s.Namespace != "" {
+					labels += fmt.Sprintf(",namespace=%q", s.Namespace)
+				}
+				if sm.labels != "" {
+					labels += "," + sm.labels
+				}
+				if _, err := fmt.Fprintf(w, "%s{%s} %g\n", metric.name, labels, sm.value); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+// goforever - processes management
+// Copyright (c) 2013 Garrett Woodworth (https://github.com/gwoo).
+
+package process
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"sort"
+)
+
+//Namespace of a tenant, created on first use. It is a manager of its own:
+//process names only need to be unique within it, and Keys, Snapshot and
+//StopAll only cover its processes. It gets the Logger, LogLevel, Clock,
+//Runner, Detach and Ping the parent has at creation, and a RunDir below the
+//parent's named after it. Its events are also delivered to the parent's
+//handlers with Event.Namespace set. The parent's Snapshot, metrics, Health
+//and Shutdown include every namespace.
+func (m *Manager) Namespace(name string) (*Manager, error) {
+	if err := ValidName(name); err != nil {
+		return nil, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.ns != "" {
+		return nil, errors.New("Namespaces do not nest.")
+	}
+	if n := m.spaces[name]; n != nil {
+		return n, nil
+	}
+	n := NewManager()
+	n.Logger, n.LogLevel, n.Clock, n.Runner, n.Detach, n.Ping = m.Logger, m.LogLevel, m.Clock, m.Runner, m.Detach, m.Ping
+	n.parent, n.ns = m, name
+	if m.RunDir != "" {
+		n.RunDir = filepath.Join(m.RunDir, name)
+	}
+	if m.spaces == nil {
+		m.spaces = map[string]*Manager{}
+	}
+	m.spaces[name] = n
+	return n, nil
+}
+
+//Sorted namespace names.
+func (m *Manager) Namespaces() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	names := make([]string, 0, len(m.spaces))
+	for name := range m.spaces {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+//The namespaces, sorted by name.
+func (m *Manager) spaceList() []*Manager {
+	var list []*Manager
+	for _, name := range m.Namespaces() {
+		m.mu.Lock()
+		list = append(list, m.spaces[name])
+		m.mu.Unlock()
+	}
+	return list
+}
+
+//Stop every process of the manager, or of the namespace it is, dependents
+//first. Unlike Shutdown further operations are still accepted.
+func (m *Manager) StopAll(ctx context.Context) error {
+	layers, err := m.order()
+	if err != nil {
+		layers = [][]string{m.Keys()}
+	}
+	var errs []error
+	if err != nil {
+		errs = append(errs, err)
+	}
+	for i := len(layers) - 1; i >= 0; i-- {
+		if err := m.stopAll(ctx, layers[i]); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
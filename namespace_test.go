@@ -0,0 +1,57 @@
+// goforever - processes management
+// Copyright (c) 2013 Garrett Woodworth (https://github.com/gwoo).
+
+package process
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestNamespaces(t *testing.T) {
+	r := NewFakeRunner()
+	m := NewManager()
+	m.Runner = r
+	var events []Event
+	m.OnEvent(func(e Event) { events = append(events, e) })
+	a, err := m.Namespace("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := m.Namespace("b")
+	if n, _ := m.Namespace("a"); n != a {
+		t.Error("Expected the same namespace.")
+	}
+	if _, err := a.Namespace("c"); err == nil {
+		t.Error("Expected nested namespaces to fail.")
+	}
+	for _, n := range []*Manager{a, b} {
+		if err := n.Add("web", &Process{Command: "/usr/bin/web", Ping: "1h"}); err != nil {
+			t.Fatal(err)
+		}
+		n.Run()
+	}
+	if len(r.Running()) != 2 || len(m.Snapshot()) != 2 || len(a.Snapshot()) != 1 {
+		t.Fatalf("Expected web in both namespaces. Result %#v\n", m.Snapshot())
+	}
+	var metrics bytes.Buffer
+	m.WriteMetrics(&metrics)
+	if ex := `process_up{process="web",namespace="b"} 1`; !strings.Contains(metrics.String(), ex) {
+		t.Errorf("Expected %s in %s\n", ex, metrics.String())
+	}
+	if err := a.StopAll(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(r.Running()) != 1 || b.Get("web").Snapshot().Pid == 0 {
+		t.Error("Expected only namespace a stopped.")
+	}
+	if len(events) != 1 || events[0].Namespace != "a" || events[0].Type != EventStop {
+		t.Errorf("Expected a stop event from a. Result %#v\n", events)
+	}
+	m.Shutdown(context.Background())
+	if len(r.Running()) != 0 {
+		t.Error("Expected Shutdown to stop every namespace.")
+	}
+}
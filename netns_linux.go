@@ -0,0 +1,69 @@
+// goforever - processes management
+// Copyright (c) 2013 Garrett Woodworth (https://github.com/gwoo).
+
+package process
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"strings"
+	"syscall"
+)
+
+//setns(2), missing from package syscall on some architectures.
+var setnsTrap = map[string]uintptr{
+	"386": 346, "amd64": 308, "arm": 375, "arm64": 268, "loong64": 268,
+	"mips": 4344, "mipsle": 4344, "mips64": 5303, "mips64le": 5303,
+	"ppc64": 350, "ppc64le": 350, "riscv64": 268, "s390x": 339,
+}
+
+func setns(fd uintptr) error {
+	trap, ok := setnsTrap[runtime.GOARCH]
+	if !ok {
+		return fmt.Errorf("setns is not supported on %s.", runtime.GOARCH)
+	}
+	if _, _, errno := syscall.RawSyscall(trap, fd, syscall.CLONE_NEWNET, 0); errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+//Run f on a thread switched to the NetNS network namespace, so that the
+//sockets it binds and the processes it starts belong to it. A name is
+//looked up in /var/run/netns, as created by "ip netns add"; a path, such
+//as /proc/1234/ns/net, is used as is.
+func (p *Process) inNetNS(f func() error) error {
+	if p.NetNS == "" {
+		return f()
+	}
+	path := p.NetNS
+	if !strings.Contains(path, "/") {
+		path = "/var/run/netns/" + path
+	}
+	target, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("netns: %s", err)
+	}
+	defer target.Close()
+	runtime.LockOSThread()
+	self, err := os.Open(fmt.Sprintf("/proc/self/task/%d/ns/net", syscall.Gettid()))
+	if err != nil {
+		runtime.UnlockOSThread()
+		return fmt.Errorf("netns: %s", err)
+	}
+	defer self.Close()
+	if err := setns(target.Fd()); err != nil {
+		runtime.UnlockOSThread()
+		return fmt.Errorf("netns %s: %s", p.NetNS, err)
+	}
+	err = f()
+	if rerr := setns(self.Fd()); rerr != nil {
+		//Leave the thread locked so that it exits with the goroutine
+		//instead of running others in the wrong namespace.
+		p.log(LevelError, "netns restore failed", Fields{"error": rerr})
+		return err
+	}
+	runtime.UnlockOSThread()
+	return err
+}
@@ -0,0 +1,18 @@
+// goforever - processes management
+// Copyright (c) 2013 Garrett Woodworth (https://github.com/gwoo).
+
+//go:build !linux
+
+package process
+
+import (
+	"errors"
+)
+
+//Network namespaces only exist on Linux.
+func (p *Process) inNetNS(f func() error) error {
+	if p.NetNS != "" {
+		return errors.New("Network namespaces are only supported on Linux.")
+	}
+	return f()
+}
@@ -0,0 +1,157 @@
+// goforever - processes management
+// Copyright (c) 2013 Garrett Woodworth (https://github.com/gwoo).
+
+package process
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+//Datagram socket named in the child's NOTIFY_SOCKET, on which it sends
+//newline separated assignments as in systemd's sd_notify: READY=1,
+//WATCHDOG=1, WATCHDOG=trigger, STOPPING=1 and STATUS=<text>.
+//github.com/jrossi/process/child sends them for Go programs.
+type notifySocket struct {
+	conn  *net.UnixConn
+	dir   string
+	ready chan struct{}
+	once  sync.Once
+	done  chan struct{}
+}
+
+//Bind the notify socket of the next start and return the variables telling
+//the child about it: NOTIFY_SOCKET and, with a Watchdog, WATCHDOG_USEC.
+//Nil without Notify or Watchdog.
+func (p *Process) openNotify() (*notifySocket, []string, error) {
+	if !p.Notify && p.Watchdog == "" {
+		return nil, nil, nil
+	}
+	dir, err := ioutil.TempDir("", "process-notify-")
+	if err != nil {
+		return nil, nil, fmt.Errorf("notify: %s", err)
+	}
+	path := filepath.Join(dir, "notify")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, nil, fmt.Errorf("notify: %s", err)
+	}
+	env := []string{"NOTIFY_SOCKET=" + path}
+	if p.Watchdog != "" {
+		usec := durationOr(p.Watchdog, 0) / time.Microsecond
+		env = append(env, "WATCHDOG_USEC="+strconv.FormatInt(int64(usec), 10))
+	}
+	n := &notifySocket{conn: conn, dir: dir, ready: make(chan struct{}), done: make(chan struct{})}
+	return n, env, nil
+}
+
+//Close the socket and remove its directory.
+func (n *notifySocket) close() {
+	if n == nil {
+		return
+	}
+	n.once.Do(func() {
+		close(n.done)
+		n.conn.Close()
+		os.RemoveAll(n.dir)
+	})
+}
+
+//Send the received assignments on lines until the socket is closed.
+func (n *notifySocket) read(lines chan<- string) {
+	buf := make([]byte, 4096)
+	for {
+		size, err := n.conn.Read(buf)
+		if err != nil {
+			return
+		}
+		for _, line := range strings.Split(string(buf[:size]), "\n") {
+			if line == "" {
+				continue
+			}
+			select {
+			case lines <- line:
+			case <-n.done:
+				return
+			}
+		}
+	}
+}
+
+//Handle a run's notifications until it exits, restarting the process when
+//its Watchdog expires.
+func (p *Process) watchNotify(n *notifySocket, exited chan struct{}) {
+	defer n.close()
+	lines := make(chan string)
+	go n.read(lines)
+	var tick <-chan time.Time
+	interval := durationOr(p.Watchdog, 0)
+	if interval > 0 {
+		ticker := p.clock().NewTicker(interval)
+		defer ticker.Stop()
+		tick = ticker.C()
+	}
+	last := p.clock().Now()
+	expire := func(reason string) {
+		p.log(LevelWarn, "watchdog expired, restarting", Fields{"reason": reason})
+		p.emit(EventRestart, reason)
+		p.Restart()
+	}
+	for {
+		select {
+		case line := <-lines:
+			switch {
+			case line == "READY=1":
+				select {
+				case <-n.ready:
+				default:
+					close(n.ready)
+				}
+			case line == "WATCHDOG=1":
+				last = p.clock().Now()
+			case line == "WATCHDOG=trigger":
+				expire("watchdog triggered by the process")
+				return
+			case line == "STOPPING=1":
+				p.log(LevelInfo, "process stopping", nil)
+			case strings.HasPrefix(line, "STATUS="):
+				p.mu.Lock()
+				p.notice = strings.TrimPrefix(line, "STATUS=")
+				p.mu.Unlock()
+			}
+		case <-tick:
+			if p.Frozen() {
+				last = p.clock().Now()
+				continue
+			}
+			if since := p.clock().Now().Sub(last); since >= interval {
+				expire(fmt.Sprintf("no watchdog notification for %s", since))
+				return
+			}
+		case <-exited:
+			return
+		}
+	}
+}
+
+//Wait for the READY=1 of a just started process, up to NotifyTimeout.
+func (p *Process) waitReady(n *notifySocket, exited chan struct{}) error {
+	timeout := durationOr(p.NotifyTimeout, 30*time.Second)
+	select {
+	case <-n.ready:
+		return nil
+	case <-exited:
+		return errors.New("Exited before notifying readiness.")
+	case <-p.clock().After(timeout):
+		return fmt.Errorf("No readiness notification within %s.", timeout)
+	}
+}
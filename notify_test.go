@@ -0,0 +1,84 @@
+// goforever - processes management
+// Copyright (c) 2013 Garrett Woodworth (https://github.com/gwoo).
+
+//go:build unix
+
+package process
+
+import (
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+//Send state to the NOTIFY_SOCKET of a fake process.
+func sendNotify(t *testing.T, f *FakeProcess, state string) {
+	for _, kv := range f.Cmd.Env {
+		if strings.HasPrefix(kv, "NOTIFY_SOCKET=") {
+			path := strings.TrimPrefix(kv, "NOTIFY_SOCKET=")
+			conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: path, Net: "unixgram"})
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			defer conn.Close()
+			conn.Write([]byte(state))
+			return
+		}
+	}
+	t.Errorf("Expected NOTIFY_SOCKET in the environment.\n")
+}
+
+func TestNotify(t *testing.T) {
+	r := NewFakeRunner()
+	r.OnStart = func(f *FakeProcess) {
+		go func() {
+			time.Sleep(10 * time.Millisecond)
+			sendNotify(t, f, "STATUS=serving\nREADY=1")
+		}()
+	}
+	p := &Process{Command: "web", Runner: r, Ping: "1h", Notify: true}
+	if err := p.start("web"); err != nil {
+		t.Fatalf("Expected a start. Result %v\n", err)
+	}
+	waitFor(t, func() bool { return p.Snapshot().Notice == "serving" })
+	p.Stop()
+
+	r = NewFakeRunner()
+	p = &Process{Command: "web", Runner: r, Ping: "1h", Notify: true, NotifyTimeout: "50ms"}
+	if err := p.start("web"); err == nil || !strings.Contains(err.Error(), "No readiness notification") {
+		t.Errorf("Expected a readiness timeout. Result %v\n", err)
+	}
+	if len(r.Running()) != 0 {
+		t.Errorf("Expected the process stopped.\n")
+	}
+}
+
+func TestWatchdog(t *testing.T) {
+	clock := NewFakeClock(time.Now())
+	r := NewFakeRunner()
+	p := &Process{Command: "web", Runner: r, Ping: "1h", Respawn: 1, Watchdog: "10s", Clock: clock}
+	RunProcess("web", p)
+	first := r.Running()[0]
+	found := false
+	for _, kv := range first.Cmd.Env {
+		found = found || kv == "WATCHDOG_USEC=10000000"
+	}
+	if !found {
+		t.Errorf("Expected WATCHDOG_USEC in the environment.\n")
+	}
+	//The ping and watchdog tickers.
+	clock.BlockUntil(2)
+	clock.Advance(6 * time.Second)
+	sendNotify(t, first, "WATCHDOG=1")
+	time.Sleep(20 * time.Millisecond)
+	clock.Advance(6 * time.Second)
+	time.Sleep(20 * time.Millisecond)
+	if first.Exited() {
+		t.Errorf("Expected the heartbeat to keep the process running.\n")
+	}
+	clock.Advance(10 * time.Second)
+	waitFor(t, func() bool { return first.Exited() && len(r.Running()) == 1 })
+	p.Stop()
+}
@@ -0,0 +1,41 @@
+// goforever - processes management
+// Copyright (c) 2013 Garrett Woodworth (https://github.com/gwoo).
+
+package process
+
+import (
+	"bytes"
+	"strconv"
+	"syscall"
+)
+
+//Kernel log searched for OOM kills.
+var kmsgPath = "/dev/kmsg"
+
+//Whether the kernel log records the OOM killer killing pid. Reading the
+//log needs CAP_SYSLOG where kernel.dmesg_restrict is set; without it OOM
+//kills are reported as kills.
+func oomKilled(pid int) bool {
+	//Read with syscalls, not an os.File: the runtime poller would wait for
+	//new records instead of returning EAGAIN at the end of the log.
+	fd, err := syscall.Open(kmsgPath, syscall.O_RDONLY|syscall.O_NONBLOCK|syscall.O_CLOEXEC, 0)
+	if err != nil {
+		return false
+	}
+	defer syscall.Close(fd)
+	needle := []byte("Killed process " + strconv.Itoa(pid) + " ")
+	buf := make([]byte, 8192)
+	for {
+		n, err := syscall.Read(fd, buf)
+		if err == syscall.EPIPE || err == syscall.EINTR {
+			//Records overwritten while reading.
+			continue
+		}
+		if err != nil || n <= 0 {
+			return false
+		}
+		if bytes.Contains(buf[:n], needle) {
+			return true
+		}
+	}
+}
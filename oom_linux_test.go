@@ -0,0 +1,32 @@
+// goforever - processes management
+// Copyright (c) 2013 Garrett Woodworth (https://github.com/gwoo).
+
+package process
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"syscall"
+	"testing"
+)
+
+func TestOOMKilled(t *testing.T) {
+	kmsg := filepath.Join(t.TempDir(), "kmsg")
+	log := "3,812,9123456,-;Out of memory: Killed process 4242 (web) total-vm:1024kB\n"
+	if err := ioutil.WriteFile(kmsg, []byte(log), 0644); err != nil {
+		t.Fatal(err)
+	}
+	defer func(path string) { kmsgPath = path }(kmsgPath)
+	kmsgPath = kmsg
+	p := &Process{}
+	killed := &ExitStatus{Code: -1, Signal: syscall.SIGKILL}
+	if r, oom := p.exitReason(killed, 4242); r != "killed by OOM" || !oom {
+		t.Errorf("Expected %#v. Result %#v\n", "killed by OOM", r)
+	}
+	if r, _ := p.exitReason(killed, 424); r != "killed (SIGKILL)" {
+		t.Errorf("Expected %#v. Result %#v\n", "killed (SIGKILL)", r)
+	}
+	if r, _ := p.exitReason(&ExitStatus{Code: 1}, 4242); r != "exited with code 1" {
+		t.Errorf("Expected %#v. Result %#v\n", "exited with code 1", r)
+	}
+}
@@ -0,0 +1,11 @@
+// goforever - processes management
+// Copyright (c) 2013 Garrett Woodworth (https://github.com/gwoo).
+
+//go:build !linux
+
+package process
+
+//OOM kills are only detected on Linux.
+func oomKilled(pid int) bool {
+	return false
+}
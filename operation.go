@@ -0,0 +1,269 @@
+// goforever - processes management
+// Copyright (c) 2013 Garrett Woodworth (https://github.com/gwoo).
+
+package process
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+)
+
+//Operation states.
+const (
+	OpPending = "pending"
+	OpRunning = "running"
+	OpDone    = "done"
+	OpFailed  = "failed"
+)
+
+//Finished operations kept for lookup by ID.
+var maxOperations = 256
+
+//Handle to an asynchronous start, stop or restart of a process.
+type Operation struct {
+	ID       string
+	Type     string
+	Process  string
+	mu       sync.Mutex
+	state    string
+	progress string
+	created  time.Time
+	done     chan struct{}
+	err      error
+}
+
+//Current state: pending, running, done or failed.
+func (op *Operation) State() string {
+	op.mu.Lock()
+	defer op.mu.Unlock()
+	return op.state
+}
+
+//Last step reported by the running operation, e.g. "waiting for exit".
+func (op *Operation) Progress() string {
+	op.mu.Lock()
+	defer op.mu.Unlock()
+	return op.progress
+}
+
+//Closed when the operation has finished.
+func (op *Operation) Done() <-chan struct{} {
+	return op.done
+}
+
+//Result of the operation, nil until it is done.
+func (op *Operation) Err() error {
+	select {
+	case <-op.done:
+		return op.err
+	default:
+		return nil
+	}
+}
+
+//Block until the operation is done and return its result.
+func (op *Operation) Wait() error {
+	<-op.done
+	return op.err
+}
+
+func (op *Operation) MarshalJSON() ([]byte, error) {
+	op.mu.Lock()
+	defer op.mu.Unlock()
+	var message string
+	if op.state == OpFailed {
+		message = op.err.Error()
+	}
+	return json.Marshal(struct {
+		ID       string    `json:"id"`
+		Type     string    `json:"type"`
+		Process  string    `json:"process"`
+		State    string    `json:"state"`
+		Progress string    `json:"progress,omitempty"`
+		Created  time.Time `json:"created"`
+		Error    string    `json:"error,omitempty"`
+	}{op.ID, op.Type, op.Process, op.state, op.progress, op.created, message})
+}
+
+//Report a step. Safe to call on a nil operation.
+func (op *Operation) report(progress string) {
+	if op == nil {
+		return
+	}
+	op.mu.Lock()
+	op.progress = progress
+	op.mu.Unlock()
+}
+
+func (op *Operation) setState(state string) {
+	op.mu.Lock()
+	op.state = state
+	op.mu.Unlock()
+}
+
+func (op *Operation) finish(err error) {
+	op.mu.Lock()
+	op.err = err
+	op.state = OpDone
+	if err != nil {
+		op.state = OpFailed
+	}
+	op.mu.Unlock()
+	close(op.done)
+}
+
+//Look up an operation by ID.
+func (m *Manager) Operation(id string) *Operation {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, op := range m.ops {
+		if op.ID == id {
+			return op
+		}
+	}
+	return nil
+}
+
+//Recent operations, oldest first.
+func (m *Manager) Operations() []*Operation {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]*Operation(nil), m.ops...)
+}
+
+//Create an operation. Called with m.mu held.
+func (m *Manager) newOperation(typ, name string) *Operation {
+	m.opSeq++
+	op := &Operation{
+		ID:      fmt.Sprintf("%d", m.opSeq),
+		Type:    typ,
+		Process: name,
+		state:   OpPending,
+		created: m.clock().Now(),
+		done:    make(chan struct{}),
+	}
+	m.ops = append(m.ops, op)
+	if len(m.ops) > maxOperations {
+		m.forgetKeys(m.ops[:len(m.ops)-maxOperations])
+		m.ops = m.ops[len(m.ops)-maxOperations:]
+	}
+	return op
+}
+
+//Queue f as an operation of type typ on p, after any operation already in
+//flight. A request of the same type as the in-flight one, or arriving
+//within delay of it, gets the in-flight operation back, as does one with
+//the idempotency key of an earlier request, see keyedOperation.
+func (m *Manager) do(name, typ, key string, delay time.Duration, f func(p *Process, op *Operation) error) (*Operation, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.closing {
+		return nil, ErrShuttingDown
+	}
+	if op, err := m.keyedLocked(key, typ, name); op != nil || err != nil {
+		return op, err
+	}
+	p, err := m.procs.Lookup(name)
+	if err != nil {
+		return nil, err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	op := p.op
+	//Applies each carry their own definition.
+	if op == nil || op.Type != typ || typ == "apply" {
+		prev := op
+		op = m.newOperation(typ, name)
+		p.op = op
+		go m.run(p, op, prev, delay, f)
+	}
+	if key != "" {
+		if m.keys == nil {
+			m.keys = map[string]*Operation{}
+		}
+		m.keys[key] = op
+	}
+	return op, nil
+}
+
+//Run f as op once prev is done and delay has passed.
+func (m *Manager) run(p *Process, op, prev *Operation, delay time.Duration, f func(p *Process, op *Operation) error) {
+	if prev != nil {
+		<-prev.Done()
+	}
+	if delay > 0 {
+		p.clock().Sleep(delay)
+	}
+	op.setState(OpRunning)
+	err := f(m.current(op.Process, p), op)
+	p = m.current(op.Process, p)
+	p.mu.Lock()
+	if p.op == op {
+		p.op = nil
+	}
+	p.mu.Unlock()
+	op.finish(err)
+}
+
+//The process now added as name: p, unless Apply replaced it.
+func (m *Manager) current(name string, p *Process) *Process {
+	if cur, err := m.Lookup(name); err == nil {
+		return cur
+	}
+	return p
+}
+
+//Start the process in the background.
+func (m *Manager) Start(name string) (*Operation, error) {
+	return m.operate("start", name, "")
+}
+
+//Stop the process in the background.
+func (m *Manager) Stop(name string) (*Operation, error) {
+	return m.operate("stop", name, "")
+}
+
+//Request a restart of the process. Requests arriving within RestartDebounce
+//of the first one, or while the restart is still running, are coalesced
+//into it and get the same operation back.
+func (m *Manager) Restart(name string) (*Operation, error) {
+	return m.operate("restart", name, "")
+}
+
+//Start, stop or restart the process in the background, see do for key.
+func (m *Manager) operate(action, name, key string) (*Operation, error) {
+	p, err := m.Lookup(name)
+	if err != nil {
+		return nil, err
+	}
+	switch action {
+	case "start":
+		return m.do(name, action, key, 0, func(p *Process, op *Operation) error {
+			op.report("starting")
+			if _, err := RunProcess(name, p); err != nil {
+				return err
+			}
+			p.emit(EventStart, "requested")
+			return nil
+		})
+	case "stop":
+		return m.do(name, action, key, 0, func(p *Process, op *Operation) error {
+			p.stop(op)
+			p.emit(EventStop, "requested")
+			return nil
+		})
+	case "restart":
+		return m.do(name, action, key, durationOr(p.RestartDebounce, 0), func(p *Process, op *Operation) error {
+			p.stop(op)
+			op.report("starting")
+			if _, err := RunProcess(name, p); err != nil {
+				return err
+			}
+			p.emit(EventRestart, "requested")
+			return nil
+		})
+	}
+	return nil, fmt.Errorf("Unknown action %q.", action)
+}
@@ -0,0 +1,79 @@
+// goforever - processes management
+// Copyright (c) 2013 Garrett Woodworth (https://github.com/gwoo).
+
+package process
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRestartDebounce(t *testing.T) {
+	dir, err := ioutil.TempDir("", "process")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	m := NewManager()
+	p := &Process{
+		Command:         "/bin/sleep",
+		Args:            []string{"10"},
+		Pidfile:         Pidfile(filepath.Join(dir, "sleep.pid")),
+		RestartDebounce: "50ms",
+	}
+	m.Add("sleep", p)
+	restarts := 0
+	m.OnEvent(func(e Event) {
+		if e.Type == EventRestart {
+			restarts++
+		}
+	})
+	first, _ := m.Restart("sleep")
+	second, _ := m.Restart("sleep")
+	if first != second {
+		t.Error("Expected restarts within the window to share an operation.")
+	}
+	if err := first.Wait(); err != nil {
+		t.Fatalf("Error: %s.", err)
+	}
+	defer p.Stop()
+	if restarts != 1 {
+		t.Errorf("Expected 1 restart. Result %d\n", restarts)
+	}
+	third, _ := m.Restart("sleep")
+	if third == first {
+		t.Error("Expected a new operation after the restart finished.")
+	}
+	third.Wait()
+}
+
+func TestOperationHandles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "process")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	m := NewManager()
+	m.Add("sleep", &Process{
+		Command: "/bin/sleep",
+		Args:    []string{"10"},
+		Pidfile: Pidfile(filepath.Join(dir, "sleep.pid")),
+	})
+	start, err := m.Start("sleep")
+	if err != nil {
+		t.Fatalf("Error: %s.", err)
+	}
+	stop, _ := m.Stop("sleep")
+	<-stop.Done()
+	if start.State() != OpDone || stop.State() != OpDone || stop.Err() != nil {
+		t.Errorf("Unexpected states %s %s %v\n", start.State(), stop.State(), stop.Err())
+	}
+	if m.Operation(stop.ID) != stop || len(m.Operations()) != 2 {
+		t.Errorf("Expected operations to be listed. Result %#v\n", m.Operations())
+	}
+	if _, err := m.Stop("missing"); err == nil {
+		t.Error("Expected error for unknown process.")
+	}
+}
@@ -0,0 +1,207 @@
+// goforever - processes management
+// Copyright (c) 2013 Garrett Woodworth (https://github.com/gwoo).
+
+package process
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"time"
+)
+
+//Setting applied by NewProcess.
+type Option func(*Process) error
+
+//Build a validated process named name running command, ready for
+//Manager.Add or RunProcess. WithCommand replaces an empty command. Struct
+//literals, as loaded from config files, skip the validation until the
+//first start.
+func NewProcess(name, command string, opts ...Option) (*Process, error) {
+	if err := ValidName(name); err != nil {
+		return nil, fmt.Errorf("%q: %s", name, err)
+	}
+	p := &Process{Name: name, Command: command}
+	for _, opt := range opts {
+		if err := opt(p); err != nil {
+			return nil, fmt.Errorf("%s: %s", name, err)
+		}
+	}
+	if p.Command == "" && p.Shell == "" {
+		return nil, errors.New("Command is empty.")
+	}
+	if err := p.validate(); err != nil {
+		return nil, fmt.Errorf("%s: %s", name, err)
+	}
+	return p, nil
+}
+
+//Check the settings a start would otherwise reject.
+func (p *Process) validate() error {
+	if err := p.checkPidfile(); err != nil {
+		return err
+	}
+	if err := p.checkRunning(); err != nil {
+		return err
+	}
+	if _, _, err := p.commandLine(); err != nil {
+		return err
+	}
+	for field, d := range map[string]string{
+		"delay": p.Delay, "ping": p.Ping, "start_backoff": p.StartBackoff,
+		"health_timeout": p.HealthTimeout, "wait_timeout": p.WaitTimeout,
+		"pidfile_timeout": p.PidfileTimeout, "ports_timeout": p.PortsTimeout,
+		"notify_timeout": p.NotifyTimeout, "watchdog": p.Watchdog,
+		"oom_delay": p.OOMDelay, "action_window": p.ActionWindow,
+		"watch_binary": p.WatchBinary, "restart_debounce": p.RestartDebounce,
+		"timeouts.start": p.timeouts().Start, "timeouts.stop": p.timeouts().Stop,
+		"timeouts.reload": p.timeouts().Reload, "timeouts.hook_exec": p.timeouts().HookExec,
+	} {
+		if _, err := time.ParseDuration(d); d != "" && err != nil {
+			return fmt.Errorf("Bad %s: %s", field, err)
+		}
+	}
+	if err := p.checkVerify(); err != nil {
+		return err
+	}
+	if p.Artifact != nil {
+		if err := p.Artifact.validate(); err != nil {
+			return err
+		}
+	}
+	for _, port := range p.ExpectedPorts {
+		if _, _, err := parsePort(port); err != nil {
+			return fmt.Errorf("Expected port %q: %s", port, err)
+		}
+	}
+	for _, path := range append(append([]string(nil), p.ReadOnlyPaths...), p.InaccessiblePaths...) {
+		if !filepath.IsAbs(path) {
+			return fmt.Errorf("Sandbox path %q is not absolute.", path)
+		}
+	}
+	if p.RunnerName != "" {
+		if _, err := newRunner(p.RunnerName, p); err != nil {
+			return err
+		}
+	}
+	if p.Container != nil && p.Container.Image == "" {
+		return errors.New("A container needs an Image.")
+	}
+	for _, cpu := range p.CPUAffinity {
+		if cpu < 0 {
+			return fmt.Errorf("Bad CPU %d in cpu_affinity.", cpu)
+		}
+	}
+	if p.Output != nil {
+		if err := p.Output.Rotate.check(); err != nil {
+			return err
+		}
+		if _, err := p.redactor(nil); err != nil {
+			return err
+		}
+	}
+	if p.BlueGreen != nil {
+		return p.BlueGreen.validate()
+	}
+	return nil
+}
+
+//Arguments after argv[0].
+func WithArgs(args ...string) Option {
+	return func(p *Process) error {
+		p.Args = append(p.Args, args...)
+		return nil
+	}
+}
+
+//KEY=VALUE environment entries.
+func WithEnv(env ...string) Option {
+	return func(p *Process) error {
+		for _, kv := range env {
+			if kv == "" || kv[0] == '=' {
+				return fmt.Errorf("Bad env entry %q.", kv)
+			}
+		}
+		p.Env = append(p.Env, env...)
+		return nil
+	}
+}
+
+//Files receiving stdout and stderr. An empty errfile shares logfile.
+func WithLogfile(logfile, errfile string) Option {
+	return func(p *Process) error {
+		if errfile == "" {
+			errfile = logfile
+		}
+		p.Logfile, p.Errfile = logfile, errfile
+		return nil
+	}
+}
+
+//File the pid is written to, see Process.PidfileFormat.
+func WithPidfile(path string) Option {
+	return func(p *Process) error {
+		p.Pidfile = Pidfile(path)
+		return nil
+	}
+}
+
+//Respawn the process up to respawn times after it exits, waiting delay
+//before each.
+func WithRestartPolicy(respawn int, delay time.Duration) Option {
+	return func(p *Process) error {
+		if respawn < 0 || delay < 0 {
+			return errors.New("Respawns and delay cannot be negative.")
+		}
+		p.Respawn = respawn
+		p.Delay = ""
+		if delay > 0 {
+			p.Delay = delay.String()
+		}
+		return nil
+	}
+}
+
+//Run time after which a process counts as running and its respawns are
+//reset.
+func WithPing(d time.Duration) Option {
+	return func(p *Process) error {
+		if d <= 0 {
+			return errors.New("Ping must be positive.")
+		}
+		p.Ping = d.String()
+		return nil
+	}
+}
+
+//Readiness probes, see Process.Health.
+func WithHealth(probes ...Probe) Option {
+	return func(p *Process) error {
+		p.Health = append(p.Health, probes...)
+		return nil
+	}
+}
+
+//Processes started before this one, see Process.DependsOn.
+func WithDependsOn(names ...string) Option {
+	return func(p *Process) error {
+		p.DependsOn = append(p.DependsOn, names...)
+		return nil
+	}
+}
+
+//Logger of the supervisor's messages about the process.
+func WithLogger(l Logger) Option {
+	return func(p *Process) error {
+		p.Logger = l
+		return nil
+	}
+}
+
+//Runner starting the process, e.g. a FakeRunner in tests.
+func WithRunner(r Runner) Option {
+	return func(p *Process) error {
+		p.Runner = r
+		return nil
+	}
+}
@@ -0,0 +1,51 @@
+// goforever - processes management
+// Copyright (c) 2013 Garrett Woodworth (https://github.com/gwoo).
+
+package process
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNewProcess(t *testing.T) {
+	runner := NewFakeRunner()
+	p, err := NewProcess("web", "/usr/bin/web",
+		WithArgs("-v", "--port=80"),
+		WithEnv("MODE=prod"),
+		WithLogfile("/var/log/web.log", ""),
+		WithPidfile("/run/web.pid"),
+		WithRestartPolicy(3, 2*time.Second),
+		WithPing(time.Minute),
+		WithRunner(runner),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Name != "web" || len(p.Args) != 2 || p.Errfile != "/var/log/web.log" || p.Respawn != 3 || p.Delay != "2s" || p.Ping != "1m0s" {
+		t.Errorf("Unexpected process %#v\n", p)
+	}
+	m := NewManager()
+	if err := m.Add(p.Name, p); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, c := range map[string]struct {
+		name, command string
+		opts          []Option
+	}{
+		"bad name":        {"a b", "/bin/true", nil},
+		"no command":      {"web", "", nil},
+		"bad env":         {"web", "/bin/true", []Option{WithEnv("=x")}},
+		"negative":        {"web", "/bin/true", []Option{WithRestartPolicy(-1, 0)}},
+		"zero ping":       {"web", "/bin/true", []Option{WithPing(0)}},
+		"bad pidfile fmt": {"web", "/bin/true", []Option{func(p *Process) error { p.PidfileFormat = "xml"; return nil }}},
+		"bad duration":    {"web", "/bin/true", []Option{func(p *Process) error { p.StartBackoff = "soon"; return nil }}},
+		"bad debounce":    {"web", "/bin/true", []Option{func(p *Process) error { p.RestartDebounce = "soon"; return nil }}},
+		"bad timeout":     {"web", "/bin/true", []Option{func(p *Process) error { p.Timeouts = &Timeouts{Stop: "soon"}; return nil }}},
+	} {
+		if _, err := NewProcess(c.name, c.command, c.opts...); err == nil {
+			t.Errorf("%s: expected an error.\n", name)
+		}
+	}
+}
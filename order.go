@@ -0,0 +1,66 @@
+// goforever - processes management
+// Copyright (c) 2013 Garrett Woodworth (https://github.com/gwoo).
+
+package process
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+)
+
+var ErrDependencyCycle = errors.New("Processes depend on each other.")
+
+//Process names in layers: each layer only depends on earlier ones. A
+//dependency names a process or an instance group.
+func (m *Manager) order() ([][]string, error) {
+	names := m.Keys()
+	deps := map[string][]string{}
+	for _, name := range names {
+		p := m.Get(name)
+		for _, dep := range p.DependsOn {
+			found := false
+			for _, n := range names {
+				if n == dep || m.Get(n).group == dep {
+					if n == name {
+						return nil, ErrDependencyCycle
+					}
+					deps[name] = append(deps[name], n)
+					found = true
+				}
+			}
+			if !found {
+				return nil, fmt.Errorf("Unknown dependency %s of %s.", dep, name)
+			}
+		}
+	}
+	var layers [][]string
+	done := map[string]bool{}
+	for len(done) < len(names) {
+		var layer []string
+		for _, name := range names {
+			if done[name] {
+				continue
+			}
+			ready := true
+			for _, dep := range deps[name] {
+				if !done[dep] {
+					ready = false
+					break
+				}
+			}
+			if ready {
+				layer = append(layer, name)
+			}
+		}
+		if len(layer) == 0 {
+			return nil, ErrDependencyCycle
+		}
+		for _, name := range layer {
+			done[name] = true
+		}
+		sort.Strings(layer)
+		layers = append(layers, layer)
+	}
+	return layers, nil
+}
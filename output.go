@@ -0,0 +1,186 @@
+// goforever - processes management
+// Copyright (c) 2013 Garrett Woodworth (https://github.com/gwoo).
+
+package process
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"sync"
+	"sync/atomic"
+)
+
+//Overflow policies for piped output.
+const (
+	DropOldest = "drop-oldest"
+	DropNewest = "drop-newest"
+	Block      = "block"
+)
+
+//Pipe child stdout and stderr through the supervisor instead of giving the
+//child the log files. Lines are queued so a slow sink never blocks the
+//child, unless Overflow is "block".
+type Output struct {
+	//Lines queued per stream. Defaults to 1024.
+	Buffer int `json:"buffer,omitempty"`
+	//What to do with a full queue: drop-oldest (default), drop-newest or
+	//block.
+	Overflow string `json:"overflow,omitempty"`
+	//Where the lines go. Defaults to a file sink on Logfile/Errfile.
+	Sinks []Sink `json:"sinks,omitempty"`
+	//Rotation of file sinks.
+	Rotate *Rotate `json:"rotate,omitempty"`
+	//Pass JSON lines to logger sinks as structured entries.
+	JSON bool `json:"json,omitempty"`
+	//Lines dropped or routed to a named sink.
+	Filters []Filter `json:"filters,omitempty"`
+	//Secrets masked in every line.
+	Redact *Redact `json:"redact,omitempty"`
+	//Capture of the first output of each start.
+	Startup *Startup `json:"startup,omitempty"`
+}
+
+//Longest line; longer ones are split.
+const maxLine = 64 * 1024
+
+//One piped output stream of a child.
+type stream struct {
+	name    string
+	policy  string
+	size    int
+	sink    io.WriteCloser
+	dropped *uint64
+	mu      sync.Mutex
+	cond    *sync.Cond
+	lines   [][]byte
+	closed  bool
+	done    chan struct{}
+}
+
+func newStream(name string, o *Output, sink io.WriteCloser, dropped *uint64) *stream {
+	s := &stream{
+		name:    name,
+		policy:  o.Overflow,
+		size:    o.Buffer,
+		sink:    sink,
+		dropped: dropped,
+		done:    make(chan struct{}),
+	}
+	if s.size <= 0 {
+		s.size = 1024
+	}
+	s.cond = sync.NewCond(&s.mu)
+	return s
+}
+
+//Queue a line according to the overflow policy.
+func (s *stream) push(line []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for len(s.lines) >= s.size {
+		switch s.policy {
+		case Block:
+			s.cond.Wait()
+			continue
+		case DropNewest:
+			atomic.AddUint64(s.dropped, 1)
+			return
+		default:
+			s.lines = s.lines[1:]
+			atomic.AddUint64(s.dropped, 1)
+		}
+	}
+	s.lines = append(s.lines, line)
+	s.cond.Broadcast()
+}
+
+//Next queued line, blocking until there is one. False once the stream is
+//closed and drained.
+func (s *stream) pop() ([]byte, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for len(s.lines) == 0 && !s.closed {
+		s.cond.Wait()
+	}
+	if len(s.lines) == 0 {
+		return nil, false
+	}
+	line := s.lines[0]
+	s.lines = s.lines[1:]
+	s.cond.Broadcast()
+	return line, true
+}
+
+func (s *stream) close() {
+	s.mu.Lock()
+	s.closed = true
+	s.cond.Broadcast()
+	s.mu.Unlock()
+}
+
+//Read lines from r until EOF, then close the stream.
+func (s *stream) read(r io.ReadCloser) {
+	defer r.Close()
+	defer s.close()
+	br := bufio.NewReaderSize(r, maxLine)
+	for {
+		line, err := br.ReadSlice('\n')
+		if len(line) > 0 {
+			s.push(append([]byte(nil), line...))
+		}
+		if err != nil && err != bufio.ErrBufferFull {
+			return
+		}
+	}
+}
+
+//Write queued lines to the sink until the stream is drained.
+func (s *stream) drain() {
+	defer close(s.done)
+	for {
+		line, ok := s.pop()
+		if !ok {
+			break
+		}
+		if s.sink != nil {
+			s.sink.Write(line)
+		}
+	}
+	if s.sink != nil {
+		s.sink.Close()
+	}
+}
+
+//Names of the piped streams, by index.
+var streamNames = [2]string{"stdout", "stderr"}
+
+//Create the pipe for output stream i of the child. The returned file is
+//the write end to hand to the child.
+func (p *Process) pipeOutput(i int, path string, red *redactor) (*os.File, error) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+	p.mu.Lock()
+	p.pipes[i] = r
+	p.mu.Unlock()
+	p.readOutput(i, r, path, red)
+	return w, nil
+}
+
+//Read output stream i of the child from r into its sinks.
+func (p *Process) readOutput(i int, r *os.File, path string, red *redactor) {
+	name := streamNames[i]
+	s := newStream(name, p.Output, p.openSinks(name, path, red), &p.drops[i])
+	go s.read(r)
+	go s.drain()
+}
+
+//Lines dropped by the overflow policy, by stream.
+func (p *Process) dropped() map[string]uint64 {
+	return map[string]uint64{
+		"stdout": atomic.LoadUint64(&p.drops[0]),
+		"stderr": atomic.LoadUint64(&p.drops[1]),
+	}
+}
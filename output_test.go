@@ -0,0 +1,151 @@
+// goforever - processes management
+// Copyright (c) 2013 Garrett Woodworth (https://github.com/gwoo).
+
+package process
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestStreamOverflow(t *testing.T) {
+	for policy, ex := range map[string]string{
+		DropOldest: "b\nc\n",
+		DropNewest: "a\nb\n",
+	} {
+		var dropped uint64
+		s := newStream("stdout", &Output{Buffer: 2, Overflow: policy}, nil, &dropped)
+		for _, line := range []string{"a\n", "b\n", "c\n"} {
+			s.push([]byte(line))
+		}
+		s.close()
+		var r bytes.Buffer
+		for line, ok := s.pop(); ok; line, ok = s.pop() {
+			r.Write(line)
+		}
+		if r.String() != ex || dropped != 1 {
+			t.Errorf("%s: expected %#v with 1 drop. Result %#v with %d\n", policy, ex, r.String(), dropped)
+		}
+	}
+}
+
+func TestPipedOutput(t *testing.T) {
+	dir, err := ioutil.TempDir("", "process")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	m := NewManager()
+	p := &Process{
+		Command: "/bin/sh",
+		Args:    []string{"-c", "echo out; echo err >&2"},
+		Logfile: filepath.Join(dir, "out.log"),
+		Errfile: filepath.Join(dir, "err.log"),
+		Output:  &Output{},
+	}
+	m.Add("sh", p)
+	if r := p.Start("sh"); r == "" {
+		t.Fatal("Expected start.")
+	}
+	<-p.exited
+	waitFor(t, func() bool {
+		out, _ := ioutil.ReadFile(p.Logfile)
+		err, _ := ioutil.ReadFile(p.Errfile)
+		return string(out) == "out\n" && string(err) == "err\n"
+	})
+	var b bytes.Buffer
+	m.WriteMetrics(&b)
+	if ex := `process_log_dropped_total{process="sh",stream="stderr"} 0`; !strings.Contains(b.String(), ex) {
+		t.Errorf("Expected %#v in %s\n", ex, b.String())
+	}
+}
+
+func TestOutputSinks(t *testing.T) {
+	dir, err := ioutil.TempDir("", "process")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	m := NewManager()
+	p := &Process{
+		Command: "/bin/sh",
+		Args:    []string{"-c", "echo a; echo b; echo c; echo err >&2"},
+		Logfile: filepath.Join(dir, "out.log"),
+		Output: &Output{Sinks: []Sink{
+			{Type: "file"},
+			{Type: "file", Path: filepath.Join(dir, "copy.log"), Streams: []string{"stdout"}},
+			{Type: "ring", Lines: 2},
+		}},
+	}
+	m.Add("sh", p)
+	if r := p.Start("sh"); r == "" {
+		t.Fatal("Expected start.")
+	}
+	<-p.exited
+	waitFor(t, func() bool {
+		return strings.Join(p.Tail("stdout", 0), ",") == "b,c" && len(p.Tail("stderr", 1)) == 1
+	})
+	for _, path := range []string{p.Logfile, filepath.Join(dir, "copy.log")} {
+		if r, _ := ioutil.ReadFile(path); string(r) != "a\nb\nc\n" {
+			t.Errorf("Expected %#v. Result %#v\n", "a\nb\nc\n", string(r))
+		}
+	}
+	if ex, r := []string{"err"}, p.Tail("stderr", 5); len(r) != 1 || r[0] != ex[0] {
+		t.Errorf("Expected %#v. Result %#v\n", ex, r)
+	}
+}
+
+func TestMultiSinkIsolation(t *testing.T) {
+	p := &Process{Name: "sink"}
+	var b bytes.Buffer
+	m := &multiSink{sinks: []*sinkWriter{
+		{name: "stdout/bad", w: failWriter{}, errors: p.sinkErrors("stdout/bad"), p: p},
+		{name: "stdout/good", w: nopCloser{&b}, errors: p.sinkErrors("stdout/good"), p: p},
+	}}
+	m.Write([]byte("a\n"))
+	m.Write([]byte("b\n"))
+	if b.String() != "a\nb\n" {
+		t.Errorf("Expected %#v. Result %#v\n", "a\nb\n", b.String())
+	}
+	p.mu.Lock()
+	r := p.sinkErrorCounts()
+	p.mu.Unlock()
+	if r["stdout/bad"] != 2 || r["stdout/good"] != 0 {
+		t.Errorf("Expected 2 errors on the bad sink. Result %#v\n", r)
+	}
+}
+
+type failWriter struct{}
+
+func (failWriter) Write(b []byte) (int, error) {
+	return 0, os.ErrClosed
+}
+
+func (failWriter) Close() error {
+	return nil
+}
+
+func TestFollow(t *testing.T) {
+	p := &Process{}
+	if ch, _ := p.Follow("stdout"); ch != nil {
+		t.Errorf("Expected no channel without a ring.\n")
+	}
+	r := p.ring("stdout", 2)
+	r.Write([]byte("before\n"))
+	ch, stop := p.Follow("stdout")
+	r.Write([]byte("after\n"))
+	if line := <-ch; line != "after" {
+		t.Errorf("Expected %#v. Result %#v\n", "after", line)
+	}
+	stop()
+	r.Write([]byte("stopped\n"))
+	select {
+	case line := <-ch:
+		t.Errorf("Expected no line after stop. Result %#v\n", line)
+	default:
+	}
+}
@@ -0,0 +1,68 @@
+// goforever - processes management
+// Copyright (c) 2013 Garrett Woodworth (https://github.com/gwoo).
+
+package process
+
+import (
+	"errors"
+)
+
+var (
+	ErrPaused    = errors.New("Process is paused.")
+	ErrNotPaused = errors.New("Process is not paused.")
+)
+
+//Maintenance mode changes, see Manager.Pause.
+const (
+	EventPause  = "pause"
+	EventResume = "resume"
+)
+
+//Stop the process for maintenance, keeping its definition: it has status
+//paused and is neither started, by Run, operations or restarts, nor health
+//checked until Unpause.
+func (m *Manager) Pause(name string) error {
+	p, err := m.Lookup(name)
+	if err != nil {
+		return err
+	}
+	p.mu.Lock()
+	paused := p.paused
+	p.paused = true
+	p.mu.Unlock()
+	if paused {
+		return nil
+	}
+	p.stop(nil)
+	p.log(LevelInfo, "paused", nil)
+	p.emit(EventPause, "maintenance")
+	return nil
+}
+
+//End the maintenance of a paused process and start it again. Named so
+//since Resume takes over the processes of an upgraded supervisor.
+func (m *Manager) Unpause(name string) error {
+	p, err := m.Lookup(name)
+	if err != nil {
+		return err
+	}
+	p.mu.Lock()
+	if !p.paused {
+		p.mu.Unlock()
+		return ErrNotPaused
+	}
+	p.paused = false
+	p.Status = "stopped"
+	p.mu.Unlock()
+	p.log(LevelInfo, "resumed", nil)
+	p.emit(EventResume, "maintenance over")
+	_, err = RunProcess(name, p)
+	return err
+}
+
+//Whether the process is paused, see Manager.Pause.
+func (p *Process) Paused() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.paused
+}
@@ -0,0 +1,72 @@
+// goforever - processes management
+// Copyright (c) 2013 Garrett Woodworth (https://github.com/gwoo).
+
+package process
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestPause(t *testing.T) {
+	r := NewFakeRunner()
+	m := NewManager()
+	m.Add("web", &Process{Command: "web", Runner: r, Ping: "1h"})
+	var events []string
+	m.OnEvent(func(e Event) { events = append(events, e.Type) })
+	p, _ := RunProcess("web", m.Get("web"))
+	if err := m.Pause("web"); err != nil {
+		t.Fatalf("Expected no error. Result %#v\n", err)
+	}
+	if status := p.CurrentStatus(); status != "paused" || !p.Paused() {
+		t.Errorf("Expected %#v. Result %#v\n", "paused", status)
+	}
+	if running := r.Running(); len(running) != 0 {
+		t.Errorf("Expected nothing running. Result %#v\n", running)
+	}
+	if _, err := RunProcess("web", p); err != ErrPaused {
+		t.Errorf("Expected %#v. Result %#v\n", ErrPaused, err)
+	}
+	if op, _ := m.Restart("web"); op.Wait() != ErrPaused {
+		t.Errorf("Expected the restart refused. Result %#v\n", op.Err())
+	}
+	if status := p.CurrentStatus(); status != "paused" {
+		t.Errorf("Expected %#v. Result %#v\n", "paused", status)
+	}
+	if err := m.Unpause("web"); err != nil {
+		t.Fatalf("Expected no error. Result %#v\n", err)
+	}
+	if running := r.Running(); len(running) != 1 || p.CurrentStatus() != "started" {
+		t.Errorf("Expected web running. Result %#v %#v\n", running, p.CurrentStatus())
+	}
+	if err := m.Unpause("web"); err != ErrNotPaused {
+		t.Errorf("Expected %#v. Result %#v\n", ErrNotPaused, err)
+	}
+	if err := m.Pause("db"); err != ErrNotFound {
+		t.Errorf("Expected %#v. Result %#v\n", ErrNotFound, err)
+	}
+	if len(events) < 2 || events[len(events)-2] != EventPause || events[len(events)-1] != EventResume {
+		t.Errorf("Expected pause and resume events. Result %#v\n", events)
+	}
+	p.Stop()
+}
+
+func TestPauseAPI(t *testing.T) {
+	r := NewFakeRunner()
+	m := NewManager()
+	m.Add("web", &Process{Command: "web", Runner: r, Ping: "1h"})
+	RunProcess("web", m.Get("web"))
+	for _, c := range []struct{ op, status string }{{"pause", "paused"}, {"resume", "started"}} {
+		w := httptest.NewRecorder()
+		m.API(nil).ServeHTTP(w, httptest.NewRequest("POST", "/processes/web/"+c.op, nil))
+		if w.Code != 200 || m.Get("web").CurrentStatus() != c.status {
+			t.Errorf("Expected %#v. Result %d %s\n", c.status, w.Code, w.Body.String())
+		}
+	}
+	w := httptest.NewRecorder()
+	m.API(nil).ServeHTTP(w, httptest.NewRequest("POST", "/processes/web/resume", nil))
+	if w.Code != 409 {
+		t.Errorf("Expected 409. Result %d\n", w.Code)
+	}
+	m.Get("web").Stop()
+}
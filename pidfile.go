@@ -0,0 +1,268 @@
+// goforever - processes management
+// Copyright (c) 2013 Garrett Woodworth (https://github.com/gwoo).
+
+package process
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//Formats of written pidfiles. Any of them is read back. Plain holds the
+//pid only; starttime adds the process's start time ("pid starttime") and
+//json also a hash of its executable, so that a reused pid is not taken
+//for the process.
+const (
+	PidfilePlain     = "plain"
+	PidfileStartTime = "starttime"
+	PidfileJSON      = "json"
+)
+
+//Contents of a pidfile. StartTime is in clock ticks since boot as the
+//kernel reports it, 0 where unknown.
+type pidInfo struct {
+	Pid         int    `json:"pid"`
+	StartTime   uint64 `json:"start_time,omitempty"`
+	CommandHash string `json:"command_hash,omitempty"`
+}
+
+//Writers of the pidfile, see Process.PidfileOwner.
+const (
+	PidfileSupervisor = "supervisor"
+	PidfileChild      = "child"
+)
+
+func (p *Process) checkPidfile() error {
+	switch p.PidfileFormat {
+	case "", PidfilePlain, PidfileStartTime, PidfileJSON:
+	default:
+		return fmt.Errorf("Unknown pidfile format %q.", p.PidfileFormat)
+	}
+	switch p.PidfileOwner {
+	case "", PidfileSupervisor:
+	case PidfileChild:
+		if p.Pidfile == "" {
+			return errors.New("A pidfile written by the child needs a Pidfile.")
+		}
+	default:
+		return fmt.Errorf("Unknown pidfile owner %q.", p.PidfileOwner)
+	}
+	return nil
+}
+
+//Describe the just started pid, started from command.
+func newPidInfo(pid int, command string) *pidInfo {
+	info := &pidInfo{Pid: pid}
+	info.StartTime, _ = procStart(pid)
+	exe, ok := procExe(pid)
+	if !ok {
+		exe = command
+		if abs, err := filepath.Abs(command); err == nil {
+			exe = abs
+		}
+		if real, err := filepath.EvalSymlinks(exe); err == nil {
+			exe = real
+		}
+	}
+	info.CommandHash = hashCommand(exe)
+	return info
+}
+
+func hashCommand(exe string) string {
+	sum := sha256.Sum256([]byte(exe))
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+//Whether info describes another process than the one now running as its
+//pid. Values the platform does not report are not compared.
+func (info *pidInfo) stale() bool {
+	if info.StartTime != 0 {
+		if start, ok := procStart(info.Pid); ok && start != info.StartTime {
+			return true
+		}
+	}
+	if info.CommandHash != "" {
+		if exe, ok := procExe(info.Pid); ok && hashCommand(exe) != info.CommandHash {
+			return true
+		}
+	}
+	return false
+}
+
+//Parse the pidfile in any format. Surrounding whitespace and # comments
+//are ignored.
+func (f *Pidfile) info() (*pidInfo, error) {
+	data, err := ioutil.ReadFile(string(*f))
+	if err != nil {
+		return nil, err
+	}
+	var lines []string
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, "{") {
+			if i := strings.IndexByte(line, '#'); i >= 0 {
+				line = strings.TrimSpace(line[:i])
+			}
+		}
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+	text := strings.Join(lines, "\n")
+	bad := func(err error) (*pidInfo, error) {
+		return nil, fmt.Errorf("%s: %s", *f, err)
+	}
+	info := &pidInfo{}
+	switch fields := strings.Fields(text); {
+	case text == "":
+		return bad(errors.New("no pid"))
+	case text[0] == '{':
+		if err := json.Unmarshal([]byte(text), info); err != nil {
+			return bad(err)
+		}
+	case len(fields) == 2:
+		if info.Pid, err = strconv.Atoi(fields[0]); err != nil {
+			return bad(err)
+		}
+		if info.StartTime, err = strconv.ParseUint(fields[1], 10, 64); err != nil {
+			return bad(err)
+		}
+	default:
+		pid, err := strconv.ParseInt(text, 0, 32)
+		if err != nil {
+			return bad(err)
+		}
+		info.Pid = int(pid)
+	}
+	if info.Pid <= 0 {
+		return bad(fmt.Errorf("invalid pid %d", info.Pid))
+	}
+	return info, nil
+}
+
+//Pid in the pidfile. 0 without an error when there is no pidfile or it is
+//stale, see read.
+func (f *Pidfile) Pid() (int, error) {
+	info, err := f.info()
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	if info.stale() {
+		return 0, nil
+	}
+	return info.Pid, nil
+}
+
+//Write info in format with the modes and owner of c, creating missing
+//directories. An empty Pidfile is not written.
+func (f *Pidfile) record(format string, info *pidInfo, c *LogFiles) error {
+	if *f == "" {
+		return nil
+	}
+	data := []byte(strconv.Itoa(info.Pid))
+	switch format {
+	case PidfileStartTime:
+		data = []byte(fmt.Sprintf("%d %d\n", info.Pid, info.StartTime))
+	case PidfileJSON:
+		data, _ = json.Marshal(info)
+		data = append(data, '\n')
+	}
+	return writePidfile(string(*f), data, c)
+}
+
+//Write a pidfile as openLog creates log files: modes default to 0750 for
+//directories and 0660 for the file, a nil c uses the defaults.
+func writePidfile(path string, data []byte, c *LogFiles) error {
+	if c == nil {
+		c = &LogFiles{}
+	}
+	chown, err := c.chowner()
+	if err != nil {
+		return err
+	}
+	if err := mkdirs(filepath.Dir(path), modeOr(c.DirMode, 0750), chown); err != nil {
+		return err
+	}
+	mode := modeOr(c.FileMode, 0660)
+	if err := ioutil.WriteFile(path, data, mode); err != nil {
+		return err
+	}
+	if err := os.Chmod(path, mode); err != nil {
+		return err
+	}
+	return chown(path)
+}
+
+type waitResult struct {
+	state *ExitStatus
+	err   error
+}
+
+//Handle whose Wait was already started by waitPidfile.
+type waitingHandle struct {
+	Handle
+	result chan waitResult
+}
+
+func (h *waitingHandle) Wait() (*ExitStatus, error) {
+	r := <-h.result
+	return r.state, r.err
+}
+
+//Wait for the child started as x to write the pidfile, returning the
+//handle of the pid it holds and whether that is another process than x,
+//which cannot be waited for. x is reaped when it exits, as forking daemons
+//do once the daemon runs; failing before the pidfile holds a running pid
+//fails the start, as does PidfileTimeout (default 10s), killing x.
+func (p *Process) waitPidfile(x Handle) (Handle, bool, error) {
+	timeout := durationOr(p.PidfileTimeout, 10*time.Second)
+	result := make(chan waitResult, 1)
+	go func() {
+		state, err := x.Wait()
+		result <- waitResult{state, err}
+	}()
+	deadline := p.clock().After(timeout)
+	tick := p.clock().NewTicker(50 * time.Millisecond)
+	defer tick.Stop()
+	var exit *waitResult
+	for {
+		pid := p.Pidfile.read()
+		if pid > 0 && pid == x.Pid() && exit == nil {
+			return &waitingHandle{x, result}, false, nil
+		}
+		if pid > 0 && pid != x.Pid() && alive(pid) {
+			found, err := os.FindProcess(pid)
+			if err != nil {
+				return nil, false, err
+			}
+			return &execHandle{found}, true, nil
+		}
+		select {
+		case r := <-result:
+			if r.err != nil {
+				return nil, false, r.err
+			}
+			if !r.state.Success() {
+				return nil, false, fmt.Errorf("%s before writing %s", r.state, p.Pidfile)
+			}
+			exit = &r
+			result = nil
+		case <-tick.C():
+		case <-deadline:
+			x.Signal(os.Kill)
+			return nil, false, fmt.Errorf("%s not written within %s", p.Pidfile, timeout)
+		}
+	}
+}
@@ -0,0 +1,192 @@
+// goforever - processes management
+// Copyright (c) 2013 Garrett Woodworth (https://github.com/gwoo).
+
+package process
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPidfileFormats(t *testing.T) {
+	dir := t.TempDir()
+	self := newPidInfo(os.Getpid(), os.Args[0])
+	for _, format := range []string{PidfilePlain, PidfileStartTime, PidfileJSON} {
+		f := Pidfile(filepath.Join(dir, format+".pid"))
+		if err := f.record(format, self, nil); err != nil {
+			t.Fatal(err)
+		}
+		if pid := f.read(); pid != os.Getpid() {
+			t.Errorf("%s: expected %#v. Result %#v\n", format, os.Getpid(), pid)
+		}
+	}
+	data, _ := ioutil.ReadFile(filepath.Join(dir, "json.pid"))
+	if data[0] != '{' {
+		t.Errorf("Expected a JSON pidfile. Result %q\n", data)
+	}
+	for _, p := range []*Process{{PidfileFormat: "xml"}, {PidfileOwner: "cron"}, {PidfileOwner: PidfileChild}} {
+		if err := p.checkPidfile(); err == nil {
+			t.Errorf("Expected an error for %#v\n", p)
+		}
+	}
+}
+
+func TestPidfileStale(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("start times are only read on linux")
+	}
+	dir := t.TempDir()
+	reused := newPidInfo(os.Getpid(), os.Args[0])
+	reused.StartTime++
+	f := Pidfile(filepath.Join(dir, "start.pid"))
+	f.record(PidfileStartTime, reused, nil)
+	if pid := f.read(); pid != 0 {
+		t.Errorf("Expected a reused pid to be stale. Result %#v\n", pid)
+	}
+	other := newPidInfo(os.Getpid(), os.Args[0])
+	other.CommandHash = hashCommand("/usr/sbin/other")
+	f = Pidfile(filepath.Join(dir, "json.pid"))
+	f.record(PidfileJSON, other, nil)
+	if pid := f.read(); pid != 0 {
+		t.Errorf("Expected a pid running another command to be stale. Result %#v\n", pid)
+	}
+}
+
+func TestPidfileChild(t *testing.T) {
+	pidfile := Pidfile(filepath.Join(t.TempDir(), "daemon.pid"))
+	p := &Process{
+		Name:         "daemon",
+		Command:      "/bin/sh",
+		Args:         []string{"-c", "sleep 10 & printf %s $! > " + string(pidfile)},
+		Pidfile:      pidfile,
+		PidfileOwner: PidfileChild,
+	}
+	if err := p.start(""); err != nil {
+		t.Fatal(err)
+	}
+	pid := p.Snapshot().Pid
+	if pid == 0 || pid != pidfile.read() || !alive(pid) {
+		t.Fatalf("Expected to track the daemon's pid %d. Result %d\n", pidfile.read(), pid)
+	}
+	p.stop(nil)
+	waitFor(t, func() bool {
+		return !alive(pid)
+	})
+}
+
+func TestPidfileChildFailures(t *testing.T) {
+	pidfile := Pidfile(filepath.Join(t.TempDir(), "daemon.pid"))
+	for args, ex := range map[string]string{
+		"exit 3":   "exit status 3",
+		"sleep 10": "not written within 100ms",
+	} {
+		p := &Process{
+			Name:           "daemon",
+			Command:        "/bin/sh",
+			Args:           []string{"-c", args},
+			Pidfile:        pidfile,
+			PidfileOwner:   PidfileChild,
+			PidfileTimeout: "100ms",
+		}
+		if err := p.start(""); err == nil || !strings.Contains(err.Error(), ex) {
+			t.Errorf("%s: expected an error with %q. Result %v\n", args, ex, err)
+		}
+	}
+}
+
+func TestPidfileTimeoutClock(t *testing.T) {
+	clock := NewFakeClock(time.Now())
+	p := &Process{
+		Name:           "daemon",
+		Command:        "/bin/sh",
+		Args:           []string{"-c", "sleep 10"},
+		Pidfile:        Pidfile(filepath.Join(t.TempDir(), "daemon.pid")),
+		PidfileOwner:   PidfileChild,
+		PidfileTimeout: "1h",
+		Clock:          clock,
+	}
+	errs := make(chan error, 1)
+	go func() { errs <- p.start("") }()
+	clock.BlockUntil(2)
+	clock.Advance(time.Hour)
+	if err := <-errs; err == nil || !strings.Contains(err.Error(), "not written within 1h") {
+		t.Errorf("Expected a timeout on the process clock. Result %v\n", err)
+	}
+}
+
+func TestPidfileParsing(t *testing.T) {
+	dir := t.TempDir()
+	for content, ex := range map[string]int{
+		"123\n":                          123,
+		"  123  \r\n":                    123,
+		"# written by the daemon\n123\n": 123,
+		"123 # main pid\n":               123,
+		"123 4567\n":                     123,
+		"{\"pid\": 123}\n":               123,
+	} {
+		f := Pidfile(filepath.Join(dir, "ok.pid"))
+		ioutil.WriteFile(string(f), []byte(content), 0600)
+		if info, err := f.info(); err != nil || info.Pid != ex {
+			t.Errorf("%q: expected %#v. Result %#v %v\n", content, ex, info, err)
+		}
+	}
+	for _, content := range []string{"", "\n# nothing\n", "abc\n", "-5\n", "1 2 3\n", "{\"pid\": \"x\"}"} {
+		f := Pidfile(filepath.Join(dir, "bad.pid"))
+		ioutil.WriteFile(string(f), []byte(content), 0600)
+		if _, err := f.Pid(); err == nil || !strings.Contains(err.Error(), string(f)) {
+			t.Errorf("%q: expected an error naming the file. Result %v\n", content, err)
+		}
+		p := &Process{Name: "bad", Pidfile: f}
+		if _, _, err := p.Find(); err == nil || !strings.Contains(err.Error(), string(f)) {
+			t.Errorf("%q: expected Find to report the parse error. Result %v\n", content, err)
+		}
+	}
+	missing := Pidfile(filepath.Join(dir, "missing.pid"))
+	if pid, err := missing.Pid(); pid != 0 || err != nil {
+		t.Errorf("Expected no pid and no error for a missing pidfile. Result %d %v\n", pid, err)
+	}
+}
+
+func TestPidfilePerms(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "run", "app", "web.pid")
+	f := Pidfile(path)
+	if err := f.record(PidfilePlain, &pidInfo{Pid: 12}, &LogFiles{DirMode: "0700", FileMode: "0644"}); err != nil {
+		t.Fatal(err)
+	}
+	for p, ex := range map[string]os.FileMode{path: 0644, filepath.Dir(path): 0700} {
+		if fi, err := os.Stat(p); err != nil || fi.Mode().Perm() != ex {
+			t.Errorf("%s: expected mode %o. Result %v %v\n", p, ex, fi.Mode().Perm(), err)
+		}
+	}
+	if pid := f.read(); pid != 12 {
+		t.Errorf("Expected %#v. Result %#v\n", 12, pid)
+	}
+}
+
+func TestRunDir(t *testing.T) {
+	m := NewManager()
+	m.RunDir = "/run/goforever"
+	web := &Process{Command: "/usr/bin/web"}
+	own := &Process{Command: "/usr/bin/db", Pidfile: "/var/run/db.pid"}
+	m.Add("web", web)
+	m.Add("db", own)
+	n, _ := m.AddInstance("worker", &Process{Command: "/usr/bin/worker"})
+	ns, _ := m.Namespace("acme")
+	cron := &Process{Command: "/usr/bin/cron"}
+	ns.Add("cron", cron)
+	for p, ex := range map[*Process]Pidfile{
+		web:      "/run/goforever/web.pid",
+		own:      "/var/run/db.pid",
+		m.Get(n): "/run/goforever/worker-1.pid",
+		cron:     "/run/goforever/acme/cron.pid",
+	} {
+		if p.Pidfile != ex {
+			t.Errorf("Expected %#v. Result %#v\n", ex, p.Pidfile)
+		}
+	}
+}
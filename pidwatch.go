@@ -0,0 +1,29 @@
+// goforever - processes management
+// Copyright (c) 2013 Garrett Woodworth (https://github.com/gwoo).
+
+package process
+
+import (
+	"os"
+	"syscall"
+	"time"
+)
+
+//How often pollPid checks a process.
+var pidPollInterval = time.Second
+
+//Call exited once pid is gone, checking with signal 0. Used where the
+//platform has no exit notification for processes that are not our children.
+func pollPid(pid int, exited func()) {
+	go func() {
+		for {
+			x, err := os.FindProcess(pid)
+			if err != nil || x.Signal(syscall.Signal(0)) != nil {
+				exited()
+				return
+			}
+			x.Release()
+			time.Sleep(pidPollInterval)
+		}
+	}()
+}
@@ -0,0 +1,84 @@
+// goforever - processes management
+// Copyright (c) 2013 Garrett Woodworth (https://github.com/gwoo).
+
+//go:build darwin || dragonfly || freebsd || netbsd || openbsd
+
+package process
+
+import (
+	"sync"
+	"syscall"
+)
+
+//All watched pids share one kqueue served by one goroutine. A pid watched
+//twice has one kevent calling every exited func.
+var kq struct {
+	once  sync.Once
+	mu    sync.Mutex
+	fd    int
+	err   error
+	exits map[int][]func()
+}
+
+//Call exited once pid is gone, using EVFILT_PROC/NOTE_EXIT on a shared
+//kqueue. Falls back to polling if the kqueue cannot be created.
+func watchPid(pid int, exited func()) {
+	kq.once.Do(func() {
+		kq.exits = map[int][]func(){}
+		kq.fd, kq.err = syscall.Kqueue()
+		if kq.err == nil {
+			syscall.CloseOnExec(kq.fd)
+			go keventPids()
+		}
+	})
+	if kq.err != nil {
+		pollPid(pid, exited)
+		return
+	}
+	kq.mu.Lock()
+	kq.exits[pid] = append(kq.exits[pid], exited)
+	watched := len(kq.exits[pid]) > 1
+	kq.mu.Unlock()
+	if watched {
+		return
+	}
+	var ev syscall.Kevent_t
+	syscall.SetKevent(&ev, pid, syscall.EVFILT_PROC, syscall.EV_ADD|syscall.EV_ONESHOT)
+	ev.Fflags = syscall.NOTE_EXIT
+	if _, err := syscall.Kevent(kq.fd, []syscall.Kevent_t{ev}, nil, nil); err != nil {
+		kq.mu.Lock()
+		all := kq.exits[pid]
+		delete(kq.exits, pid)
+		kq.mu.Unlock()
+		for _, exited := range all {
+			if err == syscall.ESRCH {
+				exited()
+			} else {
+				pollPid(pid, exited)
+			}
+		}
+	}
+}
+
+func keventPids() {
+	events := make([]syscall.Kevent_t, 64)
+	for {
+		n, err := syscall.Kevent(kq.fd, nil, events, nil)
+		if err == syscall.EINTR {
+			continue
+		}
+		if err != nil {
+			return
+		}
+		for _, ev := range events[:n] {
+			pid := int(ev.Ident)
+			kq.mu.Lock()
+			all := kq.exits[pid]
+			delete(kq.exits, pid)
+			kq.mu.Unlock()
+			for _, exited := range all {
+				exited()
+			}
+		}
+	}
+}
@@ -0,0 +1,82 @@
+// goforever - processes management
+// Copyright (c) 2013 Garrett Woodworth (https://github.com/gwoo).
+
+package process
+
+import (
+	"sync"
+	"syscall"
+)
+
+const sysPidfdOpen = 434
+
+//All watched pidfds share one epoll set served by one goroutine.
+var pidfds struct {
+	once  sync.Once
+	mu    sync.Mutex
+	epfd  int
+	err   error
+	exits map[int32]func()
+}
+
+//Call exited once pid is gone, using a pidfd in a shared epoll set. Falls
+//back to polling on kernels without pidfd_open (before 5.3).
+func watchPid(pid int, exited func()) {
+	pidfds.once.Do(func() {
+		pidfds.exits = map[int32]func(){}
+		pidfds.epfd, pidfds.err = syscall.EpollCreate1(syscall.EPOLL_CLOEXEC)
+		if pidfds.err == nil {
+			go epollPids()
+		}
+	})
+	if pidfds.err != nil {
+		pollPid(pid, exited)
+		return
+	}
+	fd, _, errno := syscall.Syscall(sysPidfdOpen, uintptr(pid), 0, 0)
+	if errno == syscall.ESRCH {
+		exited()
+		return
+	}
+	if errno != 0 {
+		pollPid(pid, exited)
+		return
+	}
+	syscall.CloseOnExec(int(fd))
+	pidfds.mu.Lock()
+	pidfds.exits[int32(fd)] = exited
+	pidfds.mu.Unlock()
+	ev := syscall.EpollEvent{Events: syscall.EPOLLIN, Fd: int32(fd)}
+	if err := syscall.EpollCtl(pidfds.epfd, syscall.EPOLL_CTL_ADD, int(fd), &ev); err != nil {
+		pidfds.mu.Lock()
+		delete(pidfds.exits, int32(fd))
+		pidfds.mu.Unlock()
+		syscall.Close(int(fd))
+		pollPid(pid, exited)
+	}
+}
+
+//A pidfd becomes readable when its process exits.
+func epollPids() {
+	events := make([]syscall.EpollEvent, 64)
+	for {
+		n, err := syscall.EpollWait(pidfds.epfd, events, -1)
+		if err == syscall.EINTR {
+			continue
+		}
+		if err != nil {
+			return
+		}
+		for _, ev := range events[:n] {
+			pidfds.mu.Lock()
+			exited := pidfds.exits[ev.Fd]
+			delete(pidfds.exits, ev.Fd)
+			pidfds.mu.Unlock()
+			syscall.EpollCtl(pidfds.epfd, syscall.EPOLL_CTL_DEL, int(ev.Fd), nil)
+			syscall.Close(int(ev.Fd))
+			if exited != nil {
+				exited()
+			}
+		}
+	}
+}
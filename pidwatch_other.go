@@ -0,0 +1,11 @@
+// goforever - processes management
+// Copyright (c) 2013 Garrett Woodworth (https://github.com/gwoo).
+
+//go:build !linux && !darwin && !dragonfly && !freebsd && !netbsd && !openbsd
+
+package process
+
+//Call exited once pid is gone.
+func watchPid(pid int, exited func()) {
+	pollPid(pid, exited)
+}
@@ -0,0 +1,31 @@
+// goforever - processes management
+// Copyright (c) 2013 Garrett Woodworth (https://github.com/gwoo).
+
+package process
+
+import (
+	"os/exec"
+	"testing"
+	"time"
+)
+
+func TestWatchPid(t *testing.T) {
+	cmd := exec.Command("/bin/sleep", "10")
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	exited := make(chan struct{})
+	watchPid(cmd.Process.Pid, func() { close(exited) })
+	select {
+	case <-exited:
+		t.Fatal("Expected process to be running.")
+	case <-time.After(50 * time.Millisecond):
+	}
+	cmd.Process.Kill()
+	cmd.Wait()
+	select {
+	case <-exited:
+	case <-time.After(3 * time.Second):
+		t.Error("Expected exit notification.")
+	}
+}
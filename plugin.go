@@ -0,0 +1,119 @@
+// goforever - processes management
+// Copyright (c) 2013 Garrett Woodworth (https://github.com/gwoo).
+
+package process
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/rpc/jsonrpc"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//Run the process as a managed plugin. Started with PLUGIN_PROTOCOL_VERSION
+//in its environment, the child must first print a handshake line on
+//stdout,
+//
+//	1|<version>|<network>|<address>
+//
+//naming the protocol Version it speaks and the unix or tcp address of a
+//JSON-RPC (net/rpc/jsonrpc) server. A missing or wrong handshake fails the
+//start. Stops call Plugin.Shutdown on that server instead of sending TERM,
+//still killing it after the Stop timeout. Further output is logged as
+//usual.
+type Plugin struct {
+	Version int `json:"version"`
+	//Wait for the handshake, 10s by default.
+	Timeout string `json:"timeout,omitempty"`
+}
+
+//Handshake core protocol version.
+const pluginCore = "1"
+
+//Control endpoint announced by a plugin.
+type pluginAddr struct {
+	network string
+	address string
+}
+
+//Give the child a pipe as stdout whose first line is sent on the returned
+//channel; the rest is copied to out, which the pipe then owns and closes.
+func pluginPipe(out *os.File) (*os.File, <-chan string, error) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		return nil, nil, err
+	}
+	lines := make(chan string, 1)
+	go func() {
+		defer r.Close()
+		br := bufio.NewReader(r)
+		line, _ := br.ReadString('\n')
+		lines <- line
+		var dst io.Writer = ioutil.Discard
+		if out != nil {
+			dst = out
+			defer closeFiles([]*os.File{out})
+		}
+		io.Copy(dst, br)
+	}()
+	return w, lines, nil
+}
+
+//Wait for and check the handshake line of a just started plugin.
+func (p *Process) pluginHandshake(lines <-chan string, exited chan struct{}) error {
+	timeout := durationOr(p.Plugin.Timeout, 10*time.Second)
+	var line string
+	select {
+	case line = <-lines:
+	case <-exited:
+		return errors.New("Exited before the plugin handshake.")
+	case <-p.clock().After(timeout):
+		return fmt.Errorf("No plugin handshake within %s.", timeout)
+	}
+	parts := strings.Split(strings.TrimSpace(line), "|")
+	if len(parts) != 4 || parts[0] != pluginCore {
+		return fmt.Errorf("Bad plugin handshake %q.", strings.TrimSpace(line))
+	}
+	if v, err := strconv.Atoi(parts[1]); err != nil || v != p.Plugin.Version {
+		return fmt.Errorf("Plugin protocol version %s, expected %d.", parts[1], p.Plugin.Version)
+	}
+	if parts[2] != "unix" && parts[2] != "tcp" {
+		return fmt.Errorf("Bad plugin network %q.", parts[2])
+	}
+	p.mu.Lock()
+	p.plugAddr = &pluginAddr{network: parts[2], address: parts[3]}
+	p.mu.Unlock()
+	p.log(LevelDebug, "plugin handshake", Fields{"network": parts[2], "address": parts[3]})
+	return nil
+}
+
+//Ask a plugin to shut down. False if it is not one or the call failed, in
+//which case it should be signalled instead.
+func (p *Process) shutdownPlugin() bool {
+	p.mu.Lock()
+	addr := p.plugAddr
+	p.mu.Unlock()
+	if addr == nil {
+		return false
+	}
+	conn, err := net.DialTimeout(addr.network, addr.address, 2*time.Second)
+	if err != nil {
+		p.log(LevelWarn, "plugin shutdown failed", Fields{"error": err})
+		return false
+	}
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	client := jsonrpc.NewClient(conn)
+	defer client.Close()
+	if err := client.Call("Plugin.Shutdown", struct{}{}, &struct{}{}); err != nil {
+		p.log(LevelWarn, "plugin shutdown failed", Fields{"error": err})
+		return false
+	}
+	return true
+}
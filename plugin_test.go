@@ -0,0 +1,103 @@
+// goforever - processes management
+// Copyright (c) 2013 Garrett Woodworth (https://github.com/gwoo).
+
+package process
+
+import (
+	"fmt"
+	"net"
+	"net/rpc"
+	"net/rpc/jsonrpc"
+	"strings"
+	"sync"
+	"testing"
+)
+
+type fakePlugin struct {
+	mu    sync.Mutex
+	calls int
+	proc  *FakeProcess
+}
+
+func (f *fakePlugin) Shutdown(args *struct{}, reply *struct{}) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls++
+	f.proc.Exit(0)
+	return nil
+}
+
+func servePlugin(t *testing.T, f *fakePlugin) net.Listener {
+	s := rpc.NewServer()
+	if err := s.RegisterName("Plugin", f); err != nil {
+		t.Fatal(err)
+	}
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go s.ServeCodec(jsonrpc.NewServerCodec(conn))
+		}
+	}()
+	return l
+}
+
+func TestPlugin(t *testing.T) {
+	f := &fakePlugin{}
+	l := servePlugin(t, f)
+	defer l.Close()
+	r := NewFakeRunner()
+	r.OnStart = func(proc *FakeProcess) {
+		f.mu.Lock()
+		f.proc = proc
+		f.mu.Unlock()
+		fmt.Fprintf(proc.Cmd.Files[1], "1|2|tcp|%s\n", l.Addr())
+	}
+	p := &Process{Command: "plugin", Runner: r, Ping: "1h", Plugin: &Plugin{Version: 2}}
+	if err := p.start("plugin"); err != nil {
+		t.Fatalf("Expected a start. Result %v\n", err)
+	}
+	found := false
+	for _, kv := range r.Processes()[0].Cmd.Env {
+		found = found || kv == "PLUGIN_PROTOCOL_VERSION=2"
+	}
+	if !found {
+		t.Errorf("Expected PLUGIN_PROTOCOL_VERSION=2 in the environment.\n")
+	}
+	p.Stop()
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.calls != 1 {
+		t.Errorf("Expected %#v. Result %#v\n", 1, f.calls)
+	}
+	if sigs := f.proc.Signals(); len(sigs) != 0 {
+		t.Errorf("Expected no signals. Result %v\n", sigs)
+	}
+}
+
+func TestPluginHandshake(t *testing.T) {
+	for line, want := range map[string]string{
+		"1|1|tcp|127.0.0.1:1\n": "Plugin protocol version 1, expected 2.",
+		"hello\n":               `Bad plugin handshake "hello".`,
+		"1|2|udp|127.0.0.1:1\n": `Bad plugin network "udp".`,
+	} {
+		r := NewFakeRunner()
+		r.OnStart = func(proc *FakeProcess) {
+			fmt.Fprint(proc.Cmd.Files[1], line)
+		}
+		p := &Process{Command: "plugin", Runner: r, Ping: "1h", Plugin: &Plugin{Version: 2}}
+		err := p.start("plugin")
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("Expected %#v. Result %v\n", want, err)
+		}
+		if len(r.Running()) != 0 {
+			t.Errorf("Expected the plugin stopped after %q.\n", line)
+		}
+	}
+}
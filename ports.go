@@ -0,0 +1,141 @@
+// goforever - processes management
+// Copyright (c) 2013 Garrett Woodworth (https://github.com/gwoo).
+
+package process
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+//Split a port given as "8080", "tcp:8080" or "udp:53" into its network and
+//number. The network defaults to tcp.
+func parsePort(s string) (string, int, error) {
+	network := "tcp"
+	if i := strings.Index(s, ":"); i >= 0 {
+		network, s = s[:i], s[i+1:]
+	}
+	if network != "tcp" && network != "udp" {
+		return "", 0, errors.New("Port network must be tcp or udp.")
+	}
+	port, err := strconv.Atoi(s)
+	if err != nil || port <= 0 || port > 65535 {
+		return "", 0, errors.New("Bad port number.")
+	}
+	return network, port, nil
+}
+
+//Pid of the process listening on port ("8080", "tcp:8080" or "udp:53"), 0
+//if none is. Workers sharing a listener give their main process. Set
+//Match.Port to adopt it.
+func FindByPort(port string) (int, error) {
+	pids, err := (&Match{Port: port}).find()
+	if err != nil || len(pids) == 0 {
+		return 0, err
+	}
+	return pids[0], nil
+}
+
+//Fail if a port in ExpectedPorts is taken, naming its owner when it can be
+//found. A port is taken if binding it fails with the address in use, or if
+//a process is found listening on it.
+func (p *Process) checkPorts() error {
+	for _, port := range p.ExpectedPorts {
+		network, n, err := parsePort(port)
+		if err != nil {
+			return fmt.Errorf("Expected port %q: %s", port, err)
+		}
+		err = bindPort(network, n)
+		if err == nil {
+			continue
+		}
+		pid, _ := FindByPort(port)
+		if pid > 0 {
+			return fmt.Errorf("Port %s:%d is in use by pid %d (%s).", network, n, pid, procCommand(pid))
+		}
+		if errors.Is(err, syscall.EADDRINUSE) {
+			return fmt.Errorf("Port %s:%d is in use.", network, n)
+		}
+	}
+	return nil
+}
+
+//Bind port on all addresses and release it.
+func bindPort(network string, port int) error {
+	addr := ":" + strconv.Itoa(port)
+	if network == "udp" {
+		c, err := net.ListenPacket(network, addr)
+		if err == nil {
+			c.Close()
+		}
+		return err
+	}
+	l, err := net.Listen(network, addr)
+	if err == nil {
+		l.Close()
+	}
+	return err
+}
+
+//Command line of pid, "unknown" if it cannot be read.
+func procCommand(pid int) string {
+	procs, _ := scanProcs()
+	for _, e := range procs {
+		if e.pid == pid {
+			return e.cmdline
+		}
+	}
+	return "unknown"
+}
+
+//Wait up to PortsTimeout for the started process to bind ExpectedPorts.
+//Without a timeout nothing is verified.
+func (p *Process) verifyPorts(exited chan struct{}) error {
+	if p.PortsTimeout == "" || len(p.ExpectedPorts) == 0 {
+		return nil
+	}
+	timeout := durationOr(p.PortsTimeout, 10*time.Second)
+	deadline := time.After(timeout)
+	tick := time.NewTicker(100 * time.Millisecond)
+	defer tick.Stop()
+	for {
+		missing := ""
+		for _, port := range p.ExpectedPorts {
+			if network, n, err := parsePort(port); err == nil && !portBound(network, n) {
+				missing = network + ":" + strconv.Itoa(n)
+				break
+			}
+		}
+		if missing == "" {
+			return nil
+		}
+		select {
+		case <-exited:
+			return fmt.Errorf("Exited before binding port %s.", missing)
+		case <-deadline:
+			return fmt.Errorf("Port %s not bound within %s.", missing, timeout)
+		case <-tick.C:
+		}
+	}
+}
+
+//Whether someone listens on port. Unlike bindPort this never holds the
+//port, so the child's own bind cannot fail because of it.
+func portBound(network string, port int) bool {
+	if pids, _ := portPids(network, port); len(pids) > 0 {
+		return true
+	}
+	if network != "tcp" {
+		return false
+	}
+	c, err := net.DialTimeout("tcp", "127.0.0.1:"+strconv.Itoa(port), time.Second)
+	if err == nil {
+		c.Close()
+	}
+	return err == nil
+}
@@ -0,0 +1,66 @@
+// goforever - processes management
+// Copyright (c) 2013 Garrett Woodworth (https://github.com/gwoo).
+
+package process
+
+import (
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+)
+
+//Pids holding a socket listening on port: the socket inodes are looked up
+//in /proc/net, then among the file descriptors of every readable process.
+func portPids(network string, port int) ([]int, error) {
+	inodes := map[string]bool{}
+	for _, file := range []string{network, network + "6"} {
+		data, err := ioutil.ReadFile("/proc/net/" + file)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return nil, err
+		}
+		for _, line := range strings.Split(string(data), "\n")[1:] {
+			fields := strings.Fields(line)
+			if len(fields) < 10 {
+				continue
+			}
+			i := strings.LastIndex(fields[1], ":")
+			p, err := strconv.ParseUint(fields[1][i+1:], 16, 16)
+			if err != nil || int(p) != port {
+				continue
+			}
+			//TCP_LISTEN, or an unconnected UDP socket.
+			if (network == "tcp" && fields[3] == "0A") || (network == "udp" && fields[3] == "07") {
+				inodes["socket:["+fields[9]+"]"] = true
+			}
+		}
+	}
+	if len(inodes) == 0 {
+		return nil, nil
+	}
+	dirs, err := ioutil.ReadDir("/proc")
+	if err != nil {
+		return nil, err
+	}
+	var pids []int
+	for _, d := range dirs {
+		pid, err := strconv.Atoi(d.Name())
+		if err != nil {
+			continue
+		}
+		fds, err := ioutil.ReadDir("/proc/" + d.Name() + "/fd")
+		if err != nil {
+			continue
+		}
+		for _, fd := range fds {
+			if link, _ := os.Readlink("/proc/" + d.Name() + "/fd/" + fd.Name()); inodes[link] {
+				pids = append(pids, pid)
+				break
+			}
+		}
+	}
+	return pids, nil
+}
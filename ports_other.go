@@ -0,0 +1,34 @@
+// goforever - processes management
+// Copyright (c) 2013 Garrett Woodworth (https://github.com/gwoo).
+
+//go:build !linux
+
+package process
+
+import (
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+//Pids holding a socket listening on port, as listed by lsof.
+func portPids(network string, port int) ([]int, error) {
+	args := []string{"-nP", "-t", "-i", network + ":" + strconv.Itoa(port)}
+	if network == "tcp" {
+		args = append(args, "-sTCP:LISTEN")
+	}
+	out, err := exec.Command("lsof", args...).Output()
+	if _, ok := err.(*exec.ExitError); ok && len(out) == 0 {
+		//lsof exits 1 when nothing matches.
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var pids []int
+	for _, field := range strings.Fields(string(out)) {
+		if pid, err := strconv.Atoi(field); err == nil {
+			pids = append(pids, pid)
+		}
+	}
+	return pids, nil
+}
@@ -0,0 +1,118 @@
+// goforever - processes management
+// Copyright (c) 2013 Garrett Woodworth (https://github.com/gwoo).
+
+package process
+
+import (
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParsePort(t *testing.T) {
+	for s, expected := range map[string]string{"8080": "tcp 8080", "tcp:80": "tcp 80", "udp:53": "udp 53"} {
+		network, port, err := parsePort(s)
+		if result := network + " " + strconv.Itoa(port); err != nil || result != expected {
+			t.Errorf("Expected %#v. Result %#v %#v\n", expected, result, err)
+		}
+	}
+	for _, s := range []string{"", "http", "sctp:80", "0", "70000"} {
+		if _, _, err := parsePort(s); err == nil {
+			t.Errorf("Expected %#v rejected.\n", s)
+		}
+	}
+}
+
+func TestFindByPort(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := strconv.Itoa(l.Addr().(*net.TCPAddr).Port)
+	if pid, err := FindByPort(port); err != nil || pid != os.Getpid() {
+		t.Errorf("Expected %d. Result %d %#v\n", os.Getpid(), pid, err)
+	}
+	u, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer u.Close()
+	udp := "udp:" + strconv.Itoa(u.LocalAddr().(*net.UDPAddr).Port)
+	if pid, err := FindByPort(udp); err != nil || pid != os.Getpid() {
+		t.Errorf("Expected %d on %s. Result %d %#v\n", os.Getpid(), udp, pid, err)
+	}
+	if pids, _ := (&Match{Port: port, Command: "^nothing$"}).find(); len(pids) != 0 {
+		t.Errorf("Expected the command to filter. Result %#v\n", pids)
+	}
+	l.Close()
+	if pid, err := FindByPort(port); err != nil || pid != 0 {
+		t.Errorf("Expected no listener. Result %d %#v\n", pid, err)
+	}
+}
+
+func TestExpectedPorts(t *testing.T) {
+	l, err := net.Listen("tcp", ":0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	port := strconv.Itoa(l.Addr().(*net.TCPAddr).Port)
+	runner := NewFakeRunner()
+	p := &Process{Command: "web", Runner: runner, ExpectedPorts: []string{port}}
+	err = p.start("web")
+	expected := "Port tcp:" + port + " is in use by pid " + strconv.Itoa(os.Getpid())
+	if err == nil || !strings.HasPrefix(err.Error(), expected) {
+		t.Errorf("Expected %#v. Result %#v\n", expected, err)
+	}
+	if n := len(runner.Processes()); n != 0 {
+		t.Errorf("Expected nothing started. Result %#v\n", n)
+	}
+	l.Close()
+	if err := p.start("web"); err != nil {
+		t.Errorf("Expected a free port to start. Result %#v\n", err)
+	}
+	p.ExpectedPorts = []string{"bad"}
+	if err := p.start("web"); err == nil {
+		t.Error("Expected a bad port to fail.")
+	}
+}
+
+func TestPortsTimeout(t *testing.T) {
+	l, err := net.Listen("tcp", ":0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := strconv.Itoa(l.Addr().(*net.TCPAddr).Port)
+	l.Close()
+	runner := NewFakeRunner()
+	p := &Process{Command: "web", Runner: runner, ExpectedPorts: []string{port}, PortsTimeout: "300ms"}
+	err = p.start("web")
+	expected := "Port tcp:" + port + " not bound within 300ms."
+	if err == nil || err.Error() != expected {
+		t.Errorf("Expected %#v. Result %#v\n", expected, err)
+	}
+	if s := p.Snapshot(); s.Pid != 0 || len(runner.Running()) != 0 {
+		t.Errorf("Expected the process stopped. Result %#v\n", s)
+	}
+
+	bound := make(chan net.Listener, 1)
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		l, err := net.Listen("tcp", ":"+port)
+		if err != nil {
+			t.Error(err)
+		}
+		bound <- l
+	}()
+	p.PortsTimeout = "5s"
+	if err := p.start("web"); err != nil {
+		t.Errorf("Expected the bound port to pass. Result %#v\n", err)
+	}
+	if l := <-bound; l != nil {
+		l.Close()
+	}
+	p.Stop()
+}
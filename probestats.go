@@ -0,0 +1,88 @@
+// goforever - processes management
+// Copyright (c) 2013 Garrett Woodworth (https://github.com/gwoo).
+
+package process
+
+import (
+	"sort"
+	"time"
+)
+
+//Health checks kept per process for ProbeStats.
+var maxProbeSamples = 100
+
+//One run of the Health probes.
+type ProbeSample struct {
+	Time    time.Time     `json:"time"`
+	Latency time.Duration `json:"latency"`
+	OK      bool          `json:"ok"`
+}
+
+//Success rate and latency of the last Health checks, oldest sample first,
+//along with the totals since the supervisor started.
+type ProbeStats struct {
+	Samples     []ProbeSample `json:"samples,omitempty"`
+	SuccessRate float64       `json:"success_rate"`
+	LatencyP50  time.Duration `json:"latency_p50"`
+	LatencyP90  time.Duration `json:"latency_p90"`
+	LatencyP99  time.Duration `json:"latency_p99"`
+	Checks      uint64        `json:"checks"`
+	Failures    uint64        `json:"failures"`
+}
+
+//Samples (kept across restarts) and totals of a process's Health checks.
+type probeWindow struct {
+	samples  []ProbeSample
+	checks   uint64
+	failures uint64
+}
+
+//Record a check. Called with p.mu held.
+func (p *Process) recordProbe(s ProbeSample) {
+	if p.probes == nil {
+		p.probes = &probeWindow{}
+	}
+	w := p.probes
+	if w.samples = append(w.samples, s); len(w.samples) > maxProbeSamples {
+		w.samples = w.samples[len(w.samples)-maxProbeSamples:]
+	}
+	w.checks++
+	if !s.OK {
+		w.failures++
+	}
+}
+
+//Health check statistics, nil before the first check. Checks are run by
+//Manager.WatchHealth.
+func (p *Process) ProbeStats() *ProbeStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.probeStats(true)
+}
+
+//See ProbeStats. Called with p.mu held.
+func (p *Process) probeStats(samples bool) *ProbeStats {
+	w := p.probes
+	if w == nil || len(w.samples) == 0 {
+		return nil
+	}
+	st := &ProbeStats{Checks: w.checks, Failures: w.failures}
+	if samples {
+		st.Samples = append([]ProbeSample(nil), w.samples...)
+	}
+	latencies := make([]time.Duration, len(w.samples))
+	ok := 0
+	for i, s := range w.samples {
+		latencies[i] = s.Latency
+		if s.OK {
+			ok++
+		}
+	}
+	st.SuccessRate = float64(ok) / float64(len(w.samples))
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	quantile := func(q float64) time.Duration {
+		return latencies[int(q*float64(len(latencies)-1)+0.5)]
+	}
+	st.LatencyP50, st.LatencyP90, st.LatencyP99 = quantile(0.5), quantile(0.9), quantile(0.99)
+	return st
+}
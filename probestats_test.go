@@ -0,0 +1,66 @@
+// goforever - processes management
+// Copyright (c) 2013 Garrett Woodworth (https://github.com/gwoo).
+
+package process
+
+import (
+	"bytes"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestProbeStats(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := NewManager()
+	p := &Process{Pid: 1, Health: []Probe{{TCP: l.Addr().String()}}}
+	m.Add("web", p)
+	if st := p.ProbeStats(); st != nil {
+		t.Errorf("Expected no stats before a check. Result %#v\n", st)
+	}
+	p.checkHealth()
+	p.checkHealth()
+	p.checkHealth()
+	l.Close()
+	p.checkHealth()
+	st := p.ProbeStats()
+	if st == nil || len(st.Samples) != 4 || st.Checks != 4 || st.Failures != 1 || st.SuccessRate != 0.75 {
+		t.Fatalf("Expected 4 checks, 1 failed. Result %#v\n", st)
+	}
+	if st.LatencyP50 <= 0 || st.LatencyP99 < st.LatencyP50 {
+		t.Errorf("Expected ordered latencies. Result %s %s\n", st.LatencyP50, st.LatencyP99)
+	}
+	if info := p.Snapshot(); info.Probes == nil || info.Probes.Samples != nil || info.Probes.Checks != 4 {
+		t.Errorf("Expected the stats without samples. Result %#v\n", info.Probes)
+	}
+	var b bytes.Buffer
+	m.WriteMetrics(&b)
+	for _, line := range []string{
+		`process_probe_success_ratio{process="web"} 0.75`,
+		`process_probe_checks_total{process="web"} 4`,
+		`process_probe_latency_seconds{process="web",quantile="0.99"}`,
+	} {
+		if !strings.Contains(b.String(), line) {
+			t.Errorf("Expected %q in the metrics.\n", line)
+		}
+	}
+}
+
+func TestProbeStatsWindow(t *testing.T) {
+	p := &Process{}
+	now := time.Now()
+	for i := 0; i < maxProbeSamples+10; i++ {
+		p.recordProbe(ProbeSample{Time: now, Latency: time.Duration(i) * time.Millisecond, OK: i >= 10})
+	}
+	st := p.ProbeStats()
+	if len(st.Samples) != maxProbeSamples || st.Checks != uint64(maxProbeSamples+10) || st.SuccessRate != 1 {
+		t.Errorf("Expected the last %d samples. Result %d %d %g\n", maxProbeSamples, len(st.Samples), st.Checks, st.SuccessRate)
+	}
+	if st.LatencyP50 != 60*time.Millisecond {
+		t.Errorf("Expected %s. Result %s\n", 60*time.Millisecond, st.LatencyP50)
+	}
+}
@@ -0,0 +1,20 @@
+// goforever - processes management
+// Copyright (c) 2013 Garrett Woodworth (https://github.com/gwoo).
+
+//go:build !linux
+
+package process
+
+//Start times, executables and states of other processes are only read on
+//Linux.
+func procStart(pid int) (uint64, bool) {
+	return 0, false
+}
+
+func procExe(pid int) (string, bool) {
+	return "", false
+}
+
+func procZombie(pid int) bool {
+	return false
+}
@@ -4,53 +4,327 @@
 package process
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
-	"io/ioutil"
 	"log"
+	"net/rpc"
 	"os"
-	"os/exec"
+	"regexp"
 	"strconv"
+	"sync"
+	"syscall"
 	"time"
 )
 
-var ping = "1m"
+//Ping interval of processes for which neither they nor their manager set
+//one.
+const DefaultPing = time.Minute
 
-//Run the process
-func RunProcess(name string, p *Process) chan *Process {
-	ch := make(chan *Process)
-	go func() {
-		p.Start(name)
-		p.ping(ping, func(time time.Duration, p *Process) {
-			if p.Pid > 0 {
-				p.respawns = 0
-				fmt.Printf("%s refreshed after %s.\n", p.Name, time)
-				p.Status = "running"
-			}
-		})
-		go p.Watch()
-		ch <- p
-	}()
-	return ch
+var (
+	ErrInvalidName   = errors.New("Invalid process name.")
+	ErrDuplicateName = errors.New("Duplicate process name.")
+	ErrNotFound      = errors.New("Unknown process.")
+)
+
+//Process names may contain letters, digits, '.', '_' and '-'.
+var validName = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+//Check that a process name is usable. "all" is reserved for children.Stop.
+func ValidName(name string) error {
+	if len(name) > 64 || name == "all" || !validName.MatchString(name) {
+		return ErrInvalidName
+	}
+	return nil
+}
+
+//Start the process, retrying as configured, and supervise it. The error is
+//that of the last failed start, after which the process is fatal.
+func RunProcess(name string, p *Process) (*Process, error) {
+	if err := p.launch(name); err != nil {
+		return p, err
+	}
+	go p.Watch()
+	return p, nil
+}
+
+//Start the process, retrying as configured, and ping it.
+func (p *Process) launch(name string) error {
+	if err := p.startRetrying(name); err != nil {
+		return err
+	}
+	p.ping(func(time time.Duration, p *Process) {
+		p.mu.Lock()
+		running := p.Pid > 0
+		if running {
+			p.respawns = 0
+			p.Status = "running"
+		}
+		p.mu.Unlock()
+		if running {
+			p.log(LevelDebug, "refreshed", Fields{"after": time.String()})
+		}
+	})
+	return nil
 }
 
 type Process struct {
-	Name     string
-	Command  string
-	Args     []string
-	Pidfile  Pidfile
-	Logfile  string
-	Errfile  string
-	Path     string
-	Respawn  int
-	Delay    string
-	Ping     string
-	Pid      int
-	Status   string
-	x        *os.Process
+	Name     string   `json:"name"`
+	Command  string   `json:"command"`
+	Args     []string `json:"args,omitempty"`
+	Env      []string `json:"env,omitempty"`
+	Flags    []Flag   `json:"flags,omitempty"`
+	Vars     Vars     `json:"vars,omitempty"`
+	Pidfile  Pidfile  `json:"pidfile,omitempty"`
+	Logfile  string   `json:"logfile,omitempty"`
+	Errfile  string   `json:"errfile,omitempty"`
+	Path     string   `json:"path,omitempty"`
+	Respawn  int      `json:"respawn,omitempty"`
+	Delay    string   `json:"delay,omitempty"`
+	Ping     string   `json:"ping,omitempty"`
+	LogLevel string   `json:"log_level,omitempty"`
+	Pid      int      `json:"pid,omitempty"`    //Deprecated: racy, use CurrentPid.
+	Status   string   `json:"status,omitempty"` //Deprecated: racy, use CurrentStatus.
+	Logger   Logger   `json:"-"`
+	Clock    Clock    `json:"-"`
+	Runner   Runner   `json:"-"`
+
+	//argv[0] of Command, defaulting to its base name. Multi-call binaries
+	//such as busybox pick their applet by it.
+	Argv0 string `json:"argv0,omitempty"`
+	//Script run with /bin/sh -c instead of a Command, with Args as its
+	//positional parameters. See CommandLine.
+	Shell string `json:"shell,omitempty"`
+	//KEY=VALUE file read at every start, overridden by Env.
+	EnvFile string `json:"envfile,omitempty"`
+	//Files whose contents are watched along with EnvFile.
+	Secrets []string `json:"secrets,omitempty"`
+	//Restart when the EnvFile or Secrets change, see Manager.WatchSources.
+	RestartOnChange bool `json:"restart_on_change,omitempty"`
+	//Restart when the Command binary changes, once it has been unchanged
+	//for this quiet period, e.g. "5s", see Manager.WatchBinaries.
+	WatchBinary string `json:"watch_binary,omitempty"`
+	//Arguments printing the binary's version, e.g. ["--version"], run
+	//with a 2s timeout whenever a start or drift check finds a new binary,
+	//see Process.Binary. Go binaries report their module version without
+	//them.
+	VersionArgs []string `json:"version_args,omitempty"`
+
+	//Number of copies to run, see Config.Manager.
+	Instances int `json:"instances,omitempty"`
+	//Readiness checks that must all pass for the process to count as healthy.
+	Health []Probe `json:"health,omitempty"`
+	//How long to wait for Health after a (re)start. Defaults to 30s.
+	HealthTimeout string `json:"health_timeout,omitempty"`
+	//Settings for Manager.BlueGreenRestart.
+	BlueGreen *BlueGreen `json:"blue_green,omitempty"`
+	//Thresholds of the health state, see Manager.WatchHealth.
+	HealthPolicy *HealthPolicy `json:"health_policy,omitempty"`
+	//Conditions, such as a database port accepting connections, that must
+	//hold before each start, and how long to wait for them. Defaults to 1m.
+	WaitFor     []Probe `json:"wait_for,omitempty"`
+	WaitTimeout string  `json:"wait_timeout,omitempty"`
+	//Consul or etcd registration while healthy.
+	Register *Registration `json:"register,omitempty"`
+	//Probes restarting the process when failing. Health holds the
+	//readiness probes.
+	Liveness *Liveness `json:"liveness,omitempty"`
+	//Processes or instance groups started before and stopped after this
+	//one by Manager.Run and Manager.Shutdown.
+	DependsOn []string `json:"depends_on,omitempty"`
+	//Run on one cluster node at a time, the one holding its lock, see
+	//Cluster.Lock.
+	Singleton bool `json:"singleton,omitempty"`
+
+	//Attempts to exec the command again when starting fails, before the
+	//process is marked fatal.
+	StartRetries int `json:"start_retries,omitempty"`
+	//Wait before the first start retry, doubled after each one.
+	StartBackoff string `json:"start_backoff,omitempty"`
+	//Window in which Manager.Restart requests are coalesced.
+	RestartDebounce string `json:"restart_debounce,omitempty"`
+	//Start, stop and restart requests the API accepts per ActionWindow
+	//(default 1m), 0 for no limit. Requests beyond it get a 429.
+	ActionLimit  int    `json:"action_limit,omitempty"`
+	ActionWindow string `json:"action_window,omitempty"`
+	//Per-phase limits.
+	Timeouts *Timeouts `json:"timeouts,omitempty"`
+	//Format the pidfile is written in, see PidfilePlain. Defaults to plain.
+	PidfileFormat string `json:"pidfile_format,omitempty"`
+	//Who writes the pidfile: supervisor (default) or child, for daemons
+	//that fork and write their own. The supervisor then waits up to
+	//PidfileTimeout (default 10s) for it and tracks the pid it holds.
+	PidfileOwner   string `json:"pidfile_owner,omitempty"`
+	PidfileTimeout string `json:"pidfile_timeout,omitempty"`
+	//Modes and owner of the pidfile and the directories created for it, as
+	//for log files.
+	PidfilePerms *LogFiles `json:"pidfile_perms,omitempty"`
+	//Recognizes the running process without a pidfile, see Find.
+	Match *Match `json:"match,omitempty"`
+	//Starting the process while it, or the pid in its pidfile, is running
+	//fails with ErrAlreadyRunning (error, the default) or adopts the pid
+	//(adopt).
+	AlreadyRunning string `json:"already_running,omitempty"`
+	//Ports the process listens on, e.g. "8080" or "udp:53". Start fails
+	//while another process holds one and, given PortsTimeout, if the
+	//process has not bound them all by then.
+	ExpectedPorts []string `json:"expected_ports,omitempty"`
+	PortsTimeout  string   `json:"ports_timeout,omitempty"`
+	//Network namespace the process and its Sockets are started in, a name
+	//from "ip netns add" or a path (Linux).
+	NetNS string `json:"netns,omitempty"`
+	//Absolute paths the process sees read-only, or not at all, through
+	//bind mounts in a mount namespace of its own (Linux, needs
+	//CAP_SYS_ADMIN). Mounts below a read-only path stay writable.
+	ReadOnlyPaths     []string `json:"read_only_paths,omitempty"`
+	InaccessiblePaths []string `json:"inaccessible_paths,omitempty"`
+	//Run the process as a container instead, see Container.
+	Container *Container `json:"container,omitempty"`
+	//Speak the plugin handshake and shutdown protocol, see Plugin.
+	Plugin *Plugin `json:"plugin,omitempty"`
+	//Pass the process a control channel for reloads, stats and graceful
+	//stops, see ControlEnv.
+	Control bool `json:"control,omitempty"`
+	//Wait up to NotifyTimeout (default 30s) for the process to send
+	//READY=1 to NOTIFY_SOCKET before its start succeeds, as systemd's
+	//Type=notify.
+	Notify        bool   `json:"notify,omitempty"`
+	NotifyTimeout string `json:"notify_timeout,omitempty"`
+	//Restart the process when it sends no WATCHDOG=1 to NOTIFY_SOCKET for
+	//this long, given to it in WATCHDOG_USEC.
+	Watchdog string `json:"watchdog,omitempty"`
+	//Runner backend registered under this name, see RegisterRunner:
+	//exec, container, ssh or one added by the program.
+	RunnerName string `json:"runner,omitempty"`
+	//Host of the ssh runner, see SSH.
+	SSH *SSH `json:"ssh,omitempty"`
+	//Sockets bound before start and passed to the process, see Socket.
+	Sockets []Socket `json:"sockets,omitempty"`
+	//CPUs the process is pinned to after start (Linux).
+	CPUAffinity []int `json:"cpu_affinity,omitempty"`
+	//Cgroup v2 the process is moved into after start, a path absolute or
+	//below /sys/fs/cgroup, created if needed (Linux). OOM kills it counts
+	//in memory.events are reported as such.
+	Cgroup string `json:"cgroup,omitempty"`
+	//Respawn delay after an OOM kill, instead of Delay.
+	OOMDelay string `json:"oom_delay,omitempty"`
+	//Size limits on the directories the process writes, checked by
+	//Manager.WatchDisk.
+	Disk []DiskLimit `json:"disk,omitempty"`
+	//Collects core and stack dumps of crashing or hung processes.
+	Crash *Crash `json:"crash,omitempty"`
+	//Commands run around start, stop and reload.
+	Hooks *Hooks `json:"hooks,omitempty"`
+	//Release fetched into place before each start, see Artifact.
+	Artifact *Artifact `json:"artifact,omitempty"`
+	//Hex SHA-256 the Command binary must have, checked before each start.
+	ExpectedSHA256 string `json:"expected_sha256,omitempty"`
+	//Pipe output through the supervisor instead of handing the child the
+	//Logfile and Errfile.
+	Output *Output `json:"output,omitempty"`
+	//Modes and owner of log files and their directories.
+	LogFiles *LogFiles `json:"log_files,omitempty"`
+	//Create a temporary directory for each start, named in TMPDIR and
+	//removed once the child has stopped. ChdirTmp also runs the child in it.
+	PrivateTmp bool `json:"private_tmp,omitempty"`
+	ChdirTmp   bool `json:"chdir_tmp,omitempty"`
+
+	//Guards the runtime state below along with Pid and Status.
+	mu       sync.Mutex
+	x        Handle
+	exited   chan struct{}
+	state    *ExitStatus
+	waitErr  error
+	started  time.Time
+	lastExit *Exit
+	exits    []Exit
+	srcHash  string
+	group    string
+	color    string
+	reason   string
 	respawns int
 	children children
+	manager  *Manager
+	op       *Operation
+	drops    [2]uint64
+	sinkErrs map[string]*uint64
+	rings    map[string]*ring
+	logs     []*logFile
+	capture  *capture
+	health   *healthWindow
+	listed   bool
+	liveSt   *liveState
+	pipes    [2]*os.File
+	pingStop chan struct{}
+	watcher  *watcher
+	tmpDir   string
+	plugAddr *pluginAddr
+	control  *rpc.Client
+	notice   string
+	oomBase  int
+	probes   *probeWindow
+	paused   bool
+	frozen   string
+	actions  []time.Time
+	limited  map[string]uint64
+	bin      *binState
+	vers     *binVersion
+	adopted  bool
+	//Held by starts, stops and reloads. Probes, liveness checks and usage
+	//sampling skip a process in transition instead of waiting on it.
+	cycle sync.Mutex
+}
+
+//How a process last exited.
+type Exit struct {
+	Time  time.Time `json:"time"`
+	Code  int       `json:"code"`
+	State string    `json:"state"`
+	Pid   int       `json:"pid,omitempty"`
+	//Readable cause, e.g. "killed by OOM", see ExitStatus.Reason.
+	Reason string `json:"reason,omitempty"`
+	//Killed by the OOM killer, per the kernel log or the process's Cgroup.
+	OOM bool `json:"oom,omitempty"`
+	//Core file collected into Crash.Dir.
+	Core string `json:"core,omitempty"`
+}
+
+//Marshal the configured fields plus the computed uptime, respawn count and
+//last exit.
+func (p *Process) MarshalJSON() ([]byte, error) {
+	type plain Process
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return json.Marshal(struct {
+		*plain
+		Uptime   string `json:"uptime,omitempty"`
+		Respawns int    `json:"respawns"`
+		LastExit *Exit  `json:"last_exit,omitempty"`
+		Reason   string `json:"reason,omitempty"`
+	}{(*plain)(p), p.uptime(), p.respawns, p.lastExit, p.reason})
+}
+
+//Time since the process was started, to the second. Empty when not running.
+func (p *Process) uptime() string {
+	if p.Pid == 0 || p.started.IsZero() {
+		return ""
+	}
+	return p.clock().Now().Sub(p.started).Truncate(time.Second).String()
+}
+
+//Copy of the process configuration without its runtime state.
+func (p *Process) clone() *Process {
+	type plain Process
+	p.mu.Lock()
+	data, _ := json.Marshal((*plain)(p))
+	p.mu.Unlock()
+	c := &Process{}
+	json.Unmarshal(data, (*plain)(c))
+	c.Pid = 0
+	c.Status = ""
+	c.Logger, c.Clock, c.Runner = p.Logger, p.Clock, p.Runner
+	return c
 }
 
 func (p *Process) String() string {
@@ -62,19 +336,37 @@ func (p *Process) String() string {
 	return string(js)
 }
 
-//Find a process by name
+//Find a process by its pidfile or, when that names no running process, by
+//Match. A found process is adopted: its exit is noticed by Watch (via
+//pidfd, kqueue or polling) although its status is unknown. A process found
+//by Match is recorded in the pidfile.
 func (p *Process) Find() (*os.Process, string, error) {
-	if p.Pidfile == "" {
+	if p.Pidfile == "" && p.Match == nil {
 		return nil, "", errors.New("Pidfile is empty.")
 	}
-	if pid := p.Pidfile.read(); pid > 0 {
+	pid, err := p.locate()
+	if err != nil {
+		return nil, "", err
+	}
+	if pid > 0 && p.Match != nil && p.Pidfile.read() != pid {
+		if err := p.Pidfile.record(p.PidfileFormat, newPidInfo(pid, p.Command), p.PidfilePerms); err != nil {
+			p.log(LevelWarn, "pidfile write failed", Fields{"error": err})
+		}
+	}
+	if pid > 0 {
 		process, err := os.FindProcess(pid)
 		if err != nil {
 			return nil, "", err
 		}
-		p.x = process
+		exited := make(chan struct{})
+		p.mu.Lock()
+		p.x = &execHandle{process}
+		p.exited = exited
 		p.Pid = process.Pid
 		p.Status = "running"
+		p.adopted = true
+		p.mu.Unlock()
+		p.watchAdopted(pid, exited)
 		message := fmt.Sprintf("%s is %#v\n", p.Name, process.Pid)
 		return process, message, nil
 	}
@@ -82,134 +374,694 @@ func (p *Process) Find() (*os.Process, string, error) {
 	return nil, message, errors.New(fmt.Sprintf("Could not find process %s.", p.Name))
 }
 
-//Start the process
+//Close exited once pid, which is not a child that can be waited for, is
+//gone.
+func (p *Process) watchAdopted(pid int, exited chan struct{}) {
+	watchPid(pid, func() {
+		p.mu.Lock()
+		p.state, p.waitErr = &ExitStatus{Code: -1}, nil
+		p.mu.Unlock()
+		close(exited)
+	})
+}
+
+//Start the process. An empty name keeps the current p.Name.
 func (p *Process) Start(name string) string {
-	p.Name = name
+	if err := p.start(name); err != nil {
+		p.log(LevelError, "start failed", Fields{"error": err})
+		return ""
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return fmt.Sprintf("%s is %#v\n", p.Name, p.Pid)
+}
+
+func (p *Process) start(name string) error {
+	if p.Paused() {
+		return ErrPaused
+	}
+	if name == "" {
+		name = p.Name
+	}
+	if err := ValidName(name); err != nil {
+		return fmt.Errorf("%q: %s", name, err)
+	}
+	if p.Name != name {
+		if p.Name != "" {
+			p.log(LevelInfo, "renamed", Fields{"name": name})
+		}
+		p.mu.Lock()
+		p.Name = name
+		p.mu.Unlock()
+	}
+	if err := p.checkPidfile(); err != nil {
+		return err
+	}
+	if adopted, err := p.alreadyRunning(); adopted || err != nil {
+		return err
+	}
+	if p.Crash != nil {
+		raiseCoreLimit()
+	}
+	for _, cpu := range p.CPUAffinity {
+		if cpu < 0 {
+			return fmt.Errorf("Bad CPU %d in cpu_affinity.", cpu)
+		}
+	}
+	if err := p.fetchArtifact(); err != nil {
+		return fmt.Errorf("artifact: %s", err)
+	}
+	env, err := p.environ()
+	if err != nil {
+		return err
+	}
+	path, b, err := p.commandLine()
+	if err != nil {
+		return err
+	}
+	if err := b.Flags(p.Flags, p.Vars); err != nil {
+		return err
+	}
+	if err := p.waitFor(); err != nil {
+		return err
+	}
+	ctx, cancel := p.startContext()
+	defer cancel()
+	if err := p.verifyBinary(ctx); err != nil {
+		return err
+	}
+	if err := p.runHook(ctx, "pre_start", p.hooks().PreStart); err != nil {
+		return err
+	}
+	if err := p.checkPorts(); err != nil {
+		return err
+	}
+	red, err := p.redactor(env)
+	if err != nil {
+		return fmt.Errorf("redact: %s", err)
+	}
+	p.mu.Lock()
+	p.capture = p.newCapture()
+	p.mu.Unlock()
 	wd, _ := os.Getwd()
-	proc := &os.ProcAttr{
-		Dir: wd,
-		Env: os.Environ(),
-		Files: []*os.File{
-			os.Stdin,
-			NewLog(p.Logfile),
-			NewLog(p.Errfile),
-		},
-	}
-	args := append([]string{p.Name}, p.Args...)
-	process, err := os.StartProcess(p.Command, args, proc)
+	files := []*os.File{os.Stdin, nil, nil}
+	for i, path := range []string{p.Logfile, p.Errfile} {
+		if p.Output == nil {
+			files[i+1] = p.openLog(path)
+			continue
+		}
+		w, err := p.pipeOutput(i, path, red)
+		if err != nil {
+			closeFiles(files[1:])
+			return err
+		}
+		files[i+1] = w
+	}
+	var handshake <-chan string
+	if p.Plugin != nil {
+		env = mergeEnv(env, []string{"PLUGIN_PROTOCOL_VERSION=" + strconv.Itoa(p.Plugin.Version)})
+		w, lines, err := pluginPipe(files[1])
+		if err != nil {
+			closeFiles(files[1:])
+			return fmt.Errorf("plugin: %s", err)
+		}
+		files[1], handshake = w, lines
+	}
+	ctlChild, ctlParent, err := p.openControl()
 	if err != nil {
-		log.Fatalf("%s failed. %s\n", p.Name, err)
-		return ""
+		closeFiles(files[1:])
+		return err
 	}
-	err = p.Pidfile.write(process.Pid)
+	notify, nenv, err := p.openNotify()
 	if err != nil {
-		log.Printf("%s pidfile error: %s\n", p.Name, err)
-		return ""
+		closeFiles(append(files[1:], ctlChild, ctlParent))
+		return err
+	}
+	env = mergeEnv(env, nenv)
+	if env, err = p.makeTmp(env); err != nil {
+		closeFiles(append(files[1:], ctlChild, ctlParent))
+		notify.close()
+		return fmt.Errorf("private tmp: %s", err)
+	}
+	if p.PrivateTmp && p.ChdirTmp {
+		wd = p.tmpDir
+	}
+	if p.PidfileOwner == PidfileChild {
+		p.Pidfile.delete()
+	}
+	var process Handle
+	err = p.inMountNS(func() error {
+		return p.inNetNS(func() error {
+			sockets, senv, err := p.openSockets()
+			if err != nil {
+				return err
+			}
+			defer closeFiles(sockets)
+			cfiles := append(files, sockets...)
+			if ctlChild != nil {
+				senv = append(senv, ControlEnv+"="+strconv.Itoa(len(cfiles)))
+				cfiles = append(cfiles, ctlChild)
+			}
+			process, err = p.runner().Start(&Cmd{
+				Path:  path,
+				Args:  b.Build(),
+				Env:   append(append(os.Environ(), env...), senv...),
+				Dir:   wd,
+				Files: cfiles,
+			})
+			return err
+		})
+	})
+	closeFiles(append(files[1:], ctlChild))
+	if err != nil {
+		closeFiles([]*os.File{ctlParent})
+		notify.close()
+		p.removeTmp()
+		return err
 	}
+	adopted := false
+	if p.PidfileOwner == PidfileChild {
+		process, adopted, err = p.waitPidfile(process)
+		if err != nil {
+			closeFiles([]*os.File{ctlParent})
+			notify.close()
+			p.removeTmp()
+			return fmt.Errorf("pidfile: %s", err)
+		}
+	} else if err := p.Pidfile.record(p.PidfileFormat, newPidInfo(process.Pid(), path), p.PidfilePerms); err != nil {
+		process.Signal(os.Kill)
+		process.Release()
+		closeFiles([]*os.File{ctlParent})
+		notify.close()
+		p.removeTmp()
+		return fmt.Errorf("pidfile: %s", err)
+	}
+	p.keepControl(ctlParent)
+	p.recordBinary()
+	exited := make(chan struct{})
+	p.mu.Lock()
 	p.x = process
-	p.Pid = process.Pid
+	p.exited = exited
+	p.Pid = process.Pid()
+	p.started = p.clock().Now()
+	p.srcHash = p.sourcesHash()
+	p.adopted = adopted
 	p.Status = "started"
-	return fmt.Sprintf("%s is %#v\n", p.Name, process.Pid)
+	p.reason = ""
+	p.notice = ""
+	p.mu.Unlock()
+	if notify != nil {
+		go p.watchNotify(notify, exited)
+	}
+	if adopted {
+		p.watchAdopted(p.Pid, exited)
+	} else {
+		go func() {
+			state, err := process.Wait()
+			p.mu.Lock()
+			p.state, p.waitErr = state, err
+			p.mu.Unlock()
+			close(exited)
+		}()
+	}
+	if p.Cgroup != "" {
+		if err := p.enterCgroup(p.Pid); err != nil {
+			p.halt(nil)
+			return fmt.Errorf("cgroup: %s", err)
+		}
+	}
+	if len(p.CPUAffinity) > 0 {
+		if err := setAffinity(p.Pid, p.CPUAffinity); err != nil {
+			p.log(LevelError, "cpu affinity failed", Fields{"error": err, "cpus": p.CPUAffinity})
+		}
+	}
+	if handshake != nil {
+		if err := p.pluginHandshake(handshake, exited); err != nil {
+			p.halt(nil)
+			return err
+		}
+	}
+	if notify != nil && p.Notify {
+		if err := p.waitReady(notify, exited); err != nil {
+			p.halt(nil)
+			return err
+		}
+	}
+	if err := p.verifyPorts(exited); err != nil {
+		p.halt(nil)
+		return err
+	}
+	p.runHook(ctx, "post_start", p.hooks().PostStart)
+	return nil
+}
+
+//Close the parent's copies of the child's files, except the supervisor's
+//own stdout and stderr.
+func closeFiles(files []*os.File) {
+	for _, f := range files {
+		if f != nil && f != os.Stdout && f != os.Stderr {
+			f.Close()
+		}
+	}
+}
+
+//Longest wait between start retries.
+var maxStartBackoff = 30 * time.Second
+
+//Start, retrying failures StartRetries times with a StartBackoff (default
+//1s) that doubles after each attempt. When the retries are exhausted the
+//process is marked fatal. Crash respawns are counted separately.
+func (p *Process) startRetrying(name string) error {
+	backoff := durationOr(p.StartBackoff, time.Second)
+	for retries := 0; ; retries++ {
+		p.cycle.Lock()
+		err := p.start(name)
+		p.cycle.Unlock()
+		if err == nil || err == ErrPaused {
+			return err
+		}
+		p.log(LevelError, "start failed", Fields{"error": err, "retries": retries})
+		if retries >= p.StartRetries {
+			p.fatal(err.Error())
+			return err
+		}
+		p.clock().Sleep(backoff)
+		if backoff *= 2; backoff > maxStartBackoff {
+			backoff = maxStartBackoff
+		}
+	}
+}
+
+//Put the process in the terminal fatal state.
+func (p *Process) fatal(reason string) {
+	p.mu.Lock()
+	p.Status = "fatal"
+	p.reason = reason
+	p.mu.Unlock()
+	p.log(LevelError, "fatal", Fields{"reason": reason})
+	p.emit(EventFatal, reason)
 }
 
 //Stop the process
 func (p *Process) Stop() string {
-	if p.x != nil {
-		// p.x.Kill() this seems to cause trouble
-		cmd := exec.Command("kill", fmt.Sprintf("%d", p.x.Pid))
-		_, err := cmd.CombinedOutput()
-		if err != nil {
-			log.Println(err)
+	p.stop(nil)
+	message := fmt.Sprintf("%s stopped.\n", p.Name)
+	return message
+}
+
+//Stop the process, reporting the steps to op: TERM, then KILL if it has not
+//exited within the Stop timeout. Waits for a start in progress to finish.
+func (p *Process) stop(op *Operation) {
+	p.cycle.Lock()
+	defer p.cycle.Unlock()
+	p.halt(op)
+}
+
+//Stop the process with p.cycle held, see stop.
+func (p *Process) halt(op *Operation) {
+	p.mu.Lock()
+	x, exited := p.x, p.exited
+	if x != nil && exited != nil {
+		select {
+		case <-exited:
+			//Already gone, only left to release once.
+			x = nil
+			if p.Pid == 0 {
+				p.mu.Unlock()
+				return
+			}
+		default:
+		}
+	}
+	if x != nil {
+		p.Status = "stopping"
+	}
+	p.mu.Unlock()
+	if x != nil {
+		//A stopped process would not handle TERM.
+		if err := p.thaw(); err != nil {
+			p.log(LevelError, "thaw failed", Fields{"error": err})
+		}
+		if err := p.deregister(); err != nil {
+			p.log(LevelError, "deregister failed", Fields{"error": err})
+		}
+		ctx := context.Background()
+		op.report("running pre_stop hook")
+		p.runHook(ctx, "pre_stop", p.hooks().PreStop)
+		if p.shutdownPlugin() {
+			op.report("requested plugin shutdown")
+		} else if p.controlStop() {
+			op.report("requested stop over the control channel")
+		} else {
+			op.report("sending TERM")
+			if err := x.Signal(syscall.SIGTERM); err != nil {
+				p.log(LevelDebug, "term failed", Fields{"error": err})
+			}
+		}
+		op.report("waiting for exit")
+		ctx, cancel := context.WithTimeout(ctx, durationOr(p.timeouts().Stop, 10*time.Second))
+		if waitExit(ctx, x, exited) != nil && p.Crash != nil && p.Crash.DumpSignal != "" {
+			op.report("requesting a stack dump")
+			p.dumpStack(x, exited)
 		}
+		if waitExit(ctx, x, exited) != nil {
+			op.report("sending KILL")
+			p.log(LevelWarn, "stop timeout, killing", nil)
+			if err := x.Signal(os.Kill); err != nil {
+				p.log(LevelError, "kill failed", Fields{"error": err})
+			}
+			kctx, kcancel := context.WithTimeout(context.Background(), time.Second)
+			waitExit(kctx, x, exited)
+			kcancel()
+		}
+		cancel()
+		op.report("stopping children")
 		p.children.Stop("all")
+		op.report("running post_stop hook")
+		p.runHook(context.Background(), "post_stop", p.hooks().PostStop)
 	}
 	p.Release("stopped")
-	message := fmt.Sprintf("%s stopped.\n", p.Name)
-	return message
 }
 
-//Release process and remove pidfile
+//Release process and remove pidfile and private tmp
 func (p *Process) Release(status string) {
+	p.mu.Lock()
 	if p.x != nil {
 		p.x.Release()
 	}
 	p.Pid = 0
 	p.Pidfile.delete()
+	if p.paused {
+		status = "paused"
+	}
 	p.Status = status
+	p.frozen = ""
+	p.cancelPing()
+	err := p.removeTmpLocked()
+	p.plugAddr = nil
+	p.closeControl()
+	p.mu.Unlock()
+	p.logTmp(err)
 }
 
-//Restart the process
+//Restart the process in the background. A supervised process is restarted
+//by its Watch goroutine; otherwise it is stopped and run again. The channel
+//receives p once it has started.
 func (p *Process) Restart() (chan *Process, string) {
-	p.Stop()
 	message := fmt.Sprintf("%s restarted.\n", p.Name)
-	ch := RunProcess(p.Name, p)
+	p.mu.Lock()
+	w := p.watcher
+	p.mu.Unlock()
+	//Buffered so that callers may ignore it.
+	ch := make(chan *Process, 1)
+	go func() {
+		if w != nil {
+			done := make(chan struct{})
+			select {
+			case w.restart <- done:
+				<-done
+				ch <- p
+				return
+			case <-w.done:
+			}
+		}
+		p.Stop()
+		RunProcess(p.Name, p)
+		ch <- p
+	}()
 	return ch, message
 }
 
-//Run callback on the process after given duration.
-func (p *Process) ping(duration string, f func(t time.Duration, p *Process)) {
-	if p.Ping != "" {
-		duration = p.Ping
-	}
-	t, err := time.ParseDuration(duration)
-	if err != nil {
-		t, _ = time.ParseDuration(ping)
-	}
+//Run callback on the process every ping interval until it is released,
+//replacing the ping of an earlier start.
+func (p *Process) ping(f func(t time.Duration, p *Process)) {
+	t := p.pingInterval()
+	stop := make(chan struct{})
+	p.mu.Lock()
+	p.cancelPing()
+	p.pingStop = stop
+	p.mu.Unlock()
+	ticker := p.clock().NewTicker(t)
 	go func() {
-		select {
-		case <-time.After(t):
-			f(t, p)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C():
+				f(t, p)
+			case <-stop:
+				return
+			}
 		}
 	}()
 }
 
-//Watch the process
+//Stop the running ping, if any. Called with p.mu held.
+func (p *Process) cancelPing() {
+	if p.pingStop != nil {
+		close(p.pingStop)
+		p.pingStop = nil
+	}
+}
+
+//Ping, else the manager's, else DefaultPing.
+func (p *Process) pingInterval() time.Duration {
+	def := DefaultPing
+	if p.manager != nil {
+		def = durationOr(p.manager.Ping, def)
+	}
+	return durationOr(p.Ping, def)
+}
+
+//Supervise the process until it stops, respawning it when it exits and
+//restarting it when asked to by Restart. Only one Watch runs per process;
+//further calls return at once.
 func (p *Process) Watch() {
-	if p.x == nil {
-		p.Release("stopped")
+	p.mu.Lock()
+	if p.watcher != nil {
+		p.mu.Unlock()
 		return
 	}
-	status := make(chan *os.ProcessState)
-	died := make(chan error)
-	go func() {
-		state, err := p.x.Wait()
-		if err != nil {
-			died <- err
-			return
-		}
-		status <- state
-	}()
+	w := &watcher{restart: make(chan chan struct{}), done: make(chan struct{})}
+	p.watcher = w
+	p.mu.Unlock()
+	for p.watchOnce(w) {
+	}
+}
+
+//Supervision goroutine of a process, see Watch.
+type watcher struct {
+	restart chan chan struct{}
+	done    chan struct{}
+}
+
+//End the supervision by w. Called with p.mu held.
+func (p *Process) unwatch(w *watcher) {
+	if p.watcher == w {
+		p.watcher = nil
+		close(w.done)
+	}
+}
+
+//Wait for the current child to exit or a restart request and handle it.
+//False once the supervision is over.
+func (p *Process) watchOnce(w *watcher) bool {
+	p.mu.Lock()
+	x, exited := p.x, p.exited
+	if x == nil || exited == nil {
+		p.unwatch(w)
+		p.mu.Unlock()
+		p.Release("stopped")
+		return false
+	}
+	p.mu.Unlock()
 	select {
-	case s := <-status:
-		if p.Status == "stopped" {
-			return
+	case done := <-w.restart:
+		defer close(done)
+		p.stop(nil)
+		return p.relaunch(w)
+	case <-exited:
+	}
+	p.mu.Lock()
+	if p.exited != exited {
+		//Started again by someone else, supervise the new child.
+		p.mu.Unlock()
+		return true
+	}
+	s, err, status := p.state, p.waitErr, p.Status
+	if status == "detached" || status == "stopped" || status == "stopping" || status == "paused" {
+		p.unwatch(w)
+		p.mu.Unlock()
+		return false
+	}
+	p.mu.Unlock()
+	if err != nil {
+		p.log(LevelError, "killed", Fields{"error": err})
+		p.mu.Lock()
+		p.unwatch(w)
+		p.mu.Unlock()
+		p.Release("killed")
+		return false
+	}
+	p.mu.Lock()
+	pid := p.Pid
+	p.mu.Unlock()
+	reason, oom := p.exitReason(s, pid)
+	p.mu.Lock()
+	p.lastExit = &Exit{Time: p.clock().Now(), Code: s.Code, State: s.String(), Pid: pid, Reason: reason, OOM: oom}
+	p.respawns++
+	respawns, exit := p.respawns, p.lastExit
+	p.mu.Unlock()
+	if p.Crash != nil && s.Signal != 0 {
+		if core := p.collectCore(pid); core != "" {
+			p.mu.Lock()
+			exit.Core = core
+			p.mu.Unlock()
 		}
-		fmt.Fprintf(os.Stderr, "%s %s\n", p.Name, s)
-		fmt.Fprintf(os.Stderr, "%s success = %#v\n", p.Name, s.Success())
-		fmt.Fprintf(os.Stderr, "%s exited = %#v\n", p.Name, s.Exited())
-		p.respawns++
-		if p.respawns > p.Respawn {
-			p.Release("exited")
-			log.Printf("%s respawn limit reached.\n", p.Name)
-			return
+	}
+	p.mu.Lock()
+	if p.exits = append(p.exits, *exit); len(p.exits) > maxExits {
+		p.exits = p.exits[1:]
+	}
+	p.mu.Unlock()
+	p.log(LevelInfo, "exited", Fields{"state": s.String(), "reason": reason, "success": s.Success(), "exited": s.Exited()})
+	p.emit(EventExit, reason)
+	if respawns > p.Respawn {
+		p.log(LevelWarn, "respawn limit reached", nil)
+		p.mu.Lock()
+		p.unwatch(w)
+		p.mu.Unlock()
+		p.Release("exited")
+		reason = "respawn limit reached"
+		if p.Crash != nil {
+			if report := p.crashReport(reason); report != "" {
+				reason += ", crash report " + report
+			}
 		}
-		fmt.Fprintf(os.Stderr, "%s respawns = %#v\n", p.Name, p.respawns)
-		if p.Delay != "" {
-			t, _ := time.ParseDuration(p.Delay)
-			time.Sleep(t)
+		p.fatal(reason)
+		return false
+	}
+	p.log(LevelInfo, "respawning", Fields{"respawns": respawns})
+	delay := p.Delay
+	if exit.OOM && p.OOMDelay != "" {
+		delay = p.OOMDelay
+	}
+	if delay != "" {
+		t, _ := time.ParseDuration(delay)
+		p.clock().Sleep(t)
+	}
+	p.stop(nil)
+	if !p.relaunch(w) {
+		return false
+	}
+	p.mu.Lock()
+	p.Status = "restarted"
+	p.mu.Unlock()
+	return true
+}
+
+//Start the stopped process again under w. False, ending the supervision,
+//if it went fatal.
+func (p *Process) relaunch(w *watcher) bool {
+	if p.launch(p.Name) == nil {
+		return true
+	}
+	p.mu.Lock()
+	p.unwatch(w)
+	p.mu.Unlock()
+	return false
+}
+
+//Add a child process.
+func (p *Process) Add(name string, child *Process) error {
+	if p.children == nil {
+		p.children = children{}
+	}
+	return p.children.Add(name, child)
+}
+
+//Add a child process as the next free instance of name (name-1, name-2, ...).
+func (p *Process) AddInstance(name string, child *Process) (string, error) {
+	if p.children == nil {
+		p.children = children{}
+	}
+	return p.children.AddInstance(name, child)
+}
+
+//Log a supervisor message with the process, pid and attempt fields attached.
+//Messages below the process level (or the manager level) are dropped.
+func (p *Process) log(level Level, msg string, fields Fields) {
+	if level >= LevelError && p.manager != nil {
+		p.manager.recordError(p.Name, msg, fields)
+	}
+	if level < p.logLevel() {
+		return
+	}
+	p.mu.Lock()
+	f := Fields{"process": p.Name, "pid": p.Pid, "attempt": p.respawns}
+	p.mu.Unlock()
+	if p.manager != nil && p.manager.ns != "" {
+		f["namespace"] = p.manager.ns
+	}
+	for k, v := range fields {
+		f[k] = v
+	}
+	p.logger().Log(level, msg, f)
+}
+
+func (p *Process) logger() Logger {
+	if p.Logger != nil {
+		return p.Logger
+	}
+	if p.manager != nil {
+		return p.manager.logger()
+	}
+	return DefaultLogger
+}
+
+//Process LogLevel overrides the manager level, which defaults to info.
+func (p *Process) logLevel() Level {
+	if p.LogLevel != "" {
+		if l, err := ParseLevel(p.LogLevel); err == nil {
+			return l
 		}
-		p.Restart()
-		p.Status = "restarted"
-	case err := <-died:
-		p.Release("killed")
-		log.Printf("%d %s killed = %#v", p.x.Pid, p.Name, err)
 	}
+	if p.manager != nil {
+		return p.manager.LogLevel
+	}
+	return LevelInfo
+}
+
+//Log a supervisor message through the manager Logger. Messages below the
+//manager level are dropped.
+func (m *Manager) log(level Level, msg string, fields Fields) {
+	if level >= LevelError {
+		name, _ := fields["process"].(string)
+		m.recordError(name, msg, fields)
+	}
+	if level < m.LogLevel {
+		return
+	}
+	f := Fields{}
+	if m.ns != "" {
+		f["namespace"] = m.ns
+	}
+	for k, v := range fields {
+		f[k] = v
+	}
+	m.logger().Log(level, msg, f)
+}
+
+func (m *Manager) logger() Logger {
+	if m.Logger != nil {
+		return m.Logger
+	}
+	return DefaultLogger
 }
 
 //Run child processes
 func (p *Process) Run() {
 	for name, p := range p.children {
-		RunProcess(name, p)
+		go RunProcess(name, p)
 	}
 }
 
@@ -235,7 +1087,7 @@ func (c children) Keys() []string {
 	return keys
 }
 
-//Get child process.
+//Get child process, nil if there is none. See Lookup.
 func (c children) Get(key string) *Process {
 	if v, ok := c[key]; ok {
 		return v
@@ -243,41 +1095,77 @@ func (c children) Get(key string) *Process {
 	return nil
 }
 
-func (c children) Stop(name string) {
+//Get child process, ErrNotFound if there is none.
+func (c children) Lookup(key string) (*Process, error) {
+	if v, ok := c[key]; ok {
+		return v, nil
+	}
+	return nil, ErrNotFound
+}
+
+//Whether there is a child process named key.
+func (c children) Exists(key string) bool {
+	_, ok := c[key]
+	return ok
+}
+
+//Add a child process under a unique, valid name.
+func (c children) Add(name string, p *Process) error {
+	if err := ValidName(name); err != nil {
+		return err
+	}
+	if _, ok := c[name]; ok {
+		return ErrDuplicateName
+	}
+	p.Name = name
+	c[name] = p
+	return nil
+}
+
+//Add a child process with the first free numeric suffix of name.
+func (c children) AddInstance(name string, p *Process) (string, error) {
+	if err := ValidName(name); err != nil {
+		return "", err
+	}
+	for i := 1; ; i++ {
+		n := fmt.Sprintf("%s-%d", name, i)
+		if _, ok := c[n]; !ok {
+			return n, c.Add(n, p)
+		}
+	}
+}
+
+//Stop and remove a child process, or all of them for "all".
+func (c children) Stop(name string) error {
 	if name == "all" {
 		for name, p := range c {
 			p.Stop()
 			delete(c, name)
 		}
-		return
+		return nil
+	}
+	p, err := c.Lookup(name)
+	if err != nil {
+		return err
 	}
-	p := c.Get(name)
 	p.Stop()
 	delete(c, name)
+	return nil
 }
 
 type Pidfile string
 
-//Read the pidfile.
+//Read the pidfile, 0 if it cannot be parsed. A pid recorded with a start
+//time or command hash that does not match the process now running as it is
+//stale and reads as 0.
 func (f *Pidfile) read() int {
-	data, err := ioutil.ReadFile(string(*f))
-	if err != nil {
-		return 0
-	}
-	pid, err := strconv.ParseInt(string(data), 0, 32)
-	if err != nil {
-		return 0
-	}
-	return int(pid)
+	pid, _ := f.Pid()
+	return pid
 }
 
-//Write the pidfile.
+//Write the pidfile. An empty Pidfile is not written.
 func (f *Pidfile) write(data int) error {
-	err := ioutil.WriteFile(string(*f), []byte(strconv.Itoa(data)), 0660)
-	if err != nil {
-		return err
-	}
-	return nil
+	return f.record(PidfilePlain, &pidInfo{Pid: data}, nil)
 }
 
 //Delete the pidfile
@@ -293,15 +1181,11 @@ func (f *Pidfile) delete() bool {
 	return false
 }
 
-//Create a new file for logging
-func NewLog(path string) *os.File {
+//Create a new file for logging, creating missing parent directories. Nil
+//for an empty path.
+func NewLog(path string) (*os.File, error) {
 	if path == "" {
-		return nil
-	}
-	file, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0660)
-	if err != nil {
-		log.Fatalf("%s", err)
-		return nil
+		return nil, nil
 	}
-	return file
+	return openLog(path, nil)
 }
@@ -4,6 +4,8 @@
 package process
 
 import (
+	"bytes"
+	"strings"
 	"testing"
 )
 
@@ -42,9 +44,163 @@ func TestProcessStart(t *testing.T) {
 	}
 	p.Start("bash")
 	ex := 0
-	r := p.x.Pid
+	r := p.x.Pid()
 	if ex >= r {
 		t.Errorf("Expected %#v < %#v\n", ex, r)
 	}
 	p.Stop()
 }
+
+func TestChildrenAdd(t *testing.T) {
+	p := &Process{}
+	if err := p.Add("web", &Process{}); err != nil {
+		t.Errorf("Error: %s.", err)
+	}
+	if err := p.Add("web", &Process{}); err != ErrDuplicateName {
+		t.Errorf("Expected %#v. Result %#v\n", ErrDuplicateName, err)
+	}
+	for _, name := range []string{"", "all", "-web", "web app", "web/1"} {
+		if err := p.Add(name, &Process{}); err != ErrInvalidName {
+			t.Errorf("%q: expected %#v. Result %#v\n", name, ErrInvalidName, err)
+		}
+	}
+	for _, ex := range []string{"worker-1", "worker-2"} {
+		r, err := p.AddInstance("worker", &Process{})
+		if err != nil || r != ex {
+			t.Errorf("Expected %#v. Result %#v %v\n", ex, r, err)
+		}
+	}
+	if c := p.children.Get("worker-2"); c == nil || c.Name != "worker-2" {
+		t.Errorf("Expected child worker-2. Result %#v\n", c)
+	}
+}
+
+func TestChildrenNotFound(t *testing.T) {
+	p := &Process{}
+	p.Add("web", &Process{})
+	if !p.children.Exists("web") || p.children.Exists("db") {
+		t.Error("Expected only web to exist.")
+	}
+	if _, err := p.children.Lookup("db"); err != ErrNotFound {
+		t.Errorf("Expected %#v. Result %#v\n", ErrNotFound, err)
+	}
+	if err := p.children.Stop("db"); err != ErrNotFound {
+		t.Errorf("Expected %#v. Result %#v\n", ErrNotFound, err)
+	}
+	if err := p.children.Stop("web"); err != nil || p.children.Exists("web") {
+		t.Errorf("Expected web stopped and removed. Result %v\n", err)
+	}
+	m := NewManager()
+	if _, err := m.Restart("db"); err != ErrNotFound {
+		t.Errorf("Expected %#v. Result %#v\n", ErrNotFound, err)
+	}
+	if err := m.Retry("db"); err != ErrNotFound {
+		t.Errorf("Expected %#v. Result %#v\n", ErrNotFound, err)
+	}
+}
+
+func TestProcessLogFields(t *testing.T) {
+	var b bytes.Buffer
+	p := &Process{Name: "web", Pid: 42, respawns: 2, Logger: NewTextLogger(&b)}
+	p.log(LevelInfo, "respawning", Fields{"note": "two words"})
+	r := b.String()
+	for _, ex := range []string{`level=info`, `msg=respawning`, `process=web`, `pid=42`, `attempt=2`, `note="two words"`} {
+		if !strings.Contains(r, ex) {
+			t.Errorf("Expected %#v in %#v\n", ex, r)
+		}
+	}
+}
+
+func TestProcessLogLevel(t *testing.T) {
+	var b bytes.Buffer
+	m := NewManager()
+	m.Logger = NewTextLogger(&b)
+	m.LogLevel = LevelWarn
+	p := &Process{}
+	m.Add("web", p)
+	p.log(LevelInfo, "exited", nil)
+	if b.Len() != 0 {
+		t.Errorf("Expected quiet manager to drop info. Result %#v\n", b.String())
+	}
+	p.LogLevel = "debug"
+	p.log(LevelDebug, "refreshed", nil)
+	if !strings.Contains(b.String(), "msg=refreshed") {
+		t.Errorf("Expected process level to override manager. Result %#v\n", b.String())
+	}
+}
+
+func TestManagerLog(t *testing.T) {
+	var b bytes.Buffer
+	m := NewManager()
+	m.Logger = NewTextLogger(&b)
+	m.LogLevel = LevelWarn
+	m.log(LevelInfo, "adopted", nil)
+	if b.Len() != 0 {
+		t.Errorf("Expected quiet manager to drop info. Result %#v\n", b.String())
+	}
+	m.log(LevelError, "saving state failed", Fields{"process": "web"})
+	if !strings.Contains(b.String(), "msg=\"saving state failed\"") {
+		t.Errorf("Expected the manager Logger. Result %#v\n", b.String())
+	}
+	if errs := m.Health().Errors; len(errs) != 1 || errs[0].Process != "web" {
+		t.Errorf("Expected the error recorded. Result %#v\n", errs)
+	}
+}
+
+func TestProcessJSON(t *testing.T) {
+	p := &Process{Name: "web", Command: "/bin/web", respawns: 2}
+	ex := `{"name":"web","command":"/bin/web","respawns":2}`
+	r := p.String()
+	if ex != r {
+		t.Errorf("Expected %s. Result %s\n", ex, r)
+	}
+}
+
+func TestProcessStartRetries(t *testing.T) {
+	m := NewManager()
+	var events []Event
+	m.OnEvent(func(e Event) { events = append(events, e) })
+	p := &Process{
+		Command:      "/nonexistent/command",
+		Pidfile:      "missing.pid",
+		StartRetries: 2,
+		StartBackoff: "1ms",
+	}
+	m.Add("missing", p)
+	if _, err := RunProcess("missing", p); err == nil || !strings.Contains(err.Error(), "no such file") {
+		t.Errorf("Expected the exec error returned. Result %#v\n", err)
+	}
+	s := p.Snapshot()
+	if s.Status != "fatal" || !strings.Contains(s.Reason, "no such file") {
+		t.Errorf("Expected fatal status with reason. Result %#v\n", s)
+	}
+	if len(events) != 1 || events[0].Type != EventFatal {
+		t.Errorf("Expected one fatal event. Result %#v\n", events)
+	}
+}
+
+func TestRestartWatcher(t *testing.T) {
+	r := NewFakeRunner()
+	p := &Process{Command: "web", Runner: r, Ping: "1h", Respawn: 1}
+	RunProcess("web", p)
+	watcher := func() *watcher {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		return p.watcher
+	}
+	waitFor(t, func() bool { return watcher() != nil })
+	w := watcher()
+	ch, _ := p.Restart()
+	<-ch
+	go p.Watch()
+	if p.CurrentPid() != FakePid+1 || watcher() != w {
+		t.Errorf("Expected a restart by the same Watch. Result %d\n", p.CurrentPid())
+	}
+	r.Running()[0].Exit(1)
+	waitFor(t, func() bool { return p.CurrentPid() == FakePid+2 })
+	if watcher() != w {
+		t.Error("Expected the respawn by the same Watch.")
+	}
+	p.Stop()
+	waitFor(t, func() bool { return watcher() == nil })
+}
@@ -0,0 +1,112 @@
+// goforever - processes management
+// Copyright (c) 2013 Garrett Woodworth (https://github.com/gwoo).
+
+package process
+
+import (
+	"errors"
+	"time"
+)
+
+var ErrRateLimited = errors.New("Too many requests for the process, retry later.")
+
+//Count an API request for action against the ActionLimit: ErrRateLimited,
+//with the wait until the next one is accepted, if it is used up.
+func (p *Process) allowAction(action string) (time.Duration, error) {
+	if _, wait := p.takeActions([]string{action}); wait > 0 {
+		return wait, ErrRateLimited
+	}
+	return 0, nil
+}
+
+//Count the actions if they fit in the ActionLimit, returning the time they
+//were counted at. Otherwise the wait until they fit, with the actions
+//counted as refused.
+func (p *Process) takeActions(actions []string) (time.Time, time.Duration) {
+	if p.ActionLimit <= 0 {
+		return time.Time{}, 0
+	}
+	window := durationOr(p.ActionWindow, time.Minute)
+	now := p.clock().Now()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	recent := p.actions[:0]
+	for _, t := range p.actions {
+		if now.Sub(t) < window {
+			recent = append(recent, t)
+		}
+	}
+	p.actions = recent
+	over := len(p.actions) + len(actions) - p.ActionLimit
+	if over <= 0 {
+		for range actions {
+			p.actions = append(p.actions, now)
+		}
+		return now, 0
+	}
+	if p.limited == nil {
+		p.limited = map[string]uint64{}
+	}
+	for _, action := range actions {
+		p.limited[action]++
+	}
+	if over > len(p.actions) {
+		//More than the limit at once, never accepted.
+		return time.Time{}, window
+	}
+	return time.Time{}, p.actions[over-1].Add(window).Sub(now)
+}
+
+//Uncount n actions taken at t.
+func (p *Process) returnActions(t time.Time, n int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for i := len(p.actions) - 1; i >= 0 && n > 0; i-- {
+		if p.actions[i].Equal(t) {
+			p.actions = append(p.actions[:i], p.actions[i+1:]...)
+			n--
+		}
+	}
+}
+
+//Count batch steps or webhook actions against the ActionLimit of their
+//processes, all or none: ErrRateLimited with the longest wait if one
+//process has too few left.
+func (m *Manager) allowOps(ops []Op) (time.Duration, error) {
+	actions := map[*Process][]string{}
+	for _, o := range ops {
+		if p := m.Get(o.Process); p != nil {
+			actions[p] = append(actions[p], o.Action)
+		}
+	}
+	var wait time.Duration
+	taken := map[*Process]time.Time{}
+	for p, a := range actions {
+		t, w := p.takeActions(a)
+		if w > wait {
+			wait = w
+		}
+		if w == 0 {
+			taken[p] = t
+		}
+	}
+	if wait == 0 {
+		return 0, nil
+	}
+	for p, t := range taken {
+		p.returnActions(t, len(actions[p]))
+	}
+	return wait, ErrRateLimited
+}
+
+//Copy of the refused requests by action. Called with p.mu held.
+func (p *Process) limitedCounts() map[string]uint64 {
+	if len(p.limited) == 0 {
+		return nil
+	}
+	counts := make(map[string]uint64, len(p.limited))
+	for action, n := range p.limited {
+		counts[action] = n
+	}
+	return counts
+}
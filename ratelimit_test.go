@@ -0,0 +1,117 @@
+// goforever - processes management
+// Copyright (c) 2013 Garrett Woodworth (https://github.com/gwoo).
+
+package process
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestActionLimit(t *testing.T) {
+	clock := NewFakeClock(time.Now())
+	m := NewManager()
+	m.Add("web", &Process{Command: "web", Runner: NewFakeRunner(), Clock: clock, ActionLimit: 2, ActionWindow: "1m"})
+	post := func() *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		m.API(nil).ServeHTTP(w, httptest.NewRequest("POST", "/processes/web/stop", nil))
+		return w
+	}
+	for i := 0; i < 2; i++ {
+		if w := post(); w.Code != 202 {
+			t.Errorf("Expected 202. Result %d %s\n", w.Code, w.Body.String())
+		}
+	}
+	clock.Advance(20 * time.Second)
+	w := post()
+	if w.Code != 429 || w.Header().Get("Retry-After") != "40" {
+		t.Errorf("Expected 429 retrying after 40s. Result %d %#v\n", w.Code, w.Header().Get("Retry-After"))
+	}
+	clock.Advance(40 * time.Second)
+	if w := post(); w.Code != 202 {
+		t.Errorf("Expected 202 after the window. Result %d %s\n", w.Code, w.Body.String())
+	}
+	if limited := m.Get("web").Snapshot().RateLimited; limited["stop"] != 1 {
+		t.Errorf("Expected 1 refused stop. Result %#v\n", limited)
+	}
+	var buf bytes.Buffer
+	m.WriteMetrics(&buf)
+	if !strings.Contains(buf.String(), `process_rate_limited_total{process="web",action="stop"} 1`) {
+		t.Errorf("Expected the refused stop counted. Result %s\n", buf.String())
+	}
+}
+
+func TestActionLimitOff(t *testing.T) {
+	p := &Process{Command: "web"}
+	for i := 0; i < 100; i++ {
+		if _, err := p.allowAction("restart"); err != nil {
+			t.Fatalf("Expected no limit. Result %#v\n", err)
+		}
+	}
+}
+
+func TestActionLimitBatch(t *testing.T) {
+	m := NewManager()
+	m.Add("web", &Process{Command: "web", Runner: NewFakeRunner(), ActionLimit: 2})
+	m.Add("db", &Process{Command: "db", Runner: NewFakeRunner(), ActionLimit: 2})
+	post := func(body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		m.API(nil).ServeHTTP(w, httptest.NewRequest("POST", "/batch", strings.NewReader(body)))
+		return w
+	}
+	if w := post(`[{"action": "stop", "process": "db"}, {"action": "stop", "process": "web"}, {"action": "stop", "process": "web"}]`); w.Code != 200 {
+		t.Fatalf("Expected 200. Result %d %s\n", w.Code, w.Body.String())
+	}
+	w := post(`[{"action": "stop", "process": "db"}, {"action": "stop", "process": "web"}]`)
+	if w.Code != 429 || w.Header().Get("Retry-After") == "" {
+		t.Errorf("Expected 429. Result %d %s\n", w.Code, w.Body.String())
+	}
+	//Nothing of the refused batch is counted against db.
+	if _, err := m.Get("db").allowAction("stop"); err != nil {
+		t.Errorf("Expected db to have an action left. Result %#v\n", err)
+	}
+	if limited := m.Get("web").Snapshot().RateLimited; limited["stop"] != 1 {
+		t.Errorf("Expected 1 refused stop of web. Result %#v\n", limited)
+	}
+	if len(m.Operations()) != 3 {
+		t.Errorf("Expected only the first batch run. Result %d operations\n", len(m.Operations()))
+	}
+}
+
+func TestActionLimitConcurrent(t *testing.T) {
+	p := &Process{Name: "web", ActionLimit: 5}
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	allowed := 0
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := p.allowAction("restart"); err == nil {
+				mu.Lock()
+				allowed++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if allowed != 5 {
+		t.Errorf("Expected 5 allowed. Result %d\n", allowed)
+	}
+}
+
+func TestActionLimitApply(t *testing.T) {
+	m := NewManager()
+	m.Add("web", &Process{Command: "web", Runner: NewFakeRunner(), Ping: "1h", ActionLimit: 1})
+	for i, ex := range []int{200, 429} {
+		w := httptest.NewRecorder()
+		m.API(nil).ServeHTTP(w, httptest.NewRequest("POST", "/processes/web/apply", strings.NewReader(`{"command": "web", "action_limit": 1}`)))
+		if w.Code != ex {
+			t.Errorf("%d: expected %d. Result %d %s\n", i, ex, w.Code, w.Body.String())
+		}
+	}
+}
@@ -0,0 +1,163 @@
+// goforever - processes management
+// Copyright (c) 2013 Garrett Woodworth (https://github.com/gwoo).
+
+//go:build unix
+
+package process
+
+import (
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+)
+
+//Runner for supervisors with many short-lived children. Instead of a thread
+//blocked in wait4 for every child, one goroutine reaps all exits with
+//wait4(-1) on SIGCHLD and hands them to the waiting handles.
+//
+//wait4(-1) collects every child of the supervisor, so commands started some
+//other way while the reaper runs (os/exec hooks and Exec probes) lose their
+//exit status and report "no child processes".
+type ReaperRunner struct {
+	once    sync.Once
+	mu      sync.Mutex
+	waiting map[int]*reaperHandle
+	sigs    chan os.Signal
+	done    chan struct{}
+}
+
+//Create a reaper runner. The reaper goroutine starts with the first process.
+func NewReaperRunner() *ReaperRunner {
+	return &ReaperRunner{
+		waiting: map[int]*reaperHandle{},
+	}
+}
+
+func (r *ReaperRunner) Start(cmd *Cmd) (Handle, error) {
+	r.once.Do(r.run)
+	files := make([]uintptr, len(cmd.Files))
+	for i, f := range cmd.Files {
+		files[i] = ^uintptr(0)
+		if f != nil {
+			files[i] = f.Fd()
+		}
+	}
+	//Hold the lock across the fork so a fast exit, reaped before the handle
+	//is registered, waits for it.
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	pid, err := syscall.ForkExec(cmd.Path, cmd.Args, &syscall.ProcAttr{
+		Dir:   cmd.Dir,
+		Env:   cmd.Env,
+		Files: files,
+	})
+	if err != nil {
+		return nil, &os.PathError{Op: "fork/exec", Path: cmd.Path, Err: err}
+	}
+	h := &reaperHandle{pid: pid, exit: make(chan *ExitStatus, 1)}
+	r.waiting[pid] = h
+	return h, nil
+}
+
+//Number of children waiting to be reaped.
+func (r *ReaperRunner) Waiting() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.waiting)
+}
+
+//Stop the reaper goroutine. Children still running are no longer reaped.
+func (r *ReaperRunner) Close() error {
+	r.once.Do(func() {})
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.done != nil {
+		signal.Stop(r.sigs)
+		close(r.done)
+		r.done = nil
+	}
+	return nil
+}
+
+func (r *ReaperRunner) run() {
+	r.sigs = make(chan os.Signal, 1)
+	r.done = make(chan struct{})
+	signal.Notify(r.sigs, syscall.SIGCHLD)
+	go func(sigs chan os.Signal, done chan struct{}) {
+		for {
+			r.reap()
+			select {
+			case <-sigs:
+			case <-done:
+				return
+			}
+		}
+	}(r.sigs, r.done)
+}
+
+//Collect every exited child without blocking. Exits of children not
+//started by the runner are dropped, see ReaperRunner.
+func (r *ReaperRunner) reap() {
+	for {
+		var ws syscall.WaitStatus
+		pid, err := syscall.Wait4(-1, &ws, syscall.WNOHANG, nil)
+		if err == syscall.EINTR {
+			continue
+		}
+		if err != nil || pid <= 0 {
+			return
+		}
+		s := &ExitStatus{Code: ws.ExitStatus()}
+		if ws.Signaled() {
+			s.Signal = ws.Signal()
+		}
+		r.mu.Lock()
+		if h, ok := r.waiting[pid]; ok {
+			delete(r.waiting, pid)
+			h.finish(s)
+		}
+		r.mu.Unlock()
+	}
+}
+
+type reaperHandle struct {
+	pid    int
+	mu     sync.Mutex
+	exited bool
+	exit   chan *ExitStatus
+}
+
+func (h *reaperHandle) finish(s *ExitStatus) {
+	h.mu.Lock()
+	h.exited = true
+	h.mu.Unlock()
+	h.exit <- s
+}
+
+func (h *reaperHandle) Pid() int {
+	return h.pid
+}
+
+func (h *reaperHandle) Wait() (*ExitStatus, error) {
+	return <-h.exit, nil
+}
+
+//Signal the child. Fails once it has been reaped, so a recycled pid is
+//never signalled.
+func (h *reaperHandle) Signal(sig os.Signal) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.exited {
+		return os.ErrProcessDone
+	}
+	s, ok := sig.(syscall.Signal)
+	if !ok {
+		return syscall.EINVAL
+	}
+	return syscall.Kill(h.pid, s)
+}
+
+func (h *reaperHandle) Release() error {
+	return nil
+}
@@ -0,0 +1,40 @@
+// goforever - processes management
+// Copyright (c) 2013 Garrett Woodworth (https://github.com/gwoo).
+
+//go:build unix
+
+package process
+
+import (
+	"syscall"
+	"testing"
+)
+
+func TestReaperRunner(t *testing.T) {
+	r := NewReaperRunner()
+	defer r.Close()
+	exit, err := r.Start(&Cmd{Path: "/bin/sh", Args: []string{"sh", "-c", "exit 3"}})
+	if err != nil {
+		t.Fatalf("Error: %s.", err)
+	}
+	sleep, err := r.Start(&Cmd{Path: "/bin/sleep", Args: []string{"sleep", "10"}})
+	if err != nil {
+		t.Fatalf("Error: %s.", err)
+	}
+	if s, _ := exit.Wait(); s.Code != 3 {
+		t.Errorf("Expected exit status 3. Result %s\n", s)
+	}
+	sleep.Signal(syscall.SIGTERM)
+	if s, _ := sleep.Wait(); s.Signal != syscall.SIGTERM {
+		t.Errorf("Expected SIGTERM. Result %s\n", s)
+	}
+	if err := sleep.Signal(syscall.SIGTERM); err == nil {
+		t.Error("Expected signalling a reaped process to fail.")
+	}
+	if r.Waiting() != 0 {
+		t.Errorf("Expected no waiting children. Result %d\n", r.Waiting())
+	}
+	if _, err := r.Start(&Cmd{Path: "/nonexistent"}); err == nil {
+		t.Error("Expected exec error.")
+	}
+}
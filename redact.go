@@ -0,0 +1,82 @@
+// goforever - processes management
+// Copyright (c) 2013 Garrett Woodworth (https://github.com/gwoo).
+
+package process
+
+import (
+	"bytes"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+//Masking of secrets in piped output before it reaches any sink.
+type Redact struct {
+	//Regular expressions whose matches are masked.
+	Patterns []string `json:"patterns,omitempty"`
+	//Names of environment variables whose values are masked, with * and ?
+	//wildcards, e.g. "*_TOKEN". Values shorter than 4 bytes are ignored.
+	Env []string `json:"env,omitempty"`
+	//Defaults to [REDACTED].
+	Replacement string `json:"replacement,omitempty"`
+}
+
+//Shortest environment value masked.
+const minSecret = 4
+
+type redactor struct {
+	res    []*regexp.Regexp
+	values [][]byte
+	with   []byte
+}
+
+//Build the redactor for a start with the child's env. An invalid pattern
+//fails the start rather than letting secrets through.
+func (p *Process) redactor(env []string) (*redactor, error) {
+	if p.Output == nil || p.Output.Redact == nil {
+		return nil, nil
+	}
+	c := p.Output.Redact
+	r := &redactor{with: []byte(c.Replacement)}
+	if c.Replacement == "" {
+		r.with = []byte("[REDACTED]")
+	}
+	for _, pattern := range c.Patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, err
+		}
+		r.res = append(r.res, re)
+	}
+	for _, kv := range env {
+		i := strings.Index(kv, "=")
+		if i < 0 || len(kv)-i-1 < minSecret {
+			continue
+		}
+		for _, glob := range c.Env {
+			if ok, _ := path.Match(glob, kv[:i]); ok {
+				r.values = append(r.values, []byte(kv[i+1:]))
+				break
+			}
+		}
+	}
+	//Longest first so a secret containing another is masked whole.
+	sort.Slice(r.values, func(i, j int) bool {
+		return len(r.values[i]) > len(r.values[j])
+	})
+	return r, nil
+}
+
+func (r *redactor) redact(line []byte) []byte {
+	if r == nil {
+		return line
+	}
+	for _, v := range r.values {
+		line = bytes.Replace(line, v, r.with, -1)
+	}
+	for _, re := range r.res {
+		line = re.ReplaceAllLiteral(line, r.with)
+	}
+	return line
+}
@@ -0,0 +1,28 @@
+// goforever - processes management
+// Copyright (c) 2013 Garrett Woodworth (https://github.com/gwoo).
+
+package process
+
+import (
+	"testing"
+)
+
+func TestRedact(t *testing.T) {
+	p := &Process{Output: &Output{Redact: &Redact{
+		Patterns: []string{`password=\S+`},
+		Env:      []string{"*_TOKEN"},
+	}}}
+	r, err := p.redactor([]string{"API_TOKEN=s3cr3t-value", "SHORT_TOKEN=abc", "HOME=/root"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	line := "token s3cr3t-value password=hunter2 abc /root\n"
+	ex := "token [REDACTED] [REDACTED] abc /root\n"
+	if res := string(r.redact([]byte(line))); res != ex {
+		t.Errorf("Expected %#v. Result %#v\n", ex, res)
+	}
+	p.Output.Redact.Patterns = []string{"("}
+	if _, err := p.redactor(nil); err == nil {
+		t.Error("Expected invalid pattern error.")
+	}
+}
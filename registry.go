@@ -0,0 +1,209 @@
+// goforever - processes management
+// Copyright (c) 2013 Garrett Woodworth (https://github.com/gwoo).
+
+package process
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+//Service discovery registration of a process while it is healthy or
+//degraded, see Manager.WatchHealth. It is removed when the process stops or
+//turns unhealthy.
+type Registration struct {
+	//consul or etcd.
+	Type string `json:"type"`
+	//Agent or cluster URL, default http://127.0.0.1:8500 for Consul and
+	//http://127.0.0.1:2379 for etcd.
+	URL string `json:"url,omitempty"`
+	//Service name, default the instance group or process name. The process
+	//name is the service id.
+	Service string   `json:"service,omitempty"`
+	Address string   `json:"address,omitempty"`
+	Port    int      `json:"port,omitempty"`
+	Tags    []string `json:"tags,omitempty"`
+	//URL Consul polls as an HTTP check, and how often (default 10s).
+	Check         string `json:"check,omitempty"`
+	CheckInterval string `json:"check_interval,omitempty"`
+	//Key prefix for etcd, default /services/. Keys are prefix/service/id.
+	Prefix string `json:"prefix,omitempty"`
+}
+
+var registryClient = &http.Client{Timeout: 5 * time.Second}
+
+//Service entry stored in etcd.
+type serviceEntry struct {
+	ID      string   `json:"id"`
+	Service string   `json:"service"`
+	Address string   `json:"address,omitempty"`
+	Port    int      `json:"port,omitempty"`
+	Tags    []string `json:"tags,omitempty"`
+	Check   string   `json:"check,omitempty"`
+}
+
+//Service entry with ${var} references expanded and defaults applied.
+func (p *Process) serviceEntry() (*Registration, *serviceEntry, error) {
+	r := p.Register
+	e := &serviceEntry{ID: p.Name, Service: r.Service, Port: r.Port, Tags: r.Tags}
+	if e.Service == "" {
+		e.Service = p.Name
+		if p.group != "" {
+			e.Service = p.group
+		}
+	}
+	var err error
+	if e.Address, err = expandVars(r.Address, p.Vars); err != nil {
+		return nil, nil, err
+	}
+	if e.Check, err = expandVars(r.Check, p.Vars); err != nil {
+		return nil, nil, err
+	}
+	return r, e, nil
+}
+
+//Register the process unless it already is.
+func (p *Process) register() error {
+	p.mu.Lock()
+	listed := p.listed
+	p.mu.Unlock()
+	if p.Register == nil || listed {
+		return nil
+	}
+	r, e, err := p.serviceEntry()
+	if err != nil {
+		return err
+	}
+	switch r.Type {
+	case "consul":
+		body := map[string]interface{}{
+			"ID":      e.ID,
+			"Name":    e.Service,
+			"Address": e.Address,
+			"Port":    e.Port,
+			"Tags":    e.Tags,
+		}
+		if e.Check != "" {
+			body["Check"] = map[string]string{
+				"HTTP":     e.Check,
+				"Interval": durationOr(r.CheckInterval, 10*time.Second).String(),
+			}
+		}
+		err = registryCall("PUT", r.url()+"/v1/agent/service/register", body)
+	case "etcd":
+		value, _ := json.Marshal(e)
+		err = registryCall("POST", r.url()+"/v3/kv/put", map[string]string{
+			"key":   base64.StdEncoding.EncodeToString([]byte(r.key(e))),
+			"value": base64.StdEncoding.EncodeToString(value),
+		})
+	default:
+		err = fmt.Errorf("Unknown registry %q.", r.Type)
+	}
+	if err != nil {
+		return err
+	}
+	p.mu.Lock()
+	p.listed = true
+	p.mu.Unlock()
+	p.log(LevelInfo, "registered", Fields{"registry": r.Type, "service": e.Service})
+	return nil
+}
+
+//Remove the registration, if any.
+func (p *Process) deregister() error {
+	p.mu.Lock()
+	listed := p.listed
+	p.mu.Unlock()
+	if p.Register == nil || !listed {
+		return nil
+	}
+	r, e, err := p.serviceEntry()
+	if err != nil {
+		return err
+	}
+	switch r.Type {
+	case "consul":
+		err = registryCall("PUT", r.url()+"/v1/agent/service/deregister/"+url.PathEscape(e.ID), nil)
+	case "etcd":
+		err = registryCall("POST", r.url()+"/v3/kv/deleterange", map[string]string{
+			"key": base64.StdEncoding.EncodeToString([]byte(r.key(e))),
+		})
+	}
+	if err != nil {
+		return err
+	}
+	p.mu.Lock()
+	p.listed = false
+	p.mu.Unlock()
+	p.log(LevelInfo, "deregistered", Fields{"registry": r.Type, "service": e.Service})
+	return nil
+}
+
+//Register or deregister for a new health state, logging failures.
+func (p *Process) updateRegistration(state string) {
+	if p.Register == nil {
+		return
+	}
+	var err error
+	if state == HealthHealthy || state == HealthDegraded {
+		err = p.register()
+	} else {
+		err = p.deregister()
+	}
+	if err != nil {
+		p.log(LevelError, "registration failed", Fields{"error": err})
+	}
+}
+
+func (r *Registration) url() string {
+	if r.URL != "" {
+		return strings.TrimRight(r.URL, "/")
+	}
+	if r.Type == "etcd" {
+		return "http://127.0.0.1:2379"
+	}
+	return "http://127.0.0.1:8500"
+}
+
+func (r *Registration) key(e *serviceEntry) string {
+	prefix := r.Prefix
+	if prefix == "" {
+		prefix = "/services/"
+	}
+	return strings.TrimRight(prefix, "/") + "/" + e.Service + "/" + e.ID
+}
+
+func registryCall(method, u string, body interface{}) error {
+	return registryJSON(method, u, body, nil)
+}
+
+//Send body as JSON, decoding the answer into out unless it is nil.
+func registryJSON(method, u string, body, out interface{}) error {
+	var data []byte
+	if body != nil {
+		data, _ = json.Marshal(body)
+	}
+	req, err := http.NewRequest(method, u, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := registryClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("%s %s returned %s.", method, u, resp.Status)
+	}
+	if out != nil {
+		return json.NewDecoder(resp.Body).Decode(out)
+	}
+	return nil
+}
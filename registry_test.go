@@ -0,0 +1,74 @@
+// goforever - processes management
+// Copyright (c) 2013 Garrett Woodworth (https://github.com/gwoo).
+
+package process
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+type registryServer struct {
+	mu    sync.Mutex
+	calls []string
+	last  map[string]interface{}
+}
+
+func (s *registryServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.calls = append(s.calls, r.Method+" "+r.URL.Path)
+	data, _ := ioutil.ReadAll(r.Body)
+	s.last = nil
+	json.Unmarshal(data, &s.last)
+}
+
+func TestConsulRegistration(t *testing.T) {
+	s := &registryServer{}
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+	p := &Process{Name: "web-1", group: "web", Pid: 1, Vars: Vars{"port": "8080"}, Register: &Registration{
+		Type:  "consul",
+		URL:   ts.URL,
+		Port:  8080,
+		Check: "http://127.0.0.1:${port}/health",
+	}}
+	p.updateRegistration(HealthHealthy)
+	p.updateRegistration(HealthDegraded)
+	if s.last["Name"] != "web" || s.last["ID"] != "web-1" {
+		t.Errorf("Expected service web with id web-1. Result %#v\n", s.last)
+	}
+	if check, _ := s.last["Check"].(map[string]interface{}); check["HTTP"] != "http://127.0.0.1:8080/health" {
+		t.Errorf("Expected expanded check. Result %#v\n", s.last["Check"])
+	}
+	p.updateRegistration(HealthUnhealthy)
+	ex := []string{"PUT /v1/agent/service/register", "PUT /v1/agent/service/deregister/web-1"}
+	if len(s.calls) != len(ex) || s.calls[0] != ex[0] || s.calls[1] != ex[1] {
+		t.Errorf("Expected %#v. Result %#v\n", ex, s.calls)
+	}
+}
+
+func TestEtcdRegistration(t *testing.T) {
+	s := &registryServer{}
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+	p := &Process{Name: "api", Register: &Registration{Type: "etcd", URL: ts.URL, Port: 9000}}
+	if err := p.register(); err != nil {
+		t.Fatal(err)
+	}
+	key, _ := base64.StdEncoding.DecodeString(s.last["key"].(string))
+	if string(key) != "/services/api/api" {
+		t.Errorf("Expected %#v. Result %#v\n", "/services/api/api", string(key))
+	}
+	if err := p.deregister(); err != nil {
+		t.Fatal(err)
+	}
+	if ex := "POST /v3/kv/deleterange"; s.calls[len(s.calls)-1] != ex {
+		t.Errorf("Expected %#v. Result %#v\n", ex, s.calls)
+	}
+}
@@ -0,0 +1,121 @@
+// goforever - processes management
+// Copyright (c) 2013 Garrett Woodworth (https://github.com/gwoo).
+
+package process
+
+import (
+	"bufio"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"time"
+)
+
+//Exits kept for crash reports.
+const maxExits = 10
+
+//Environment variables masked in crash reports besides Redact.Env.
+var secretEnv = []string{"*TOKEN*", "*SECRET*", "*PASSWORD*", "*PASSWD*", "*KEY*", "*CREDENTIAL*"}
+
+//What a process left behind when it reached its respawn limit, written to
+//Crash.Dir for triage.
+type CrashReport struct {
+	Process string      `json:"process"`
+	Time    time.Time   `json:"time"`
+	Reason  string      `json:"reason"`
+	Info    ProcessInfo `json:"info"`
+	//Latest exits, oldest first.
+	Exits []Exit `json:"exits"`
+	//The process's own environment, secrets masked.
+	Env    []string `json:"env,omitempty"`
+	Stdout []string `json:"stdout,omitempty"`
+	Stderr []string `json:"stderr,omitempty"`
+}
+
+//Write a crash report to the crash directory. Its path, empty on failure.
+func (p *Process) crashReport(reason string) string {
+	lines := p.Crash.ReportLines
+	if lines <= 0 {
+		lines = 100
+	}
+	r := &CrashReport{Process: p.Name, Time: p.clock().Now(), Reason: reason, Info: p.Snapshot()}
+	p.mu.Lock()
+	r.Exits = append([]Exit(nil), p.exits...)
+	p.mu.Unlock()
+	if env, err := p.environ(); err == nil {
+		r.Env = p.maskEnv(env)
+	}
+	r.Stdout = p.Tail("stdout", lines)
+	if r.Stdout == nil {
+		r.Stdout = tailFile(p.Logfile, lines)
+	}
+	r.Stderr = p.Tail("stderr", lines)
+	if r.Stderr == nil && p.Errfile != p.Logfile {
+		r.Stderr = tailFile(p.Errfile, lines)
+	}
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err == nil {
+		err = os.MkdirAll(p.Crash.Dir, 0750)
+	}
+	pid := 0
+	if len(r.Exits) > 0 {
+		pid = r.Exits[len(r.Exits)-1].Pid
+	}
+	dest := p.dumpPath(pid, "report.json")
+	if err == nil {
+		err = ioutil.WriteFile(dest, data, 0640)
+	}
+	if err != nil {
+		p.log(LevelError, "crash report failed", Fields{"error": err})
+		return ""
+	}
+	p.retainDumps()
+	return dest
+}
+
+//Env with the values of secret variables, and of Redact patterns, masked.
+func (p *Process) maskEnv(env []string) []string {
+	globs := secretEnv
+	if p.Output != nil && p.Output.Redact != nil {
+		globs = append(append([]string(nil), secretEnv...), p.Output.Redact.Env...)
+	}
+	red, _ := p.redactor(nil)
+	var masked []string
+	for _, kv := range env {
+		name := kv
+		if i := strings.Index(kv, "="); i >= 0 {
+			name = kv[:i]
+		}
+		for _, glob := range globs {
+			if ok, _ := path.Match(glob, strings.ToUpper(name)); ok {
+				kv = name + "=[REDACTED]"
+				break
+			}
+		}
+		masked = append(masked, string(red.redact([]byte(kv))))
+	}
+	return masked
+}
+
+//Last n lines of the file at path, nil if it cannot be read.
+func tailFile(path string, n int) []string {
+	if path == "" {
+		return nil
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+	var lines []string
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		lines = append(lines, s.Text())
+		if len(lines) > n {
+			lines = lines[1:]
+		}
+	}
+	return lines
+}
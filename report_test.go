@@ -0,0 +1,71 @@
+// goforever - processes management
+// Copyright (c) 2013 Garrett Woodworth (https://github.com/gwoo).
+
+package process
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestCrashReport(t *testing.T) {
+	dir, err := ioutil.TempDir("", "process")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	m := NewManager()
+	fatal := make(chan string, 1)
+	m.OnEvent(func(e Event) {
+		if e.Type == EventFatal {
+			fatal <- e.Reason
+		}
+	})
+	runner := NewFakeRunner()
+	p := &Process{
+		Command: "web",
+		Runner:  runner,
+		Respawn: 1,
+		Ping:    "1h",
+		Env:     []string{"API_TOKEN=hunter22", "MODE=prod", "DSN=postgres://app:pa55word@db"},
+		Output:  &Output{Redact: &Redact{Patterns: []string{`:[^:@]+@`}, Replacement: ":***@"}},
+		Crash:   &Crash{Dir: dir},
+	}
+	m.Add("web", p)
+	RunProcess("web", p)
+	for i := 0; i < 2; i++ {
+		waitFor(t, func() bool { return len(runner.Running()) == 1 })
+		runner.Running()[0].Exit(3)
+		waitFor(t, func() bool { return len(runner.Running()) == 1 || p.Snapshot().Status == "fatal" })
+	}
+	waitFor(t, func() bool { return p.Snapshot().Status == "fatal" })
+	i := strings.Index(p.Snapshot().Reason, "crash report ")
+	if i < 0 {
+		t.Fatalf("Expected a crash report. Result %#v\n", p.Snapshot().Reason)
+	}
+	path := p.Snapshot().Reason[i+len("crash report "):]
+	if reason := <-fatal; !strings.HasSuffix(reason, path) {
+		t.Errorf("Expected the fatal event to name %s. Result %#v\n", path, reason)
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := &CrashReport{}
+	if err := json.Unmarshal(data, r); err != nil {
+		t.Fatal(err)
+	}
+	if len(r.Exits) != 2 || r.Exits[0].Code != 3 || r.Exits[1].Pid != FakePid+1 {
+		t.Errorf("Expected 2 exits with code 3. Result %#v\n", r.Exits)
+	}
+	expected := []string{"API_TOKEN=[REDACTED]", "MODE=prod", "DSN=postgres://app:***@db"}
+	if strings.Join(r.Env, " ") != strings.Join(expected, " ") {
+		t.Errorf("Expected %#v. Result %#v\n", expected, r.Env)
+	}
+	if r.Process != "web" || r.Info.Status != "fatal" && r.Info.Status != "exited" {
+		t.Errorf("Unexpected report %#v\n", r)
+	}
+}
@@ -0,0 +1,138 @@
+// goforever - processes management
+// Copyright (c) 2013 Garrett Woodworth (https://github.com/gwoo).
+
+package process
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+//Revisions kept per process for Rollback.
+var maxRevisions = 10
+
+//Definition of a process as applied, see Manager.Apply. Revision 1 is the
+//one it was added with.
+type Revision struct {
+	Number     int       `json:"number"`
+	Time       time.Time `json:"time"`
+	Definition *Process  `json:"definition"`
+}
+
+//Revisions of the process, oldest first, with the earliest ones dropped
+//beyond the last 10.
+func (m *Manager) Revisions(name string) ([]Revision, error) {
+	p, err := m.Lookup(name)
+	if err != nil {
+		return nil, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]Revision(nil), m.history(name, p)...), nil
+}
+
+//Revisions of p, starting with its definition as added. Called with m.mu
+//held.
+func (m *Manager) history(name string, p *Process) []Revision {
+	if m.revs == nil {
+		m.revs = map[string][]Revision{}
+	}
+	if len(m.revs[name]) == 0 {
+		m.revs[name] = []Revision{{Number: 1, Time: m.started, Definition: p.clone()}}
+	}
+	return m.revs[name]
+}
+
+//Replace the definition of a process with def, as a new revision, and
+//restart it with def if it was running. Queued as an operation after those
+//already in flight, which run on def once it is applied. A nil Logger,
+//Clock or Runner in def is taken from the current definition. Returns the
+//revision number.
+func (m *Manager) Apply(name string, def *Process) (int, error) {
+	if _, err := m.Lookup(name); err != nil {
+		return 0, err
+	}
+	if def.Command == "" && def.Shell == "" {
+		return 0, errors.New("Command is empty.")
+	}
+	if err := def.validate(); err != nil {
+		return 0, err
+	}
+	var number int
+	op, err := m.do(name, "apply", "", 0, func(old *Process, op *Operation) error {
+		running := old.CurrentPid() > 0
+		if running {
+			old.stop(op)
+		}
+		number = m.replace(name, old, def)
+		def.log(LevelInfo, "applied", Fields{"revision": number})
+		def.emit(EventApply, fmt.Sprintf("revision %d", number))
+		if running {
+			op.report("starting")
+			if _, err := RunProcess(name, def); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	err = op.Wait()
+	return number, err
+}
+
+//Put def in place of old as a new revision, carrying over its history,
+//paused state, rate limit and queued operations. Returns the revision number.
+func (m *Manager) replace(name string, old, def *Process) int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	revs := m.history(name, old)
+	rev := Revision{Number: revs[len(revs)-1].Number + 1, Time: m.clock().Now(), Definition: def.clone()}
+	if revs = append(revs, rev); len(revs) > maxRevisions {
+		revs = revs[len(revs)-maxRevisions:]
+	}
+	m.revs[name] = revs
+	old.mu.Lock()
+	def.exits = append([]Exit(nil), old.exits...)
+	def.lastExit = old.lastExit
+	def.group = old.group
+	def.paused = old.paused
+	def.op = old.op
+	def.actions = append([]time.Time(nil), old.actions...)
+	def.limited = old.limitedCounts()
+	old.mu.Unlock()
+	if def.Logger == nil {
+		def.Logger = old.Logger
+	}
+	if def.Clock == nil {
+		def.Clock = old.Clock
+	}
+	if def.Runner == nil {
+		def.Runner = old.Runner
+	}
+	def.Name = name
+	def.manager = m
+	if def.paused {
+		def.Status = "paused"
+	}
+	m.procs[name] = def
+	m.defaultPidfile(name, def)
+	return rev.Number
+}
+
+//Apply the definition of an earlier revision again, restarting the
+//process. Returns the new revision number.
+func (m *Manager) Rollback(name string, number int) (int, error) {
+	revs, err := m.Revisions(name)
+	if err != nil {
+		return 0, err
+	}
+	for _, rev := range revs {
+		if rev.Number == number {
+			return m.Apply(name, rev.Definition.clone())
+		}
+	}
+	return 0, fmt.Errorf("Unknown revision %d of %q.", number, name)
+}
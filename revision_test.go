@@ -0,0 +1,125 @@
+// goforever - processes management
+// Copyright (c) 2013 Garrett Woodworth (https://github.com/gwoo).
+
+package process
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRollback(t *testing.T) {
+	r := NewFakeRunner()
+	m := NewManager()
+	m.Add("web", &Process{Command: "web", Args: []string{"-v1"}, Runner: r, Ping: "1h"})
+	RunProcess("web", m.Get("web"))
+	rev, err := m.Apply("web", &Process{Command: "web", Args: []string{"-v2"}, Ping: "1h"})
+	if err != nil || rev != 2 {
+		t.Fatalf("Expected revision 2. Result %d %v\n", rev, err)
+	}
+	running := r.Running()
+	if len(running) != 1 || running[0].Cmd.Args[1] != "-v2" {
+		t.Fatalf("Expected -v2 running. Result %#v\n", running)
+	}
+	if m.Get("web").Runner != r {
+		t.Errorf("Expected the runner kept.\n")
+	}
+	if _, err := m.Rollback("web", 7); err == nil {
+		t.Errorf("Expected an unknown revision.\n")
+	}
+	if rev, err = m.Rollback("web", 1); err != nil || rev != 3 {
+		t.Fatalf("Expected revision 3. Result %d %v\n", rev, err)
+	}
+	if running = r.Running(); len(running) != 1 || running[0].Cmd.Args[1] != "-v1" {
+		t.Errorf("Expected -v1 running again. Result %#v\n", running)
+	}
+	revs, _ := m.Revisions("web")
+	if len(revs) != 3 || revs[2].Definition.Args[0] != "-v1" {
+		t.Errorf("Expected 3 revisions. Result %#v\n", revs)
+	}
+	if _, err := m.Apply("db", &Process{Command: "db"}); err != ErrNotFound {
+		t.Errorf("Expected %#v. Result %#v\n", ErrNotFound, err)
+	}
+	m.Get("web").Stop()
+}
+
+func TestRevisionAPI(t *testing.T) {
+	r := NewFakeRunner()
+	m := NewManager()
+	m.Add("web", &Process{Command: "web", Runner: r, Ping: "1h"})
+	w := httptest.NewRecorder()
+	m.API(nil).ServeHTTP(w, httptest.NewRequest("POST", "/processes/web/apply", strings.NewReader(`{"command": "web", "args": ["-v2"]}`)))
+	if w.Code != 200 || !strings.Contains(w.Body.String(), `"revision":2`) {
+		t.Errorf("Expected revision 2. Result %d %s\n", w.Code, w.Body.String())
+	}
+	w = httptest.NewRecorder()
+	m.API(nil).ServeHTTP(w, httptest.NewRequest("GET", "/processes/web/revisions", nil))
+	var revs []Revision
+	if err := json.Unmarshal(w.Body.Bytes(), &revs); err != nil || len(revs) != 2 {
+		t.Errorf("Expected 2 revisions. Result %s\n", w.Body.String())
+	}
+	w = httptest.NewRecorder()
+	m.API(nil).ServeHTTP(w, httptest.NewRequest("POST", "/processes/web/rollback?revision=1", nil))
+	if w.Code != 200 || !strings.Contains(w.Body.String(), `"revision":3`) {
+		t.Errorf("Expected revision 3. Result %d %s\n", w.Code, w.Body.String())
+	}
+	if args := m.Get("web").Args; len(args) != 0 {
+		t.Errorf("Expected the first definition. Result %#v\n", args)
+	}
+}
+
+func TestApplyQueued(t *testing.T) {
+	r := NewFakeRunner()
+	clock := NewFakeClock(time.Now())
+	m := NewManager()
+	m.Add("web", &Process{Command: "web", Args: []string{"-v1"}, Runner: r, Clock: clock, Ping: "1h", RestartDebounce: "10s"})
+	RunProcess("web", m.Get("web"))
+	defer func() { m.Get("web").Stop() }()
+	if _, err := m.Apply("web", &Process{}); err == nil {
+		t.Errorf("Expected a definition without a command refused.\n")
+	}
+	var mu sync.Mutex
+	var types []string
+	m.OnEvent(func(e Event) {
+		mu.Lock()
+		types = append(types, e.Type)
+		mu.Unlock()
+	})
+	m.Restart("web")
+	clock.BlockUntil(2)
+	applied := make(chan error, 1)
+	go func() {
+		_, err := m.Apply("web", &Process{Command: "web", Args: []string{"-v2"}, Ping: "1h"})
+		applied <- err
+	}()
+	waitFor(t, func() bool { return len(m.Operations()) == 2 })
+	op, _ := m.Restart("web")
+	clock.Advance(10 * time.Second)
+	if err := <-applied; err != nil {
+		t.Fatalf("Error: %s.", err)
+	}
+	//The second restart is debounced after the apply.
+	waitFor(t, func() bool {
+		clock.Advance(10 * time.Second)
+		return op.State() == OpDone
+	})
+	running := r.Running()
+	if len(running) != 1 || running[0].Cmd.Args[1] != "-v2" {
+		t.Errorf("Expected only -v2 running. Result %#v\n", running)
+	}
+	mu.Lock()
+	if ex := []string{"restart", "apply", "restart"}; !reflect.DeepEqual(ex, types) {
+		t.Errorf("Expected %#v. Result %#v\n", ex, types)
+	}
+	mu.Unlock()
+	m.Pause("web")
+	m.Apply("web", &Process{Command: "web", Args: []string{"-v3"}, Ping: "1h"})
+	if !m.Get("web").Paused() || len(r.Running()) != 0 {
+		t.Errorf("Expected the process to stay paused.\n")
+	}
+}
@@ -0,0 +1,76 @@
+// goforever - processes management
+// Copyright (c) 2013 Garrett Woodworth (https://github.com/gwoo).
+
+package process
+
+import (
+	"fmt"
+	"time"
+)
+
+//Restart the instances of name at most maxUnavailable at a time, waiting for
+//each batch to become healthy and then sleeping pause before the next one.
+//Stops at the first instance that fails to become healthy.
+func (m *Manager) RollingRestart(name string, maxUnavailable int, pause time.Duration) error {
+	return m.rollingRestart(name, maxUnavailable, pause, "rolling restart")
+}
+
+//RollingRestart recording reason in the restart events.
+func (m *Manager) rollingRestart(name string, maxUnavailable int, pause time.Duration, reason string) error {
+	procs := m.Instances(name)
+	if len(procs) == 0 {
+		return fmt.Errorf("No instances of %s.", name)
+	}
+	if maxUnavailable < 1 {
+		maxUnavailable = 1
+	}
+	for i := 0; i < len(procs); i += maxUnavailable {
+		if i > 0 && pause > 0 {
+			m.clock().Sleep(pause)
+		}
+		end := i + maxUnavailable
+		if end > len(procs) {
+			end = len(procs)
+		}
+		batch := procs[i:end]
+		for _, p := range batch {
+			ch, _ := p.Restart()
+			<-ch
+			p.emit(EventRestart, reason)
+		}
+		for _, p := range batch {
+			ctx, cancel := p.startContext()
+			err := p.waitHealthy(ctx)
+			cancel()
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+//Restart the running processes changed reports, one after the other and
+//each once the previous one has started, recording reason in the restart
+//events. Instance groups get a rolling restart, one instance at a time.
+//Used by the watchers so that a change never takes everything down at once.
+func (m *Manager) restartChanged(changed func(p *Process) bool, reason string) {
+	rolled := map[string]bool{}
+	for _, name := range m.Keys() {
+		p := m.Get(name)
+		if p == nil || rolled[p.group] || !changed(p) {
+			continue
+		}
+		p.log(LevelInfo, "restarting", Fields{"reason": reason})
+		if p.group != "" {
+			rolled[p.group] = true
+			if err := m.rollingRestart(p.group, 1, 0, reason); err != nil {
+				p.log(LevelError, "rolling restart failed", Fields{"error": err})
+			}
+			continue
+		}
+		ch, _ := p.Restart()
+		<-ch
+		p.emit(EventRestart, reason)
+	}
+}
@@ -0,0 +1,55 @@
+// goforever - processes management
+// Copyright (c) 2013 Garrett Woodworth (https://github.com/gwoo).
+
+package process
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRollingRestart(t *testing.T) {
+	dir, err := ioutil.TempDir("", "process")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	c := &Config{Processes: map[string]*Process{
+		"sleep": {
+			Command:   "/bin/sleep",
+			Args:      []string{"10"},
+			Pidfile:   Pidfile(filepath.Join(dir, "{name}.pid")),
+			Instances: 2,
+			Health:    []Probe{{TCP: ln.Addr().String()}},
+		},
+	}}
+	m, err := c.Manager()
+	if err != nil {
+		t.Fatalf("Error: %s.", err)
+	}
+	procs := m.Instances("sleep")
+	if len(procs) != 2 || procs[1].Name != "sleep-2" || procs[1].Pidfile != Pidfile(filepath.Join(dir, "sleep-2.pid")) {
+		t.Fatalf("Unexpected instances %v\n", procs)
+	}
+	defer func() {
+		for _, p := range procs {
+			p.Stop()
+		}
+	}()
+	if err := m.RollingRestart("sleep", 1, 0); err != nil {
+		t.Fatalf("Error: %s.", err)
+	}
+	for _, p := range procs {
+		if p.Snapshot().Pid == 0 {
+			t.Errorf("Expected %s running.\n", p.Name)
+		}
+	}
+}
@@ -0,0 +1,198 @@
+// goforever - processes management
+// Copyright (c) 2013 Garrett Woodworth (https://github.com/gwoo).
+
+package process
+
+import (
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+//Returned by Process.Archive for a name that is not an archive.
+var ErrNoArchive = errors.New("No such archive.")
+
+//Layout of the suffix added to rotated files, sorting by time.
+const archiveLayout = "20060102-150405.000000000"
+
+//Rotation of piped output files.
+type Rotate struct {
+	//Rotate once a file reaches this many bytes.
+	MaxSize int64 `json:"max_size"`
+	//Compress rotated files: "gzip", or empty for none.
+	Compress string `json:"compress,omitempty"`
+	//Delete the oldest archives of a file once together they exceed this
+	//many bytes.
+	MaxTotal int64 `json:"max_total,omitempty"`
+	//Keep at most this many archives of a file.
+	MaxFiles int `json:"max_files,omitempty"`
+}
+
+//A rotated log file.
+type Archive struct {
+	Name string    `json:"name"`
+	Log  string    `json:"log"`
+	Size int64     `json:"size"`
+	Time time.Time `json:"time"`
+	path string
+}
+
+func (r *Rotate) check() error {
+	if r == nil {
+		return nil
+	}
+	switch r.Compress {
+	case "", "gzip":
+		return nil
+	}
+	return fmt.Errorf("Unknown compression %q.", r.Compress)
+}
+
+//Move the file aside, compress it and apply retention, then start a new one.
+func (f *logFile) rotate() error {
+	f.f.Close()
+	f.f = nil
+	now := f.p.clock().Now().UTC()
+	archive := f.path + "." + now.Format(archiveLayout)
+	for exists(archive) || exists(archive+".gz") {
+		now = now.Add(time.Nanosecond)
+		archive = f.path + "." + now.Format(archiveLayout)
+	}
+	if err := os.Rename(f.path, archive); err != nil {
+		return err
+	}
+	if f.r != nil && f.r.Compress == "gzip" {
+		if err := gzipFile(archive); err != nil {
+			f.p.log(LevelError, "compress failed", Fields{"file": archive, "error": err})
+		}
+	}
+	f.retain()
+	return f.open()
+}
+
+//Delete the oldest archives beyond MaxFiles or MaxTotal.
+func (f *logFile) retain() {
+	if f.r == nil {
+		return
+	}
+	archives := archives(f.path)
+	var total int64
+	for i := len(archives) - 1; i >= 0; i-- {
+		a := archives[i]
+		total += a.Size
+		keep := len(archives) - i
+		if (f.r.MaxFiles > 0 && keep > f.r.MaxFiles) || (f.r.MaxTotal > 0 && total > f.r.MaxTotal) {
+			if err := os.Remove(a.path); err != nil {
+				f.p.log(LevelError, "remove archive failed", Fields{"file": a.path, "error": err})
+			}
+		}
+	}
+}
+
+func exists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+func gzipFile(path string) error {
+	in, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(path+".gz", os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0660)
+	if err != nil {
+		return err
+	}
+	zw := gzip.NewWriter(out)
+	if _, err = io.Copy(zw, in); err == nil {
+		err = zw.Close()
+	}
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(path + ".gz")
+		return err
+	}
+	return os.Remove(path)
+}
+
+//Archives of the log at path, oldest first.
+func archives(path string) []Archive {
+	matches, _ := filepath.Glob(path + ".*")
+	var list []Archive
+	for _, m := range matches {
+		suffix := strings.TrimSuffix(strings.TrimPrefix(m, path+"."), ".gz")
+		t, err := time.Parse(archiveLayout, suffix)
+		if err != nil {
+			continue
+		}
+		fi, err := os.Stat(m)
+		if err != nil {
+			continue
+		}
+		list = append(list, Archive{
+			Name: filepath.Base(m),
+			Log:  path,
+			Size: fi.Size(),
+			Time: t,
+			path: m,
+		})
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Time.Before(list[j].Time)
+	})
+	return list
+}
+
+//Files written by the process's output, Logfile and Errfile included.
+func (p *Process) logPaths() []string {
+	var paths []string
+	seen := map[string]bool{}
+	add := func(path string) {
+		if path != "" && !seen[path] {
+			seen[path] = true
+			paths = append(paths, path)
+		}
+	}
+	add(p.Logfile)
+	add(p.Errfile)
+	if p.Output != nil {
+		for _, s := range p.Output.Sinks {
+			if s.Type == "file" {
+				add(s.Path)
+			}
+		}
+	}
+	return paths
+}
+
+//Rotated log files of the process, oldest first.
+func (p *Process) Archives() []Archive {
+	var list []Archive
+	for _, path := range p.logPaths() {
+		list = append(list, archives(path)...)
+	}
+	sort.SliceStable(list, func(i, j int) bool {
+		return list[i].Time.Before(list[j].Time)
+	})
+	return list
+}
+
+//Open an archive by name, as listed by Archives. Compressed archives are
+//returned as stored.
+func (p *Process) Archive(name string) (io.ReadCloser, error) {
+	for _, a := range p.Archives() {
+		if a.Name == name {
+			return os.Open(a.path)
+		}
+	}
+	return nil, ErrNoArchive
+}
@@ -0,0 +1,91 @@
+// goforever - processes management
+// Copyright (c) 2013 Garrett Woodworth (https://github.com/gwoo).
+
+package process
+
+import (
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRotate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "process")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	p := &Process{
+		Name:    "web",
+		Logfile: filepath.Join(dir, "out.log"),
+		Output:  &Output{Rotate: &Rotate{MaxSize: 4, Compress: "gzip", MaxFiles: 2}},
+		Clock:   NewFakeClock(time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)),
+	}
+	w, err := p.openLogFile(p.Logfile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{"aaa\n", "bbb\n", "ccc\n", "ddd\n"} {
+		w.Write([]byte(line))
+	}
+	w.Close()
+	if r, _ := ioutil.ReadFile(p.Logfile); string(r) != "ddd\n" {
+		t.Errorf("Expected %#v. Result %#v\n", "ddd\n", string(r))
+	}
+	list := p.Archives()
+	if len(list) != 2 {
+		t.Fatalf("Expected 2 archives. Result %#v\n", list)
+	}
+	f, err := p.Archive(list[1].Name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r, _ := ioutil.ReadAll(zr); string(r) != "ccc\n" {
+		t.Errorf("Expected %#v. Result %#v\n", "ccc\n", string(r))
+	}
+	if _, err := p.Archive("../out.log"); err != ErrNoArchive {
+		t.Errorf("Expected %#v. Result %#v\n", ErrNoArchive, err)
+	}
+}
+
+func TestRotateUnknownCompression(t *testing.T) {
+	p := &Process{Output: &Output{Rotate: &Rotate{Compress: "zstd"}}}
+	if _, err := p.openLogFile("out.log"); err == nil {
+		t.Error("Expected unknown compression error.")
+	}
+}
+
+func TestRotateSharedFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "out.log")
+	p := &Process{
+		Name:    "web",
+		Logfile: path,
+		Errfile: path,
+		Output:  &Output{Rotate: &Rotate{MaxSize: 4, MaxFiles: 5}},
+		Clock:   NewFakeClock(time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)),
+	}
+	out, _ := p.openLogFile(p.Logfile)
+	errs, _ := p.openLogFile(p.Errfile)
+	if out != errs {
+		t.Fatalf("Expected one log file for both streams.\n")
+	}
+	out.Write([]byte("aaa\n"))
+	errs.Write([]byte("bbb\n"))
+	out.Close()
+	errs.Write([]byte("ccc\n"))
+	errs.Close()
+	if r, _ := ioutil.ReadFile(path); string(r) != "ccc\n" {
+		t.Errorf("Expected %#v. Result %#v\n", "ccc\n", string(r))
+	}
+	if n := len(p.Archives()); n != 2 {
+		t.Errorf("Expected 2 archives. Result %d\n", n)
+	}
+}
@@ -0,0 +1,215 @@
+// goforever - processes management
+// Copyright (c) 2013 Garrett Woodworth (https://github.com/gwoo).
+
+package process
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"syscall"
+)
+
+//Starts processes for supervision, on the host by default (ExecRunner).
+//Start returns once the process runs, or failed to; the supervisor then
+//waits on, signals and releases it through the Handle only, so a backend
+//may run it anywhere it can report its exit. More backends are registered
+//with RegisterRunner and picked with Process.RunnerName.
+type Runner interface {
+	Start(cmd *Cmd) (Handle, error)
+}
+
+//Create the runner of a process from its configuration.
+type RunnerFactory func(p *Process) (Runner, error)
+
+var (
+	runnersMu sync.Mutex
+	runners   = map[string]RunnerFactory{
+		"exec": func(p *Process) (Runner, error) { return ExecRunner, nil },
+		"container": func(p *Process) (Runner, error) {
+			if p.Container == nil {
+				return nil, errors.New("The container runner needs a Container.")
+			}
+			return p.Container.runner(p.Name), nil
+		},
+		"ssh": func(p *Process) (Runner, error) {
+			if p.SSH == nil || p.SSH.Host == "" {
+				return nil, errors.New("The ssh runner needs an SSH host.")
+			}
+			return p.SSH, nil
+		},
+	}
+)
+
+//Make a runner backend available to processes as name. Registering a name
+//again replaces the backend.
+func RegisterRunner(name string, f RunnerFactory) {
+	runnersMu.Lock()
+	defer runnersMu.Unlock()
+	runners[name] = f
+}
+
+//Names of the registered runner backends, sorted.
+func Runners() []string {
+	runnersMu.Lock()
+	defer runnersMu.Unlock()
+	names := make([]string, 0, len(runners))
+	for name := range runners {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+//Runner of the registered backend name for p.
+func newRunner(name string, p *Process) (Runner, error) {
+	runnersMu.Lock()
+	f := runners[name]
+	runnersMu.Unlock()
+	if f == nil {
+		return nil, fmt.Errorf("Unknown runner %q.", name)
+	}
+	return f(p)
+}
+
+//Runner failing every start, for a backend that could not be created.
+type errRunner struct {
+	err error
+}
+
+func (r errRunner) Start(cmd *Cmd) (Handle, error) {
+	return nil, r.err
+}
+
+//Entries of env missing from or differing from the supervisor's own
+//environment, for runners starting processes elsewhere.
+func ownEnv(env []string) []string {
+	host := map[string]bool{}
+	for _, kv := range os.Environ() {
+		host[kv] = true
+	}
+	var own []string
+	for _, kv := range env {
+		if !host[kv] {
+			own = append(own, kv)
+		}
+	}
+	return own
+}
+
+//What to start. Args includes argv[0]. Files are the child's stdin, stdout
+//and stderr, then any descriptors it inherits from 3 up; nil entries are
+//closed in the child. Runners must start the child from the calling
+//goroutine for it to get the namespace of Process.NetNS.
+type Cmd struct {
+	Path  string
+	Args  []string
+	Env   []string
+	Dir   string
+	Files []*os.File
+}
+
+//A started process.
+type Handle interface {
+	Pid() int
+	//Block until the process exits. Only one caller may wait.
+	Wait() (*ExitStatus, error)
+	Signal(sig os.Signal) error
+	Release() error
+}
+
+//How a process ended. Signal is 0 unless it was killed by one.
+type ExitStatus struct {
+	Code   int
+	Signal syscall.Signal
+}
+
+func (s *ExitStatus) Success() bool {
+	return s.Code == 0 && s.Signal == 0
+}
+
+//Whether the process exited by itself rather than by a signal.
+func (s *ExitStatus) Exited() bool {
+	return s.Signal == 0
+}
+
+func (s *ExitStatus) String() string {
+	if s.Signal != 0 {
+		return "signal: " + s.Signal.String()
+	}
+	return fmt.Sprintf("exit status %d", s.Code)
+}
+
+//Runner using os.StartProcess.
+var ExecRunner Runner = execRunner{}
+
+type execRunner struct{}
+
+func (execRunner) Start(cmd *Cmd) (Handle, error) {
+	x, err := os.StartProcess(cmd.Path, cmd.Args, &os.ProcAttr{
+		Dir:   cmd.Dir,
+		Env:   cmd.Env,
+		Files: cmd.Files,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &execHandle{x}, nil
+}
+
+//Handle for an os.Process, started by ExecRunner or found by pid.
+type execHandle struct {
+	x *os.Process
+}
+
+func (h *execHandle) Pid() int {
+	return h.x.Pid
+}
+
+func (h *execHandle) Wait() (*ExitStatus, error) {
+	state, err := h.x.Wait()
+	if err != nil {
+		return nil, err
+	}
+	return exitStatus(state), nil
+}
+
+func (h *execHandle) Signal(sig os.Signal) error {
+	return h.x.Signal(sig)
+}
+
+func (h *execHandle) Release() error {
+	return h.x.Release()
+}
+
+func exitStatus(state *os.ProcessState) *ExitStatus {
+	s := &ExitStatus{Code: state.ExitCode()}
+	if ws, ok := state.Sys().(syscall.WaitStatus); ok && ws.Signaled() {
+		s.Signal = ws.Signal()
+	}
+	return s
+}
+
+//Process Runner, then its RunnerName backend, then its Container, then the
+//manager's, then ExecRunner.
+func (p *Process) runner() Runner {
+	if p.Runner != nil {
+		return p.Runner
+	}
+	if p.RunnerName != "" {
+		r, err := newRunner(p.RunnerName, p)
+		if err != nil {
+			return errRunner{err}
+		}
+		return r
+	}
+	if p.Container != nil {
+		return p.Container.runner(p.Name)
+	}
+	if p.manager != nil && p.manager.Runner != nil {
+		return p.manager.Runner
+	}
+	return ExecRunner
+}
@@ -0,0 +1,39 @@
+// goforever - processes management
+// Copyright (c) 2013 Garrett Woodworth (https://github.com/gwoo).
+
+package process
+
+import (
+	"testing"
+)
+
+func TestRegisterRunner(t *testing.T) {
+	r := NewFakeRunner()
+	RegisterRunner("fake", func(p *Process) (Runner, error) { return r, nil })
+	defer func() {
+		runnersMu.Lock()
+		delete(runners, "fake")
+		runnersMu.Unlock()
+	}()
+	found := false
+	for _, name := range Runners() {
+		found = found || name == "fake"
+	}
+	if !found {
+		t.Errorf("Expected fake in %#v\n", Runners())
+	}
+	p := &Process{Command: "/usr/bin/web", Ping: "1h", RunnerName: "fake"}
+	if _, err := RunProcess("web", p); err != nil || len(r.Running()) != 1 {
+		t.Errorf("Expected web started by the fake runner. Result %v\n", err)
+	}
+	p.Stop()
+	for _, name := range []string{"nope", "ssh", "container"} {
+		p := &Process{Command: "/usr/bin/web", RunnerName: name}
+		if _, err := RunProcess("web", p); err == nil {
+			t.Errorf("%s: expected the start to fail.\n", name)
+		}
+		if err := p.validate(); err == nil {
+			t.Errorf("%s: expected the validation to fail.\n", name)
+		}
+	}
+}
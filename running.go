@@ -0,0 +1,66 @@
+// goforever - processes management
+// Copyright (c) 2013 Garrett Woodworth (https://github.com/gwoo).
+
+package process
+
+import (
+	"errors"
+	"fmt"
+	"os"
+)
+
+var ErrAlreadyRunning = errors.New("Process is already running.")
+
+//What starting a process that is already running does, see
+//Process.AlreadyRunning.
+const (
+	RunningError = "error"
+	RunningAdopt = "adopt"
+)
+
+func (p *Process) checkRunning() error {
+	switch p.AlreadyRunning {
+	case "", RunningError, RunningAdopt:
+		return nil
+	}
+	return fmt.Errorf("Unknown already_running policy %q.", p.AlreadyRunning)
+}
+
+//Guard a start against a second copy. The process is running if its child
+//has not exited or the pid in its pidfile is alive. That is an error unless
+//AlreadyRunning is adopt, in which case the pidfile pid is adopted and true
+//is returned.
+func (p *Process) alreadyRunning() (bool, error) {
+	if err := p.checkRunning(); err != nil {
+		return false, err
+	}
+	adopt := p.AlreadyRunning == RunningAdopt
+	p.mu.Lock()
+	x, exited, pid := p.x, p.exited, p.Pid
+	p.mu.Unlock()
+	if x != nil && exited != nil && pid > 0 {
+		select {
+		case <-exited:
+		default:
+			if adopt {
+				return true, nil
+			}
+			return false, ErrAlreadyRunning
+		}
+	}
+	if p.Pidfile == "" {
+		return false, nil
+	}
+	pid = p.Pidfile.read()
+	if pid <= 0 || pid == os.Getpid() || !alive(pid) {
+		return false, nil
+	}
+	if !adopt {
+		return false, fmt.Errorf("%s: pid %d from %s.", ErrAlreadyRunning, pid, p.Pidfile)
+	}
+	if _, _, err := p.Find(); err != nil {
+		return false, err
+	}
+	p.log(LevelInfo, "adopted", Fields{"pid": pid})
+	return true, nil
+}
@@ -0,0 +1,51 @@
+// goforever - processes management
+// Copyright (c) 2013 Garrett Woodworth (https://github.com/gwoo).
+
+package process
+
+import (
+	"io/ioutil"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestAlreadyRunning(t *testing.T) {
+	r := NewFakeRunner()
+	p := &Process{Command: "web", Runner: r, Ping: "1h"}
+	RunProcess("web", p)
+	if _, err := RunProcess("web", p); err != ErrAlreadyRunning {
+		t.Errorf("Expected %#v. Result %#v\n", ErrAlreadyRunning, err)
+	}
+	p.AlreadyRunning = RunningAdopt
+	if _, err := RunProcess("web", p); err != nil || len(r.Running()) != 1 || p.CurrentPid() != FakePid {
+		t.Errorf("Expected the running copy to be kept. Result %v %d\n", err, len(r.Running()))
+	}
+	p.Stop()
+	p.AlreadyRunning = "ignore"
+	if _, err := RunProcess("web", p); err == nil {
+		t.Error("Expected an unknown policy to fail.")
+	}
+}
+
+func TestAlreadyRunningPidfile(t *testing.T) {
+	cmd := exec.Command("sleep", "10")
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer cmd.Wait()
+	defer cmd.Process.Kill()
+	pidfile := filepath.Join(t.TempDir(), "web.pid")
+	ioutil.WriteFile(pidfile, []byte(strconv.Itoa(cmd.Process.Pid)), 0644)
+	r := NewFakeRunner()
+	p := &Process{Command: "web", Runner: r, Ping: "1h", Pidfile: Pidfile(pidfile)}
+	if _, err := RunProcess("web", p); err == nil || !strings.HasPrefix(err.Error(), ErrAlreadyRunning.Error()) {
+		t.Errorf("Expected %#v. Result %#v\n", ErrAlreadyRunning, err)
+	}
+	p.AlreadyRunning = RunningAdopt
+	if _, err := RunProcess("web", p); err != nil || p.CurrentPid() != cmd.Process.Pid || len(r.Running()) != 0 {
+		t.Errorf("Expected pid %d adopted. Result %v %d\n", cmd.Process.Pid, err, p.CurrentPid())
+	}
+}
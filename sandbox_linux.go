@@ -0,0 +1,74 @@
+// goforever - processes management
+// Copyright (c) 2013 Garrett Woodworth (https://github.com/gwoo).
+
+package process
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"syscall"
+)
+
+//Run f on a thread of its own in a new mount namespace where ReadOnlyPaths
+//are read-only and InaccessiblePaths hidden, so that the processes f starts
+//see the filesystem that way. The thread is never unlocked, it exits with
+//f and takes the namespace with it.
+func (p *Process) inMountNS(f func() error) error {
+	if len(p.ReadOnlyPaths) == 0 && len(p.InaccessiblePaths) == 0 {
+		return f()
+	}
+	errc := make(chan error, 1)
+	go func() {
+		runtime.LockOSThread()
+		if err := p.sandbox(); err != nil {
+			errc <- err
+			return
+		}
+		errc <- f()
+	}()
+	return <-errc
+}
+
+//Unshare the mount namespace of the calling thread and apply the mounts.
+//Read-only paths are bound onto themselves and remounted read-only;
+//inaccessible directories get an empty tmpfs of mode 000 over them and
+//files /dev/null.
+func (p *Process) sandbox() error {
+	for _, path := range append(append([]string(nil), p.ReadOnlyPaths...), p.InaccessiblePaths...) {
+		if !filepath.IsAbs(path) {
+			return fmt.Errorf("Sandbox path %q is not absolute.", path)
+		}
+	}
+	if err := syscall.Unshare(syscall.CLONE_NEWNS); err != nil {
+		return fmt.Errorf("mount namespace: %s", err)
+	}
+	//Keep the mounts below from propagating back to the host.
+	if err := syscall.Mount("", "/", "", syscall.MS_REC|syscall.MS_PRIVATE, ""); err != nil {
+		return fmt.Errorf("mount namespace: %s", err)
+	}
+	for _, path := range p.ReadOnlyPaths {
+		if err := syscall.Mount(path, path, "", syscall.MS_BIND|syscall.MS_REC, ""); err != nil {
+			return fmt.Errorf("read-only %s: %s", path, err)
+		}
+		if err := syscall.Mount("", path, "", syscall.MS_BIND|syscall.MS_REMOUNT|syscall.MS_RDONLY, ""); err != nil {
+			return fmt.Errorf("read-only %s: %s", path, err)
+		}
+	}
+	for _, path := range p.InaccessiblePaths {
+		st, err := os.Stat(path)
+		if err != nil {
+			return fmt.Errorf("inaccessible %s: %s", path, err)
+		}
+		if st.IsDir() {
+			err = syscall.Mount("tmpfs", path, "tmpfs", syscall.MS_RDONLY|syscall.MS_NOSUID|syscall.MS_NODEV|syscall.MS_NOEXEC, "mode=000")
+		} else {
+			err = syscall.Mount("/dev/null", path, "", syscall.MS_BIND, "")
+		}
+		if err != nil {
+			return fmt.Errorf("inaccessible %s: %s", path, err)
+		}
+	}
+	return nil
+}
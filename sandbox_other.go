@@ -0,0 +1,18 @@
+// goforever - processes management
+// Copyright (c) 2013 Garrett Woodworth (https://github.com/gwoo).
+
+//go:build !linux
+
+package process
+
+import (
+	"errors"
+)
+
+//Mount namespaces only exist on Linux.
+func (p *Process) inMountNS(f func() error) error {
+	if len(p.ReadOnlyPaths) > 0 || len(p.InaccessiblePaths) > 0 {
+		return errors.New("Filesystem sandboxing is only supported on Linux.")
+	}
+	return f()
+}
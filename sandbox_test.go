@@ -0,0 +1,50 @@
+// goforever - processes management
+// Copyright (c) 2013 Garrett Woodworth (https://github.com/gwoo).
+
+package process
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestSandbox(t *testing.T) {
+	if runtime.GOOS != "linux" || os.Getuid() != 0 || exec.Command("unshare", "-m", "true").Run() != nil {
+		t.Skip("Needs Linux and CAP_SYS_ADMIN.")
+	}
+	dir := t.TempDir()
+	ro, hidden, out := filepath.Join(dir, "ro"), filepath.Join(dir, "hidden"), filepath.Join(dir, "out")
+	os.Mkdir(ro, 0755)
+	os.Mkdir(hidden, 0755)
+	ioutil.WriteFile(filepath.Join(hidden, "secret"), []byte("s"), 0644)
+	p := &Process{
+		Command:           "/bin/sh",
+		Args:              []string{"-c", "touch " + ro + "/x 2>/dev/null && echo writable > " + out + "; ls " + hidden + " >> " + out + "; echo done >> " + out},
+		Ping:              "1h",
+		ReadOnlyPaths:     []string{ro},
+		InaccessiblePaths: []string{hidden},
+	}
+	if _, err := RunProcess("sandbox", p); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, func() bool {
+		data, _ := ioutil.ReadFile(out)
+		return strings.Contains(string(data), "done")
+	})
+	p.Stop()
+	if data, _ := ioutil.ReadFile(out); string(data) != "done\n" {
+		t.Errorf("Expected %s read-only and %s hidden. Result %q\n", ro, hidden, data)
+	}
+	if _, err := os.Stat(filepath.Join(hidden, "secret")); err != nil {
+		t.Errorf("Expected the host mounts untouched. Result %v\n", err)
+	}
+	p = &Process{Command: "/bin/true", ReadOnlyPaths: []string{"relative"}}
+	if _, err := RunProcess("relative", p); err == nil {
+		t.Error("Expected a relative path to fail.")
+	}
+}
@@ -0,0 +1,44 @@
+// goforever - processes management
+// Copyright (c) 2013 Garrett Woodworth (https://github.com/gwoo).
+
+package process
+
+import (
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+)
+
+//Every process readable in /proc.
+func scanProcs() ([]procEntry, error) {
+	dirs, err := ioutil.ReadDir("/proc")
+	if err != nil {
+		return nil, err
+	}
+	var procs []procEntry
+	for _, d := range dirs {
+		pid, err := strconv.Atoi(d.Name())
+		if err != nil {
+			continue
+		}
+		base := "/proc/" + d.Name()
+		cmdline, err := ioutil.ReadFile(base + "/cmdline")
+		if err != nil || len(cmdline) == 0 {
+			//Gone, or a kernel thread.
+			continue
+		}
+		e := procEntry{pid: pid}
+		e.cmdline = strings.TrimRight(strings.Replace(string(cmdline), "\x00", " ", -1), " ")
+		e.exe, _ = os.Readlink(base + "/exe")
+		if stat, err := ioutil.ReadFile(base + "/stat"); err == nil {
+			if i := strings.LastIndexByte(string(stat), ')'); i >= 0 {
+				if fields := strings.Fields(string(stat[i+1:])); len(fields) > 1 {
+					e.ppid, _ = strconv.Atoi(fields[1])
+				}
+			}
+		}
+		procs = append(procs, e)
+	}
+	return procs, nil
+}
@@ -0,0 +1,40 @@
+// goforever - processes management
+// Copyright (c) 2013 Garrett Woodworth (https://github.com/gwoo).
+
+//go:build !linux
+
+package process
+
+import (
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+//Every process listed by ps. ps does not report executable paths, the
+//command's first word stands in for it.
+func scanProcs() ([]procEntry, error) {
+	out, err := exec.Command("ps", "-axww", "-o", "pid=", "-o", "ppid=", "-o", "command=").Output()
+	if err != nil {
+		return nil, err
+	}
+	var procs []procEntry
+	for _, line := range strings.Split(string(out), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 3 {
+			continue
+		}
+		pid, err := strconv.Atoi(fields[0])
+		if err != nil {
+			continue
+		}
+		ppid, _ := strconv.Atoi(fields[1])
+		procs = append(procs, procEntry{
+			pid:     pid,
+			ppid:    ppid,
+			exe:     fields[2],
+			cmdline: strings.Join(fields[2:], " "),
+		})
+	}
+	return procs, nil
+}
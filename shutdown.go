@@ -0,0 +1,124 @@
+// goforever - processes management
+// Copyright (c) 2013 Garrett Woodworth (https://github.com/gwoo).
+
+package process
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+//Returned for operations requested after Manager.Shutdown.
+var ErrShuttingDown = errors.New("Manager is shutting down.")
+
+//Logger that buffers and can be flushed, see Manager.Shutdown.
+type Flusher interface {
+	Flush() error
+}
+
+//Stop every process, or detach from them with Detach, and refuse further
+//operations. Dependents stop before the processes they depend on, each
+//taking its Stop timeout; processes still running when ctx is done are
+//killed. Singleton locks are released once their processes stopped, unless
+//detaching. Waits for piped output to be written and flushes the logger if it
+//is a Flusher. Returns the errors joined.
+func (m *Manager) Shutdown(ctx context.Context) error {
+	m.mu.Lock()
+	m.closing = true
+	m.mu.Unlock()
+	var errs []error
+	for _, n := range m.spaceList() {
+		if err := n.Shutdown(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %s", n.ns, err))
+		}
+	}
+	if err := m.StopAll(ctx); err != nil {
+		errs = append(errs, err)
+	}
+	if !m.Detach {
+		m.releaseLeases()
+	}
+	for _, name := range m.Keys() {
+		if err := m.Get(name).flushLogs(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %s", name, err))
+		}
+	}
+	m.saves.Wait()
+	m.saveState()
+	if f, ok := m.Logger.(Flusher); ok {
+		if err := f.Flush(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+//Stop the named processes in parallel, killing them when ctx is done.
+func (m *Manager) stopAll(ctx context.Context, names []string) error {
+	done := make(chan string, len(names))
+	for _, name := range names {
+		p := m.Get(name)
+		go func(name string) {
+			if m.Detach {
+				p.detach()
+			} else {
+				p.stop(nil)
+				p.emit(EventStop, "shutdown")
+			}
+			done <- name
+		}(name)
+	}
+	left := map[string]bool{}
+	for _, name := range names {
+		left[name] = true
+	}
+	for len(left) > 0 {
+		select {
+		case name := <-done:
+			delete(left, name)
+		case <-ctx.Done():
+			var errs []error
+			for name := range left {
+				p := m.Get(name)
+				p.mu.Lock()
+				x := p.x
+				p.mu.Unlock()
+				if x != nil {
+					x.Signal(os.Kill)
+				}
+				errs = append(errs, fmt.Errorf("%s: %s", name, ctx.Err()))
+			}
+			return errors.Join(errs...)
+		}
+	}
+	return nil
+}
+
+//Wait for the piped output files to be closed by their streams.
+func (p *Process) flushLogs(ctx context.Context) error {
+	tick := p.clock().NewTicker(10 * time.Millisecond)
+	defer tick.Stop()
+	for {
+		p.mu.Lock()
+		open := len(p.logs)
+		p.mu.Unlock()
+		if open == 0 {
+			return nil
+		}
+		select {
+		case <-tick.C():
+		case <-ctx.Done():
+			return fmt.Errorf("%d log files not flushed: %s", open, ctx.Err())
+		}
+	}
+}
+
+//Whether Shutdown was called.
+func (m *Manager) shuttingDown() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.closing
+}
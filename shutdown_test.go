@@ -0,0 +1,82 @@
+// goforever - processes management
+// Copyright (c) 2013 Garrett Woodworth (https://github.com/gwoo).
+
+package process
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+)
+
+func TestOrder(t *testing.T) {
+	m := NewManager()
+	m.Add("db", &Process{})
+	m.AddInstance("api", &Process{DependsOn: []string{"db"}})
+	m.AddInstance("api", &Process{DependsOn: []string{"db"}})
+	m.Add("web", &Process{DependsOn: []string{"api"}})
+	layers, err := m.order()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ex := "[[db] [api-1 api-2] [web]]"
+	if r := fmt.Sprint(layers); r != ex {
+		t.Errorf("Expected %s. Result %s\n", ex, r)
+	}
+	m.Get("db").DependsOn = []string{"web"}
+	if _, err := m.order(); err != ErrDependencyCycle {
+		t.Errorf("Expected %#v. Result %#v\n", ErrDependencyCycle, err)
+	}
+	m.Get("db").DependsOn = []string{"cache"}
+	if _, err := m.order(); err == nil {
+		t.Error("Expected unknown dependency error.")
+	}
+}
+
+func TestShutdown(t *testing.T) {
+	r := NewFakeRunner()
+	m := NewManager()
+	m.Runner = r
+	var mu sync.Mutex
+	var stopped []string
+	m.OnEvent(func(e Event) {
+		if e.Type == EventStop {
+			mu.Lock()
+			stopped = append(stopped, e.Process)
+			mu.Unlock()
+		}
+	})
+	m.Add("db", &Process{Command: "/usr/bin/db", Ping: "1h"})
+	m.Add("web", &Process{Command: "/usr/bin/web", Ping: "1h", DependsOn: []string{"db"}})
+	m.Run()
+	if len(r.Running()) != 2 {
+		t.Fatalf("Expected 2 running. Result %#v\n", r.Running())
+	}
+	if err := m.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(r.Running()) != 0 || len(stopped) != 2 || stopped[0] != "web" {
+		t.Errorf("Expected web then db stopped. Result %#v\n", stopped)
+	}
+	if _, err := m.Start("web"); err != ErrShuttingDown {
+		t.Errorf("Expected %#v. Result %#v\n", ErrShuttingDown, err)
+	}
+	if h := m.Health(); h.Status != "stopping" {
+		t.Errorf("Expected stopping. Result %#v\n", h.Status)
+	}
+}
+
+func TestShutdownTimeout(t *testing.T) {
+	r := NewFakeRunner()
+	r.OnStart = func(f *FakeProcess) { f.IgnoreSignals = true }
+	m := NewManager()
+	m.Runner = r
+	m.Add("stuck", &Process{Command: "/usr/bin/stuck", Ping: "1h", Timeouts: &Timeouts{Stop: "1h"}})
+	m.Run()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := m.Shutdown(ctx); err == nil {
+		t.Error("Expected a timeout error.")
+	}
+}
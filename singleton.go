@@ -0,0 +1,91 @@
+// goforever - processes management
+// Copyright (c) 2013 Garrett Woodworth (https://github.com/gwoo).
+
+package process
+
+import (
+	"sync"
+	"time"
+)
+
+//Leadership of the singletons on this node.
+type leases struct {
+	//Serializes elections and the release on shutdown.
+	mu     sync.Mutex
+	locker Locker
+	lock   *Lock
+	ttl    time.Duration
+	held   map[string]bool
+	//Last successful Lock call by process, so that lock errors only drop
+	//a lease once it could have expired.
+	renewed map[string]time.Time
+}
+
+//Whether the manager is in a cluster electing its Singleton processes,
+//which Run then leaves to the election.
+func (m *Manager) electing() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.cluster != nil && m.cluster.leases != nil
+}
+
+//Acquire or renew the lock of every singleton, starting those this node
+//just became leader of and stopping those it lost. A draining node only
+//renews, see Manager.Drain.
+func (m *Manager) elect(s *clusterState) {
+	l := s.leases
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if m.shuttingDown() {
+		return
+	}
+	for _, name := range m.Keys() {
+		p := m.Get(name)
+		if p == nil || !p.Singleton {
+			continue
+		}
+		was := l.held[name]
+		if !was && s.isDraining() {
+			continue
+		}
+		held, err := l.locker.Lock(l.lock.key(name), s.node, l.ttl)
+		now := time.Now()
+		if err != nil {
+			p.log(LevelError, "singleton lock failed", Fields{"error": err})
+			held = was && now.Sub(l.renewed[name]) < l.ttl
+		} else if held {
+			l.renewed[name] = now
+		}
+		l.held[name] = held
+		switch {
+		case held && !was:
+			p.log(LevelInfo, "singleton acquired", Fields{"node": s.node})
+			m.Start(name)
+		case !held && was:
+			p.log(LevelWarn, "singleton lost", Fields{"node": s.node})
+			m.Stop(name)
+		}
+	}
+}
+
+//Release the locks held, letting other nodes take over right away.
+func (m *Manager) releaseLeases() {
+	m.mu.Lock()
+	s := m.cluster
+	m.mu.Unlock()
+	if s == nil || s.leases == nil {
+		return
+	}
+	l := s.leases
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for name, held := range l.held {
+		if !held {
+			continue
+		}
+		if err := l.locker.Unlock(l.lock.key(name), s.node); err != nil {
+			m.log(LevelError, "singleton unlock failed", Fields{"process": name, "error": err})
+		}
+		l.held[name] = false
+	}
+}
@@ -0,0 +1,52 @@
+// goforever - processes management
+// Copyright (c) 2013 Garrett Woodworth (https://github.com/gwoo).
+
+package process
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSingletonFailover(t *testing.T) {
+	lock := &Lock{Type: "file", Path: t.TempDir(), TTL: "1m"}
+	nodes := map[string]*Manager{}
+	var urls []string
+	for _, node := range []string{"a", "b"} {
+		m := NewManager()
+		m.Runner = NewFakeRunner()
+		m.Add("cron", &Process{Command: "/usr/bin/cron", Ping: "1h", Singleton: true})
+		m.Add("web", &Process{Command: "/usr/bin/web", Ping: "1h"})
+		srv := httptest.NewServer(m.API(nil))
+		defer srv.Close()
+		nodes[node] = m
+		urls = append(urls, srv.URL)
+	}
+	done := make(chan struct{})
+	defer close(done)
+	a, b := nodes["a"], nodes["b"]
+	if err := a.JoinCluster(&Cluster{Node: "a", Peers: urls[1:], Lock: lock, Interval: "10ms"}, done); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, func() bool {
+		return a.Get("cron").Snapshot().Pid > 0
+	})
+	if err := b.JoinCluster(&Cluster{Node: "b", Peers: urls[:1], Lock: lock, Interval: "10ms"}, done); err != nil {
+		t.Fatal(err)
+	}
+	b.Run()
+	if pid := b.Get("web").Snapshot().Pid; pid == 0 {
+		t.Errorf("Expected Run to start web.\n")
+	}
+	waitFor(t, func() bool {
+		return len(b.Locate("cron")) == 2
+	})
+	if pid := b.Get("cron").Snapshot().Pid; pid != 0 {
+		t.Errorf("Expected cron to run on a only. Result pid %d on b\n", pid)
+	}
+	a.Shutdown(context.Background())
+	waitFor(t, func() bool {
+		return b.Get("cron").Snapshot().Pid > 0
+	})
+}
@@ -0,0 +1,318 @@
+// goforever - processes management
+// Copyright (c) 2013 Garrett Woodworth (https://github.com/gwoo).
+
+package process
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+//Destination for piped output. Each output stream is written to every
+//configured sink; a failing sink does not affect the others.
+type Sink struct {
+	//file, ring, syslog, logger or stderr.
+	Type string `json:"type"`
+	//File path, defaulting to Logfile or Errfile.
+	Path string `json:"path,omitempty"`
+	//Lines kept by a ring, see Process.Tail. Defaults to 1000.
+	Lines int `json:"lines,omitempty"`
+	//Syslog tag (default process name), network and address (default the
+	//local syslog).
+	Tag     string `json:"tag,omitempty"`
+	Network string `json:"network,omitempty"`
+	Address string `json:"address,omitempty"`
+	//Streams this sink receives, default both stdout and stderr.
+	Streams []string `json:"streams,omitempty"`
+	//Name for filter routes. A sink routed to only receives routed lines.
+	Name string `json:"name,omitempty"`
+}
+
+func (s *Sink) wants(stream string) bool {
+	if len(s.Streams) == 0 {
+		return true
+	}
+	for _, name := range s.Streams {
+		if name == stream {
+			return true
+		}
+	}
+	return false
+}
+
+//Open the sinks of one stream. Without configured sinks the stream goes to
+//path, as it does without Output.
+func (p *Process) openSinks(stream, path string, red *redactor) io.WriteCloser {
+	sinks := p.Output.Sinks
+	if len(sinks) == 0 {
+		sinks = []Sink{{Type: "file"}}
+	}
+	m := &multiSink{filters: p.filters(stream), red: red}
+	p.mu.Lock()
+	m.cap = p.capture
+	p.mu.Unlock()
+	routes := map[string]bool{}
+	for _, f := range m.filters {
+		routes[f.route] = true
+	}
+	for i := range sinks {
+		s := &sinks[i]
+		if !s.wants(stream) {
+			continue
+		}
+		w, err := p.openSink(s, stream, path)
+		if err != nil {
+			p.log(LevelError, "sink failed", Fields{"sink": s.Type, "stream": stream, "error": err})
+			continue
+		}
+		if w == nil {
+			continue
+		}
+		name := stream + "/" + s.Type
+		if s.Name != "" {
+			name = stream + "/" + s.Name
+		}
+		m.sinks = append(m.sinks, &sinkWriter{
+			name:   name,
+			route:  s.Name,
+			routed: s.Name != "" && routes[s.Name],
+			w:      w,
+			errors: p.sinkErrors(name),
+			p:      p,
+		})
+	}
+	return m
+}
+
+func (p *Process) openSink(s *Sink, stream, path string) (io.WriteCloser, error) {
+	switch s.Type {
+	case "file":
+		if s.Path != "" {
+			path = s.Path
+		}
+		if path == "" {
+			return nil, nil
+		}
+		f, err := p.openLogFile(path)
+		if err != nil {
+			p.log(LevelError, "log open failed, using stderr", Fields{"file": path, "error": err})
+			return nopCloser{os.Stderr}, nil
+		}
+		return f, nil
+	case "ring":
+		return p.ring(stream, s.Lines), nil
+	case "syslog":
+		tag := s.Tag
+		if tag == "" {
+			tag = p.Name
+		}
+		return newSyslogSink(s.Network, s.Address, tag, stream)
+	case "logger":
+		return &loggerSink{p: p, stream: stream}, nil
+	case "stderr":
+		return nopCloser{os.Stderr}, nil
+	}
+	return nil, fmt.Errorf("Unknown sink type %q.", s.Type)
+}
+
+//Error counter for a sink, kept across restarts.
+func (p *Process) sinkErrors(name string) *uint64 {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.sinkErrs == nil {
+		p.sinkErrs = map[string]*uint64{}
+	}
+	if p.sinkErrs[name] == nil {
+		p.sinkErrs[name] = new(uint64)
+	}
+	return p.sinkErrs[name]
+}
+
+//Write errors by stream/sink. Called with p.mu held.
+func (p *Process) sinkErrorCounts() map[string]uint64 {
+	if len(p.sinkErrs) == 0 {
+		return nil
+	}
+	counts := map[string]uint64{}
+	for name, n := range p.sinkErrs {
+		counts[name] = atomic.LoadUint64(n)
+	}
+	return counts
+}
+
+//Writes every line, redacted, to all sinks, isolating their errors. Lines
+//matched by a filter only go to the sink it routes to, if any.
+type multiSink struct {
+	sinks   []*sinkWriter
+	filters []filter
+	red     *redactor
+	cap     *capture
+}
+
+func (m *multiSink) Write(b []byte) (int, error) {
+	line := m.red.redact(b)
+	if m.cap.add(line) {
+		return len(b), nil
+	}
+	for _, f := range m.filters {
+		if !f.matches(line) {
+			continue
+		}
+		for _, s := range m.sinks {
+			if f.route != "" && s.route == f.route {
+				s.write(line)
+			}
+		}
+		return len(b), nil
+	}
+	for _, s := range m.sinks {
+		if !s.routed {
+			s.write(line)
+		}
+	}
+	return len(b), nil
+}
+
+func (m *multiSink) Close() error {
+	for _, s := range m.sinks {
+		s.w.Close()
+	}
+	return nil
+}
+
+type sinkWriter struct {
+	name    string
+	route   string
+	routed  bool
+	w       io.WriteCloser
+	errors  *uint64
+	failing bool
+	p       *Process
+}
+
+//Write, counting errors. Only the first error of a run is logged.
+func (s *sinkWriter) write(line []byte) {
+	_, err := s.w.Write(line)
+	if err == nil {
+		s.failing = false
+		return
+	}
+	atomic.AddUint64(s.errors, 1)
+	if !s.failing {
+		s.failing = true
+		s.p.log(LevelError, "sink write failed", Fields{"sink": s.name, "error": err})
+	}
+}
+
+type nopCloser struct {
+	io.Writer
+}
+
+func (nopCloser) Close() error {
+	return nil
+}
+
+//Ring buffer of a stream's last lines, shared across restarts.
+func (p *Process) ring(stream string, lines int) *ring {
+	if lines <= 0 {
+		lines = 1000
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.rings == nil {
+		p.rings = map[string]*ring{}
+	}
+	if r := p.rings[stream]; r != nil {
+		return r
+	}
+	r := &ring{lines: make([]string, lines)}
+	p.rings[stream] = r
+	return r
+}
+
+//Last n lines of stream ("stdout" or "stderr") kept by a ring sink, oldest
+//first. n <= 0 returns everything kept.
+func (p *Process) Tail(stream string, n int) []string {
+	p.mu.Lock()
+	r := p.rings[stream]
+	p.mu.Unlock()
+	if r == nil {
+		return nil
+	}
+	return r.tail(n)
+}
+
+//Lines written to stream's ring sink from now on, until stop is called.
+//Lines are dropped while the channel is full. Nil without a ring.
+func (p *Process) Follow(stream string) (lines <-chan string, stop func()) {
+	p.mu.Lock()
+	r := p.rings[stream]
+	p.mu.Unlock()
+	if r == nil {
+		return nil, func() {}
+	}
+	return r.follow()
+}
+
+type ring struct {
+	mu    sync.Mutex
+	lines []string
+	next  int
+	full  bool
+	subs  map[chan string]bool
+}
+
+func (r *ring) Write(line []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	s := strings.TrimRight(string(line), "\n")
+	r.lines[r.next] = s
+	r.next = (r.next + 1) % len(r.lines)
+	if r.next == 0 {
+		r.full = true
+	}
+	for ch := range r.subs {
+		select {
+		case ch <- s:
+		default:
+		}
+	}
+	return len(line), nil
+}
+
+func (r *ring) follow() (chan string, func()) {
+	ch := make(chan string, 100)
+	r.mu.Lock()
+	if r.subs == nil {
+		r.subs = map[chan string]bool{}
+	}
+	r.subs[ch] = true
+	r.mu.Unlock()
+	return ch, func() {
+		r.mu.Lock()
+		delete(r.subs, ch)
+		r.mu.Unlock()
+	}
+}
+
+func (r *ring) Close() error {
+	return nil
+}
+
+func (r *ring) tail(n int) []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var lines []string
+	if r.full {
+		lines = append(lines, r.lines[r.next:]...)
+	}
+	lines = append(lines, r.lines[:r.next]...)
+	if n > 0 && n < len(lines) {
+		lines = lines[len(lines)-n:]
+	}
+	return lines
+}
@@ -0,0 +1,144 @@
+// goforever - processes management
+// Copyright (c) 2013 Garrett Woodworth (https://github.com/gwoo).
+
+package process
+
+import (
+	"time"
+)
+
+//Point-in-time copy of a process's configuration and state. It shares no
+//memory with the Process, so it is safe to hold and read from any goroutine.
+type ProcessInfo struct {
+	Name     string        `json:"name"`
+	Command  string        `json:"command"`
+	Args     []string      `json:"args,omitempty"`
+	Pidfile  string        `json:"pidfile,omitempty"`
+	Pid      int           `json:"pid,omitempty"`
+	Status   string        `json:"status,omitempty"`
+	Color    string        `json:"color,omitempty"`
+	Reason   string        `json:"reason,omitempty"`
+	Respawn  int           `json:"respawn"`
+	Respawns int           `json:"respawns"`
+	Started  time.Time     `json:"started,omitempty"`
+	Uptime   time.Duration `json:"uptime,omitempty"`
+	LastExit *Exit         `json:"last_exit,omitempty"`
+	//Piped output lines dropped by the overflow policy, by stream.
+	LogDropped map[string]uint64 `json:"log_dropped,omitempty"`
+	//Piped output write errors, by stream/sink.
+	LogSinkErrors map[string]uint64 `json:"log_sink_errors,omitempty"`
+	//Output captured at the last start, while the process is not running.
+	StartupOutput string `json:"startup_output,omitempty"`
+	//Health state of processes with Health probes.
+	Health string `json:"health,omitempty"`
+	//Passing readiness checks, see Process.Ready.
+	Ready bool `json:"ready"`
+	//See Manager.Namespace.
+	Namespace string `json:"namespace,omitempty"`
+	//CPU time and resident memory in bytes of the running process, where
+	//the platform reports them (Linux).
+	CPUTime time.Duration `json:"cpu_time,omitempty"`
+	Memory  uint64        `json:"memory,omitempty"`
+	//Ping interval in effect, see Manager.Ping.
+	Ping string `json:"ping,omitempty"`
+	//Last STATUS= the process sent to NOTIFY_SOCKET, see Process.Notify.
+	Notice string `json:"notice,omitempty"`
+	//Health check success rate and latency, without the samples, see
+	//Process.ProbeStats.
+	Probes *ProbeStats `json:"probes,omitempty"`
+	//API requests refused by the ActionLimit, by action.
+	RateLimited map[string]uint64 `json:"rate_limited,omitempty"`
+	//Binary the process was started from, see Process.Binary.
+	Binary *BinaryInfo `json:"binary,omitempty"`
+}
+
+//Take a snapshot of the process.
+func (p *Process) Snapshot() ProcessInfo {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	info := ProcessInfo{
+		Name:     p.Name,
+		Command:  p.Command,
+		Args:     append([]string(nil), p.Args...),
+		Pidfile:  string(p.Pidfile),
+		Pid:      p.Pid,
+		Status:   p.Status,
+		Color:    p.color,
+		Reason:   p.reason,
+		Respawn:  p.Respawn,
+		Respawns: p.respawns,
+		Ping:     p.pingInterval().String(),
+		Notice:   p.notice,
+	}
+	if p.Pid > 0 && !p.started.IsZero() {
+		info.Started = p.started
+		info.Uptime = p.clock().Now().Sub(p.started)
+		if p.cycle.TryLock() {
+			info.CPUTime, info.Memory, _ = usage(p.Pid)
+			p.cycle.Unlock()
+		}
+	}
+	if p.lastExit != nil {
+		exit := *p.lastExit
+		info.LastExit = &exit
+	}
+	if p.Output != nil {
+		info.LogDropped = p.dropped()
+	}
+	info.LogSinkErrors = p.sinkErrorCounts()
+	if p.manager != nil {
+		info.Namespace = p.manager.ns
+	}
+	if len(p.Health) > 0 {
+		info.Health = HealthUnknown
+		if p.health != nil && p.health.pid == p.Pid {
+			info.Health = p.health.state
+		}
+		info.Ready = info.Health == HealthHealthy || info.Health == HealthDegraded
+	} else {
+		info.Ready = p.Pid > 0
+	}
+	info.Probes = p.probeStats(false)
+	info.RateLimited = p.limitedCounts()
+	info.Binary = p.binaryLocked()
+	if p.Status != "running" {
+		info.StartupOutput = p.capture.String()
+	}
+	return info
+}
+
+//Pid of the running process, 0 if there is none. Unlike reading the Pid
+//field it does not race the supervision goroutines.
+func (p *Process) CurrentPid() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.Pid
+}
+
+//Status of the process, see CurrentPid.
+func (p *Process) CurrentStatus() string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.Status
+}
+
+//Respawns since the process last ran for its Ping interval.
+func (p *Process) Respawns() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.respawns
+}
+
+//Snapshots of all processes, sorted by name.
+func (m *Manager) Snapshot() []ProcessInfo {
+	infos := []ProcessInfo{}
+	for _, name := range m.Keys() {
+		if p := m.Get(name); p != nil {
+			infos = append(infos, p.Snapshot())
+		}
+	}
+	for _, n := range m.spaceList() {
+		infos = append(infos, n.Snapshot()...)
+	}
+	return infos
+}
@@ -0,0 +1,82 @@
+// goforever - processes management
+// Copyright (c) 2013 Garrett Woodworth (https://github.com/gwoo).
+
+package process
+
+import (
+	"os"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestSnapshot(t *testing.T) {
+	p := &Process{Name: "web", Args: []string{"-v"}, Pid: 10, Status: "running", respawns: 1}
+	p.started = time.Now().Add(-time.Minute)
+	p.lastExit = &Exit{Code: 2}
+	s := p.Snapshot()
+	p.Args[0] = "-q"
+	p.lastExit.Code = 3
+	if s.Args[0] != "-v" || s.LastExit.Code != 2 {
+		t.Errorf("Expected snapshot to be a copy. Result %#v\n", s)
+	}
+	if s.Pid != 10 || s.Respawns != 1 || s.Uptime < time.Minute {
+		t.Errorf("Unexpected snapshot %#v\n", s)
+	}
+}
+
+func TestAccessors(t *testing.T) {
+	runner := NewFakeRunner()
+	p := &Process{Command: "web", Runner: runner, Respawn: 5, Ping: "1h"}
+	RunProcess("web", p)
+	pid := p.CurrentPid()
+	if pid != FakePid || p.CurrentStatus() != "started" || p.Respawns() != 0 {
+		t.Errorf("Unexpected state %d %s %d\n", pid, p.CurrentStatus(), p.Respawns())
+	}
+	//Read while Watch respawns, for the race detector.
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for p.CurrentPid() == pid {
+		}
+	}()
+	runner.Running()[0].Exit(1)
+	<-done
+	waitFor(t, func() bool { return p.CurrentPid() == FakePid+1 && p.Respawns() == 1 })
+	p.Stop()
+	if p.CurrentPid() != 0 || p.CurrentStatus() != "stopped" {
+		t.Errorf("Expected stopped. Result %d %s\n", p.CurrentPid(), p.CurrentStatus())
+	}
+}
+
+func TestPingPrecedence(t *testing.T) {
+	m := NewManager()
+	p := &Process{Command: "web"}
+	m.Add("web", p)
+	if r := p.Snapshot().Ping; r != DefaultPing.String() {
+		t.Errorf("Expected %#v. Result %#v\n", DefaultPing.String(), r)
+	}
+	m.Ping = "30s"
+	if r := p.Snapshot().Ping; r != "30s" {
+		t.Errorf("Expected %#v. Result %#v\n", "30s", r)
+	}
+	p.Ping = "5s"
+	if r := p.Snapshot().Ping; r != "5s" {
+		t.Errorf("Expected %#v. Result %#v\n", "5s", r)
+	}
+	n, _ := m.Namespace("team")
+	if n.Ping != "30s" {
+		t.Errorf("Expected %#v. Result %#v\n", "30s", n.Ping)
+	}
+}
+
+func TestSnapshotUsage(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("usage is only read on linux")
+	}
+	p := &Process{Name: "self", Pid: os.Getpid(), Status: "running", started: time.Now()}
+	s := p.Snapshot()
+	if s.Memory == 0 {
+		t.Errorf("Expected resident memory. Result %#v\n", s)
+	}
+}
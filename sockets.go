@@ -0,0 +1,90 @@
+// goforever - processes management
+// Copyright (c) 2013 Garrett Woodworth (https://github.com/gwoo).
+
+package process
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+)
+
+//Socket bound by the supervisor and passed to the child, e.g. to serve
+//one copy of a service per address on a multi-homed host.
+type Socket struct {
+	//tcp, udp or unix. Defaults to tcp.
+	Network string `json:"network,omitempty"`
+	//Address to bind, e.g. "10.0.1.5:80" or a unix socket path.
+	Address string `json:"address"`
+	//Name in LISTEN_FDNAMES. Defaults to the address.
+	Name string `json:"name,omitempty"`
+}
+
+//Files of the bound Sockets, in order, and the variables telling the child
+//about them as in systemd socket activation: LISTEN_FDS and LISTEN_FDNAMES.
+//The sockets are the child's descriptors 3 and up. LISTEN_PID is not set
+//since the pid is unknown before the exec.
+func (p *Process) openSockets() ([]*os.File, []string, error) {
+	if len(p.Sockets) == 0 {
+		return nil, nil, nil
+	}
+	var files []*os.File
+	var names []string
+	for _, s := range p.Sockets {
+		f, err := s.open()
+		if err != nil {
+			closeFiles(files)
+			return nil, nil, fmt.Errorf("socket %s: %s", s.Address, err)
+		}
+		files = append(files, f)
+		name := s.Name
+		if name == "" {
+			name = s.Address
+		}
+		names = append(names, name)
+	}
+	env := []string{
+		"LISTEN_FDS=" + strconv.Itoa(len(files)),
+		"LISTEN_FDNAMES=" + strings.Join(names, ":"),
+	}
+	return files, env, nil
+}
+
+//Bind the socket and return a copy of its descriptor.
+func (s Socket) open() (*os.File, error) {
+	network := s.Network
+	if network == "" {
+		network = "tcp"
+	}
+	var f interface{ File() (*os.File, error) }
+	switch network {
+	case "tcp", "unix":
+		if network == "unix" {
+			//A socket file left by a previous run.
+			os.Remove(s.Address)
+		}
+		l, err := net.Listen(network, s.Address)
+		if err != nil {
+			return nil, err
+		}
+		defer l.Close()
+		f = l.(interface{ File() (*os.File, error) })
+		if u, ok := l.(*net.UnixListener); ok {
+			//Keep the socket file for the child.
+			u.SetUnlinkOnClose(false)
+		}
+	case "udp":
+		c, err := net.ListenPacket(network, s.Address)
+		if err != nil {
+			return nil, err
+		}
+		defer c.Close()
+		f = c.(*net.UDPConn)
+	default:
+		return nil, errors.New("Socket network must be tcp, udp or unix.")
+	}
+	return f.File()
+}
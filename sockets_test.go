@@ -0,0 +1,80 @@
+// goforever - processes management
+// Copyright (c) 2013 Garrett Woodworth (https://github.com/gwoo).
+
+package process
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestSockets(t *testing.T) {
+	dir, err := ioutil.TempDir("", "process")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	sock := filepath.Join(dir, "web.sock")
+	runner := NewFakeRunner()
+	var addrs []string
+	runner.OnStart = func(f *FakeProcess) {
+		for _, file := range f.Cmd.Files[3:] {
+			if l, err := net.FileListener(file); err == nil {
+				addrs = append(addrs, l.Addr().Network())
+				l.Close()
+			} else if c, err := net.FilePacketConn(file); err == nil {
+				addrs = append(addrs, c.LocalAddr().Network())
+				c.Close()
+			}
+		}
+	}
+	p := &Process{Command: "web", Runner: runner, Sockets: []Socket{
+		{Address: "127.0.0.1:0", Name: "http"},
+		{Network: "udp", Address: "127.0.0.1:0"},
+		{Network: "unix", Address: sock, Name: "admin"},
+	}}
+	if err := p.start("web"); err != nil {
+		t.Fatal(err)
+	}
+	defer p.Stop()
+	if expected := []string{"tcp", "udp", "unix"}; strings.Join(addrs, " ") != strings.Join(expected, " ") {
+		t.Errorf("Expected %#v. Result %#v\n", expected, addrs)
+	}
+	env := strings.Join(runner.Processes()[0].Cmd.Env, "\n")
+	for _, expected := range []string{"LISTEN_FDS=3", "LISTEN_FDNAMES=http:127.0.0.1:0:admin"} {
+		if !strings.Contains(env, expected) {
+			t.Errorf("Expected %#v in the environment.\n", expected)
+		}
+	}
+	if _, err := os.Stat(sock); err != nil {
+		t.Errorf("Expected the socket file kept. Result %#v\n", err)
+	}
+
+	p.Stop()
+	p.Sockets = []Socket{{Network: "sctp", Address: ":80"}}
+	if err := p.start("web"); err == nil {
+		t.Error("Expected an unknown network to fail.")
+	}
+}
+
+func TestNetNS(t *testing.T) {
+	runner := NewFakeRunner()
+	p := &Process{Command: "web", Runner: runner, NetNS: "nonexistent-ns"}
+	if err := p.start("web"); err == nil || len(runner.Processes()) != 0 {
+		t.Errorf("Expected a missing namespace to fail. Result %#v\n", err)
+	}
+	if runtime.GOOS != "linux" || os.Getuid() != 0 {
+		return
+	}
+	p.NetNS = "/proc/self/ns/net"
+	p.Sockets = []Socket{{Address: "127.0.0.1:0"}}
+	if err := p.start("web"); err != nil {
+		t.Skipf("Cannot enter the namespace: %s", err)
+	}
+	p.Stop()
+}
@@ -0,0 +1,136 @@
+// goforever - processes management
+// Copyright (c) 2013 Garrett Woodworth (https://github.com/gwoo).
+
+package process
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+//Run the process on a remote host through the ssh client, kept in the
+//foreground with a terminal so that the remote command is hung up when the
+//client exits. Env entries that differ from the supervisor's environment
+//are set on the remote command. Used by the ssh runner, see
+//RegisterRunner.
+type SSH struct {
+	Host string `json:"host"`
+	User string `json:"user,omitempty"`
+	Port int    `json:"port,omitempty"`
+	//Further client options, e.g. "-i", "/etc/process/id_ed25519".
+	Options []string `json:"options,omitempty"`
+	//Client binary, ssh by default.
+	Client string `json:"client,omitempty"`
+	//Remote pidfile the command records its pid in. Signals are then sent
+	//to the remote process with kill over another connection, so that it
+	//can stop gracefully, rather than to the client.
+	Pidfile string `json:"pidfile,omitempty"`
+	//Remote file the output is appended to instead of being streamed back,
+	//so that it outlives the connection. See Log.
+	Logfile string `json:"logfile,omitempty"`
+}
+
+//Client and connection arguments up to and including the host.
+func (s *SSH) connect(tty bool) []string {
+	client := s.Client
+	if client == "" {
+		client = "ssh"
+	}
+	args := []string{client}
+	if tty {
+		args = append(args, "-tt")
+	}
+	if s.User != "" {
+		args = append(args, "-l", s.User)
+	}
+	if s.Port != 0 {
+		args = append(args, "-p", strconv.Itoa(s.Port))
+	}
+	args = append(args, s.Options...)
+	return append(args, s.Host, "--")
+}
+
+//Run the remote shell command and return its output.
+func (s *SSH) run(command string) ([]byte, error) {
+	args := s.connect(false)
+	return exec.Command(args[0], append(args[1:], command)...).Output()
+}
+
+//Arguments of the client running cmd.
+func (s *SSH) args(cmd *Cmd) []string {
+	var remote []string
+	if s.Pidfile != "" {
+		remote = append(remote, "echo $$ >", shellQuote(s.Pidfile), "&&")
+	}
+	remote = append(remote, "exec", "env")
+	for _, kv := range ownEnv(cmd.Env) {
+		remote = append(remote, shellQuote(kv))
+	}
+	remote = append(remote, shellQuote(cmd.Path))
+	for i := 1; i < len(cmd.Args); i++ {
+		remote = append(remote, shellQuote(cmd.Args[i]))
+	}
+	if s.Logfile != "" {
+		remote = append(remote, ">>", shellQuote(s.Logfile), "2>&1")
+	}
+	return append(s.connect(true), strings.Join(remote, " "))
+}
+
+func (s *SSH) Start(cmd *Cmd) (Handle, error) {
+	for _, f := range cmd.Files[3:] {
+		if f != nil {
+			return nil, errors.New("Remote processes cannot inherit Sockets.")
+		}
+	}
+	args := s.args(cmd)
+	path, err := exec.LookPath(args[0])
+	if err != nil {
+		return nil, fmt.Errorf("ssh client: %s", err)
+	}
+	h, err := ExecRunner.Start(&Cmd{Path: path, Args: args, Env: cmd.Env, Dir: cmd.Dir, Files: cmd.Files[:3]})
+	if err != nil {
+		return nil, err
+	}
+	return &sshHandle{Handle: h, s: s}, nil
+}
+
+//Last lines of the remote Logfile.
+func (s *SSH) Log(lines int) ([]string, error) {
+	if s.Logfile == "" {
+		return nil, errors.New("No remote logfile.")
+	}
+	out, err := s.run(fmt.Sprintf("tail -n %d %s", lines, shellQuote(s.Logfile)))
+	if err != nil {
+		return nil, fmt.Errorf("ssh %s: %s", s.Host, err)
+	}
+	text := strings.TrimRight(string(out), "\n")
+	if text == "" {
+		return []string{}, nil
+	}
+	return strings.Split(text, "\n"), nil
+}
+
+//Handle of the client. With a remote Pidfile signals other than 0 go to
+//the remote process, falling back to the client when that fails; KILL
+//always ends the client as well.
+type sshHandle struct {
+	Handle
+	s *SSH
+}
+
+func (h *sshHandle) Signal(sig os.Signal) error {
+	n, ok := sig.(syscall.Signal)
+	if h.s.Pidfile == "" || !ok || n == 0 {
+		return h.Handle.Signal(sig)
+	}
+	_, err := h.s.run(fmt.Sprintf("kill -%d $(cat %s)", n, shellQuote(h.s.Pidfile)))
+	if err != nil || sig == os.Kill {
+		return h.Handle.Signal(sig)
+	}
+	return nil
+}
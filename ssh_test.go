@@ -0,0 +1,65 @@
+// goforever - processes management
+// Copyright (c) 2013 Garrett Woodworth (https://github.com/gwoo).
+
+package process
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+//Fake ssh client logging its arguments and running the remote command
+//locally.
+func fakeSSH(t *testing.T) (client, log string) {
+	dir := t.TempDir()
+	log, client = filepath.Join(dir, "log"), filepath.Join(dir, "ssh")
+	script := "#!/bin/sh\necho \"$@\" >> " + log + "\nfor last; do :; done\nexec /bin/sh -c \"$last\"\n"
+	ioutil.WriteFile(client, []byte(script), 0755)
+	return client, log
+}
+
+func TestSSH(t *testing.T) {
+	client, log := fakeSSH(t)
+	p := &Process{
+		Command:    "/bin/sleep",
+		Args:       []string{"10"},
+		Env:        []string{"FOO=it's"},
+		Ping:       "1h",
+		RunnerName: "ssh",
+		SSH:        &SSH{Host: "app1", User: "deploy", Port: 2222, Client: client},
+	}
+	if _, err := RunProcess("web", p); err != nil {
+		t.Fatal(err)
+	}
+	defer p.Stop()
+	read := func() string {
+		data, _ := ioutil.ReadFile(log)
+		return strings.TrimSpace(string(data))
+	}
+	waitFor(t, func() bool { return read() != "" })
+	ex := `-tt -l deploy -p 2222 app1 -- exec env 'FOO=it'\''s' /bin/sleep 10`
+	if r := read(); r != ex {
+		t.Errorf("Expected %#v. Result %#v\n", ex, r)
+	}
+}
+
+func TestSSHPidfileLog(t *testing.T) {
+	client, log := fakeSSH(t)
+	dir := t.TempDir()
+	s := &SSH{Host: "app1", Client: client, Pidfile: filepath.Join(dir, "web.pid"), Logfile: filepath.Join(dir, "web.log")}
+	p := &Process{Command: "/bin/sh", Args: []string{"-c", "echo hello; exec sleep 10"}, Ping: "1h", RunnerName: "ssh", SSH: s}
+	if _, err := RunProcess("web", p); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, func() bool {
+		lines, _ := s.Log(10)
+		return len(lines) == 1 && lines[0] == "hello"
+	})
+	p.Stop()
+	data, _ := ioutil.ReadFile(log)
+	if !strings.Contains(string(data), "app1 -- kill -15 $(cat "+s.Pidfile+")") {
+		t.Errorf("Expected TERM sent remotely. Result %s\n", data)
+	}
+}
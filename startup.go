@@ -0,0 +1,73 @@
+// goforever - processes management
+// Copyright (c) 2013 Garrett Woodworth (https://github.com/gwoo).
+
+package process
+
+import (
+	"sync"
+)
+
+//Capture of the first output of each start, kept so that a child failing
+//right away ("address already in use") can be diagnosed from its status.
+type Startup struct {
+	//Bytes captured from stdout and stderr together. Defaults to 4096.
+	Bytes int `json:"bytes,omitempty"`
+	//Keep the captured output (e.g. a banner) out of the sinks.
+	Suppress bool `json:"suppress,omitempty"`
+}
+
+type capture struct {
+	mu       sync.Mutex
+	buf      []byte
+	size     int
+	suppress bool
+}
+
+func (p *Process) newCapture() *capture {
+	if p.Output == nil || p.Output.Startup == nil {
+		return nil
+	}
+	size := p.Output.Startup.Bytes
+	if size <= 0 {
+		size = 4096
+	}
+	return &capture{size: size, suppress: p.Output.Startup.Suppress}
+}
+
+//Capture a line, true if the sinks should not receive it. Only whole
+//lines are suppressed; the one crossing the limit is captured in part and
+//still written.
+func (c *capture) add(line []byte) bool {
+	if c == nil {
+		return false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	room := c.size - len(c.buf)
+	if room <= 0 {
+		return false
+	}
+	if len(line) > room {
+		c.buf = append(c.buf, line[:room]...)
+		return false
+	}
+	c.buf = append(c.buf, line...)
+	return c.suppress
+}
+
+func (c *capture) String() string {
+	if c == nil {
+		return ""
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return string(c.buf)
+}
+
+//Output captured since the last start, see Output.Startup.
+func (p *Process) StartupOutput() string {
+	p.mu.Lock()
+	c := p.capture
+	p.mu.Unlock()
+	return c.String()
+}
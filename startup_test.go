@@ -0,0 +1,43 @@
+// goforever - processes management
+// Copyright (c) 2013 Garrett Woodworth (https://github.com/gwoo).
+
+package process
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCapture(t *testing.T) {
+	p := &Process{Output: &Output{Startup: &Startup{Bytes: 6, Suppress: true}}}
+	c := p.newCapture()
+	if !c.add([]byte("ab\n")) {
+		t.Error("Expected the first line to be suppressed.")
+	}
+	if c.add([]byte("cdef\n")) || c.add([]byte("gh\n")) {
+		t.Error("Expected lines past the limit to be written.")
+	}
+	if ex, r := "ab\ncde", c.String(); r != ex {
+		t.Errorf("Expected %#v. Result %#v\n", ex, r)
+	}
+}
+
+func TestStartupOutput(t *testing.T) {
+	m := NewManager()
+	p := &Process{
+		Command: "/bin/sh",
+		Args:    []string{"-c", "echo listen: address already in use >&2; exit 1"},
+		Output:  &Output{Startup: &Startup{}, Sinks: []Sink{{Type: "ring"}}},
+	}
+	m.Add("web", p)
+	if r := p.Start("web"); r == "" {
+		t.Fatal("Expected start.")
+	}
+	<-p.exited
+	waitFor(t, func() bool {
+		return strings.Contains(p.Snapshot().StartupOutput, "address already in use")
+	})
+	if r := p.Tail("stderr", 0); len(r) != 1 {
+		t.Errorf("Expected the line in the sinks too. Result %#v\n", r)
+	}
+}
@@ -0,0 +1,106 @@
+// goforever - processes management
+// Copyright (c) 2013 Garrett Woodworth (https://github.com/gwoo).
+
+package process
+
+import (
+	"errors"
+	"time"
+)
+
+//Runtime state of a manager, namespaces included, for backups, migrations
+//between hosts and debugging, see Manager.Export. Definitions hold the
+//configured Env as is.
+type State struct {
+	Time      time.Time      `json:"time"`
+	Processes []ProcessState `json:"processes"`
+	Errors    []ErrorEntry   `json:"errors,omitempty"`
+}
+
+//One process of a State: its configuration, a snapshot and its recent exits.
+type ProcessState struct {
+	Namespace  string      `json:"namespace,omitempty"`
+	Definition *Process    `json:"definition"`
+	Info       ProcessInfo `json:"info"`
+	Exits      []Exit      `json:"exits,omitempty"`
+}
+
+//Export the definitions, status and history of every process.
+func (m *Manager) Export() *State {
+	s := &State{Time: m.clock().Now(), Processes: []ProcessState{}}
+	for _, n := range append([]*Manager{m}, m.spaceList()...) {
+		for _, name := range n.Keys() {
+			p := n.Get(name)
+			if p == nil {
+				continue
+			}
+			ps := ProcessState{Namespace: n.ns, Definition: p.clone(), Info: p.Snapshot()}
+			p.mu.Lock()
+			ps.Exits = append([]Exit(nil), p.exits...)
+			p.mu.Unlock()
+			s.Processes = append(s.Processes, ps)
+		}
+	}
+	m.mu.Lock()
+	s.Errors = append([]ErrorEntry(nil), m.errs...)
+	m.mu.Unlock()
+	return s
+}
+
+//Add the processes of an exported state, creating their namespaces, with
+//their history: exits, respawns and the fatal state and reason. They are
+//not started. Nothing is added if one of them is invalid or already exists.
+func (m *Manager) Import(s *State) error {
+	for _, ps := range s.Processes {
+		if ps.Definition == nil {
+			return errors.New("Process state without a definition.")
+		}
+		if err := ValidName(ps.Info.Name); err != nil {
+			return err
+		}
+		if n := m.space(ps.Namespace); n != nil && n.Exists(ps.Info.Name) {
+			return ErrDuplicateName
+		}
+	}
+	for _, ps := range s.Processes {
+		n := m
+		if ps.Namespace != "" {
+			var err error
+			if n, err = m.Namespace(ps.Namespace); err != nil {
+				return err
+			}
+		}
+		p := ps.Definition
+		if err := n.Add(ps.Info.Name, p); err != nil {
+			return err
+		}
+		p.mu.Lock()
+		p.Pid, p.Status = 0, ""
+		p.exits = append([]Exit(nil), ps.Exits...)
+		p.respawns = ps.Info.Respawns
+		if ps.Info.LastExit != nil {
+			exit := *ps.Info.LastExit
+			p.lastExit = &exit
+		}
+		if ps.Info.Status == "fatal" {
+			p.Status, p.reason = "fatal", ps.Info.Reason
+		}
+		p.mu.Unlock()
+	}
+	m.mu.Lock()
+	if m.errs = append(m.errs, s.Errors...); len(m.errs) > maxErrors {
+		m.errs = m.errs[len(m.errs)-maxErrors:]
+	}
+	m.mu.Unlock()
+	return nil
+}
+
+//Manager of namespace ns, m for "", nil if it does not exist yet.
+func (m *Manager) space(ns string) *Manager {
+	if ns == "" {
+		return m
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.spaces[ns]
+}
@@ -0,0 +1,50 @@
+// goforever - processes management
+// Copyright (c) 2013 Garrett Woodworth (https://github.com/gwoo).
+
+package process
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestExportImport(t *testing.T) {
+	r := NewFakeRunner()
+	m := NewManager()
+	m.Runner = r
+	m.Add("web", &Process{Command: "/usr/bin/web", Args: []string{"-v"}, Ping: "1h", Respawn: 1})
+	n, _ := m.Namespace("team")
+	n.Add("cron", &Process{Command: "/usr/bin/cron", Ping: "1h"})
+	web := m.Get("web")
+	RunProcess("web", web)
+	r.Running()[0].Exit(3)
+	waitFor(t, func() bool { return web.CurrentPid() == FakePid+1 })
+	m.recordError("web", "start failed", Fields{"error": "boom"})
+	data, err := json.Marshal(m.Export())
+	web.Stop()
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &State{}
+	if err := json.Unmarshal(data, s); err != nil {
+		t.Fatal(err)
+	}
+	to := NewManager()
+	if err := to.Import(s); err != nil {
+		t.Fatal(err)
+	}
+	p := to.Get("web")
+	info := p.Snapshot()
+	if p.Command != "/usr/bin/web" || len(p.Args) != 1 || info.Pid != 0 || info.Respawns != 1 || info.LastExit == nil || info.LastExit.Code != 3 {
+		t.Errorf("Unexpected import %#v\n", info)
+	}
+	if len(p.exits) != 1 || len(to.Health().Errors) != 1 {
+		t.Errorf("Expected the history imported. Result %#v %#v\n", p.exits, to.Health().Errors)
+	}
+	if c, err := to.Namespace("team"); err != nil || c.Get("cron") == nil {
+		t.Error("Expected team/cron imported.")
+	}
+	if err := to.Import(s); err != ErrDuplicateName {
+		t.Errorf("Expected %#v. Result %#v\n", ErrDuplicateName, err)
+	}
+}
@@ -0,0 +1,143 @@
+// goforever - processes management
+// Copyright (c) 2013 Garrett Woodworth (https://github.com/gwoo).
+
+package process
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+//StatsD agent the process metrics are pushed to, see Manager.EmitStatsD.
+type StatsD struct {
+	//host:port of the agent, default 127.0.0.1:8125.
+	Address string `json:"address,omitempty"`
+	//Prepended to the metric names, e.g. "goforever".
+	Prefix string `json:"prefix,omitempty"`
+	//Time between pushes of the gauges, default 10s.
+	Interval string `json:"interval,omitempty"`
+	//Send the process and namespace as DogStatsD tags instead of in the
+	//metric names.
+	Tags bool `json:"tags,omitempty"`
+}
+
+//Largest packet sent, fitting an Ethernet MTU.
+const statsdPacket = 1432
+
+type statsdClient struct {
+	s    *StatsD
+	conn net.Conn
+	mu   sync.Mutex
+	buf  bytes.Buffer
+}
+
+//Push the gauges up, uptime_seconds, respawns, cpu_seconds and
+//memory_bytes of every process each Interval, and count the restarts and
+//exits as they happen, until done is closed. Names are
+//<prefix>.<namespace>.<process>.<metric>, the namespace only for processes
+//in one, unless Tags is set.
+func (m *Manager) EmitStatsD(s *StatsD, done <-chan struct{}) error {
+	addr := s.Address
+	if addr == "" {
+		addr = "127.0.0.1:8125"
+	}
+	conn, err := net.Dial("udp", addr)
+	if err != nil {
+		return fmt.Errorf("statsd: %s", err)
+	}
+	c := &statsdClient{s: s, conn: conn}
+	m.OnEvent(func(e Event) {
+		switch e.Type {
+		case EventRestart:
+			c.send("restarts", e.Namespace, e.Process, 1, "c")
+		case EventExit:
+			c.send("exits", e.Namespace, e.Process, 1, "c")
+		default:
+			return
+		}
+		c.flush()
+	})
+	interval := durationOr(s.Interval, 10*time.Second)
+	go func() {
+		defer conn.Close()
+		for {
+			select {
+			case <-done:
+				return
+			case <-m.clock().After(interval):
+			}
+			c.gauges(m.Snapshot())
+		}
+	}()
+	return nil
+}
+
+//Send the gauges of infos.
+func (c *statsdClient) gauges(infos []ProcessInfo) {
+	for _, info := range infos {
+		up := 0.0
+		if info.Pid > 0 {
+			up = 1
+		}
+		c.send("up", info.Namespace, info.Name, up, "g")
+		c.send("uptime_seconds", info.Namespace, info.Name, info.Uptime.Seconds(), "g")
+		c.send("respawns", info.Namespace, info.Name, float64(info.Respawns), "g")
+		c.send("cpu_seconds", info.Namespace, info.Name, info.CPUTime.Seconds(), "g")
+		c.send("memory_bytes", info.Namespace, info.Name, float64(info.Memory), "g")
+	}
+	c.flush()
+}
+
+//Queue a metric, sending the queued ones first when the packet is full.
+func (c *statsdClient) send(metric, ns, name string, value float64, typ string) {
+	var line string
+	if c.s.Tags {
+		tags := "process:" + name
+		if ns != "" {
+			tags += ",namespace:" + ns
+		}
+		line = fmt.Sprintf("%s:%g|%s|#%s", statsdName(c.s.Prefix, metric), value, typ, tags)
+	} else {
+		line = fmt.Sprintf("%s:%g|%s", statsdName(c.s.Prefix, ns, name, metric), value, typ)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.buf.Len() > 0 && c.buf.Len()+1+len(line) > statsdPacket {
+		c.flushLocked()
+	}
+	if c.buf.Len() > 0 {
+		c.buf.WriteByte('\n')
+	}
+	c.buf.WriteString(line)
+}
+
+func (c *statsdClient) flush() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.flushLocked()
+}
+
+func (c *statsdClient) flushLocked() {
+	if c.buf.Len() == 0 {
+		return
+	}
+	//Lost metrics are not worth more than a retry at the next interval.
+	c.conn.Write(c.buf.Bytes())
+	c.buf.Reset()
+}
+
+//Dot separated name of the non-empty parts, dots within them replaced so
+//they do not add levels.
+func statsdName(parts ...string) string {
+	var names []string
+	for _, part := range parts {
+		if part != "" {
+			names = append(names, strings.NewReplacer(".", "_", ":", "_", "|", "_").Replace(part))
+		}
+	}
+	return strings.Join(names, ".")
+}
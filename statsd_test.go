@@ -0,0 +1,73 @@
+// goforever - processes management
+// Copyright (c) 2013 Garrett Woodworth (https://github.com/gwoo).
+
+package process
+
+import (
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func listenStatsD(t *testing.T) (net.PacketConn, func() string) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	read := func() string {
+		buf := make([]byte, statsdPacket)
+		conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(buf[:n])
+	}
+	return conn, read
+}
+
+func TestStatsD(t *testing.T) {
+	conn, read := listenStatsD(t)
+	defer conn.Close()
+	clock := NewFakeClock(time.Now())
+	m := NewManager()
+	m.Clock = clock
+	r := NewFakeRunner()
+	p := &Process{Command: "web", Runner: r, Ping: "1h", Respawn: 1}
+	m.Add("web.1", p)
+	done := make(chan struct{})
+	defer close(done)
+	if err := m.EmitStatsD(&StatsD{Address: conn.LocalAddr().String(), Prefix: "gf", Interval: "10s"}, done); err != nil {
+		t.Fatal(err)
+	}
+	RunProcess("web.1", p)
+	clock.BlockUntil(2)
+	clock.Advance(10 * time.Second)
+	lines := strings.Split(read(), "\n")
+	ex := []string{"gf.web_1.up:1|g", "gf.web_1.uptime_seconds:10|g", "gf.web_1.respawns:0|g"}
+	if len(lines) != 5 {
+		t.Fatalf("Expected 5 gauges. Result %#v\n", lines)
+	}
+	for i := range ex {
+		if lines[i] != ex[i] {
+			t.Errorf("Expected %#v. Result %#v\n", ex[i], lines[i])
+		}
+	}
+	r.Running()[0].Exit(1)
+	if packet := read(); packet != "gf.web_1.exits:1|c" {
+		t.Errorf("Expected %#v. Result %#v\n", "gf.web_1.exits:1|c", packet)
+	}
+	p.Stop()
+}
+
+func TestStatsDTags(t *testing.T) {
+	conn, read := listenStatsD(t)
+	defer conn.Close()
+	m := NewManager()
+	m.EmitStatsD(&StatsD{Address: conn.LocalAddr().String(), Prefix: "gf", Tags: true}, nil)
+	m.emit(Event{Process: "web", Type: EventRestart, Namespace: "team-a"})
+	if packet := read(); packet != "gf.restarts:1|c|#process:web,namespace:team-a" {
+		t.Errorf("Expected %#v. Result %#v\n", "gf.restarts:1|c|#process:web,namespace:team-a", packet)
+	}
+}
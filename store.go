@@ -0,0 +1,140 @@
+// goforever - processes management
+// Copyright (c) 2013 Garrett Woodworth (https://github.com/gwoo).
+
+package process
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+//Persistent process definitions, events and exit history, kept across
+//supervisor restarts, see Manager.Store. FileStore is the built-in one;
+//others, such as SQLite or bbolt databases, implement the same methods.
+type Store interface {
+	//Record an event.
+	AddEvent(e Event) error
+	//Recorded events matching f, oldest first.
+	Events(f EventFilter) ([]Event, error)
+	//Replace the saved state, see Manager.Export.
+	SaveState(s *State) error
+	//State last saved, nil if there is none.
+	LoadState() (*State, error)
+}
+
+//Store in a directory, readable without the supervisor: events.log holds
+//the events as JSON lines and state.json the last state.
+type FileStore struct {
+	Dir string
+	mu  sync.Mutex
+}
+
+//Open the FileStore in dir, creating the directory if needed.
+func NewFileStore(dir string) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &FileStore{Dir: dir}, nil
+}
+
+func (s *FileStore) AddEvent(e Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return appendEvent(filepath.Join(s.Dir, "events.log"), e)
+}
+
+func (s *FileStore) Events(f EventFilter) ([]Event, error) {
+	s.mu.Lock()
+	events, err := readEvents(filepath.Join(s.Dir, "events.log"))
+	s.mu.Unlock()
+	if os.IsNotExist(err) {
+		err = nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return filterEvents(events, f), nil
+}
+
+//Write state.json through a temporary file, so that a crash leaves the
+//previous state.
+func (s *FileStore) SaveState(st *State) error {
+	data, err := json.MarshalIndent(st, "", "  ")
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	tmp := filepath.Join(s.Dir, ".state.json.tmp")
+	if err := ioutil.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, filepath.Join(s.Dir, "state.json"))
+}
+
+func (s *FileStore) LoadState() (*State, error) {
+	s.mu.Lock()
+	data, err := ioutil.ReadFile(filepath.Join(s.Dir, "state.json"))
+	s.mu.Unlock()
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	st := &State{}
+	if err := json.Unmarshal(data, st); err != nil {
+		return nil, err
+	}
+	return st, nil
+}
+
+//Save the state to the Store, if any, logging failures. Saves run one at a
+//time.
+func (m *Manager) saveState() {
+	if m.Store == nil {
+		return
+	}
+	m.saving.Lock()
+	defer m.saving.Unlock()
+	if err := m.Store.SaveState(m.Export()); err != nil {
+		m.log(LevelError, "saving state failed", Fields{"error": err})
+	}
+}
+
+//Give the configured processes the exit history saved in the Store by a
+//previous run, and the fatal state of those that were fatal. Call before
+//Run. Processes no longer configured are left out.
+func (m *Manager) Restore() error {
+	if m.Store == nil {
+		return nil
+	}
+	s, err := m.Store.LoadState()
+	if err != nil || s == nil {
+		return err
+	}
+	for _, ps := range s.Processes {
+		n := m.space(ps.Namespace)
+		if n == nil {
+			continue
+		}
+		p := n.Get(ps.Info.Name)
+		if p == nil {
+			continue
+		}
+		p.mu.Lock()
+		p.exits = append([]Exit(nil), ps.Exits...)
+		if ps.Info.LastExit != nil {
+			exit := *ps.Info.LastExit
+			p.lastExit = &exit
+		}
+		if ps.Info.Status == "fatal" {
+			p.Status, p.reason = "fatal", ps.Info.Reason
+		}
+		p.mu.Unlock()
+	}
+	return nil
+}
@@ -0,0 +1,52 @@
+// goforever - processes management
+// Copyright (c) 2013 Garrett Woodworth (https://github.com/gwoo).
+
+package process
+
+import (
+	"context"
+	"testing"
+)
+
+func TestFileStore(t *testing.T) {
+	s, err := NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st, err := s.LoadState(); st != nil || err != nil {
+		t.Errorf("Expected no state. Result %#v %v\n", st, err)
+	}
+	if events, err := s.Events(EventFilter{}); len(events) != 0 || err != nil {
+		t.Errorf("Expected no events. Result %#v %v\n", events, err)
+	}
+	r := NewFakeRunner()
+	m := NewManager()
+	m.Store = s
+	p := &Process{Command: "web", Runner: r, Ping: "1h", Respawn: 1}
+	m.Add("web", p)
+	RunProcess("web", p)
+	m.emit(Event{Process: "web", Type: EventStart})
+	r.Running()[0].Exit(3)
+	waitFor(t, func() bool { return len(r.Running()) == 1 && p.Snapshot().LastExit != nil })
+	m.Shutdown(context.Background())
+
+	//A new supervisor with the same store.
+	m = NewManager()
+	m.Store = s
+	p = &Process{Command: "web", Runner: r, Ping: "1h"}
+	m.Add("web", p)
+	if err := m.Restore(); err != nil {
+		t.Fatal(err)
+	}
+	if exit := p.Snapshot().LastExit; exit == nil || exit.Code != 3 {
+		t.Errorf("Expected the restored exit. Result %#v\n", exit)
+	}
+	events, err := m.Events(EventFilter{Process: "web", Type: EventExit})
+	if err != nil || len(events) != 1 || events[0].Reason != "exited with code 3" {
+		t.Errorf("Expected the stored exit event. Result %#v %v\n", events, err)
+	}
+	st, _ := s.LoadState()
+	if st == nil || len(st.Processes) != 1 || st.Processes[0].Definition.Command != "web" {
+		t.Errorf("Expected the stored definition. Result %#v\n", st)
+	}
+}
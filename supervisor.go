@@ -0,0 +1,84 @@
+// goforever - processes management
+// Copyright (c) 2013 Garrett Woodworth (https://github.com/gwoo).
+
+package process
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+//Errors kept for Manager.Health.
+const maxErrors = 20
+
+//Error logged by a process, kept for Manager.Health.
+type ErrorEntry struct {
+	Time    time.Time `json:"time"`
+	Process string    `json:"process"`
+	Message string    `json:"message"`
+	Error   string    `json:"error,omitempty"`
+}
+
+//State of the supervisor itself.
+type SupervisorHealth struct {
+	//ok, degraded while some process is fatal, or stopping.
+	Status    string         `json:"status"`
+	Uptime    string         `json:"uptime"`
+	Processes int            `json:"processes"`
+	ByStatus  map[string]int `json:"by_status"`
+	Errors    []ErrorEntry   `json:"errors,omitempty"`
+}
+
+//Record an error logged by a process.
+func (m *Manager) recordError(process, msg string, fields Fields) {
+	e := ErrorEntry{Time: m.clock().Now(), Process: process, Message: msg}
+	if err, ok := fields["error"]; ok {
+		e.Error = fmt.Sprint(err)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.errs = append(m.errs, e)
+	if len(m.errs) > maxErrors {
+		m.errs = m.errs[len(m.errs)-maxErrors:]
+	}
+}
+
+//Report the supervisor state: processes by status and the latest errors.
+func (m *Manager) Health() SupervisorHealth {
+	h := SupervisorHealth{Status: "ok", ByStatus: map[string]int{}}
+	for _, s := range m.Snapshot() {
+		status := s.Status
+		if status == "" {
+			status = "stopped"
+		}
+		h.ByStatus[status]++
+		h.Processes++
+		if status == "fatal" {
+			h.Status = "degraded"
+		}
+	}
+	m.mu.Lock()
+	if m.closing {
+		h.Status = "stopping"
+	}
+	h.Uptime = time.Since(m.started).Round(time.Second).String()
+	h.Errors = append([]ErrorEntry(nil), m.errs...)
+	m.mu.Unlock()
+	return h
+}
+
+//Handler answering GET /healthz with Manager.Health as JSON, for container
+//health checks or mounting in a larger service. The status code is 503
+//once the manager is stopping.
+func (m *Manager) HealthHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h := m.Health()
+		w.Header().Set("Content-Type", "application/json")
+		if h.Status == "stopping" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		json.NewEncoder(w).Encode(h)
+	})
+}
@@ -0,0 +1,35 @@
+// goforever - processes management
+// Copyright (c) 2013 Garrett Woodworth (https://github.com/gwoo).
+
+package process
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSupervisorHealth(t *testing.T) {
+	m := NewManager()
+	m.Logger = &recordLogger{}
+	m.Add("web", &Process{Pid: 1, Status: "running"})
+	bad := &Process{Status: "fatal"}
+	m.Add("worker", bad)
+	m.Add("idle", &Process{})
+	for i := 0; i < maxErrors+1; i++ {
+		bad.log(LevelError, "start failed", Fields{"error": errors.New("boom")})
+	}
+	rec := httptest.NewRecorder()
+	m.HealthHandler().ServeHTTP(rec, httptest.NewRequest("GET", "/healthz", nil))
+	var h SupervisorHealth
+	if err := json.Unmarshal(rec.Body.Bytes(), &h); err != nil {
+		t.Fatal(err)
+	}
+	if h.Status != "degraded" || h.Processes != 3 || h.ByStatus["running"] != 1 || h.ByStatus["fatal"] != 1 || h.ByStatus["stopped"] != 1 {
+		t.Errorf("Unexpected health %#v\n", h)
+	}
+	if len(h.Errors) != maxErrors || h.Errors[0].Process != "worker" || h.Errors[0].Error != "boom" {
+		t.Errorf("Expected %d recent errors. Result %#v\n", maxErrors, h.Errors)
+	}
+}
@@ -0,0 +1,15 @@
+// goforever - processes management
+// Copyright (c) 2013 Garrett Woodworth (https://github.com/gwoo).
+
+//go:build windows || plan9
+
+package process
+
+import (
+	"errors"
+	"io"
+)
+
+func newSyslogSink(network, address, tag, stream string) (io.WriteCloser, error) {
+	return nil, errors.New("Syslog is not supported on this platform.")
+}
@@ -0,0 +1,20 @@
+// goforever - processes management
+// Copyright (c) 2013 Garrett Woodworth (https://github.com/gwoo).
+
+//go:build !windows && !plan9
+
+package process
+
+import (
+	"io"
+	"log/syslog"
+)
+
+//Syslog writer logging stdout at info and stderr at err priority.
+func newSyslogSink(network, address, tag, stream string) (io.WriteCloser, error) {
+	priority := syslog.LOG_INFO | syslog.LOG_DAEMON
+	if stream == "stderr" {
+		priority = syslog.LOG_ERR | syslog.LOG_DAEMON
+	}
+	return syslog.Dial(network, address, priority, tag)
+}
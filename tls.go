@@ -0,0 +1,46 @@
+// goforever - processes management
+// Copyright (c) 2013 Garrett Woodworth (https://github.com/gwoo).
+
+package process
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io/ioutil"
+)
+
+//TLS for the control API. With ClientCA set clients must present a
+//certificate signed by it (mTLS).
+type TLS struct {
+	//PEM certificate chain and key files.
+	Cert string `json:"cert"`
+	Key  string `json:"key"`
+	//PEM file of the CAs client certificates are verified against.
+	ClientCA string `json:"client_ca,omitempty"`
+}
+
+//Server configuration for t, requiring TLS 1.2 or later.
+func (t *TLS) Config() (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(t.Cert, t.Key)
+	if err != nil {
+		return nil, err
+	}
+	c := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+	if t.ClientCA != "" {
+		data, err := ioutil.ReadFile(t.ClientCA)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(data) {
+			return nil, errors.New("No certificates in client CA file.")
+		}
+		c.ClientCAs = pool
+		c.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return c, nil
+}
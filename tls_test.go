@@ -0,0 +1,99 @@
+// goforever - processes management
+// Copyright (c) 2013 Garrett Woodworth (https://github.com/gwoo).
+
+package process
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+//Write a certificate and key for 127.0.0.1, signed by parent (self-signed
+//when nil), returning the file paths.
+func writeCert(t *testing.T, dir, name string, parent *tls.Certificate) (string, string, tls.Certificate) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  parent == nil,
+	}
+	signer, signKey := tmpl, interface{}(key)
+	if parent != nil {
+		signer, signKey = parent.Leaf, parent.PrivateKey
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, signer, &key.PublicKey, signKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDer, _ := x509.MarshalECPrivateKey(key)
+	certPath := filepath.Join(dir, name+".crt")
+	keyPath := filepath.Join(dir, name+".key")
+	ioutil.WriteFile(certPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600)
+	ioutil.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0600)
+	leaf, _ := x509.ParseCertificate(der)
+	return certPath, keyPath, tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf}
+}
+
+func TestTLSClientCA(t *testing.T) {
+	dir := t.TempDir()
+	caPath, _, ca := writeCert(t, dir, "ca", nil)
+	certPath, keyPath, _ := writeCert(t, dir, "server", &ca)
+	_, _, client := writeCert(t, dir, "client", &ca)
+	c, err := (&TLS{Cert: certPath, Key: keyPath, ClientCA: caPath}).Config()
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewUnstartedServer(NewManager().API(nil))
+	srv.TLS = c
+	srv.StartTLS()
+	defer srv.Close()
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.Leaf)
+	get := func(certs ...tls.Certificate) error {
+		cl := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: roots, Certificates: certs}}}
+		resp, err := cl.Get(srv.URL + "/healthz")
+		if err == nil {
+			resp.Body.Close()
+		}
+		return err
+	}
+	if err := get(client); err != nil {
+		t.Errorf("Expected %#v. Result %#v\n", nil, err.Error())
+	}
+	if err := get(); err == nil {
+		t.Errorf("Expected an error without a client certificate.\n")
+	}
+}
+
+func TestTLSConfigErrors(t *testing.T) {
+	dir := t.TempDir()
+	certPath, keyPath, _ := writeCert(t, dir, "server", nil)
+	if _, err := (&TLS{Cert: certPath, Key: filepath.Join(dir, "missing")}).Config(); err == nil {
+		t.Errorf("Expected an error for a missing key.\n")
+	}
+	if _, err := (&TLS{Cert: certPath, Key: keyPath, ClientCA: keyPath}).Config(); err == nil {
+		t.Errorf("Expected an error for a client CA without certificates.\n")
+	}
+}
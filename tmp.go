@@ -0,0 +1,52 @@
+// goforever - processes management
+// Copyright (c) 2013 Garrett Woodworth (https://github.com/gwoo).
+
+package process
+
+import (
+	"io/ioutil"
+	"os"
+)
+
+//Create the private temporary directory of a start, see PrivateTmp, and
+//return env with TMPDIR naming it. A directory left by an earlier start is
+//removed first.
+func (p *Process) makeTmp(env []string) ([]string, error) {
+	if !p.PrivateTmp {
+		return env, nil
+	}
+	p.removeTmp()
+	dir, err := ioutil.TempDir("", "process-"+p.Name+"-")
+	if err != nil {
+		return nil, err
+	}
+	p.mu.Lock()
+	p.tmpDir = dir
+	p.mu.Unlock()
+	return mergeEnv(env, []string{"TMPDIR=" + dir}), nil
+}
+
+//Remove the private temporary directory, if any.
+func (p *Process) removeTmp() {
+	p.mu.Lock()
+	err := p.removeTmpLocked()
+	p.mu.Unlock()
+	p.logTmp(err)
+}
+
+//See removeTmp. Called with p.mu held; the error is logged by logTmp once
+//it is released.
+func (p *Process) removeTmpLocked() error {
+	if p.tmpDir == "" {
+		return nil
+	}
+	err := os.RemoveAll(p.tmpDir)
+	p.tmpDir = ""
+	return err
+}
+
+func (p *Process) logTmp(err error) {
+	if err != nil {
+		p.log(LevelWarn, "private tmp not removed", Fields{"error": err})
+	}
+}
@@ -0,0 +1,38 @@
+// goforever - processes management
+// Copyright (c) 2013 Garrett Woodworth (https://github.com/gwoo).
+
+package process
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestPrivateTmp(t *testing.T) {
+	r := NewFakeRunner()
+	p := &Process{Command: "web", Runner: r, Ping: "1h", Respawn: 1, PrivateTmp: true, ChdirTmp: true}
+	RunProcess("web", p)
+	first := r.Running()[0]
+	dir := first.Cmd.Dir
+	if st, err := os.Stat(dir); err != nil || !st.IsDir() || !strings.Contains(dir, "process-web-") {
+		t.Fatalf("Expected a private tmp. Result %q %v\n", dir, err)
+	}
+	found := false
+	for _, kv := range first.Cmd.Env {
+		found = found || kv == "TMPDIR="+dir
+	}
+	if !found {
+		t.Errorf("Expected TMPDIR=%s in the environment.\n", dir)
+	}
+	first.Exit(1)
+	waitFor(t, func() bool { return len(r.Running()) == 1 && r.Running()[0] != first })
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		t.Errorf("Expected %s removed on respawn. Result %v\n", dir, err)
+	}
+	next := r.Running()[0].Cmd.Dir
+	p.Stop()
+	if _, err := os.Stat(next); !os.IsNotExist(err) {
+		t.Errorf("Expected %s removed on stop. Result %v\n", next, err)
+	}
+}
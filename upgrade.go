@@ -0,0 +1,160 @@
+// goforever - processes management
+// Copyright (c) 2013 Garrett Woodworth (https://github.com/gwoo).
+
+package process
+
+import (
+	"encoding/json"
+	"net"
+	"os"
+	"sync"
+	"time"
+)
+
+//Environment variable carrying the state handed to an upgraded supervisor.
+const upgradeEnv = "PROCESS_UPGRADE_STATE"
+
+//State handed over by Manager.Upgrade.
+type handoff struct {
+	Processes map[string]handoffProcess `json:"processes"`
+	//Inherited listening sockets by address.
+	Listeners map[string]int `json:"listeners,omitempty"`
+}
+
+type handoffProcess struct {
+	Pid      int       `json:"pid"`
+	Started  time.Time `json:"started"`
+	Respawns int       `json:"respawns"`
+	//Read ends of piped stdout and stderr, 0 for none.
+	Pipes [2]int `json:"pipes"`
+	//Not a child of the supervisor, see Process.Find.
+	Adopted bool `json:"adopted,omitempty"`
+}
+
+var (
+	inheritOnce sync.Once
+	inheritMu   sync.Mutex
+	inherited   *handoff
+)
+
+//State from the previous supervisor, if this one was started by Upgrade.
+//Read once and removed from the environment so children do not see it.
+func inheritedState() *handoff {
+	inheritOnce.Do(func() {
+		s := os.Getenv(upgradeEnv)
+		if s == "" {
+			return
+		}
+		os.Unsetenv(upgradeEnv)
+		h := &handoff{}
+		if json.Unmarshal([]byte(s), h) == nil {
+			inherited = h
+		}
+	})
+	return inherited
+}
+
+//Listen on addr, reusing the socket handed over by Manager.Upgrade when
+//there is one, so that clients are not refused during an upgrade.
+func Listen(network, addr string) (net.Listener, error) {
+	if h := inheritedState(); h != nil {
+		if l := h.listener(addr); l != nil {
+			return l, nil
+		}
+	}
+	return net.Listen(network, addr)
+}
+
+//Take the inherited listener for addr, nil if there is none.
+func (h *handoff) listener(addr string) net.Listener {
+	inheritMu.Lock()
+	fd, ok := h.Listeners[addr]
+	delete(h.Listeners, addr)
+	inheritMu.Unlock()
+	if !ok {
+		return nil
+	}
+	f := os.NewFile(uintptr(fd), addr)
+	defer f.Close()
+	l, err := net.FileListener(f)
+	if err != nil {
+		return nil
+	}
+	return l
+}
+
+//Take over the children of the supervisor this one replaced through
+//Upgrade. Call before Run, which then watches them instead of starting
+//them. Does nothing when not started by Upgrade.
+func (m *Manager) Resume() error {
+	h := inheritedState()
+	if h == nil {
+		return nil
+	}
+	return m.resume(h)
+}
+
+func (m *Manager) resume(h *handoff) error {
+	for name, hp := range h.Processes {
+		p := m.Get(name)
+		if p == nil {
+			m.log(LevelWarn, "unknown process handed over", Fields{"process": name, "pid": hp.Pid})
+			continue
+		}
+		if err := p.resume(hp); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//Watch the child handed over, reading its piped output again. A process
+//the previous supervisor adopted is not a child and is polled instead.
+func (p *Process) resume(hp handoffProcess) error {
+	x, err := os.FindProcess(hp.Pid)
+	if err != nil {
+		return err
+	}
+	h := &execHandle{x}
+	exited := make(chan struct{})
+	p.mu.Lock()
+	p.x = h
+	p.exited = exited
+	p.Pid = hp.Pid
+	p.started = hp.Started
+	p.respawns = hp.Respawns
+	p.srcHash = p.sourcesHash()
+	p.adopted = hp.Adopted
+	p.Status = "running"
+	p.mu.Unlock()
+	if hp.Adopted {
+		p.watchAdopted(hp.Pid, exited)
+	} else {
+		go func() {
+			state, err := h.Wait()
+			p.mu.Lock()
+			p.state, p.waitErr = state, err
+			p.mu.Unlock()
+			close(exited)
+		}()
+	}
+	if p.Output != nil {
+		env, _ := p.environ()
+		red, err := p.redactor(env)
+		if err != nil {
+			return err
+		}
+		for i, path := range []string{p.Logfile, p.Errfile} {
+			if fd := hp.Pipes[i]; fd > 0 {
+				closeOnExec(fd)
+				r := os.NewFile(uintptr(fd), streamNames[i])
+				p.mu.Lock()
+				p.pipes[i] = r
+				p.mu.Unlock()
+				p.readOutput(i, r, path, red)
+			}
+		}
+	}
+	p.log(LevelInfo, "resumed", nil)
+	return nil
+}
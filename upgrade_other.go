@@ -0,0 +1,17 @@
+// goforever - processes management
+// Copyright (c) 2013 Garrett Woodworth (https://github.com/gwoo).
+
+//go:build !unix
+
+package process
+
+import (
+	"errors"
+	"net"
+)
+
+func closeOnExec(fd int) {}
+
+func (m *Manager) Upgrade(path string, listeners ...net.Listener) error {
+	return errors.New("Upgrade is not supported on this platform.")
+}
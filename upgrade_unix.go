@@ -0,0 +1,132 @@
+// goforever - processes management
+// Copyright (c) 2013 Garrett Woodworth (https://github.com/gwoo).
+
+//go:build unix
+
+package process
+
+import (
+	"encoding/json"
+	"net"
+	"os"
+	"strings"
+	"syscall"
+)
+
+//Replace the supervisor with the binary at path, re-executed in place with
+//the same pid so the children stay its own and keep running. The new
+//binary must call Resume before Run to take them over, and Listen to get
+//the listeners passed here. Output lines read but not yet written when the
+//exec happens are lost. Only returns on failure.
+func (m *Manager) Upgrade(path string, listeners ...net.Listener) error {
+	m.mu.Lock()
+	m.closing = true
+	m.mu.Unlock()
+	h, err := m.handoff(listeners)
+	if err == nil {
+		var state []byte
+		state, err = json.Marshal(h)
+		if err == nil {
+			env := []string{upgradeEnv + "=" + string(state)}
+			for _, kv := range os.Environ() {
+				if !strings.HasPrefix(kv, upgradeEnv+"=") {
+					env = append(env, kv)
+				}
+			}
+			err = syscall.Exec(path, os.Args, env)
+		}
+		h.close()
+	}
+	m.mu.Lock()
+	m.closing = false
+	m.mu.Unlock()
+	return err
+}
+
+func closeOnExec(fd int) {
+	syscall.CloseOnExec(fd)
+}
+
+//Inheritable copy of the descriptor of f, taken without Fd so that a
+//stream closing f meanwhile is not raced.
+func dupFile(f *os.File) (int, error) {
+	c, err := f.SyscallConn()
+	if err != nil {
+		return 0, err
+	}
+	var fd int
+	var dupErr error
+	if err := c.Control(func(s uintptr) { fd, dupErr = syscall.Dup(int(s)) }); err != nil {
+		return 0, err
+	}
+	return fd, dupErr
+}
+
+//Close the inheritable descriptors of a handoff that did not happen, so
+//that later children do not inherit them.
+func (h *handoff) close() {
+	for _, hp := range h.Processes {
+		hp.close()
+	}
+	for _, fd := range h.Listeners {
+		syscall.Close(fd)
+	}
+}
+
+func (hp handoffProcess) close() {
+	for _, fd := range hp.Pipes {
+		if fd > 0 {
+			syscall.Close(fd)
+		}
+	}
+}
+
+//Collect the running children and inheritable copies of the pipe and
+//listener descriptors.
+func (m *Manager) handoff(listeners []net.Listener) (*handoff, error) {
+	h := &handoff{Processes: map[string]handoffProcess{}, Listeners: map[string]int{}}
+	for _, name := range m.Keys() {
+		p := m.Get(name)
+		p.mu.Lock()
+		hp := handoffProcess{Pid: p.Pid, Started: p.started, Respawns: p.respawns, Adopted: p.adopted}
+		pipes := p.pipes
+		running := p.x != nil && p.Pid > 0
+		p.mu.Unlock()
+		if !running {
+			continue
+		}
+		for i, r := range pipes {
+			if r == nil {
+				continue
+			}
+			fd, err := dupFile(r)
+			if err != nil {
+				hp.close()
+				h.close()
+				return nil, err
+			}
+			hp.Pipes[i] = fd
+		}
+		h.Processes[name] = hp
+	}
+	for _, l := range listeners {
+		fl, ok := l.(interface{ File() (*os.File, error) })
+		if !ok {
+			continue
+		}
+		f, err := fl.File()
+		if err != nil {
+			h.close()
+			return nil, err
+		}
+		//Dup drops close-on-exec.
+		fd, err := syscall.Dup(int(f.Fd()))
+		f.Close()
+		if err != nil {
+			h.close()
+			return nil, err
+		}
+		h.Listeners[l.Addr().String()] = fd
+	}
+	return h, nil
+}
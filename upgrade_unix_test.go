@@ -0,0 +1,150 @@
+// goforever - processes management
+// Copyright (c) 2013 Garrett Woodworth (https://github.com/gwoo).
+
+//go:build unix
+
+package process
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestUpgradeHandoff(t *testing.T) {
+	newProcess := func() *Process {
+		return &Process{
+			Command: "/bin/sh",
+			Args:    []string{"-c", "sleep 0.3; echo after; exec sleep 10"},
+			Ping:    "1h",
+			Output:  &Output{Sinks: []Sink{{Type: "ring"}}},
+		}
+	}
+	m := NewManager()
+	m.Add("echo", newProcess())
+	m.Run()
+	pid := m.Get("echo").Snapshot().Pid
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	h, err := m.handoff([]net.Listener{l})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hp := h.Processes["echo"]; hp.Pid != pid || hp.Pipes[0] <= 0 {
+		t.Fatalf("Expected pid %d with its stdout pipe. Result %#v\n", pid, hp)
+	}
+
+	//The old supervisor's copies go away with the exec.
+	old := m.Get("echo")
+	old.mu.Lock()
+	old.Status = "detached"
+	old.pipes[0].Close()
+	old.pipes[1].Close()
+	old.mu.Unlock()
+
+	next := NewManager()
+	p := newProcess()
+	next.Add("echo", p)
+	if err := next.resume(h); err != nil {
+		t.Fatal(err)
+	}
+	next.Run()
+	if s := p.Snapshot(); s.Pid != pid || s.Status != "running" {
+		t.Errorf("Expected pid %d resumed. Result %#v\n", pid, s)
+	}
+	if il := h.listener(l.Addr().String()); il == nil || il.Addr().String() != l.Addr().String() {
+		t.Errorf("Expected the listener on %s. Result %#v\n", l.Addr(), il)
+	} else {
+		il.Close()
+	}
+	if h.listener(l.Addr().String()) != nil {
+		t.Error("Expected the listener to be taken once.")
+	}
+	waitFor(t, func() bool { return strings.Join(p.Tail("stdout", 0), "") == "after" })
+	p.Stop()
+}
+
+func TestUpgradeHandoffAdopted(t *testing.T) {
+	//Started by a shell that exits, so not a child of the supervisor.
+	out, err := exec.Command("/bin/sh", "-c", "sleep 10 >/dev/null 2>&1 & echo $!").Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pid, _ := strconv.Atoi(strings.TrimSpace(string(out)))
+	defer syscall.Kill(pid, syscall.SIGKILL)
+	pidfile := filepath.Join(t.TempDir(), "daemon.pid")
+	ioutil.WriteFile(pidfile, []byte(strconv.Itoa(pid)), 0644)
+	newProcess := func() *Process {
+		return &Process{Command: "/bin/sleep", Pidfile: Pidfile(pidfile), Ping: "1h"}
+	}
+	m := NewManager()
+	m.Add("daemon", newProcess())
+	m.Run()
+	h, err := m.handoff(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hp := h.Processes["daemon"]; hp.Pid != pid || !hp.Adopted {
+		t.Fatalf("Expected pid %d handed over as adopted. Result %#v\n", pid, hp)
+	}
+	old := m.Get("daemon")
+	old.mu.Lock()
+	old.Status = "detached"
+	old.cancelPing()
+	old.mu.Unlock()
+
+	next := NewManager()
+	p := newProcess()
+	next.Add("daemon", p)
+	if err := next.resume(h); err != nil {
+		t.Fatal(err)
+	}
+	next.Run()
+	time.Sleep(200 * time.Millisecond)
+	if s := p.Snapshot(); s.Pid != pid || s.Status != "running" {
+		t.Errorf("Expected pid %d still running. Result %#v\n", pid, s)
+	}
+	if _, err := os.Stat(pidfile); err != nil {
+		t.Errorf("Expected the pidfile kept. Result %v\n", err)
+	}
+	p.Stop()
+	waitFor(t, func() bool { return !alive(pid) })
+}
+
+func TestUpgradeFailureClosesDescriptors(t *testing.T) {
+	m := NewManager()
+	m.Add("echo", &Process{
+		Command: "/bin/sh",
+		Args:    []string{"-c", "exec sleep 10"},
+		Ping:    "1h",
+		Output:  &Output{Sinks: []Sink{{Type: "ring"}}},
+	})
+	m.Run()
+	defer m.Get("echo").Stop()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	fds := func() int {
+		names, _ := ioutil.ReadDir("/dev/fd")
+		return len(names)
+	}
+	before := fds()
+	if err := m.Upgrade("/nonexistent", l); err == nil {
+		t.Fatal("Expected the exec to fail.")
+	}
+	if after := fds(); after != before {
+		t.Errorf("Expected %d descriptors. Result %d\n", before, after)
+	}
+}
@@ -0,0 +1,38 @@
+// goforever - processes management
+// Copyright (c) 2013 Garrett Woodworth (https://github.com/gwoo).
+
+package process
+
+import (
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//Clock ticks per second of /proc times, fixed at 100 on Linux.
+const userHz = 100
+
+//CPU time and resident memory of pid, read from /proc.
+func usage(pid int) (time.Duration, uint64, bool) {
+	data, err := ioutil.ReadFile("/proc/" + strconv.Itoa(pid) + "/stat")
+	if err != nil {
+		return 0, 0, false
+	}
+	//The command may hold spaces and parentheses, the fields follow the
+	//last ")", starting with the state (field 3).
+	i := strings.LastIndexByte(string(data), ')')
+	if i < 0 {
+		return 0, 0, false
+	}
+	fields := strings.Fields(string(data[i+1:]))
+	if len(fields) < 22 {
+		return 0, 0, false
+	}
+	utime, _ := strconv.ParseUint(fields[11], 10, 64)
+	stime, _ := strconv.ParseUint(fields[12], 10, 64)
+	rss, _ := strconv.ParseUint(fields[21], 10, 64)
+	cpu := time.Duration(utime+stime) * time.Second / userHz
+	return cpu, rss * uint64(os.Getpagesize()), true
+}
@@ -0,0 +1,15 @@
+// goforever - processes management
+// Copyright (c) 2013 Garrett Woodworth (https://github.com/gwoo).
+
+//go:build !linux
+
+package process
+
+import (
+	"time"
+)
+
+//Resource usage is only read on Linux.
+func usage(pid int) (time.Duration, uint64, bool) {
+	return 0, 0, false
+}
@@ -0,0 +1,108 @@
+// goforever - processes management
+// Copyright (c) 2013 Garrett Woodworth (https://github.com/gwoo).
+
+package process
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+//Emitted when the binary fails ExpectedSHA256 or the Verify hook.
+const EventVerify = "verify_failed"
+
+//Whether Command names a file on this host, which it does not for Shell
+//processes, containers and the registered runners other than exec.
+func (p *Process) localBinary() bool {
+	return p.Shell == "" && p.Container == nil && (p.RunnerName == "" || p.RunnerName == "exec")
+}
+
+//Path of the Command binary on this host, relative to the supervisor's
+//working directory like the start resolves it. Empty if it has none, see
+//localBinary.
+func (p *Process) binary() (string, error) {
+	if !p.localBinary() || p.Command == "" {
+		return "", nil
+	}
+	name := p.Command
+	if !filepath.IsAbs(name) {
+		wd, err := os.Getwd()
+		if err != nil {
+			return "", err
+		}
+		name = filepath.Join(wd, name)
+	}
+	return exec.LookPath(name)
+}
+
+//Hex SHA-256 of the file.
+func fileSHA256(name string) (string, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+//Check ExpectedSHA256 and the Verify hook are usable, only a local binary
+//being checked, see localBinary.
+func (p *Process) checkVerify() error {
+	if p.ExpectedSHA256 == "" && len(p.hooks().Verify) == 0 {
+		return nil
+	}
+	if !p.localBinary() {
+		return errors.New("Only a local command binary can be verified.")
+	}
+	if p.ExpectedSHA256 == "" {
+		return nil
+	}
+	if sum, err := hex.DecodeString(p.ExpectedSHA256); err != nil || len(sum) != sha256.Size {
+		return fmt.Errorf("Bad expected_sha256 %q.", p.ExpectedSHA256)
+	}
+	return nil
+}
+
+//Check the binary against ExpectedSHA256 and the Verify hook before it is
+//started, emitting EventVerify when it fails.
+func (p *Process) verifyBinary(ctx context.Context) error {
+	verify := p.hooks().Verify
+	if p.ExpectedSHA256 == "" && len(verify) == 0 {
+		return nil
+	}
+	err := func() error {
+		bin, err := p.binary()
+		if err != nil || bin == "" {
+			return err
+		}
+		if p.ExpectedSHA256 != "" {
+			sum, err := fileSHA256(bin)
+			if err != nil {
+				return err
+			}
+			if !strings.EqualFold(sum, p.ExpectedSHA256) {
+				return fmt.Errorf("%s has sha256 %s, expected %s.", bin, sum, p.ExpectedSHA256)
+			}
+		}
+		if len(verify) > 0 {
+			return p.runHook(ctx, "verify", append(append([]string(nil), verify...), bin))
+		}
+		return nil
+	}()
+	if err != nil {
+		p.emit(EventVerify, err.Error())
+	}
+	return err
+}
@@ -0,0 +1,90 @@
+// goforever - processes management
+// Copyright (c) 2013 Garrett Woodworth (https://github.com/gwoo).
+
+package process
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestExpectedSHA256(t *testing.T) {
+	bin := filepath.Join(t.TempDir(), "web")
+	ioutil.WriteFile(bin, []byte("#!/bin/sh\n"), 0755)
+	sum, _ := fileSHA256(bin)
+	r := NewFakeRunner()
+	m := NewManager()
+	m.Add("web", &Process{Command: bin, Runner: r, Ping: "1h", ExpectedSHA256: sum})
+	var reasons []string
+	m.OnEvent(func(e Event) {
+		if e.Type == EventVerify {
+			reasons = append(reasons, e.Reason)
+		}
+	})
+	p := m.Get("web")
+	if _, err := RunProcess("web", p); err != nil {
+		t.Fatalf("Expected no error. Result %#v\n", err)
+	}
+	p.Stop()
+	ioutil.WriteFile(bin, []byte("#!/bin/sh\nevil\n"), 0755)
+	if _, err := RunProcess("web", p); err == nil || !strings.Contains(err.Error(), "expected "+sum) {
+		t.Errorf("Expected a checksum error. Result %#v\n", err)
+	}
+	if n := len(r.Processes()); n != 1 {
+		t.Errorf("Expected 1 start. Result %d\n", n)
+	}
+	if len(reasons) != 1 || !strings.Contains(reasons[0], bin) {
+		t.Errorf("Expected a verify event. Result %#v\n", reasons)
+	}
+}
+
+func TestVerifyHook(t *testing.T) {
+	bin := filepath.Join(t.TempDir(), "web")
+	ioutil.WriteFile(bin, []byte("signed"), 0755)
+	r := NewFakeRunner()
+	p := &Process{Name: "web", Command: bin, Runner: r, Ping: "1h", Hooks: &Hooks{Verify: []string{"/bin/sh", "-c", `grep -q signed "$1"`, "verify"}}}
+	if _, err := RunProcess("web", p); err != nil {
+		t.Fatalf("Expected no error. Result %#v\n", err)
+	}
+	p.Stop()
+	ioutil.WriteFile(bin, []byte("tampered"), 0755)
+	if _, err := RunProcess("web", p); err == nil {
+		t.Errorf("Expected the verify hook to refuse the start.\n")
+	}
+}
+
+func TestCheckVerify(t *testing.T) {
+	sum := strings.Repeat("ab", 32)
+	for _, p := range []*Process{
+		{Command: "web", ExpectedSHA256: "abc"},
+		{Command: "web", ExpectedSHA256: strings.Repeat("zz", 32)},
+		{Shell: "web --port 80", ExpectedSHA256: sum},
+		{Shell: "web --port 80", Hooks: &Hooks{Verify: []string{"check"}}},
+		{Command: "web", ExpectedSHA256: sum, Container: &Container{Image: "web"}},
+		{Command: "web", ExpectedSHA256: sum, RunnerName: "ssh", SSH: &SSH{Host: "web1"}},
+	} {
+		if err := p.validate(); err == nil {
+			t.Errorf("Expected an error for %#v.\n", p)
+		}
+	}
+	p := &Process{Command: "web", ExpectedSHA256: strings.ToUpper(sum)}
+	if err := p.validate(); err != nil {
+		t.Errorf("Expected no error. Result %#v\n", err)
+	}
+}
+
+func TestBinaryRelative(t *testing.T) {
+	dir := t.TempDir()
+	os.Mkdir(filepath.Join(dir, "bin"), 0755)
+	ioutil.WriteFile(filepath.Join(dir, "bin", "web"), []byte("#!/bin/sh\n"), 0755)
+	wd, _ := os.Getwd()
+	os.Chdir(dir)
+	defer os.Chdir(wd)
+	p := &Process{Command: "bin/web", Path: "/nonexistent"}
+	if bin, err := p.binary(); err != nil || bin != filepath.Join(dir, "bin", "web") {
+		t.Errorf("Expected the binary in the working directory. Result %#v %v\n", bin, err)
+	}
+}
@@ -0,0 +1,150 @@
+// goforever - processes management
+// Copyright (c) 2013 Garrett Woodworth (https://github.com/gwoo).
+
+package process
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"debug/buildinfo"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+//Binary a process runs, or the one on disk, see Manager.Drift.
+type BinaryInfo struct {
+	Path    string    `json:"path"`
+	SHA256  string    `json:"sha256"`
+	ModTime time.Time `json:"mod_time"`
+	Size    int64     `json:"size"`
+	//First line printed by the binary run with VersionArgs, or else the
+	//module version (or VCS revision) of a Go binary.
+	Version string `json:"version,omitempty"`
+}
+
+//Binary of a running process against the one now on disk.
+type Drift struct {
+	Process   string      `json:"process"`
+	Namespace string      `json:"namespace,omitempty"`
+	Running   BinaryInfo  `json:"running"`
+	Disk      *BinaryInfo `json:"disk,omitempty"`
+	//The binary on disk differs from the running one.
+	Outdated bool   `json:"outdated"`
+	Error    string `json:"error,omitempty"`
+}
+
+//Longest a binary may take to print its version.
+var versionTimeout = 2 * time.Second
+
+//Version of a binary, kept for as long as its path holds the same file.
+type binVersion struct {
+	path    string
+	sum     string
+	mod     time.Time
+	size    int64
+	version string
+}
+
+//Describe the binary at path. The version is only asked again once the
+//binary changed.
+func (p *Process) readBinary(path string) (BinaryInfo, error) {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return BinaryInfo{}, err
+	}
+	sum, err := fileSHA256(path)
+	if err != nil {
+		return BinaryInfo{}, err
+	}
+	info := BinaryInfo{Path: path, SHA256: sum, ModTime: fi.ModTime(), Size: fi.Size()}
+	key := binVersion{path: path, sum: sum, mod: info.ModTime, size: info.Size}
+	p.mu.Lock()
+	v := p.vers
+	p.mu.Unlock()
+	if v != nil && v.path == key.path && v.sum == key.sum && v.mod.Equal(key.mod) && v.size == key.size {
+		info.Version = v.version
+		return info, nil
+	}
+	info.Version = p.binaryVersion(path)
+	key.version = info.Version
+	p.mu.Lock()
+	p.vers = &key
+	p.mu.Unlock()
+	return info, nil
+}
+
+//Version of the binary at path, "" if it tells none.
+func (p *Process) binaryVersion(path string) string {
+	if len(p.VersionArgs) > 0 {
+		ctx, cancel := context.WithTimeout(context.Background(), versionTimeout)
+		defer cancel()
+		out, err := exec.CommandContext(ctx, path, p.VersionArgs...).Output()
+		if err != nil {
+			p.log(LevelDebug, "version failed", Fields{"error": err})
+			return ""
+		}
+		line, _ := bufio.NewReader(bytes.NewReader(out)).ReadString('\n')
+		return strings.TrimSpace(line)
+	}
+	info, err := buildinfo.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	if v := info.Main.Version; v != "" && v != "(devel)" {
+		return v
+	}
+	for _, s := range info.Settings {
+		if s.Key == "vcs.revision" {
+			return s.Value
+		}
+	}
+	return ""
+}
+
+//Binary the running process was started from, nil if unknown, e.g. for
+//Shell and adopted processes.
+func (p *Process) Binary() *BinaryInfo {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.binaryLocked()
+}
+
+//See Binary. Called with p.mu held.
+func (p *Process) binaryLocked() *BinaryInfo {
+	if p.bin == nil || p.Pid == 0 {
+		return nil
+	}
+	info := p.bin.info
+	return &info
+}
+
+//Compare the binary of every running process with the one now at its
+//path, sorted by namespace and name.
+func (m *Manager) Drift() []Drift {
+	drifts := []Drift{}
+	for _, name := range m.Keys() {
+		p := m.Get(name)
+		if p == nil {
+			continue
+		}
+		running := p.Binary()
+		if running == nil {
+			continue
+		}
+		d := Drift{Process: name, Namespace: m.ns, Running: *running}
+		disk, err := p.readBinary(running.Path)
+		if err != nil {
+			d.Error, d.Outdated = err.Error(), true
+		} else {
+			d.Disk, d.Outdated = &disk, disk.SHA256 != running.SHA256
+		}
+		drifts = append(drifts, d)
+	}
+	for _, n := range m.spaceList() {
+		drifts = append(drifts, n.Drift()...)
+	}
+	return drifts
+}
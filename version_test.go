@@ -0,0 +1,68 @@
+// goforever - processes management
+// Copyright (c) 2013 Garrett Woodworth (https://github.com/gwoo).
+
+package process
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDrift(t *testing.T) {
+	bin := filepath.Join(t.TempDir(), "web")
+	ioutil.WriteFile(bin, []byte("#!/bin/sh\necho web 1.2.0\necho built today\n"), 0755)
+	m := NewManager()
+	m.Add("web", &Process{Command: bin, Runner: NewFakeRunner(), Ping: "1h", VersionArgs: []string{"--version"}})
+	m.Add("db", &Process{Command: "db", Runner: NewFakeRunner(), Ping: "1h"})
+	RunProcess("web", m.Get("web"))
+	RunProcess("db", m.Get("db"))
+	defer m.Get("web").Stop()
+	defer m.Get("db").Stop()
+	running := m.Get("web").Binary()
+	if running == nil || running.Version != "web 1.2.0" || running.Path != bin {
+		t.Fatalf("Expected web 1.2.0. Result %#v\n", running)
+	}
+	if m.Get("db").Binary() != nil {
+		t.Errorf("Expected no binary for an unknown command.\n")
+	}
+	drifts := m.Drift()
+	if len(drifts) != 1 || drifts[0].Outdated || drifts[0].Disk.SHA256 != running.SHA256 {
+		t.Errorf("Expected web up to date. Result %#v\n", drifts)
+	}
+	ioutil.WriteFile(bin, []byte("#!/bin/sh\necho web 1.3.0\n"), 0755)
+	w := httptest.NewRecorder()
+	m.API(nil).ServeHTTP(w, httptest.NewRequest("GET", "/drift", nil))
+	drifts = nil
+	json.Unmarshal(w.Body.Bytes(), &drifts)
+	if len(drifts) != 1 || !drifts[0].Outdated || drifts[0].Disk.Version != "web 1.3.0" || drifts[0].Running.Version != "web 1.2.0" {
+		t.Errorf("Expected web outdated. Result %s\n", w.Body.String())
+	}
+	os.Remove(bin)
+	if drifts = m.Drift(); len(drifts) != 1 || !drifts[0].Outdated || drifts[0].Error == "" {
+		t.Errorf("Expected a missing binary outdated. Result %#v\n", drifts)
+	}
+}
+
+func TestDriftVersionCached(t *testing.T) {
+	dir := t.TempDir()
+	bin := filepath.Join(dir, "web")
+	runs := filepath.Join(dir, "runs")
+	ioutil.WriteFile(bin, []byte("#!/bin/sh\necho run >> "+runs+"\necho web 1.2.0\n"), 0755)
+	m := NewManager()
+	m.Add("web", &Process{Command: bin, Runner: NewFakeRunner(), Ping: "1h", VersionArgs: []string{"--version"}})
+	RunProcess("web", m.Get("web"))
+	defer m.Get("web").Stop()
+	for i := 0; i < 3; i++ {
+		if drifts := m.Drift(); len(drifts) != 1 || drifts[0].Disk.Version != "web 1.2.0" {
+			t.Errorf("Expected web 1.2.0. Result %#v\n", drifts)
+		}
+	}
+	data, _ := ioutil.ReadFile(runs)
+	if ex := "run\n"; string(data) != ex {
+		t.Errorf("Expected %#v. Result %#v\n", ex, string(data))
+	}
+}
@@ -0,0 +1,239 @@
+// goforever - processes management
+// Copyright (c) 2013 Garrett Woodworth (https://github.com/gwoo).
+
+package process
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+)
+
+//Largest webhook payload read.
+const maxHookPayload = 1 << 20
+
+var ErrBadSignature = errors.New("Missing or invalid signature.")
+
+//Inbound webhook, e.g. from CI or a registry, mapping payloads to actions.
+//Senders sign the body with HMAC-SHA256 under Secret and send it hex
+//encoded as X-Hub-Signature-256: sha256=<hex>, as GitHub does.
+type Webhook struct {
+	Secret string `json:"secret"`
+	//Applied in order to every payload, each one whose Match it passes.
+	Rules []HookRule `json:"rules"`
+}
+
+//Action of a webhook on a process or instance group.
+type HookRule struct {
+	//Payload fields by dotted path and their required value, e.g.
+	//{"ref": "refs/heads/main"}. Empty matches every payload.
+	Match map[string]string `json:"match,omitempty"`
+	//restart (a process) or rolling_restart (the instances of a group).
+	Action  string `json:"action"`
+	Process string `json:"process"`
+	//KEY=VALUE entries set before restarting, where {path} is replaced
+	//with the payload field, e.g. "TAG={push_data.tag}".
+	Env []string `json:"env,omitempty"`
+	//Instances restarted at a time and the wait between batches of a
+	//rolling_restart, see Manager.RollingRestart.
+	MaxUnavailable int    `json:"max_unavailable,omitempty"`
+	Pause          string `json:"pause,omitempty"`
+}
+
+var hookField = regexp.MustCompile(`\{([\w.-]+)\}`)
+
+//Whether sig, the X-Hub-Signature-256 header, signs body under secret.
+func (h *Webhook) verify(body []byte, sig string) bool {
+	if h.Secret == "" || !strings.HasPrefix(sig, "sha256=") {
+		return false
+	}
+	got, err := hex.DecodeString(strings.TrimPrefix(sig, "sha256="))
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(sha256.New, []byte(h.Secret))
+	mac.Write(body)
+	return hmac.Equal(got, mac.Sum(nil))
+}
+
+//Field of a decoded JSON payload by dotted path, false if there is none or
+//it is an object or list.
+func hookValue(payload interface{}, path string) (string, bool) {
+	for _, key := range strings.Split(path, ".") {
+		fields, ok := payload.(map[string]interface{})
+		if !ok {
+			return "", false
+		}
+		if payload, ok = fields[key]; !ok {
+			return "", false
+		}
+	}
+	switch v := payload.(type) {
+	case string:
+		return v, true
+	case json.Number, bool:
+		return fmt.Sprint(v), true
+	}
+	return "", false
+}
+
+//Whether the payload has every field of the rule's Match.
+func (r *HookRule) matches(payload interface{}) bool {
+	for path, want := range r.Match {
+		if got, ok := hookValue(payload, path); !ok || got != want {
+			return false
+		}
+	}
+	return true
+}
+
+//The rule's Env with the payload fields filled in, an error naming the
+//fields the payload lacks.
+func (r *HookRule) env(payload interface{}) ([]string, error) {
+	env := make([]string, len(r.Env))
+	var missing []string
+	for i, kv := range r.Env {
+		env[i] = hookField.ReplaceAllStringFunc(kv, func(field string) string {
+			path := field[1 : len(field)-1]
+			v, ok := hookValue(payload, path)
+			if !ok {
+				missing = append(missing, path)
+			}
+			return v
+		})
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("Payload has no %s.", strings.Join(missing, ", "))
+	}
+	return env, nil
+}
+
+//Check that the rule names a known action and target.
+func (m *Manager) checkRule(r *HookRule) error {
+	switch r.Action {
+	case "restart":
+		_, err := m.Lookup(r.Process)
+		return err
+	case "rolling_restart":
+		if len(m.Instances(r.Process)) == 0 {
+			return fmt.Errorf("No instances of %s.", r.Process)
+		}
+		return nil
+	}
+	return fmt.Errorf("Unknown action %q.", r.Action)
+}
+
+//Restarts the rule makes, counted against the ActionLimit.
+func (r *HookRule) ops(m *Manager) []Op {
+	if r.Action == "restart" {
+		return []Op{{Action: "restart", Process: r.Process}}
+	}
+	var ops []Op
+	for _, name := range m.Keys() {
+		if p := m.Get(name); p != nil && p.group == r.Process {
+			ops = append(ops, Op{Action: "restart", Process: name})
+		}
+	}
+	return ops
+}
+
+//Update the env of the rule's processes and restart them.
+func (m *Manager) runRule(r *HookRule, env []string) error {
+	procs := m.Instances(r.Process)
+	if r.Action == "restart" {
+		procs = []*Process{m.Get(r.Process)}
+	}
+	if len(env) > 0 {
+		for _, p := range procs {
+			p.cycle.Lock()
+			p.mu.Lock()
+			p.Env = mergeEnv(p.Env, env)
+			p.mu.Unlock()
+			p.cycle.Unlock()
+		}
+	}
+	if r.Action == "rolling_restart" {
+		return m.RollingRestart(r.Process, r.MaxUnavailable, durationOr(r.Pause, 0))
+	}
+	op, err := m.Restart(r.Process)
+	if err != nil {
+		return err
+	}
+	return op.Wait()
+}
+
+//Verify and apply a payload for the webhook name: 404 for an unknown hook,
+//401 for a bad signature, 400 for a bad payload or rule, and otherwise 202
+//with the processes of the matching rules, which run in the background.
+func (m *Manager) apiHook(w http.ResponseWriter, r *http.Request, name string) {
+	h := m.Webhooks[name]
+	if h == nil {
+		apiError(w, http.StatusNotFound, "Unknown webhook.")
+		return
+	}
+	body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxHookPayload))
+	if err != nil {
+		apiError(w, http.StatusBadRequest, "Bad payload: "+err.Error())
+		return
+	}
+	if !h.verify(body, r.Header.Get("X-Hub-Signature-256")) {
+		apiError(w, http.StatusUnauthorized, ErrBadSignature.Error())
+		return
+	}
+	var payload interface{}
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	if err := dec.Decode(&payload); err != nil {
+		apiError(w, http.StatusBadRequest, "Bad payload: "+err.Error())
+		return
+	}
+	type run struct {
+		rule *HookRule
+		env  []string
+	}
+	var runs []run
+	triggered := []string{}
+	for i := range h.Rules {
+		rule := &h.Rules[i]
+		if !rule.matches(payload) {
+			continue
+		}
+		env, err := rule.env(payload)
+		if err == nil {
+			err = m.checkRule(rule)
+		}
+		if err != nil {
+			apiError(w, http.StatusBadRequest, fmt.Sprintf("Rule %d: %s", i, err))
+			return
+		}
+		runs = append(runs, run{rule, env})
+		triggered = append(triggered, rule.Process)
+	}
+	go func() {
+		for _, run := range runs {
+			if _, err := m.allowOps(run.rule.ops(m)); err != nil {
+				m.log(LevelWarn, "webhook action rate limited", Fields{"webhook": name, "action": run.rule.Action, "process": run.rule.Process})
+				continue
+			}
+			start := time.Now()
+			err := m.runRule(run.rule, run.env)
+			fields := Fields{"webhook": name, "action": run.rule.Action, "process": run.rule.Process, "duration": time.Since(start)}
+			if err != nil {
+				fields["error"] = err
+				m.log(LevelError, "webhook action failed", fields)
+				return
+			}
+			m.log(LevelInfo, "webhook action done", fields)
+		}
+	}()
+	apiJSON(w, http.StatusAccepted, map[string][]string{"triggered": triggered})
+}
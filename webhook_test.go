@@ -0,0 +1,89 @@
+// goforever - processes management
+// Copyright (c) 2013 Garrett Woodworth (https://github.com/gwoo).
+
+package process
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestWebhook(t *testing.T) {
+	r := NewFakeRunner()
+	m := NewManager()
+	m.Add("web", &Process{Command: "web", Runner: r, Ping: "1h", Env: []string{"TAG=v1", "PORT=80"}})
+	RunProcess("web", m.Get("web"))
+	defer m.Get("web").Stop()
+	m.Webhooks = map[string]*Webhook{"ci": {Secret: "s3cret", Rules: []HookRule{
+		{Match: map[string]string{"ref": "refs/heads/main"}, Action: "restart", Process: "web", Env: []string{"TAG={release.tag}"}},
+		{Match: map[string]string{"ref": "refs/heads/dev"}, Action: "restart", Process: "web"},
+	}}}
+	post := func(hook, body, secret string) *httptest.ResponseRecorder {
+		mac := hmac.New(sha256.New, []byte(secret))
+		mac.Write([]byte(body))
+		req := httptest.NewRequest("POST", "/hooks/"+hook, strings.NewReader(body))
+		req.Header.Set("X-Hub-Signature-256", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+		w := httptest.NewRecorder()
+		m.API(&Auth{Tokens: map[string]string{"t": RoleOperator}}).ServeHTTP(w, req)
+		return w
+	}
+	body := `{"ref": "refs/heads/main", "release": {"tag": "v2", "build": 7}}`
+	if w := post("ci", body, "wrong"); w.Code != 401 {
+		t.Errorf("Expected 401. Result %d %s\n", w.Code, w.Body.String())
+	}
+	if w := post("cd", body, "s3cret"); w.Code != 404 {
+		t.Errorf("Expected 404. Result %d\n", w.Code)
+	}
+	w := post("ci", body, "s3cret")
+	if w.Code != 202 || !strings.Contains(w.Body.String(), `"triggered":["web"]`) {
+		t.Fatalf("Expected web triggered. Result %d %s\n", w.Code, w.Body.String())
+	}
+	waitFor(t, func() bool { return len(r.Processes()) == 2 && len(r.Running()) == 1 })
+	env := strings.Join(r.Running()[0].Cmd.Env, " ")
+	if !strings.Contains(env, "TAG=v2") || strings.Contains(env, "TAG=v1") || !strings.Contains(env, "PORT=80") {
+		t.Errorf("Expected TAG=v2 with PORT kept. Result %s\n", env)
+	}
+	if w := post("ci", `{"ref": "refs/tags/v3"}`, "s3cret"); w.Code != 202 || !strings.Contains(w.Body.String(), `"triggered":[]`) {
+		t.Errorf("Expected nothing triggered. Result %d %s\n", w.Code, w.Body.String())
+	}
+	if w := post("ci", `{"ref": "refs/heads/main"}`, "s3cret"); w.Code != 400 {
+		t.Errorf("Expected 400 without release.tag. Result %d %s\n", w.Code, w.Body.String())
+	}
+}
+
+func TestHookValue(t *testing.T) {
+	payload := map[string]interface{}{"a": map[string]interface{}{"b": "c"}, "n": true}
+	for path, expected := range map[string]string{"a.b": "c", "n": "true", "a": "", "a.x": "", "n.x": ""} {
+		if v, _ := hookValue(payload, path); v != expected {
+			t.Errorf("Expected %#v for %s. Result %#v\n", expected, path, v)
+		}
+	}
+}
+
+func TestWebhookActionLimit(t *testing.T) {
+	r := NewFakeRunner()
+	m := NewManager()
+	m.Add("web", &Process{Command: "web", Runner: r, Ping: "1h", ActionLimit: 1})
+	RunProcess("web", m.Get("web"))
+	defer m.Get("web").Stop()
+	m.Webhooks = map[string]*Webhook{"ci": {Secret: "s", Rules: []HookRule{{Action: "restart", Process: "web"}}}}
+	m.Get("web").allowAction("restart")
+	body := `{}`
+	mac := hmac.New(sha256.New, []byte("s"))
+	mac.Write([]byte(body))
+	req := httptest.NewRequest("POST", "/hooks/ci", strings.NewReader(body))
+	req.Header.Set("X-Hub-Signature-256", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	w := httptest.NewRecorder()
+	m.API(nil).ServeHTTP(w, req)
+	if w.Code != 202 {
+		t.Fatalf("Expected 202. Result %d %s\n", w.Code, w.Body.String())
+	}
+	waitFor(t, func() bool { return m.Get("web").Snapshot().RateLimited["restart"] == 1 })
+	if n := len(r.Processes()); n != 1 {
+		t.Errorf("Expected no restart. Result %d starts\n", n)
+	}
+}